@@ -0,0 +1,172 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "columns.go" - Comparing lines of TSV or fixed-width data on selected
+// columns only.  The other columns are still displayed, but they don't affect
+// the alignment.
+
+// ------------------------------------------- type KeyFunc
+
+// A KeyFunc extracts the part of a line which should be compared.
+type KeyFunc func(line *diff.TextLine) string
+
+// -------------------------------------------
+// ------------------------------------------- type KeyedLine
+// -------------------------------------------
+
+// A KeyedLine compares as its key, but stringifies as the full line.
+type KeyedLine struct {
+	Line *diff.TextLine
+	Key *diff.TextLine
+}
+
+// Assert that Comparable is implemented by KeyedLine.
+var _ diff.Comparable = KeyedLine{}
+
+// ------------------------------------------- KeyedLine Compare
+
+func (keyedLine KeyedLine) Compare(other diff.Comparable) float32 {
+	return keyedLine.Key.Compare(other.(KeyedLine).Key)
+}
+
+// ------------------------------------------- KeyedLine Stringify
+
+func (keyedLine KeyedLine) Stringify(maxWidth int) string {
+	return keyedLine.Line.Stringify(maxWidth)
+}
+
+// -------------------------------------------
+// ------------------------------------------- type KeyedLines
+// -------------------------------------------
+
+type KeyedLines []KeyedLine
+
+// Assert that ComparableSequence is implemented by KeyedLines.
+var _ diff.ComparableSequence = KeyedLines(nil)
+
+// ------------------------------------------- KeyLines
+
+// Pair each line with its key.  Item i of the result is line i, so alignments
+// computed on the keyed lines apply to the lines themselves.
+func KeyLines(lines diff.ComparableLines, keyFn KeyFunc) KeyedLines {
+	keyedLines := make(KeyedLines, len(lines))
+	for index, line := range lines {
+		keyedLines[index] = KeyedLine{line, diff.NewTextLine(keyFn(line))}
+	}
+	return keyedLines
+}
+
+// -------------------------------------------
+
+func (keyedLines KeyedLines) Length() int {
+	return len(keyedLines)
+}
+
+// -------------------------------------------
+
+func (keyedLines KeyedLines) GetItemAt(index int) diff.Comparable {
+	return keyedLines[index]
+}
+
+// -------------------------------------------
+
+func (keyedLines KeyedLines) GetDescription() string {
+	return fmt.Sprintf("%d keyed lines", len(keyedLines))
+}
+
+// -------------------------------------------
+// ------------------------------------------- TsvKeyColumns
+// -------------------------------------------
+
+// Key TSV lines on the given columns, numbered from 1.  The raw text is split
+// on tabs, since expanding them would lose the field boundaries.  Columns
+// missing from a short line are empty.
+func TsvKeyColumns(columns []int) KeyFunc {
+	return func (line *diff.TextLine) string {
+		text := line.RawText
+		if text == "" {
+			text = line.Text
+		}
+		fields := strings.Split(text, "\t")
+		keyFields := make([]string, len(columns))
+		for index, column := range columns {
+			if column <= len(fields) {
+				keyFields[index] = fields[column - 1]
+			}
+		}
+		return strings.Join(keyFields, "\t")
+	}
+}
+
+// ------------------------------------------- ParseColumnList
+
+// Parse a comma separated list of column numbers, e.g. "1,3".
+func ParseColumnList(spec string) ([]int, error) {
+	var columns []int
+	for _, word := range strings.Split(spec, ",") {
+		column, err := strconv.Atoi(strings.TrimSpace(word))
+		if err != nil || column < 1 {
+			return nil, fmt.Errorf("%q is not a column number", word)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// -------------------------------------------
+// ------------------------------------------- FixedColumns
+// -------------------------------------------
+
+// A ColumnRange is a range of character columns, numbered from 1, including
+// both ends.
+type ColumnRange struct {
+	First, Last int
+}
+
+// Key fixed-width lines on the given column ranges.  Columns are counted in
+// runes, after tabs have been expanded.  Ranges running off the end of a
+// short line are cut short.
+func FixedColumns(ranges []ColumnRange) KeyFunc {
+	return func (line *diff.TextLine) string {
+		runes := []rune(line.Text)
+		keyParts := make([]string, len(ranges))
+		for index, columnRange := range ranges {
+			first, last := columnRange.First - 1, columnRange.Last
+			if last > len(runes) {
+				last = len(runes)
+			}
+			if first < last {
+				keyParts[index] = string(runes[first:last])
+			}
+		}
+		return strings.Join(keyParts, "\x00")
+	}
+}
+
+// ------------------------------------------- ParseColumnRanges
+
+// Parse a comma separated list of column ranges, e.g. "1-10,25-30".  A single
+// number is a range of one column.
+func ParseColumnRanges(spec string) ([]ColumnRange, error) {
+	var ranges []ColumnRange
+	for _, word := range strings.Split(spec, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(word), "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		last := first
+		if err == nil && len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || first < 1 || last < first {
+			return nil, fmt.Errorf("%q is not a column range", word)
+		}
+		ranges = append(ranges, ColumnRange{first, last})
+	}
+	return ranges, nil
+}
@@ -0,0 +1,98 @@
+package adapter
+
+import (
+	"fmt"
+	"testing"
+
+	"diffy/diff"
+)
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+// Make lines as diff.ReadLines would, keeping the raw text with its tabs.
+func makeRawLines(rows ...string) diff.ComparableLines {
+	var lines diff.ComparableLines
+	for _, row := range rows {
+		line := diff.NewTextLine(row)
+		line.RawText = row
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestTsvKeyColumns
+// -------------------------------------------
+
+func TestTsvKeyColumns(t *testing.T) {
+
+	keyFn := TsvKeyColumns([]int{1, 3})
+	left := makeRawLines("1\talice\tadmin\t2019-01-01", "2\tbob\tstaff\t2019-02-02", "3\tcarol\tguest\t2019-03-03")
+
+	// Only the non-key columns have changed: everything matches.
+	right := makeRawLines("1\tAlice Smith\tadmin\t2020-12-31", "2\tRobert\tstaff\t2021-01-01", "3\tcarol\tguest")
+	_, alignment := diff.Diff_v2(KeyLines(left, keyFn), KeyLines(right, keyFn))
+	if counts := countLinkTypes(alignment); counts[diff.Matching] != 3 {
+		t.Errorf("Expected every row to match on its key columns, got %v", alignment.Links)
+	}
+
+	// A key column has changed: that row differs.
+	right = makeRawLines("1\talice\tadmin\t2019-01-01", "2\tbob\tmanager\t2019-02-02", "3\tcarol\tguest\t2019-03-03")
+	_, alignment = diff.Diff_v2(KeyLines(left, keyFn), KeyLines(right, keyFn))
+	if counts := countLinkTypes(alignment); counts[diff.Matching] != 2 {
+		t.Errorf("Expected the row with a changed key to differ, got %v", alignment.Links)
+	}
+	for _, link := range alignment.Links {
+		if (link.LeftIndex == 1) != (link.LinkType != diff.Matching) {
+			t.Errorf("Unexpected link %v", link)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestFixedColumns
+// -------------------------------------------
+
+func TestFixedColumns(t *testing.T) {
+
+	ranges, err := ParseColumnRanges("1-4, 11-15")
+	if err != nil || fmt.Sprint(ranges) != "[{1 4} {11 15}]" {
+		t.Fatalf("Unexpected ranges %v, %v", ranges, err)
+	}
+	keyFn := FixedColumns(ranges)
+
+	left := makeRawLines("0001 12:00 ALPHA  ok", "0002 12:01 BRAVO  ok", "0003 12:02 DELTA")
+	right := makeRawLines("0001 13:30 ALPHA  retried", "0002 13:31 BRAVO  ok", "0003 13:32 ECHO")
+	_, alignment := diff.Diff_v2(KeyLines(left, keyFn), KeyLines(right, keyFn))
+	for _, link := range alignment.Links {
+		if (link.LeftIndex == 2 || link.RightIndex == 2) == (link.LinkType == diff.Matching) {
+			t.Errorf("Expected only the third row to differ, got %v", alignment.Links)
+			break
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestParseColumns
+// -------------------------------------------
+
+func TestParseColumns(t *testing.T) {
+	if columns, err := ParseColumnList("1,3"); err != nil || fmt.Sprint(columns) != "[1 3]" {
+		t.Errorf("Unexpected columns %v, %v", columns, err)
+	}
+	if ranges, err := ParseColumnRanges("5"); err != nil || ranges[0] != (ColumnRange{5, 5}) {
+		t.Errorf("Unexpected ranges %v, %v", ranges, err)
+	}
+	for _, spec := range []string{"", "0", "a,b", "1,,2"} {
+		if _, err := ParseColumnList(spec); err == nil {
+			t.Errorf("Expected an error for column list %q", spec)
+		}
+	}
+	for _, spec := range []string{"", "3-1", "0-2", "1-x"} {
+		if _, err := ParseColumnRanges(spec); err == nil {
+			t.Errorf("Expected an error for column ranges %q", spec)
+		}
+	}
+}
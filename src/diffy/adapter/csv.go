@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "csv.go" - An adapter which compares CSV files record by record, with the
+// records compared field by field.
+
+func init() {
+	Register(".csv", CsvAdapter)
+}
+
+// -------------------------------------------
+// ------------------------------------------- type CsvRecord
+// -------------------------------------------
+
+type CsvRecord []string
+
+// Assert that Comparable is implemented by CsvRecord.
+var _ diff.Comparable = CsvRecord(nil)
+
+// ------------------------------------------- CsvRecord Compare
+
+// Two records are compared field by field.  The cost is the fraction of
+// fields which differ, where a field missing from the shorter record counts
+// as different.
+func (record CsvRecord) Compare(other diff.Comparable) float32 {
+	otherRecord := other.(CsvRecord)
+	fieldCount := len(record)
+	if len(otherRecord) > fieldCount {
+		fieldCount = len(otherRecord)
+	}
+	if fieldCount == 0 {
+		return 0.0
+	}
+
+	differentCount := 0
+	for i := 0; i < fieldCount; i++ {
+		if i >= len(record) || i >= len(otherRecord) || record[i] != otherRecord[i] {
+			differentCount++
+		}
+	}
+	return float32(differentCount) / float32(fieldCount)
+}
+
+// ------------------------------------------- CsvRecord Stringify
+
+func (record CsvRecord) Stringify(maxWidth int) string {
+	return diff.NewTextLine(record.String()).Stringify(maxWidth)
+}
+
+// ------------------------------------------- CsvRecord String
+
+// Format the record as a single CSV line, quoting fields as needed.
+func (record CsvRecord) String() string {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(record)
+	writer.Flush()
+	return strings.TrimRight(buffer.String(), "\r\n")
+}
+
+// -------------------------------------------
+// ------------------------------------------- type CsvRecords
+// -------------------------------------------
+
+type CsvRecords []CsvRecord
+
+// Assert that ComparableSequence is implemented by CsvRecords.
+var _ diff.ComparableSequence = CsvRecords(nil)
+
+// -------------------------------------------
+
+func (records CsvRecords) Length() int {
+	return len(records)
+}
+
+// -------------------------------------------
+
+func (records CsvRecords) GetItemAt(index int) diff.Comparable {
+	return records[index]
+}
+
+// -------------------------------------------
+
+func (records CsvRecords) GetDescription() string {
+	return fmt.Sprintf("%d records", len(records))
+}
+
+// -------------------------------------------
+// ------------------------------------------- CsvAdapter
+// -------------------------------------------
+
+// Parse CSV content into records.  Records may have differing numbers of
+// fields.
+func CsvAdapter(content []byte) (diff.ComparableSequence, Renderer, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	rawRecords, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := make(CsvRecords, len(rawRecords))
+	for index, rawRecord := range rawRecords {
+		records[index] = CsvRecord(rawRecord)
+	}
+
+	renderer := func (item diff.Comparable) string {
+		return item.(CsvRecord).String()
+	}
+	return records, renderer, nil
+}
@@ -0,0 +1,144 @@
+package adapter
+
+import (
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+func parseTestCsv(t *testing.T, rows ...string) diff.ComparableSequence {
+	seq, _, err := CsvAdapter([]byte(strings.Join(rows, "\n") + "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error parsing CSV: %v", err)
+	}
+	return seq
+}
+
+func countLinkTypes(alignment *diff.Alignment) map[diff.LinkType]int {
+	counts := make(map[diff.LinkType]int)
+	for _, link := range alignment.Links {
+		counts[link.LinkType]++
+	}
+	return counts
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestCsvRecordCompare
+// -------------------------------------------
+
+func TestCsvRecordCompare(t *testing.T) {
+
+	testCases := []struct {
+		left, right CsvRecord
+		cost float32
+	}{
+		{CsvRecord{}, CsvRecord{}, 0.0},
+		{CsvRecord{"1", "alice", "admin"}, CsvRecord{"1", "alice", "admin"}, 0.0},
+		{CsvRecord{"1", "alice", "admin"}, CsvRecord{"1", "alice", "staff"}, 1.0 / 3.0},
+		{CsvRecord{"1", "alice", "admin"}, CsvRecord{"1", "alice"}, 1.0 / 3.0},
+		{CsvRecord{"1", "alice", "admin"}, CsvRecord{"2", "bob", "staff"}, 1.0},
+	}
+
+	for _, testCase := range testCases {
+		if cost := testCase.left.Compare(testCase.right); cost != testCase.cost {
+			t.Errorf("%v vs %v: got cost %f, expected %f", testCase.left, testCase.right, cost, testCase.cost)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestCsvAdapterAlignment
+// -------------------------------------------
+
+func TestCsvAdapterAlignment(t *testing.T) {
+
+	// Bob's role was updated to a much longer value, and carol was added.
+	leftRows := []string{
+		"id,name,role",
+		"1,alice,admin",
+		"2,bob,staff",
+		"4,dave,guest",
+	}
+	rightRows := []string{
+		"id,name,role",
+		"1,alice,admin",
+		"2,bob,administrator",
+		"3,carol,staff",
+		"4,dave,guest",
+	}
+
+	findBobLink := func (alignment *diff.Alignment) diff.Link {
+		for _, link := range alignment.Links {
+			if link.LeftIndex == 2 {
+				return link
+			}
+		}
+		t.Fatalf("no link for bob's record")
+		return diff.Link{}
+	}
+
+	// Compared as lines of text, bob's two records have too little in common
+	// and end up split into a deletion and an insertion.
+	leftLines, rightLines := diff.ComparableLines{}, diff.ComparableLines{}
+	for _, row := range leftRows {
+		leftLines = append(leftLines, diff.NewTextLine(row))
+	}
+	for _, row := range rightRows {
+		rightLines = append(rightLines, diff.NewTextLine(row))
+	}
+	_, alignment := diff.Diff_v2(leftLines, rightLines)
+	alignment = alignment.RealignUsingThreshold(leftLines, rightLines, 0.4)
+	if link := findBobLink(alignment); link.LinkType != diff.LeftOnly {
+		t.Errorf("line-based: expected bob's old record to be LeftOnly, got %v", link)
+	}
+
+	// Compared field by field, two of the three fields still agree, so bob's
+	// records pair up as one changed record.
+	left, right := parseTestCsv(t, leftRows...), parseTestCsv(t, rightRows...)
+	_, alignment = diff.Diff_v2(left, right)
+	alignment = alignment.RealignUsingThreshold(left, right, 0.4)
+	if link := findBobLink(alignment); link != (diff.Link{LinkType: diff.Different, LeftIndex: 2, RightIndex: 2}) {
+		t.Errorf("CSV: expected bob's records to be paired as different, got %v", link)
+	}
+
+	counts := countLinkTypes(alignment)
+	expected := map[diff.LinkType]int{diff.Matching: 3, diff.Different: 1, diff.LeftOnly: 0, diff.RightOnly: 1}
+	for linkType, count := range expected {
+		if counts[linkType] != count {
+			t.Errorf("CSV: link type %d: got %d links, expected %d (%v)", linkType, counts[linkType], count, alignment.Links)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestLookup
+// -------------------------------------------
+
+func TestLookup(t *testing.T) {
+	if _, found := Lookup("data/report.CSV"); !found {
+		t.Errorf("expected the CSV adapter to be found regardless of case")
+	}
+	if _, found := Lookup("main.go"); found {
+		t.Errorf("expected no adapter for .go files")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestRenderLines
+// -------------------------------------------
+
+func TestRenderLines(t *testing.T) {
+	seq, renderer, err := CsvAdapter([]byte("a,\"b,c\"\n1,2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := RenderLines(seq, renderer)
+	if len(lines) != 2 || lines[0].Text != `a,"b,c"` || lines[1].Text != "1,2" {
+		t.Errorf("unexpected rendered lines: %q, %q", lines[0].Text, lines[1].Text)
+	}
+}
@@ -0,0 +1,62 @@
+package adapter
+
+import (
+	"path/filepath"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "registry.go" - A plugin point for diffing domain specific files.  An
+// adapter turns the raw content of a file into the units that should be
+// compared (e.g. CSV records rather than lines of text), along with a
+// renderer which turns each unit back into a line of text for display.
+
+// -------------------------------------------
+// ------------------------------------------- types
+// -------------------------------------------
+
+// A Renderer converts one item of an adapted sequence into display text.
+type Renderer func(item diff.Comparable) string
+
+// An AdapterFunc converts the content of a file into a ComparableSequence
+// and a Renderer for its items.
+type AdapterFunc func(content []byte) (diff.ComparableSequence, Renderer, error)
+
+// -------------------------------------------
+// ------------------------------------------- the registry
+// -------------------------------------------
+
+// Adapters keyed by lower case file extension, including the leading dot.
+var adapters = map[string]AdapterFunc{}
+
+// ------------------------------------------- Register
+
+// Register an adapter for files with the given extension, e.g. ".csv".
+// Registering a second adapter for the same extension replaces the first.
+func Register(extension string, adapterFn AdapterFunc) {
+	adapters[strings.ToLower(extension)] = adapterFn
+}
+
+// ------------------------------------------- Lookup
+
+// Find the adapter for a file based on its extension.  The second return
+// value is false when there isn't one, in which case the caller should fall
+// back to the line-based default.
+func Lookup(path string) (AdapterFunc, bool) {
+	adapterFn, found := adapters[strings.ToLower(filepath.Ext(path))]
+	return adapterFn, found
+}
+
+// ------------------------------------------- RenderLines
+
+// Render every item of an adapted sequence as a TextLine, so it can be
+// displayed by the regular line-based output code.  Item i of the sequence
+// becomes line i, so alignments computed on the sequence still apply.
+func RenderLines(seq diff.ComparableSequence, renderer Renderer) diff.ComparableLines {
+	lines := make(diff.ComparableLines, seq.Length())
+	for index := range lines {
+		lines[index] = diff.NewTextLine(renderer(seq.GetItemAt(index)))
+	}
+	return lines
+}
@@ -0,0 +1,85 @@
+package diff
+
+// "adaptive-realign.go" - Choosing the RealignUsingThreshold threshold based
+// on how similar the files are overall.
+
+// The threshold used for realigning for display, unless it's chosen adaptively.
+const DEFAULT_REALIGN_THRESHOLD = 0.4
+
+// -------------------------------------------
+// ------------------------------------------- type AdaptiveThreshold
+// -------------------------------------------
+
+// An AdaptiveThreshold scales the realign threshold with the overall
+// similarity of two files.  In a near-identical pair of files, a changed line
+// which only vaguely resembles its partner is more likely to be a replacement
+// than an edit, so pairs should be split more eagerly.  In a mostly rewritten
+// pair, vague resemblance is about as good as it gets, so pairs should be
+// kept together.
+//
+// The threshold is interpolated linearly: "Strict" for identical files, and
+// "Lax" for files with nothing in common.
+//
+//     threshold = Strict + (Lax - Strict) * (1 - similarity)
+//
+// TextLine comparisons never cost between 0.4 and 1.0, because
+// TextLine.Similarity rounds anything below 0.6 down to 0.0, so any threshold
+// from 0.4 up to (but not including) 1.0 behaves the same.  With the default
+// settings, that's every pair of files less than about 70% similar.
+
+type AdaptiveThreshold struct {
+	Strict float32		// the threshold for identical files
+	Lax float32			// the threshold for completely different files
+}
+
+var DefaultAdaptiveThreshold = AdaptiveThreshold{Strict: 0.2, Lax: 1.0}
+
+// ------------------------------------------- AdaptiveThreshold Choose method
+
+// Choose the realign threshold for files with the given overall similarity.
+func (adaptive AdaptiveThreshold) Choose(similarity float32) float32 {
+	return adaptive.Strict + (adaptive.Lax - adaptive.Strict) * (1.0 - similarity)
+}
+
+// ------------------------------------------- AdaptiveThreshold ChooseFor method
+
+// Choose the realign threshold for "left" and "right", given their alignment.
+func (adaptive AdaptiveThreshold) ChooseFor(alignment *Alignment, left, right ComparableSequence) float32 {
+	return adaptive.ChooseForWith(alignment, left, right, DirectCompare)
+}
+
+// ------------------------------------------- AdaptiveThreshold ChooseForWith method
+
+// ChooseFor, comparing items with "compare", e.g. a SimilarityCache.
+func (adaptive AdaptiveThreshold) ChooseForWith(alignment *Alignment, left, right ComparableSequence, compare CompareFunc) float32 {
+	similarity := SequenceSimilarity(alignment.CostWith(left, right, compare), left.Length(), right.Length())
+	return adaptive.Choose(similarity)
+}
+
+// ------------------------------------------- Alignment Cost
+
+// The total cost of an alignment: the cost of each Different pair, plus one
+// for each item on only one side.  For an alignment straight out of Diff_v2,
+// this is the edit distance.
+func (alignment *Alignment) Cost(left, right ComparableSequence) float32 {
+	return alignment.CostWith(left, right, DirectCompare)
+}
+
+// ------------------------------------------- Alignment CostWith
+
+// Cost, comparing items with "compare", e.g. a SimilarityCache.
+func (alignment *Alignment) CostWith(left, right ComparableSequence, compare CompareFunc) float32 {
+	var cost float32
+	for _, link := range alignment.Links {
+		switch link.LinkType {
+		case Matching:
+		case Different:
+			cost += compare(left.GetItemAt(link.LeftIndex), right.GetItemAt(link.RightIndex))
+		case LeftOnly, RightOnly:
+			cost += 1.0
+		default:
+			panic("not reached")
+		}
+	}
+	return cost
+}
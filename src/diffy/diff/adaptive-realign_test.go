@@ -0,0 +1,94 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- helper functions
+
+// Count the links of each type in an alignment.
+func countLinkTypes(alignment *Alignment) map[LinkType]int {
+	counts := map[LinkType]int{}
+	for _, link := range alignment.Links {
+		counts[link.LinkType]++
+	}
+	return counts
+}
+
+// ------------------------------------------- TestAdaptiveThresholdChoose
+
+func TestAdaptiveThresholdChoose(t *testing.T) {
+	adaptive := AdaptiveThreshold{Strict: 0.2, Lax: 0.6}
+	for _, testCase := range []struct {
+		similarity, expected float32
+	}{
+		{1.0, 0.2},
+		{0.5, 0.4},
+		{0.0, 0.6},
+	} {
+		if threshold := adaptive.Choose(testCase.similarity); fmt.Sprintf("%.3f", threshold) != fmt.Sprintf("%.3f", testCase.expected) {
+			t.Errorf("Similarity %v: expected threshold %v, got %v", testCase.similarity, testCase.expected, threshold)
+		}
+	}
+}
+
+// ------------------------------------------- TestAdaptiveRealign
+
+func TestAdaptiveRealign(t *testing.T) {
+
+	// Twenty lines, one of which has been replaced by a line that looks a bit
+	// like it.  Keeping the pair together suggests an edit that never happened.
+	var nearLeft, nearRight ComparableLines
+	for i := 0; i < 20; i++ {
+		text := fmt.Sprintf("line %02d of the unchanged text", i)
+		nearLeft = append(nearLeft, NewTextLine(text))
+		nearRight = append(nearRight, NewTextLine(text))
+	}
+	nearLeft[10] = NewTextLine("the quick brown fox jumps")
+	nearRight[10] = NewTextLine("the quick brown cat naps")
+
+	// Every line but one has been reworked.  Pairing the lines up is the most
+	// useful thing the diff can show.
+	rewrittenLeft := ComparableLines{
+		NewTextLine("the quick brown fox jumps over the dog"),
+		NewTextLine("return computeTotal(items, taxRate)"),
+		NewTextLine("this line stays the same"),
+		NewTextLine("the quick brown fox jumps"),
+		NewTextLine("abcdefghijklmnopqrst"),
+	}
+	rewrittenRight := ComparableLines{
+		NewTextLine("the quick brown cat sleeps under the dog"),
+		NewTextLine("return computeSubtotal(items)"),
+		NewTextLine("this line stays the same"),
+		NewTextLine("the quick brown cat naps"),
+		NewTextLine("abcdefghijklmnXXXXXX"),
+	}
+
+	_, nearAlignment := Diff_v2(nearLeft, nearRight)
+	_, rewrittenAlignment := Diff_v2(rewrittenLeft, rewrittenRight)
+
+	nearThreshold := DefaultAdaptiveThreshold.ChooseFor(nearAlignment, nearLeft, nearRight)
+	rewrittenThreshold := DefaultAdaptiveThreshold.ChooseFor(rewrittenAlignment, rewrittenLeft, rewrittenRight)
+	if nearThreshold >= DEFAULT_REALIGN_THRESHOLD || rewrittenThreshold <= DEFAULT_REALIGN_THRESHOLD {
+		t.Fatalf("Expected thresholds either side of the default, got %v (near-identical) and %v (rewritten)", nearThreshold, rewrittenThreshold)
+	}
+
+	// The near-identical pair: the fixed threshold keeps the replacement
+	// paired up, the adaptive one splits it.
+	if counts := countLinkTypes(nearAlignment.RealignUsingThreshold(nearLeft, nearRight, DEFAULT_REALIGN_THRESHOLD)); counts[Different] != 1 {
+		t.Errorf("Expected the default threshold to keep the pair, got %v", counts)
+	}
+	if counts := countLinkTypes(nearAlignment.RealignUsingThreshold(nearLeft, nearRight, nearThreshold)); counts[Different] != 0 || counts[LeftOnly] != 1 || counts[RightOnly] != 1 {
+		t.Errorf("Expected the adaptive threshold to split the pair, got %v", counts)
+	}
+
+	// The rewritten pair: splitting as eagerly as for the near-identical pair
+	// would pull edited lines apart, but the adaptive threshold keeps them all.
+	if counts := countLinkTypes(rewrittenAlignment.RealignUsingThreshold(rewrittenLeft, rewrittenRight, nearThreshold)); counts[Different] == 4 {
+		t.Errorf("Expected the strict threshold to split some pairs, got %v", counts)
+	}
+	if counts := countLinkTypes(rewrittenAlignment.RealignUsingThreshold(rewrittenLeft, rewrittenRight, rewrittenThreshold)); counts[Different] != 4 || counts[Matching] != 1 {
+		t.Errorf("Expected the adaptive threshold to keep every pair, got %v", counts)
+	}
+}
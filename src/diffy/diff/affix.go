@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// "affix.go" - Finding and stripping a prefix or suffix shared by every line
+// of both files, such as a logging tag, which would otherwise dominate the diff.
+
+// -------------------------------------------
+// ------------------------------------------- CommonAffix
+// -------------------------------------------
+
+// The longest prefix and suffix shared by every line of every one of "sequences",
+// or "" if there's none.  Neither takes a line's last character, so no line
+// is left empty, and neither ends partway through a word: the prefix ends at,
+// and the suffix starts at, a character which isn't a letter or a digit.  A
+// sequence with no lines shares nothing with the others.
+
+func CommonAffix(sequences ...ComparableLines) (prefix, suffix string) {
+
+	// The affixes have to be stripped from each form of a line alike.
+	var texts []string
+	for _, lines := range sequences {
+		if len(lines) == 0 {
+			return "", ""
+		}
+		for _, line := range lines {
+			texts = append(texts, line.Text, line.compareText)
+			if line.RawText != "" {
+				texts = append(texts, line.RawText)
+			}
+		}
+	}
+	if len(texts) == 0 {
+		return "", ""
+	}
+
+	shortest := texts[0]
+	for _, text := range texts {
+		if len(text) < len(shortest) {
+			shortest = text
+		}
+	}
+	if shortest == "" {
+		return "", ""
+	}
+	_, lastSize := utf8.DecodeLastRuneInString(shortest)
+	shortest = shortest[:len(shortest) - lastSize]
+
+	prefixLen := len(shortest)
+	for _, text := range texts {
+		prefixLen = commonPrefixLen(text[:prefixLen], shortest[:prefixLen])
+	}
+	prefix = trimPrefixToWord(shortest[:prefixLen])
+
+	remaining := len(shortest) - len(prefix)
+	suffixLen := remaining
+	for _, text := range texts {
+		suffixLen = commonSuffixLen(text[len(text) - suffixLen:], texts[0][len(texts[0]) - suffixLen:])
+	}
+	suffix = trimSuffixToWord(texts[0][len(texts[0]) - suffixLen:])
+	return prefix, suffix
+}
+
+// ------------------------------------------- commonPrefixLen
+
+// The length in bytes of the longest common prefix of "a" and "b", which
+// must be the same length, backed off to a whole number of runes.
+func commonPrefixLen(a, b string) int {
+	length := 0
+	for length < len(a) && a[length] == b[length] {
+		length++
+	}
+	for length > 0 && length < len(a) && !utf8.RuneStart(a[length]) {
+		length--
+	}
+	return length
+}
+
+// ------------------------------------------- commonSuffixLen
+
+// Like commonPrefixLen, for the longest common suffix.
+func commonSuffixLen(a, b string) int {
+	length := 0
+	for length < len(a) && a[len(a) - 1 - length] == b[len(b) - 1 - length] {
+		length++
+	}
+	for length > 0 && !utf8.RuneStart(a[len(a) - length]) {
+		length--
+	}
+	return length
+}
+
+// ------------------------------------------- trimPrefixToWord
+
+// trimPrefixToWord("[INFO] ab") => "[INFO] "
+func trimPrefixToWord(prefix string) string {
+	return strings.TrimRightFunc(prefix, isWordChar)
+}
+
+// ------------------------------------------- trimSuffixToWord
+
+// trimSuffixToWord("de (pid 7)") => " (pid 7)"
+func trimSuffixToWord(suffix string) string {
+	return strings.TrimLeftFunc(suffix, isWordChar)
+}
+
+func isWordChar(char rune) bool {
+	return unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+// -------------------------------------------
+// ------------------------------------------- StripAffix
+// -------------------------------------------
+
+// A copy of "lines" with "prefix" and "suffix" stripped from each line, which
+// they must come from CommonAffix for.  The lines themselves are left alone.
+
+func StripAffix(lines ComparableLines, prefix, suffix string) ComparableLines {
+	if prefix == "" && suffix == "" {
+		return lines
+	}
+	stripped := make(ComparableLines, len(lines))
+	for index, line := range lines {
+		stripped[index] = line.stripAffix(prefix, suffix)
+	}
+	return stripped
+}
+
+// ------------------------------------------- TextLine stripAffix method
+
+func (line *TextLine) stripAffix(prefix, suffix string) *TextLine {
+	strip := func (text string) string {
+		if text == "" {
+			return ""
+		}
+		return text[len(prefix):len(text) - len(suffix)]
+	}
+	stripped := *line
+	stripped.Text = strip(line.Text)
+	stripped.RawText = strip(line.RawText)
+	if prefix != "" {
+		stripped.RawIndent = leadingWhitespace(stripped.RawText)
+	}
+	stripped.compareText = strip(line.compareText)
+	stripped.compareLength = utf8.RuneCountInString(stripped.compareText)
+	stripped.diffHash = DiffHash{}
+	stripped.diffHash.Init(stripped.compareText)
+	return &stripped
+}
@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- TestCommonAffix
+
+func TestCommonAffix(t *testing.T) {
+
+	testCases := []struct {
+		left, right []string
+		prefix, suffix string
+	}{
+		// A uniform prefix on every line.
+		{[]string{"[INFO] starting", "[INFO] ready"}, []string{"[INFO] starting", "[INFO] stopped"}, "[INFO] ", ""},
+		// A prefix and a suffix.
+		{[]string{"app: one (pid 7)", "app: two (pid 7)"}, []string{"app: three (pid 7)"}, "app: ", " (pid 7)"},
+		// Not partway through a word: "[INFO] st" is common, but "st" isn't noise.
+		{[]string{"[INFO] start"}, []string{"[INFO] stop"}, "[INFO] ", ""},
+		{[]string{"10:00:01 up"}, []string{"10:00:02 up"}, "10:00:", " up"},
+		// Nothing in common.
+		{[]string{"INFO a", "WARN b"}, []string{"INFO c"}, "", ""},
+		// A blank line has nothing to strip.
+		{[]string{"[INFO] a", ""}, []string{"[INFO] c"}, "", ""},
+		// No line is stripped bare, even when every line is the same.
+		{[]string{"--- x", "--- x"}, []string{"--- x"}, "--- ", ""},
+		{[]string{"--"}, []string{"--"}, "-", ""},
+		// An empty file shares nothing.
+		{[]string{"[INFO] a"}, nil, "", ""},
+		// Multibyte characters aren't split.
+		{[]string{"«é» a"}, []string{"«é» b"}, "«é» ", ""},
+		{[]string{"«1"}, []string{"«2"}, "«", ""},
+	}
+
+	for _, testCase := range testCases {
+		prefix, suffix := CommonAffix(makeTestLines(testCase.left...), makeTestLines(testCase.right...))
+		if prefix != testCase.prefix || suffix != testCase.suffix {
+			t.Errorf("%q vs %q: expected %q and %q, got %q and %q", testCase.left, testCase.right, testCase.prefix, testCase.suffix, prefix, suffix)
+		}
+	}
+}
+
+// ------------------------------------------- TestStripAffix
+
+func TestStripAffix(t *testing.T) {
+
+	// Every line has the same logging tag, which makes the lines look more alike than they are.
+	left := makeTestLines("[2024-01-01 main] opening the file", "[2024-01-01 main] reading", "[2024-01-01 main] closing the file")
+	right := makeTestLines("[2024-01-01 main] opening the file", "[2024-01-01 main] parsing", "[2024-01-01 main] closing the file")
+
+	prefix, suffix := CommonAffix(left, right)
+	if prefix != "[2024-01-01 main] " || suffix != "" {
+		t.Fatalf("Expected the logging tag, got %q and %q", prefix, suffix)
+	}
+	strippedLeft, strippedRight := StripAffix(left, prefix, suffix), StripAffix(right, prefix, suffix)
+
+	// The lines are stripped for display and for comparison, and the originals are left alone.
+	if strippedLeft[1].Text != "reading" || strippedLeft[1].CompareText() != "reading" || left[1].Text != "[2024-01-01 main] reading" {
+		t.Errorf("Unexpected stripped line %q (compared as %q), from %q", strippedLeft[1].Text, strippedLeft[1].CompareText(), left[1].Text)
+	}
+	if similarity, expected := strippedLeft[1].Similarity(strippedRight[1]), NewTextLine("reading").Similarity(NewTextLine("parsing")); similarity != expected {
+		t.Errorf("Expected the stripped lines to compare as %v, got %v", expected, similarity)
+	}
+
+	// The diff itself is the same shape, less the noise.
+	_, alignment := Diff_v2(strippedLeft, strippedRight)
+	expected := []Link{{Matching, 0, 0}, {Different, 1, 1}, {Matching, 2, 2}}
+	if fmt.Sprint(alignment.Links) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, alignment.Links)
+	}
+
+	// Raw text and indentation are stripped alike.
+	line := NewTextLine("> \tx")
+	line.RawText, line.RawIndent = "> \tx", ""
+	if stripped := StripAffix(ComparableLines{line}, "> ", "")[0]; stripped.RawText != "\tx" || stripped.RawIndent != "\t" {
+		t.Errorf("Expected the raw text %q with indent %q, got %q with %q", "\tx", "\t", stripped.RawText, stripped.RawIndent)
+	}
+
+	// With nothing to strip, the lines are returned as they are.
+	if stripped := StripAffix(left, "", ""); &stripped[0] != &left[0] {
+		t.Errorf("Expected the same lines back")
+	}
+}
@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"fmt"
+)
+
+// "alignment-changes.go" - Comparing two alignments of the same pair of files,
+// e.g. from runs with different options, to see which lines were classified
+// differently.
+
+// ------------------------------------------- type LinkChange
+
+// A LinkChange is a line whose link differs between two alignments: the link
+// covering it before and the link covering it after.
+type LinkChange struct {
+	Before, After Link
+}
+
+// ------------------------------------------- CompareAlignments
+//
+// The links which differ between two alignments of the same files, in left
+// file order and then right file order.  Lines are compared on both sides, so
+// a left-only line which becomes part of a changed pair shows up once for the
+// left line and once for the right line, but a change seen from both sides,
+// e.g. a matching pair becoming a changed pair, is only reported once.
+//
+// It's an error for the alignments to cover different lines, since then they
+// can't be of the same files, or the same part of them.
+//
+func CompareAlignments(before, after *Alignment) ([]LinkChange, error) {
+
+	beforeLeft, beforeRight := coveredLines(before)
+	afterLeft, afterRight := coveredLines(after)
+	if !sameLines(beforeLeft, afterLeft) || !sameLines(beforeRight, afterRight) {
+		return nil, fmt.Errorf("the alignments are of different lines: %d and %d lines before, %d and %d lines after",
+								len(beforeLeft), len(beforeRight), len(afterLeft), len(afterRight))
+	}
+
+	var changes []LinkChange
+	seen := make(map[LinkChange]bool)
+	addChanges := func (lines []int, lookupBefore, lookupAfter func (int) (Link, bool)) {
+		for _, index := range lines {
+			beforeLink, _ := lookupBefore(index)
+			afterLink, _ := lookupAfter(index)
+			change := LinkChange{beforeLink, afterLink}
+			if beforeLink != afterLink && !seen[change] {
+				changes = append(changes, change)
+				seen[change] = true
+			}
+		}
+	}
+	beforeLookup, afterLookup := NewLinkLookup(before), NewLinkLookup(after)
+	addChanges(beforeLeft, beforeLookup.Left, afterLookup.Left)
+	addChanges(beforeRight, beforeLookup.Right, afterLookup.Right)
+	return changes, nil
+}
+
+// The indexes of the left and right lines an alignment covers, in order.
+// They needn't be contiguous, e.g. for part of an alignment.
+func coveredLines(alignment *Alignment) (left, right []int) {
+	for _, link := range alignment.Links {
+		if link.LeftIndex >= 0 {
+			left = append(left, link.LeftIndex)
+		}
+		if link.RightIndex >= 0 {
+			right = append(right, link.RightIndex)
+		}
+	}
+	return left, right
+}
+
+func sameLines(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"testing"
+)
+
+// ------------------------------------------- TestCompareAlignments
+
+func TestCompareAlignments(t *testing.T) {
+
+	before := makeTestAlignment("  * ")
+	after := makeTestAlignment("  * ")
+	if changes, err := CompareAlignments(before, after); err != nil || len(changes) != 0 {
+		t.Errorf("Expected identical alignments to have no changes, got %v, %v", changes, err)
+	}
+
+	// One link changes type.
+	after = makeTestAlignment(" ** ")
+	changes, err := CompareAlignments(before, after)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := LinkChange{Link{Matching, 1, 1}, Link{Different, 1, 1}}
+	if len(changes) != 1 || changes[0] != expected {
+		t.Errorf("Expected just %v, got %v", expected, changes)
+	}
+
+	// A changed pair split into a deletion and an insertion is seen from both sides.
+	after = makeTestAlignment("  -+ ")
+	changes, err = CompareAlignments(before, after)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expectedChanges := []LinkChange{
+		{Link{Different, 2, 2}, Link{LeftOnly, 2, -1}},
+		{Link{Different, 2, 2}, Link{RightOnly, -1, 2}},
+	}
+	if len(changes) != len(expectedChanges) || changes[0] != expectedChanges[0] || changes[1] != expectedChanges[1] {
+		t.Errorf("Expected %v, got %v", expectedChanges, changes)
+	}
+
+	// Parts of alignments are compared by the lines they cover, however far in.
+	partBefore := &Alignment{Links: []Link{{Matching, 2, 2}, {Different, 3, 3}, {Matching, 7, 7}}}
+	partAfter := &Alignment{Links: []Link{{Matching, 2, 2}, {LeftOnly, 3, -1}, {RightOnly, -1, 3}, {Matching, 7, 7}}}
+	changes, err = CompareAlignments(partBefore, partAfter)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expectedChanges = []LinkChange{
+		{Link{Different, 3, 3}, Link{LeftOnly, 3, -1}},
+		{Link{Different, 3, 3}, Link{RightOnly, -1, 3}},
+	}
+	if len(changes) != len(expectedChanges) || changes[0] != expectedChanges[0] || changes[1] != expectedChanges[1] {
+		t.Errorf("Expected %v, got %v", expectedChanges, changes)
+	}
+	if _, err := CompareAlignments(partBefore, &Alignment{Links: []Link{{Matching, 2, 2}, {Different, 3, 3}, {Matching, 8, 7}}}); err == nil {
+		t.Errorf("Expected an error for alignments of different lines")
+	}
+
+	// Alignments of different files can't be compared.
+	if _, err := CompareAlignments(before, makeTestAlignment("  * -")); err == nil {
+		t.Errorf("Expected an error for alignments of different files")
+	}
+}
@@ -1,5 +1,11 @@
 package diff
 
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
 // -------------------------------------------
 // ------------------------------------------- type Alignment
 // -------------------------------------------
@@ -43,11 +49,37 @@ type Link struct {
 	RightIndex int 		// -1 or zero-based index into the right or second sequence
 }
 
+// ------------------------------------------- Alignment EditOps
+//
+// The number of insertions and deletions: the LeftOnly and RightOnly links.
+// For an alignment with no Different links, such as a realigned one with a
+// threshold of zero, that's the "D" of Myers' algorithm.  The edit distance
+// Diff_v2 returns counts these too, but adds the cost of each Different pair,
+// which is only a fraction of a line for a pair that's nearly the same.
+//
+func (alignment *Alignment) EditOps() int {
+	editOps := 0
+	for _, link := range alignment.Links {
+		if link.LinkType == LeftOnly || link.LinkType == RightOnly {
+			editOps++
+		}
+	}
+	return editOps
+}
+
 // ------------------------------------------- Alignment RealignUsingThreshold
 //
 // Generate a nicer alignment using a thresholded similarity comparison.
 //
 func (alignment *Alignment) RealignUsingThreshold(left, right ComparableSequence, threshold float32) *Alignment {
+	return alignment.RealignUsingThresholdWith(left, right, threshold, DirectCompare)
+}
+
+// ------------------------------------------- Alignment RealignUsingThresholdWith
+//
+// RealignUsingThreshold, comparing items with "compare", e.g. a SimilarityCache.
+//
+func (alignment *Alignment) RealignUsingThresholdWith(left, right ComparableSequence, threshold float32, compare CompareFunc) *Alignment {
 
 	leftItem := func (link Link) Comparable {
 		return left.GetItemAt(link.LeftIndex)
@@ -66,7 +98,7 @@ func (alignment *Alignment) RealignUsingThreshold(left, right ComparableSequence
 
 	var newLinks, rightLinks []Link
 	for _, link := range alignment.Links {
-		if link.LinkType == Different && leftItem(link).Compare(rightItem(link)) > threshold {
+		if link.LinkType == Different && compare(leftItem(link), rightItem(link)) > threshold {
 			newLinks = append(newLinks, Link{LeftOnly, link.LeftIndex, -1})
 			rightLinks = append(rightLinks, Link{RightOnly, -1, link.RightIndex})
 		} else {
@@ -76,12 +108,237 @@ func (alignment *Alignment) RealignUsingThreshold(left, right ComparableSequence
 		}
 	}
 	newLinks = append(newLinks, rightLinks...)	// we might have some outstanding right links, append them
-	return &Alignment{newLinks}
+	return &Alignment{Links: newLinks}
+}
+
+// ------------------------------------------- Alignment IgnoreTrailingBlankLines
+//
+// Ignore the changes at the very end of the alignment which involve nothing
+// but blank lines, such as an editor adding or removing a trailing blank line.
+//
+// The tail of the alignment is the longest run of links at the end in which
+// every line present is blank (empty or all whitespace).  The tail is rebuilt
+// as Matching links pairing its left and right lines in order, and whichever
+// side has more blank lines in it has the extra ones cut, so the result still
+// covers every line of the sequences it returns along with it.  Those are
+// "left" and "right", less any extra lines at their ends.
+//
+// The diff may well have paired a trailing blank line with a changed line on
+// the other side, so a Different link just before the tail with one blank
+// side is taken apart: its other line stays a change, and the blank joins the
+// tail.
+//
+func (alignment *Alignment) IgnoreTrailingBlankLines(left, right ComparableLines) (*Alignment, ComparableLines, ComparableLines) {
+
+	isBlank := func (lines ComparableLines, index int) bool {
+		return index < 0 || strings.TrimSpace(lines[index].Text) == ""
+	}
+
+	links := alignment.Links
+	tailStart := len(links)
+	for tailStart > 0 {
+		link := links[tailStart - 1]
+		if !isBlank(left, link.LeftIndex) || !isBlank(right, link.RightIndex) {
+			break
+		}
+		tailStart--
+	}
+	if tailStart > 0 && links[tailStart - 1].LinkType == Different {
+		link := links[tailStart - 1]
+		var changed, blank Link
+		split := true
+		switch {
+		case isBlank(right, link.RightIndex):
+			changed, blank = Link{LeftOnly, link.LeftIndex, -1}, Link{RightOnly, -1, link.RightIndex}
+		case isBlank(left, link.LeftIndex):
+			changed, blank = Link{RightOnly, -1, link.RightIndex}, Link{LeftOnly, link.LeftIndex, -1}
+		default:
+			split = false
+		}
+		if split {
+			links = append(append(append([]Link(nil), links[:tailStart - 1]...), changed, blank), links[tailStart:]...)
+		}
+	}
+
+	// The tail's lines are the last of each side.
+	leftCount, rightCount := countSides(links[tailStart:])
+	leftStart, rightStart := len(left) - leftCount, len(right) - rightCount
+	pairCount := leftCount
+	if rightCount < pairCount {
+		pairCount = rightCount
+	}
+
+	newLinks := make([]Link, tailStart, tailStart + pairCount)
+	copy(newLinks, links[:tailStart])
+	for index := 0; index < pairCount; index++ {
+		newLinks = append(newLinks, Link{Matching, leftStart + index, rightStart + index})
+	}
+	return &Alignment{Links: newLinks}, left[:leftStart + pairCount], right[:rightStart + pairCount]
+}
+
+// ------------------------------------------- Alignment MatchBlockIndents
+//
+// Treat a block of lines whose only change is a uniform shift in indentation
+// as Matching, e.g. when a block has been wrapped in an "if".
+//
+// Each run of Different links is checked for a consistent shift: the same
+// whitespace prefix added to (or removed from) every right (or left) line in
+// the run, with the rest of the line unchanged.  Lines which are blank on both
+// sides go along with the shift.  If the whole run is consistent, its links
+// become Matching, even though the texts aren't identical; use IndentShift to
+// tell such links apart.
+//
+func (alignment *Alignment) MatchBlockIndents(left, right ComparableLines) *Alignment {
+	newLinks := make([]Link, len(alignment.Links))
+	copy(newLinks, alignment.Links)
+
+	for start := 0; start < len(newLinks); {
+		if newLinks[start].LinkType != Different {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(newLinks) && newLinks[end].LinkType == Different {
+			end++
+		}
+
+		shift, consistent := "", true
+		for _, link := range newLinks[start:end] {
+			leftText, rightText := left[link.LeftIndex].Text, right[link.RightIndex].Text
+			if strings.TrimSpace(leftText) == "" && strings.TrimSpace(rightText) == "" {
+				continue
+			}
+			lineShift := IndentShift(leftText, rightText)
+			if lineShift == "" || (shift != "" && lineShift != shift) {
+				consistent = false
+				break
+			}
+			shift = lineShift
+		}
+		if consistent && shift != "" {
+			for i := start; i < end; i++ {
+				newLinks[i].LinkType = Matching
+			}
+		}
+		start = end
+	}
+
+	return &Alignment{Links: newLinks}
+}
+
+// ------------------------------------------- IndentShift
+
+// If "rightText" is "leftText" with extra leading whitespace, or the other way
+// around, describe the shift: "+" followed by the added whitespace, or "-"
+// followed by the removed whitespace.  Otherwise return the empty string.
+func IndentShift(leftText, rightText string) string {
+	switch {
+	case len(rightText) > len(leftText) && strings.HasSuffix(rightText, leftText):
+		if added := rightText[:len(rightText) - len(leftText)]; strings.TrimSpace(added) == "" {
+			return "+" + added
+		}
+	case len(leftText) > len(rightText) && strings.HasSuffix(leftText, rightText):
+		if removed := leftText[:len(leftText) - len(rightText)]; strings.TrimSpace(removed) == "" {
+			return "-" + removed
+		}
+	}
+	return ""
+}
+
+// ------------------------------------------- Alignment Validate
+
+// Check that the alignment is a well-formed alignment of "left" with "right":
+// each link has the indexes its type says it has, and no others, and every
+// index of each sequence appears exactly once, in ascending order.  Every
+// pass over an alignment should keep it that way, so a problem is a bug.
+// Whether the items of a Matching link actually match isn't checked, since
+// passes like MatchBlockIndents treat items as matching which aren't equal.
+func (alignment *Alignment) Validate(left, right ComparableSequence) error {
+	nextLeft, nextRight := 0, 0
+	for linkNumber, link := range alignment.Links {
+		var hasLeft, hasRight bool
+		switch link.LinkType {
+		case Matching, Different:
+			hasLeft, hasRight = true, true
+		case LeftOnly:
+			hasLeft = true
+		case RightOnly:
+			hasRight = true
+		default:
+			return fmt.Errorf("link %d %v has an unknown link type", linkNumber, link)
+		}
+		if (link.LeftIndex >= 0) != hasLeft || (link.RightIndex >= 0) != hasRight || link.LeftIndex < -1 || link.RightIndex < -1 {
+			return fmt.Errorf("link %d %v has the wrong indexes for its type", linkNumber, link)
+		}
+		if hasLeft {
+			if link.LeftIndex != nextLeft {
+				return fmt.Errorf("link %d %v has left index %d where %d was expected", linkNumber, link, link.LeftIndex, nextLeft)
+			}
+			nextLeft++
+		}
+		if hasRight {
+			if link.RightIndex != nextRight {
+				return fmt.Errorf("link %d %v has right index %d where %d was expected", linkNumber, link, link.RightIndex, nextRight)
+			}
+			nextRight++
+		}
+	}
+	if nextLeft != left.Length() || nextRight != right.Length() {
+		return fmt.Errorf("the links cover %d left and %d right items of %d and %d", nextLeft, nextRight, left.Length(), right.Length())
+	}
+	return nil
+}
+
+// ------------------------------------------- Alignment Swap
+
+// Return a copy of the alignment with the left and right sides exchanged, so
+// that an alignment of "s" to "t" becomes an alignment of "t" to "s".
+func (alignment *Alignment) Swap() *Alignment {
+	newLinks := make([]Link, len(alignment.Links))
+	for i, link := range alignment.Links {
+		switch link.LinkType {
+		case LeftOnly:
+			link.LinkType = RightOnly
+		case RightOnly:
+			link.LinkType = LeftOnly
+		}
+		link.LeftIndex, link.RightIndex = link.RightIndex, link.LeftIndex
+		newLinks[i] = link
+	}
+	return &Alignment{Links: newLinks}
 }
 
 // ------------------------------------------- Alignment Dump
 
+// The width of each item column in the output of Dump.
+const DEFAULT_DUMP_WIDTH = 30
+
 func (alignment *Alignment) Dump(left, right ComparableSequence, computedEditDistance int, s SimpleLogger) {
+	alignment.DumpWithWidth(left, right, computedEditDistance, s, DEFAULT_DUMP_WIDTH)
+}
+
+// ------------------------------------------- Alignment DumpWithWidth
+
+// Like Dump, but with "width" runes for each of the item columns, for when the
+// default truncates too much, e.g. with real code lines.
+func (alignment *Alignment) DumpWithWidth(left, right ComparableSequence, computedEditDistance int, s SimpleLogger, width int) {
+
+	// Wide enough for the biggest index, and for the -1 of a missing item.
+	indexWidth := len(fmt.Sprint(left.Length()))
+	if rightIndexWidth := len(fmt.Sprint(right.Length())); rightIndexWidth > indexWidth {
+		indexWidth = rightIndexWidth
+	}
+	if indexWidth < 2 {
+		indexWidth = 2
+	}
+
+	// Pad by runes rather than bytes, so non-ASCII text doesn't throw off the columns.
+	pad := func (text string) string {
+		if padCount := width - utf8.RuneCountInString(text); padCount > 0 {
+			text += strings.Repeat(" ", padCount)
+		}
+		return text
+	}
 
 	s.Printf(".................................................... ")
 	s.Printf("%s/%s (edit distance: %d)\n", left.GetDescription(), right.GetDescription(), computedEditDistance)
@@ -112,7 +369,8 @@ func (alignment *Alignment) Dump(left, right ComparableSequence, computedEditDis
 		default:
 			panic("Missing case")
 		}
-		s.Printf("%s %2d %-30s %-30s %2d\n", codeChar, link.LeftIndex, leftItem.Stringify(30), rightItem.Stringify(30), link.RightIndex)
+		s.Printf("%s %*d %s %s %*d\n", codeChar, indexWidth, link.LeftIndex,
+			pad(leftItem.Stringify(width)), pad(rightItem.Stringify(width)), indexWidth, link.RightIndex)
 	}
 	s.Println()
 
@@ -126,5 +384,6 @@ func (alignment *Alignment) Dump(left, right ComparableSequence, computedEditDis
 	s.Println()
 	nonMatchingCount := len(alignment.Links) - matchingCount
 	s.Printf("non-matching count, computed edit distance = %d, %d\n", nonMatchingCount, computedEditDistance)
+	s.Printf("edit ops (insertions + deletions, not counting changed pairs) = %d\n", alignment.EditOps())
 	s.Println()
 }
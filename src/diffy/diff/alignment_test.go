@@ -0,0 +1,298 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+// Build an alignment from a compact string of link type codes, using the
+// same codes as Dump: " " for Matching, "*" for Different, "-" for LeftOnly,
+// and "+" for RightOnly.  The indexes are filled in automatically.
+func makeTestAlignment(codes string) *Alignment {
+	alignment := new(Alignment)
+	leftIndex, rightIndex := 0, 0
+	for _, code := range codes {
+		var link Link
+		switch code {
+		case ' ':
+			link = Link{Matching, leftIndex, rightIndex}
+		case '*':
+			link = Link{Different, leftIndex, rightIndex}
+		case '-':
+			link = Link{LeftOnly, leftIndex, -1}
+		case '+':
+			link = Link{RightOnly, -1, rightIndex}
+		default:
+			panic("unknown link code")
+		}
+		if link.LeftIndex >= 0 {
+			leftIndex++
+		}
+		if link.RightIndex >= 0 {
+			rightIndex++
+		}
+		alignment.Links = append(alignment.Links, link)
+	}
+	return alignment
+}
+
+// The inverse of makeTestAlignment.
+func alignmentCodes(alignment *Alignment) string {
+	codes := ""
+	for _, link := range alignment.Links {
+		codes += map[LinkType]string{Matching: " ", Different: "*", LeftOnly: "-", RightOnly: "+"}[link.LinkType]
+	}
+	return codes
+}
+
+// ------------------------------------------- TestEditOps
+
+func TestEditOps(t *testing.T) {
+
+	// Two changed lines, one deleted, and two inserted.
+	left := makeTestLines("one", "two", "three", "four", "five")
+	right := makeTestLines("one", "2", "three", "FOUR", "six", "seven")
+	alignment := makeTestAlignment(" * *-++")
+	if editOps := alignment.EditOps(); editOps != 3 {
+		t.Errorf("Expected 3 edit ops, got %d", editOps)
+	}
+
+	// Splitting every changed pair turns each into a deletion and an insertion.
+	if editOps := alignment.RealignUsingThreshold(left, right, 0.0).EditOps(); editOps != 7 {
+		t.Errorf("Expected 7 edit ops once the pairs are split, got %d", editOps)
+	}
+
+	// Dump reports them, next to the edit distance.
+	logger := new(tCaptureLogger)
+	alignment.Dump(left, right, 4, logger)
+	if !strings.Contains(logger.String(), "edit ops (insertions + deletions, not counting changed pairs) = 3\n") {
+		t.Errorf("Expected the dump to give the edit ops, got\n%s", logger.String())
+	}
+}
+
+// ------------------------------------------- TestDumpWithWidth
+
+func TestDumpWithWidth(t *testing.T) {
+
+	const width = 60
+	left := makeTestLines(
+		"short",
+		strings.Repeat("a long line of code, ", 5),
+		"caf\u00e9 au lait",
+		"deleted",
+	)
+	right := makeTestLines(
+		"short",
+		strings.Repeat("a long line of code! ", 5),
+		"caf\u00e9 au lait",
+	)
+	alignment := makeTestAlignment(" * -")
+
+	logger := new(tCaptureLogger)
+	alignment.DumpWithWidth(left, right, 2, logger, width)
+
+	// The edit sequence is between the "=====" underline and the next blank line.
+	lines := strings.Split(logger.String(), "\n")
+	start := 0
+	for lines[start] != "=============" {
+		start++
+	}
+	rows := lines[start + 2:]
+	for i, row := range rows {
+		if row == "" {
+			rows = rows[:i]
+			break
+		}
+	}
+
+	if len(rows) != len(alignment.Links) {
+		t.Fatalf("Expected %d rows, got %d:\n%s", len(alignment.Links), len(rows), logger.String())
+	}
+	expectedLength := 1 + 1 + 2 + 1 + width + 1 + width + 1 + 2
+	for _, row := range rows {
+		if length := utf8.RuneCountInString(row); length != expectedLength {
+			t.Errorf("Expected every row to be %d runes, got %d: %q", expectedLength, length, row)
+		}
+	}
+	if !strings.Contains(rows[1], strings.Repeat("a long line of code, ", 2)) {
+		t.Errorf("Expected the long line to be shown up to the width, got %q", rows[1])
+	}
+}
+
+// ------------------------------------------- TestIgnoreTrailingBlankLines
+
+func TestIgnoreTrailingBlankLines(t *testing.T) {
+
+	testCases := []struct {
+		left, right []string
+		expected string
+		leftCount, rightCount int
+	}{
+		{[]string{"a", "b"}, []string{"a", "b", ""}, "  ", 2, 2},						// one extra trailing blank
+		{[]string{"a", "b", "", "  ", ""}, []string{"a", "b"}, "  ", 2, 2},				// several trailing blanks
+		{[]string{"a", "", ""}, []string{"a", ""}, "  ", 2, 2},							// a blank still matches
+		{[]string{"a", "  "}, []string{"a", "", ""}, "  ", 2, 2},						// whatever the whitespace
+		{[]string{"a", "bravo"}, []string{"a", "bravo!", ""}, " *", 2, 2},			// the real change stays
+		{[]string{"a", "", "b"}, []string{"a", "b"}, " - ", 3, 2},						// only blanks at the end count
+		{[]string{"a", "b", ""}, []string{"a", "b", "", "c"}, "   +", 3, 4},			// not blank, not ignored
+		{[]string{"x", "y"}, []string{"x", "z", ""}, " +-", 2, 2},						// a blank paired with a change
+		{[]string{"x", "y", ""}, []string{"x", "z"}, " -+", 2, 2},						// ...on either side
+	}
+
+	for _, testCase := range testCases {
+		left, right := makeTestLines(testCase.left...), makeTestLines(testCase.right...)
+		_, alignment := Diff_v2(left, right)
+		result, newLeft, newRight := alignment.IgnoreTrailingBlankLines(left, right)
+		if codes := alignmentCodes(result); codes != testCase.expected {
+			t.Errorf("%q vs %q: got %q, expected %q", testCase.left, testCase.right, codes, testCase.expected)
+		}
+		if len(newLeft) != testCase.leftCount || len(newRight) != testCase.rightCount {
+			t.Errorf("%q vs %q: expected %d and %d lines, got %d and %d", testCase.left, testCase.right, testCase.leftCount, testCase.rightCount, len(newLeft), len(newRight))
+		}
+
+		// Every line that's left is still accounted for.
+		if err := result.Validate(newLeft, newRight); err != nil {
+			t.Errorf("%q vs %q: %v", testCase.left, testCase.right, err)
+		}
+	}
+}
+
+// ------------------------------------------- TestMatchBlockIndents
+
+func TestMatchBlockIndents(t *testing.T) {
+
+	left := makeTestLines(
+		"x := 1",
+		"fmt.Println(x)",
+		"x++",
+		"return x",
+		"done()",
+	)
+	right := makeTestLines(
+		"if verbose {",
+		"    x := 1",
+		"    fmt.Println(x)",
+		"    x++",
+		"}",
+		"return x",
+		"done()",
+	)
+
+	// Build the alignment by hand, the way a diff pairs up the shifted lines.
+	alignment := &Alignment{Links: []Link{
+		{RightOnly, -1, 0},
+		{Different, 0, 1},
+		{Different, 1, 2},
+		{Different, 2, 3},
+		{RightOnly, -1, 4},
+		{Matching, 3, 5},
+		{Matching, 4, 6},
+	}}
+	result := alignment.MatchBlockIndents(left, right)
+	if codes := alignmentCodes(result); codes != "+   +  " {
+		t.Errorf("Expected the indented block to match, got %q", codes)
+	}
+
+	// An inconsistent shift isn't a block indent.
+	right[3] = NewTextLine("        x++")
+	result = alignment.MatchBlockIndents(left, right)
+	if codes := alignmentCodes(result); codes != "+***+  " {
+		t.Errorf("Expected an inconsistent shift to stay different, got %q", codes)
+	}
+
+	// And neither is a content change.
+	right[3] = NewTextLine("    x--")
+	result = alignment.MatchBlockIndents(left, right)
+	if codes := alignmentCodes(result); codes != "+***+  " {
+		t.Errorf("Expected a content change to stay different, got %q", codes)
+	}
+
+	if shift := IndentShift("x", "\tx"); shift != "+\t" {
+		t.Errorf("Expected a tab to be added, got %q", shift)
+	}
+	if shift := IndentShift("    x", "  x"); shift != "-  " {
+		t.Errorf("Expected two spaces to be removed, got %q", shift)
+	}
+}
+
+// ------------------------------------------- TestMatchBlockIndentsAfterDiff
+
+func TestMatchBlockIndentsAfterDiff(t *testing.T) {
+	left := makeTestLines(
+		"total := computeTotal(items)",
+		"fmt.Printf(\"total: %d\\n\", total)",
+		"recordTotal(database, total)",
+		"return total",
+	)
+	right := makeTestLines(
+		"if verbose {",
+		"    total := computeTotal(items)",
+		"    fmt.Printf(\"total: %d\\n\", total)",
+		"    recordTotal(database, total)",
+		"}",
+		"return total",
+	)
+	_, alignment := Diff_v2(left, right)
+	result := alignment.MatchBlockIndents(left, right)
+
+	for index := 0; index < 3; index++ {
+		link, found := findLinkForLeft(result, index)
+		if !found || link.LinkType != Matching || link.RightIndex != index + 1 {
+			t.Errorf("Expected left line %d to match right line %d, got %v", index, index + 1, link)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestValidate
+// -------------------------------------------
+
+func TestValidate(t *testing.T) {
+
+	left, right := makeTestLines("a", "b", "c", "d"), makeTestLines("a", "B", "c", "e", "f")
+
+	// The alignments the passes produce are valid.
+	_, alignment := Diff_v2(left, right)
+	for _, valid := range []*Alignment{
+		alignment,
+		alignment.RealignUsingThreshold(left, right, DEFAULT_REALIGN_THRESHOLD),
+		alignment.Swap().Swap(),
+		makeTestAlignment(" * -++"),
+	} {
+		if err := valid.Validate(left, right); err != nil {
+			t.Errorf("Expected %v to be valid, got %v", valid.Links, err)
+		}
+	}
+	if err := (&Alignment{}).Validate(makeTestLines(), makeTestLines()); err != nil {
+		t.Errorf("Expected an empty alignment of nothing to be valid, got %v", err)
+	}
+
+	// Each kind of corruption is caught.
+	testCases := []struct {
+		name string
+		links []Link
+		expected string
+	}{
+		{"a left index missing", []Link{{Matching, 0, 0}, {Different, 2, 1}, {Matching, 3, 2}, {RightOnly, -1, 3}, {RightOnly, -1, 4}}, "left index 2 where 1"},
+		{"a right index repeated", []Link{{Matching, 0, 0}, {Different, 1, 0}, {Matching, 2, 1}, {LeftOnly, 3, -1}, {RightOnly, -1, 2}}, "right index 0 where 1"},
+		{"indexes out of order", []Link{{Matching, 1, 0}, {Matching, 0, 1}, {Matching, 2, 2}, {Different, 3, 3}, {RightOnly, -1, 4}}, "left index 1 where 0"},
+		{"a matching link without a right index", []Link{{Matching, 0, -1}}, "wrong indexes"},
+		{"a left-only link with a right index", []Link{{Matching, 0, 0}, {LeftOnly, 1, 1}}, "wrong indexes"},
+		{"a right-only link with a left index", []Link{{RightOnly, 0, 0}}, "wrong indexes"},
+		{"a bad index", []Link{{LeftOnly, -2, -1}}, "wrong indexes"},
+		{"an unknown link type", []Link{{LinkType(7), 0, 0}}, "unknown link type"},
+		{"lines left over", []Link{{Matching, 0, 0}, {Different, 1, 1}}, "cover 2 left and 2 right items of 4 and 5"},
+		{"too many lines", append(makeTestAlignment(" * -++").Links, Link{LeftOnly, 4, -1}), "cover 5 left and 5 right items of 4 and 5"},
+	}
+	for _, testCase := range testCases {
+		err := (&Alignment{Links: testCase.links}).Validate(left, right)
+		if err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Errorf("%s: expected an error with %q, got %v", testCase.name, testCase.expected, err)
+		}
+	}
+}
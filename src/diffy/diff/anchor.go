@@ -0,0 +1,181 @@
+package diff
+
+// "anchor.go" - Forcing the alignment at anchor lines.
+
+// -------------------------------------------
+// ------------------------------------------- DiffAnchored
+// -------------------------------------------
+
+// Diff two sequences of lines, using the lines selected by "isAnchor" to
+// divide the files into sections which are diffed independently with "diffFn".
+// This keeps the alignment from drifting across section boundaries, e.g. when
+// sections are marked with sentinel comments like "// BEGIN section-foo".
+//
+// Anchor lines on the left are paired with anchor lines on the right by exact
+// text equality, in order, using a longest common subsequence.  Paired anchors
+// are locked into the alignment as Matching links.  An anchor line which can't
+// be paired is a section boundary on its own side only: it is never aligned
+// with anything, and shows up as a LeftOnly or RightOnly link.
+
+func DiffAnchored(left, right ComparableLines, isAnchor func (line *TextLine) bool, diffFn DiffFunc) (float32, *Alignment) {
+
+	findAnchors := func (lines ComparableLines) []int {
+		var anchorIndexes []int
+		for index, line := range lines {
+			if isAnchor(line) {
+				anchorIndexes = append(anchorIndexes, index)
+			}
+		}
+		return anchorIndexes
+	}
+
+	leftAnchorIndexes := findAnchors(left)
+	rightAnchorIndexes := findAnchors(right)
+	anchors := pairEqualLines(left, right, leftAnchorIndexes, rightAnchorIndexes, lineText)
+
+	// Any anchor lines which didn't get paired up are excluded from the section diffs.
+	leftPaired, rightPaired := make(map[int]bool), make(map[int]bool)
+	for _, anchor := range anchors {
+		leftPaired[anchor.LeftIndex] = true
+		rightPaired[anchor.RightIndex] = true
+	}
+	var leftExcluded, rightExcluded []int
+	for _, index := range leftAnchorIndexes {
+		if !leftPaired[index] {
+			leftExcluded = append(leftExcluded, index)
+		}
+	}
+	for _, index := range rightAnchorIndexes {
+		if !rightPaired[index] {
+			rightExcluded = append(rightExcluded, index)
+		}
+	}
+
+	return DiffBetweenAnchors(left, right, anchors, leftExcluded, rightExcluded, diffFn)
+}
+
+// -------------------------------------------
+// ------------------------------------------- DiffUniqueAnchored
+// -------------------------------------------
+
+// Diff two sequences of lines, anchored at the lines which appear exactly once
+// in each, as patience diff does.  The unique lines are paired in order, just
+// as anchors are by DiffAnchored, and the gaps between them are diffed with
+// "diffFn".  That keeps repetitive text, such as boilerplate blocks, from
+// being aligned with the wrong copy.  Each pair of unique lines is grown into
+// a run of identical lines in both directions, again as patience diff does,
+// so that a function's boilerplate stays with its uniquely named header.
+//
+// Unlike anchor lines, a unique line which can't be paired isn't a section
+// boundary; it's just diffed along with the rest of its gap.  Lines are the
+// same when their compare text is, so a line which only differs in what the
+// read options normalize away is still unique.
+
+func DiffUniqueAnchored(left, right ComparableLines, diffFn DiffFunc) (float32, *Alignment) {
+
+	countLines := func (lines ComparableLines) map[string]int {
+		counts := make(map[string]int)
+		for _, line := range lines {
+			counts[line.CompareText()]++
+		}
+		return counts
+	}
+	leftCounts, rightCounts := countLines(left), countLines(right)
+	isUnique := func (line *TextLine) bool { return leftCounts[line.CompareText()] == 1 && rightCounts[line.CompareText()] == 1 }
+
+	findUniqueLines := func (lines ComparableLines) []int {
+		var uniqueIndexes []int
+		for index, line := range lines {
+			if isUnique(line) {
+				uniqueIndexes = append(uniqueIndexes, index)
+			}
+		}
+		return uniqueIndexes
+	}
+	anchors := growAnchors(left, right, pairEqualLines(left, right, findUniqueLines(left), findUniqueLines(right), (*TextLine).CompareText))
+
+	return DiffBetweenAnchors(left, right, anchors, nil, nil, diffFn)
+}
+
+// ------------------------------------------- growAnchors
+
+// Extend each anchor into the run of lines around it with the same compare
+// text, stopping at the neighbouring anchors.  The anchors are Matching links
+// in ascending order, and so is the result.
+func growAnchors(left, right ComparableLines, anchors []Link) []Link {
+	isEqual := func (leftIndex, rightIndex int) bool { return left[leftIndex].CompareText() == right[rightIndex].CompareText() }
+
+	var grown []Link
+	for index, anchor := range anchors {
+
+		// Backwards, as far as the end of the previous anchor's run.
+		leftLimit, rightLimit := 0, 0
+		if len(grown) > 0 {
+			leftLimit, rightLimit = grown[len(grown) - 1].LeftIndex + 1, grown[len(grown) - 1].RightIndex + 1
+		}
+		start := 0
+		for anchor.LeftIndex - start - 1 >= leftLimit && anchor.RightIndex - start - 1 >= rightLimit && isEqual(anchor.LeftIndex - start - 1, anchor.RightIndex - start - 1) {
+			start++
+		}
+		for offset := start; offset > 0; offset-- {
+			grown = append(grown, Link{Matching, anchor.LeftIndex - offset, anchor.RightIndex - offset})
+		}
+		grown = append(grown, anchor)
+
+		// Forwards, as far as the next anchor.
+		leftLimit, rightLimit = len(left), len(right)
+		if index + 1 < len(anchors) {
+			leftLimit, rightLimit = anchors[index + 1].LeftIndex, anchors[index + 1].RightIndex
+		}
+		for offset := 1; anchor.LeftIndex + offset < leftLimit && anchor.RightIndex + offset < rightLimit && isEqual(anchor.LeftIndex + offset, anchor.RightIndex + offset); offset++ {
+			grown = append(grown, Link{Matching, anchor.LeftIndex + offset, anchor.RightIndex + offset})
+		}
+	}
+	return grown
+}
+
+// ------------------------------------------- pairEqualLines
+
+// Pair up the selected left lines with the selected right lines whose text,
+// as "textOf" gives it, is identical, using a classic longest common
+// subsequence.  The pairs are returned as Matching links in ascending order.
+func pairEqualLines(left, right ComparableLines, leftIndexes, rightIndexes []int, textOf func (line *TextLine) string) []Link {
+
+	m, n := len(leftIndexes), len(rightIndexes)
+	matrix := make([]int, (m + 1) * (n + 1))
+	offset := func (i, j int) int { return i * (n + 1) + j }
+	isEqual := func (i, j int) bool { return textOf(left[leftIndexes[i]]) == textOf(right[rightIndexes[j]]) }
+
+	// matrix[i, j] is the LCS length of the suffixes starting at i and j.
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if isEqual(i, j) {
+				matrix[offset(i, j)] = matrix[offset(i + 1, j + 1)] + 1
+			} else if matrix[offset(i + 1, j)] >= matrix[offset(i, j + 1)] {
+				matrix[offset(i, j)] = matrix[offset(i + 1, j)]
+			} else {
+				matrix[offset(i, j)] = matrix[offset(i, j + 1)]
+			}
+		}
+	}
+
+	var pairs []Link
+	for i, j := 0, 0; i < m && j < n; {
+		if isEqual(i, j) {
+			pairs = append(pairs, Link{Matching, leftIndexes[i], rightIndexes[j]})
+			i, j = i + 1, j + 1
+		} else if matrix[offset(i + 1, j)] >= matrix[offset(i, j + 1)] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return pairs
+}
+
+// ------------------------------------------- lineText
+
+// The text of a line as it's shown, for pairing lines which are exactly the same.
+func lineText(line *TextLine) string {
+	return line.Text
+}
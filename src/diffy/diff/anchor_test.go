@@ -0,0 +1,217 @@
+package diff
+
+import (
+	"regexp"
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+// Build a ComparableLines slice from plain strings.
+func makeTestLines(texts ...string) ComparableLines {
+	var lines ComparableLines
+	for _, text := range texts {
+		lines = append(lines, NewTextLine(text))
+	}
+	return lines
+}
+
+// Check that an alignment covers every left and right index exactly once,
+// in ascending order, and report any problems as test errors.
+func checkAlignmentCoverage(t *testing.T, alignment *Alignment, leftLength, rightLength int) {
+	nextLeft, nextRight := 0, 0
+	for _, link := range alignment.Links {
+		if link.LeftIndex >= 0 {
+			if link.LeftIndex != nextLeft {
+				t.Errorf("expected left index %d, got %d", nextLeft, link.LeftIndex)
+			}
+			nextLeft = link.LeftIndex + 1
+		}
+		if link.RightIndex >= 0 {
+			if link.RightIndex != nextRight {
+				t.Errorf("expected right index %d, got %d", nextRight, link.RightIndex)
+			}
+			nextRight = link.RightIndex + 1
+		}
+	}
+	if nextLeft != leftLength || nextRight != rightLength {
+		t.Errorf("alignment covers %d/%d items, expected %d/%d", nextLeft, nextRight, leftLength, rightLength)
+	}
+}
+
+// Find the link referring to the given left index, if any.
+func findLinkForLeft(alignment *Alignment, leftIndex int) (Link, bool) {
+	for _, link := range alignment.Links {
+		if link.LeftIndex == leftIndex {
+			return link, true
+		}
+	}
+	return Link{}, false
+}
+
+var anchorRegexp = regexp.MustCompile(`^// BEGIN `)
+
+func isTestAnchor(line *TextLine) bool {
+	return anchorRegexp.MatchString(line.Text)
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestDiffAnchoredMatchedAnchors
+// -------------------------------------------
+
+func TestDiffAnchoredMatchedAnchors(t *testing.T) {
+
+	// The "shared line" moved from section foo to section bar.  Without
+	// anchors the diff is free to pair it up across the section boundary.
+	left := makeTestLines(
+		"// BEGIN section-foo",
+		"foo := 1",
+		"shared line",
+		"// BEGIN section-bar",
+		"bar := 2",
+	)
+	right := makeTestLines(
+		"// BEGIN section-foo",
+		"foo := 1",
+		"// BEGIN section-bar",
+		"shared line",
+		"bar := 2",
+	)
+
+	_, alignment := DiffAnchored(left, right, isTestAnchor, Diff_v2)
+	checkAlignmentCoverage(t, alignment, len(left), len(right))
+
+	// Both anchors are locked together.
+	for _, anchor := range []Link{{Matching, 0, 0}, {Matching, 3, 2}} {
+		if link, _ := findLinkForLeft(alignment, anchor.LeftIndex); link != anchor {
+			t.Errorf("expected anchor link %v, got %v", anchor, link)
+		}
+	}
+
+	// The shared line must not be aligned across the section boundary.
+	if link, _ := findLinkForLeft(alignment, 2); link.LinkType != LeftOnly {
+		t.Errorf("expected the moved line to be LeftOnly within its section, got %v", link)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestDiffAnchoredMismatchedAnchors
+// -------------------------------------------
+
+func TestDiffAnchoredMismatchedAnchors(t *testing.T) {
+
+	// Section "baz" only exists on the left.
+	left := makeTestLines(
+		"// BEGIN section-foo",
+		"foo := 1",
+		"// BEGIN section-baz",
+		"baz := 3",
+		"// BEGIN section-bar",
+		"bar := 2",
+	)
+	right := makeTestLines(
+		"// BEGIN section-foo",
+		"foo := 1",
+		"// BEGIN section-baz2",
+		"baz := 3",
+		"// BEGIN section-bar",
+		"bar := 2",
+	)
+
+	_, alignment := DiffAnchored(left, right, isTestAnchor, Diff_v2)
+	checkAlignmentCoverage(t, alignment, len(left), len(right))
+
+	// The unpaired anchors are never aligned with anything.
+	if link, _ := findLinkForLeft(alignment, 2); link.LinkType != LeftOnly {
+		t.Errorf("expected the unpaired left anchor to be LeftOnly, got %v", link)
+	}
+	for _, link := range alignment.Links {
+		if link.RightIndex == 2 && link.LinkType != RightOnly {
+			t.Errorf("expected the unpaired right anchor to be RightOnly, got %v", link)
+		}
+	}
+
+	// The content around the unpaired anchors still lines up.
+	if link, _ := findLinkForLeft(alignment, 3); link != (Link{Matching, 3, 3}) {
+		t.Errorf("expected \"baz := 3\" to match, got %v", link)
+	}
+	if link, _ := findLinkForLeft(alignment, 4); link != (Link{Matching, 4, 4}) {
+		t.Errorf("expected the paired bar anchor to match, got %v", link)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestDiffAnchoredNoAnchors
+// -------------------------------------------
+
+func TestDiffAnchoredNoAnchors(t *testing.T) {
+
+	// Without any anchor lines the result is the same as a plain diff.
+	left := makeTestLines("a", "b", "c")
+	right := makeTestLines("a", "c", "d")
+
+	distance, alignment := DiffAnchored(left, right, isTestAnchor, Diff_v2)
+	expectedDistance, expectedAlignment := Diff_v2(left, right)
+
+	if distance != expectedDistance {
+		t.Errorf("expected distance %f, got %f", expectedDistance, distance)
+	}
+	if len(alignment.Links) != len(expectedAlignment.Links) {
+		t.Fatalf("expected %d links, got %d", len(expectedAlignment.Links), len(alignment.Links))
+	}
+	for i := range alignment.Links {
+		if alignment.Links[i] != expectedAlignment.Links[i] {
+			t.Errorf("link %d: expected %v, got %v", i, expectedAlignment.Links[i], alignment.Links[i])
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestDiffUniqueAnchored
+// -------------------------------------------
+
+func TestDiffUniqueAnchored(t *testing.T) {
+
+	// Three functions with identical bodies: the first is deleted and a new
+	// one added at the end.
+	function := func (name string) []string {
+		return []string{"func " + name + "() {", "\tlock.Acquire()", "\tdefer lock.Release()", "\treturn nil", "}", ""}
+	}
+	var leftTexts, rightTexts []string
+	leftTexts = append(append(leftTexts, function("openTheFile")...), function("closeTheFile")...)
+	rightTexts = append(append(rightTexts, function("closeTheFile")...), function("renameTheFile")...)
+	left, right := makeTestLines(leftTexts...), makeTestLines(rightTexts...)
+
+	// A plain diff pairs each function with the wrong one, since the bodies all match.
+	_, alignment := Diff_v2(left, right)
+	if link, _ := findLinkForLeft(alignment, 6); link.RightIndex == 0 {
+		t.Fatalf("Expected the plain diff to misalign the functions, which this test relies on")
+	}
+
+	// Anchored on "func closeTheFile() {", the one function in common lines up with itself.
+	_, alignment = DiffUniqueAnchored(left, right, Diff_v2)
+	checkAlignmentCoverage(t, alignment, len(left), len(right))
+	for leftIndex := 0; leftIndex < len(left); leftIndex++ {
+		link, _ := findLinkForLeft(alignment, leftIndex)
+		if leftIndex < 6 && link.LinkType != LeftOnly {
+			t.Errorf("Expected left line %d, in the deleted function, to be LeftOnly, got %v", leftIndex, link)
+		}
+		if leftIndex >= 6 && link != (Link{Matching, leftIndex, leftIndex - 6}) {
+			t.Errorf("Expected left line %d to match right line %d, got %v", leftIndex, leftIndex - 6, link)
+		}
+	}
+	for _, link := range alignment.Links {
+		if link.RightIndex >= 6 && link.LinkType != RightOnly {
+			t.Errorf("Expected right line %d, in the new function, to be RightOnly, got %v", link.RightIndex, link)
+		}
+	}
+
+	// Lines are unique by their compare text, e.g. with the whitespace ignored.
+	right[0] = NewNormalizedTextLine("func  closeTheFile()  {", rightTexts[0])
+	_, alignment = DiffUniqueAnchored(left, right, Diff_v2)
+	if link, _ := findLinkForLeft(alignment, 6); link != (Link{Matching, 6, 0}) {
+		t.Errorf("Expected the headers to match on their compare text, got %v", link)
+	}
+}
@@ -0,0 +1,275 @@
+package diff
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// "bench_test.go" - Benchmarks and accuracy checks for the similarity machinery.
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+const ACCURACY_CHAR_SET = "abcdefghij ();="
+
+// Generate a random string of "length" runes drawn from "charSet".
+func randomString(rng *rand.Rand, charSet []rune, length int) string {
+	runes := make([]rune, length)
+	for i := range runes {
+		runes[i] = charSet[rng.Intn(len(charSet))]
+	}
+	return string(runes)
+}
+
+// Apply "editCount" random single rune insertions, deletions, and
+// replacements to "s" and return the result.
+func mutateString(rng *rand.Rand, charSet []rune, s string, editCount int) string {
+	runes := []rune(s)
+	for i := 0; i < editCount; i++ {
+		position := 0
+		if len(runes) > 0 {
+			position = rng.Intn(len(runes))
+		}
+		switch op := rng.Intn(3); {
+		case op == 0 || len(runes) == 0:
+			char := charSet[rng.Intn(len(charSet))]
+			runes = append(runes[:position], append([]rune{char}, runes[position:]...)...)
+		case op == 1:
+			runes = append(runes[:position], runes[position + 1:]...)
+		default:
+			runes[position] = charSet[rng.Intn(len(charSet))]
+		}
+	}
+	return string(runes)
+}
+
+// Generate a pseudo source file of "lineCount" lines, along with an edited
+// copy where roughly one line in ten has been changed, inserted, or deleted.
+func generateFilePair(rng *rand.Rand, lineCount int) (ComparableLines, ComparableLines) {
+	charSet := []rune(ACCURACY_CHAR_SET)
+	var leftLines, rightLines ComparableLines
+	for i := 0; i < lineCount; i++ {
+		text := fmt.Sprintf("line%d %s", i, randomString(rng, charSet, 20 + rng.Intn(40)))
+		leftLines = append(leftLines, NewTextLine(text))
+		switch rng.Intn(30) {
+		case 0:
+			rightLines = append(rightLines, NewTextLine(mutateString(rng, charSet, text, 3)))
+		case 1:
+			// deleted line
+		case 2:
+			rightLines = append(rightLines, NewTextLine(text), NewTextLine(randomString(rng, charSet, 30)))
+		default:
+			rightLines = append(rightLines, NewTextLine(text))
+		}
+	}
+	return leftLines, rightLines
+}
+
+// Compute the rank of each value in "values", averaging the ranks of ties.
+func computeRanks(values []float64) []float64 {
+	indexes := make([]int, len(values))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.Slice(indexes, func (a, b int) bool { return values[indexes[a]] < values[indexes[b]] })
+
+	ranks := make([]float64, len(values))
+	for start := 0; start < len(indexes); {
+		end := start + 1
+		for end < len(indexes) && values[indexes[end]] == values[indexes[start]] {
+			end++
+		}
+		averageRank := float64(start + end - 1) / 2.0
+		for k := start; k < end; k++ {
+			ranks[indexes[k]] = averageRank
+		}
+		start = end
+	}
+	return ranks
+}
+
+// Compute Spearman's rank correlation coefficient for two equal length samples.
+func spearmanCorrelation(xs, ys []float64) float64 {
+	xRanks, yRanks := computeRanks(xs), computeRanks(ys)
+	n := float64(len(xs))
+
+	var xMean, yMean float64
+	for i := range xRanks {
+		xMean += xRanks[i] / n
+		yMean += yRanks[i] / n
+	}
+
+	var covariance, xVariance, yVariance float64
+	for i := range xRanks {
+		dx, dy := xRanks[i] - xMean, yRanks[i] - yMean
+		covariance += dx * dy
+		xVariance += dx * dx
+		yVariance += dy * dy
+	}
+	if xVariance == 0 || yVariance == 0 {
+		return 0
+	}
+	return covariance / (math.Sqrt(xVariance) * math.Sqrt(yVariance))
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestLevenshteinSimilarity
+// -------------------------------------------
+
+func TestLevenshteinSimilarity(t *testing.T) {
+
+	testCases := []struct {
+		s, t string
+		similarity float32
+	}{
+		{"", "", 1.0},
+		{"", "abcd", 0.0},
+		{"abcd", "abcd", 1.0},
+		{"abcd", "abce", 0.75},
+		{"cat", "hat", 2.0 / 3.0},
+		{"He’s Alive!", "It’s Alive!", 1.0 - 2.0 / 11.0},
+	}
+
+	for _, testCase := range testCases {
+		similarity := LevenshteinSimilarity(testCase.s, testCase.t)
+		if math.Abs(float64(similarity - testCase.similarity)) > 1e-6 {
+			t.Errorf("LevenshteinSimilarity(%q, %q): got %f, expected %f", testCase.s, testCase.t, similarity, testCase.similarity)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestDiffHashAccuracy
+// -------------------------------------------
+
+// The DiffHash similarity is only an approximation, but it should at least
+// rank pairs of strings in roughly the same order as the exact Levenshtein
+// similarity does.  If a change to the hash makes the two rankings drift
+// apart, alignment quality will silently suffer, so we guard against that
+// with a rank correlation threshold.
+
+const MIN_DIFF_HASH_RANK_CORRELATION = 0.8
+
+func TestDiffHashAccuracy(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1133))
+	charSet := []rune(ACCURACY_CHAR_SET)
+
+	var hashSimilarities, exactSimilarities []float64
+	for i := 0; i < 2000; i++ {
+		s := randomString(rng, charSet, 10 + rng.Intn(50))
+		u := mutateString(rng, charSet, s, rng.Intn(len(s)))
+
+		var diffHashS, diffHashU DiffHash
+		diffHashS.Init(s)
+		diffHashU.Init(u)
+
+		hashSimilarities = append(hashSimilarities, float64(diffHashS.Similarity(diffHashU)))
+		exactSimilarities = append(exactSimilarities, float64(LevenshteinSimilarity(s, u)))
+	}
+
+	correlation := spearmanCorrelation(hashSimilarities, exactSimilarities)
+	t.Logf("DiffHash vs Levenshtein rank correlation: %.3f", correlation)
+	if correlation < MIN_DIFF_HASH_RANK_CORRELATION {
+		t.Errorf("DiffHash vs Levenshtein rank correlation is %.3f, expected at least %.3f",
+					correlation, MIN_DIFF_HASH_RANK_CORRELATION)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- benchmarks
+// -------------------------------------------
+
+func BenchmarkDiff_v2(b *testing.B) {
+	for _, lineCount := range []int{100, 500, 1000} {
+		rng := rand.New(rand.NewSource(int64(lineCount)))
+		leftLines, rightLines := generateFilePair(rng, lineCount)
+		b.Run(fmt.Sprintf("%d-lines", lineCount), func (b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Diff_v2(leftLines, rightLines)
+			}
+		})
+	}
+}
+
+// A TextLine without the identical line fast path in Compare, for comparison.
+type hashOnlyTextLine struct {
+	*TextLine
+}
+
+func (line hashOnlyTextLine) Compare(other Comparable) float32 {
+	return 1.0 - line.Similarity(other.(hashOnlyTextLine).TextLine)
+}
+
+type hashOnlyLines []hashOnlyTextLine
+
+func (lines hashOnlyLines) Length() int                  { return len(lines) }
+func (lines hashOnlyLines) GetItemAt(index int) Comparable { return lines[index] }
+func (lines hashOnlyLines) GetDescription() string       { return "hash only lines" }
+
+func makeHashOnlyLines(lines ComparableLines) hashOnlyLines {
+	wrapped := make(hashOnlyLines, len(lines))
+	for index, line := range lines {
+		wrapped[index] = hashOnlyTextLine{line}
+	}
+	return wrapped
+}
+
+func BenchmarkDiff_v2MostlyIdentical(b *testing.B) {
+
+	// A 2000 line file in which only one line in a hundred has been edited.
+	rng := rand.New(rand.NewSource(1171))
+	charSet := []rune(ACCURACY_CHAR_SET)
+	var leftLines, rightLines ComparableLines
+	for i := 0; i < 2000; i++ {
+		text := fmt.Sprintf("line%d %s", i, randomString(rng, charSet, 20 + rng.Intn(40)))
+		leftLines = append(leftLines, NewTextLine(text))
+		if rng.Intn(100) == 0 {
+			text = mutateString(rng, charSet, text, 3)
+		}
+		rightLines = append(rightLines, NewTextLine(text))
+	}
+
+	hashOnlyLeft, hashOnlyRight := makeHashOnlyLines(leftLines), makeHashOnlyLines(rightLines)
+
+	b.Run("fast-path", func (b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Diff_v2(leftLines, rightLines)
+		}
+	})
+	b.Run("hash-only", func (b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Diff_v2(hashOnlyLeft, hashOnlyRight)
+		}
+	})
+}
+
+func BenchmarkDiffHashSimilarity(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	charSet := []rune(ACCURACY_CHAR_SET)
+	s := randomString(rng, charSet, 60)
+	u := mutateString(rng, charSet, s, 6)
+
+	var diffHashS, diffHashU DiffHash
+	diffHashS.Init(s)
+	diffHashU.Init(u)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diffHashS.Similarity(diffHashU)
+	}
+}
+
+func BenchmarkDiffHashInit(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	s := randomString(rng, []rune(ACCURACY_CHAR_SET), 60)
+
+	var diffHash DiffHash
+	for i := 0; i < b.N; i++ {
+		diffHash.Init(s)
+	}
+}
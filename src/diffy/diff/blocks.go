@@ -0,0 +1,158 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// "blocks.go" - Diffing code files function by function.  Each file is cut
+// into top-level blocks, the blocks are paired up by their signatures, and
+// each pair is diffed on its own, so one function's lines can't be aligned
+// with another's.
+
+// -------------------------------------------
+// ------------------------------------------- type Block
+// -------------------------------------------
+
+// A Block is the lines [Start, End) of a file.
+
+type Block struct {
+	Start, End int
+}
+
+// A BlockStartFunc decides whether "line" starts a new block, given the line
+// before it, which is nil for the first line of the file.
+type BlockStartFunc func(line, previous *TextLine) bool
+
+// ------------------------------------------- IsTopLevelBlockStart
+//
+// The default BlockStartFunc, a brace and indentation heuristic: a block
+// starts at an unindented line, which isn't a closing bracket, following a
+// blank line or a closing bracket.  That's the first line of a function, or
+// of the comment above it, in most brace languages, and in Python.
+//
+func IsTopLevelBlockStart(line, previous *TextLine) bool {
+	isClosing := func (text string) bool {
+		return strings.HasPrefix(text, "}") || strings.HasPrefix(text, ")") || strings.HasPrefix(text, "]")
+	}
+	if line.Text == "" || line.Text != strings.TrimLeft(line.Text, " ") || isClosing(line.Text) {
+		return false
+	}
+	return previous == nil || strings.TrimSpace(previous.Text) == "" || isClosing(previous.Text)
+}
+
+// ------------------------------------------- RegexBlockStart
+
+// A BlockStartFunc for blocks which start at lines matching "pattern".
+func RegexBlockStart(pattern *regexp.Regexp) BlockStartFunc {
+	return func (line, previous *TextLine) bool {
+		return pattern.MatchString(line.Text)
+	}
+}
+
+// ------------------------------------------- FindBlocks
+
+// Cut "lines" into blocks, each running from one block start to the next.
+// Any lines before the first block start are a block of their own.
+func FindBlocks(lines ComparableLines, isBlockStart BlockStartFunc) []Block {
+	var blocks []Block
+	start := 0
+	for index, line := range lines {
+		var previous *TextLine
+		if index > 0 {
+			previous = lines[index - 1]
+		}
+		if index > start && isBlockStart(line, previous) {
+			blocks = append(blocks, Block{start, index})
+			start = index
+		}
+	}
+	if start < len(lines) {
+		blocks = append(blocks, Block{start, len(lines)})
+	}
+	return blocks
+}
+
+// ------------------------------------------- blockSignature
+
+// The line which identifies a block: its first line which isn't blank or a
+// comment, e.g. "func main() {".  Blocks which are nothing but comments are
+// identified by their first line.
+func blockSignature(lines ComparableLines, block Block) *TextLine {
+	for _, line := range lines[block.Start:block.End] {
+		text := strings.TrimSpace(line.Text)
+		isComment := false
+		for _, prefix := range []string{"//", "/*", "*", "#", "--", ";"} {
+			if strings.HasPrefix(text, prefix) {
+				isComment = true
+			}
+		}
+		if text != "" && !isComment {
+			return line
+		}
+	}
+	return lines[block.Start]
+}
+
+// -------------------------------------------
+// ------------------------------------------- DiffBlocks
+// -------------------------------------------
+
+// Diff two files block by block.  The blocks found by "isBlockStart" are
+// paired up by their signatures, keeping them in order, and each pair of
+// blocks is diffed independently with "diffFn".  A block with no partner is
+// deleted or inserted as a whole.
+//
+// Blocks with identical signatures are paired first, just as DiffAnchored
+// pairs anchors.  The blocks left over between those pairs, e.g. functions
+// whose signatures changed, are then paired by how similar their signatures
+// are.  Pairs whose signatures are too dissimilar, by the same
+// DEFAULT_REALIGN_THRESHOLD the display uses, aren't paired.
+//
+// Since blocks stay in order, of two functions which swapped places one is
+// paired and the other is shown deleted in one place and inserted in the
+// other, rather than having its lines paired with the wrong function.
+
+func DiffBlocks(left, right ComparableLines, isBlockStart BlockStartFunc, diffFn DiffFunc) (float32, *Alignment) {
+
+	leftBlocks, rightBlocks := FindBlocks(left, isBlockStart), FindBlocks(right, isBlockStart)
+	signatures := func (lines ComparableLines, blocks []Block) (ComparableLines, []int) {
+		signatures, indexes := make(ComparableLines, len(blocks)), make([]int, len(blocks))
+		for index, block := range blocks {
+			signatures[index], indexes[index] = blockSignature(lines, block), index
+		}
+		return signatures, indexes
+	}
+	leftSignatures, leftIndexes := signatures(left, leftBlocks)
+	rightSignatures, rightIndexes := signatures(right, rightBlocks)
+	anchors := pairEqualLines(leftSignatures, rightSignatures, leftIndexes, rightIndexes, lineText)
+	_, blockAlignment := DiffBetweenAnchors(leftSignatures, rightSignatures, anchors, nil, nil, Diff_v2)
+	blockAlignment = blockAlignment.RealignUsingThreshold(leftSignatures, rightSignatures, DEFAULT_REALIGN_THRESHOLD)
+
+	var totalDistance float32
+	var links []Link
+	for _, blockLink := range blockAlignment.Links {
+		switch blockLink.LinkType {
+		case Matching, Different:
+			leftBlock, rightBlock := leftBlocks[blockLink.LeftIndex], rightBlocks[blockLink.RightIndex]
+			leftSub, rightSub := NewSubSequence(left, leftBlock.Start, leftBlock.End), NewSubSequence(right, rightBlock.Start, rightBlock.End)
+			distance, alignment := diffFn(leftSub, rightSub)
+			totalDistance += distance
+			links = append(links, alignment.Remap(leftSub.Indexes, rightSub.Indexes).Links...)
+		case LeftOnly:
+			for index := leftBlocks[blockLink.LeftIndex].Start; index < leftBlocks[blockLink.LeftIndex].End; index++ {
+				links = append(links, Link{LeftOnly, index, -1})
+				totalDistance += 1.0
+			}
+		case RightOnly:
+			for index := rightBlocks[blockLink.RightIndex].Start; index < rightBlocks[blockLink.RightIndex].End; index++ {
+				links = append(links, Link{RightOnly, -1, index})
+				totalDistance += 1.0
+			}
+		default:
+			panic("not reached")
+		}
+	}
+
+	return totalDistance, &Alignment{Links: links}
+}
@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------- TestFindBlocks
+
+func TestFindBlocks(t *testing.T) {
+	lines := makeTestLines(
+		"package main",
+		"",
+		"// alpha does it",
+		"func alpha() {",
+		"}",
+		"func beta() {",
+		"	if x {",
+		"	}",
+		"}",
+	)
+	blocks := FindBlocks(lines, IsTopLevelBlockStart)
+	if fmt.Sprint(blocks) != "[{0 2} {2 5} {5 9}]" {
+		t.Errorf("Unexpected blocks %v", blocks)
+	}
+	if signature := blockSignature(lines, blocks[1]); signature.Text != "func alpha() {" {
+		t.Errorf("Expected the comment to be skipped in the signature, got %q", signature.Text)
+	}
+
+	blocks = FindBlocks(lines, RegexBlockStart(regexp.MustCompile(`^func `)))
+	if fmt.Sprint(blocks) != "[{0 3} {3 5} {5 9}]" {
+		t.Errorf("Unexpected regex blocks %v", blocks)
+	}
+}
+
+// ------------------------------------------- TestDiffBlocksReordered
+
+func TestDiffBlocksReordered(t *testing.T) {
+
+	// Three similar functions, each with a small edit, and the last one moved
+	// to the front.
+	function := func (name, value string) []string {
+		return []string{
+			"func " + name + "(items []int) int {",
+			"	total := 0",
+			"	for _, item := range items {",
+			"		total += item * " + value,
+			"	}",
+			"	return total",
+			"}",
+			"",
+		}
+	}
+	var leftTexts, rightTexts []string
+	leftTexts = append(leftTexts, "package sums", "")
+	leftTexts = append(leftTexts, function("sumDoubled", "2")...)
+	leftTexts = append(leftTexts, function("sumTripled", "3")...)
+	leftTexts = append(leftTexts, function("sumQuadrupled", "4")...)
+	rightTexts = append(rightTexts, "package sums", "")
+	rightTexts = append(rightTexts, function("sumQuadrupled", "(2 + 2)")...)
+	rightTexts = append(rightTexts, function("sumDoubled", "(1 + 1)")...)
+	rightTexts = append(rightTexts, function("sumTripled", "(1 + 2)")...)
+	left, right := makeTestLines(leftTexts...), makeTestLines(rightTexts...)
+
+	_, alignment := DiffBlocks(left, right, IsTopLevelBlockStart, Diff_v2)
+	checkAlignmentCoverage(t, alignment, len(left), len(right))
+
+	// Which function each line belongs to.
+	functionOf := func (lines ComparableLines, index int) string {
+		for ; index >= 0; index-- {
+			if strings.HasPrefix(lines[index].Text, "func ") {
+				return strings.Fields(lines[index].Text)[1][:strings.Index(strings.Fields(lines[index].Text)[1], "(")]
+			}
+		}
+		return "package"
+	}
+
+	differentCount := map[string]int{}
+	for _, link := range alignment.Links {
+		switch link.LinkType {
+		case Matching, Different:
+			leftFunction, rightFunction := functionOf(left, link.LeftIndex), functionOf(right, link.RightIndex)
+			if leftFunction != rightFunction {
+				t.Errorf("Line %d of %s was paired with line %d of %s", link.LeftIndex + 1, leftFunction, link.RightIndex + 1, rightFunction)
+			}
+			if link.LinkType == Different {
+				differentCount[leftFunction]++
+			}
+		case LeftOnly, RightOnly:
+			if index := link.LeftIndex; index >= 0 && functionOf(left, index) != "sumQuadrupled" {
+				t.Errorf("Expected only the moved function to be deleted, got line %d of %s", index + 1, functionOf(left, index))
+			}
+			if index := link.RightIndex; index >= 0 && functionOf(right, index) != "sumQuadrupled" {
+				t.Errorf("Expected only the moved function to be inserted, got line %d of %s", index + 1, functionOf(right, index))
+			}
+		}
+	}
+
+	// The functions which stayed in order each have just their one edit.
+	if differentCount["sumDoubled"] != 1 || differentCount["sumTripled"] != 1 {
+		t.Errorf("Expected one changed line in each paired function, got %v", differentCount)
+	}
+}
@@ -0,0 +1,109 @@
+package diff
+
+import "math"
+
+// "compact-matrix.go" - Halving the memory Diff_v2 needs when every item
+// either matches or doesn't, which is the case for runes and tokens.
+
+// -------------------------------------------
+// ------------------------------------------- type EqualityOnlySequence
+// -------------------------------------------
+
+// An EqualityOnlySequence is a sequence whose items cost either 0 or 1 to
+// compare: there are no degrees of similarity.  Every edit distance is then a
+// whole number no larger than the total length of the sequences, so it fits
+// in a uint16 when the sequences are short enough.
+
+type EqualityOnlySequence interface {
+	ComparableSequence
+	EqualityOnly() bool
+}
+
+// Assert that EqualityOnlySequence is implemented by the sequences of runes
+// and tokens, and by views onto them.
+var _ EqualityOnlySequence = ComparableString(nil)
+var _ EqualityOnlySequence = ComparableTokens(nil)
+var _ EqualityOnlySequence = (*SubSequence)(nil)
+
+// -------------------------------------------
+
+func isEqualityOnly(seq ComparableSequence) bool {
+	equalityOnly, ok := seq.(EqualityOnlySequence)
+	return ok && equalityOnly.EqualityOnly()
+}
+
+// ------------------------------------------- diff_v2Sized
+
+// The full Diff_v2 matrix computation, with the smallest matrix cells that
+// will do for "s" and "t".  The alignment is the same either way.
+func diff_v2Sized(s, t ComparableSequence) (distance float32, alignment *Alignment) {
+	if isEqualityOnly(s) && isEqualityOnly(t) && s.Length() + t.Length() <= math.MaxUint16 {
+		if distance, alignment, ok := diff_v2Compact(s, t); ok {
+			return distance, alignment
+		}
+	}
+	return diff_v2WithHooks(s, t, nil, nil)
+}
+
+// ------------------------------------------- diff_v2Compact
+
+// Diff_v2's matrix computation with two byte cells.  The sums and minimums
+// of whole numbers are exact in a float32 as well, so the alignment extracted
+// is the one the float32 matrix would give.  If an item turns out to cost
+// something other than 0 or 1 after all, or is weighted, give up and report
+// !ok.
+func diff_v2Compact(s, t ComparableSequence) (distance float32, alignment *Alignment, ok bool) {
+
+	if _, weighted := itemWeights(s); weighted {
+		return 0, nil, false
+	}
+	if _, weighted := itemWeights(t); weighted {
+		return 0, nil, false
+	}
+
+	m, n := s.Length(), t.Length()
+	matrix := make([]uint16, (m + 1) * (n + 1))
+	offset := func (i, j int) int { return i * (n + 1) + j }
+
+	for j := 0; j < n + 1; j++ {
+		matrix[offset(0, j)] = uint16(j)
+	}
+	for i := 1; i < m + 1; i++ {
+		matrix[offset(i, 0)] = uint16(i)
+	}
+
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			var cost uint16
+			switch s.GetItemAt(i).Compare(t.GetItemAt(j)) {
+			case 0.0:
+				cost = 0
+			case 1.0:
+				cost = 1
+			default:
+				return 0, nil, false
+			}
+			matrix[offset(i + 1, j + 1)] = min_uint16_3(
+				matrix[offset(i, j)] + cost,
+				matrix[offset(i, j + 1)] + 1,
+				matrix[offset(i + 1, j)] + 1,
+			)
+		}
+	}
+
+	cell := func (i, j int) float32 { return float32(matrix[offset(i, j)]) }
+	return float32(matrix[offset(m, n)]), backtraceAlignment(s, t, cell, nil), true
+}
+
+// -------------------------------------------
+
+func min_uint16_3(a, b, c uint16) uint16 {
+	min := a
+	if b < min {
+		min = b
+	}
+	if c < min {
+		min = c
+	}
+	return min
+}
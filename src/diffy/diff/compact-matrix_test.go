@@ -0,0 +1,94 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+// A sequence of runes which doesn't admit to being equality only, so it gets
+// the float32 matrix.
+type gradedString struct {
+	ComparableString
+}
+
+func (s gradedString) EqualityOnly() bool {
+	return false
+}
+
+// A sequence of lines which claims to be equality only, but isn't.
+type mislabeledLines struct {
+	ComparableLines
+}
+
+func (lines mislabeledLines) EqualityOnly() bool {
+	return true
+}
+
+// Generate a random string of about "length" runes and a mutated copy.
+func generateStringPair(rng *rand.Rand, length int) (ComparableString, ComparableString) {
+	charSet := []rune(ACCURACY_CHAR_SET)
+	s := randomString(rng, charSet, length)
+	return MakeComparableString(s), MakeComparableString(mutateString(rng, charSet, s, length / 10))
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestDiff_v2Compact
+// -------------------------------------------
+
+func TestDiff_v2Compact(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1197))
+	for trial := 0; trial < 20; trial++ {
+		s, u := generateStringPair(rng, 1 + rng.Intn(300))
+
+		// The compact matrix gives exactly the alignment the float32 one does.
+		distance, alignment := Diff_v2(s, u)
+		expectedDistance, expectedAlignment := Diff_v2WithRowFunc(s, u, nil)
+		if distance != expectedDistance || fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+			t.Errorf("Trial %d: the compact matrix changed the alignment of %q and %q", trial, string(s), string(u))
+		}
+
+		// And so do the tokens.
+		tokens, otherTokens := ComparableTokens(Tokenize(string(s))), ComparableTokens(Tokenize(string(u)))
+		distance, alignment = Diff_v2(tokens, otherTokens)
+		expectedDistance, expectedAlignment = Diff_v2WithRowFunc(tokens, otherTokens, nil)
+		if distance != expectedDistance || fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+			t.Errorf("Trial %d: the compact matrix changed the token alignment", trial)
+		}
+	}
+
+	// A sequence whose items turn out to have degrees of similarity after all
+	// falls back on the float32 matrix.
+	left, right := generateFilePair(rng, 50)
+	distance, alignment := Diff_v2(mislabeledLines{left}, mislabeledLines{right})
+	expectedDistance, expectedAlignment := Diff_v2WithRowFunc(left, right, nil)
+	if distance != expectedDistance || fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+		t.Errorf("Expected graded lines to get the float32 matrix")
+	}
+}
+
+// ------------------------------------------- BenchmarkDiff_v2Compact
+
+// Compare the memory used by the two kinds of matrix; run with -benchmem.
+func BenchmarkDiff_v2Compact(b *testing.B) {
+	rng := rand.New(rand.NewSource(1197))
+	s, u := generateStringPair(rng, 2000)
+
+	b.Run("uint16", func (b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Diff_v2(s, u)
+		}
+	})
+	b.Run("float32", func (b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Diff_v2(gradedString{s}, gradedString{u})
+		}
+	})
+}
@@ -9,6 +9,50 @@ type Comparable interface {
 	Stringify(maxWidth int) string
 }
 
+// -------------------------------------------
+
+// A WeightedComparable costs Weight() to insert or delete, rather than 1.
+
+type WeightedComparable interface {
+	Comparable
+	Weight() float32
+}
+
+// Assert that WeightedComparable is implemented by TextLine.
+var _ WeightedComparable = (*TextLine)(nil)
+
+// The cost of inserting or deleting "item".
+func itemWeight(item Comparable) float32 {
+	if weighted, ok := item.(WeightedComparable); ok {
+		return weighted.Weight()
+	}
+	return 1.0
+}
+
+// The cost of pairing up "sItem" with "tItem", whose weights are "sWeight"
+// and "tWeight".  Replacing a heavy item with something else should cost as
+// much as deleting it, or else the heavy item is simply paired up with
+// whatever is in its way, so the cost is scaled by the larger weight.
+func substitutionCost(sItem, tItem Comparable, sWeight, tWeight float32) float32 {
+	cost := sItem.Compare(tItem)
+	if tWeight > sWeight {
+		return cost * tWeight
+	}
+	return cost * sWeight
+}
+
+// The cost of inserting or deleting each item of "seq", and whether any of
+// them costs something other than 1.
+func itemWeights(seq ComparableSequence) ([]float32, bool) {
+	weights := make([]float32, seq.Length())
+	weighted := false
+	for index := range weights {
+		weights[index] = itemWeight(seq.GetItemAt(index))
+		weighted = weighted || weights[index] != 1.0
+	}
+	return weights, weighted
+}
+
 // -------------------------------------------
 // -------------------------------------------
 // -------------------------------------------
@@ -76,4 +120,11 @@ func (s ComparableString) GetDescription() string {
 	return string(s)
 }
 
+// ------------------------------------------- ComparableString EqualityOnly method
+
+// Runes are either equal or not.
+func (s ComparableString) EqualityOnly() bool {
+	return true
+}
+
 
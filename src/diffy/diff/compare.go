@@ -0,0 +1,430 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"diffy/etc"
+)
+
+// "compare.go" - The one-call interface for library users: diff two slices of
+// strings, or two byte slices, and get back a result which knows how to describe itself as hunks,
+// an edit script, or a unified diff, without having to interpret Links.
+
+// -------------------------------------------
+// ------------------------------------------- type DiffResult
+// -------------------------------------------
+
+type DiffResult struct {
+	Left, Right ComparableLines
+	Distance float32
+	Alignment *Alignment
+	LeftMissingNewline, RightMissingNewline bool	// does the text end without a newline?  (only from CompareBytes)
+}
+
+// The number of unchanged lines shown around each change in a hunk, as in
+// "diff -u".
+const DEFAULT_CONTEXT = 3
+
+// The number of unchanged lines shown before each change in a hunk, and after
+// it, for reading with more context on one side than the other.  A run of
+// fewer than "MinMatchRun" unchanged lines between two changes doesn't split
+// them into two hunks, however little context there is, so that a "}" or a
+// blank line which happens to match doesn't fragment a change.
+type HunkContext struct {
+	Before, After int
+	MinMatchRun int
+}
+
+var DefaultHunkContext = HunkContext{Before: DEFAULT_CONTEXT, After: DEFAULT_CONTEXT}
+
+// -------------------------------------------
+// ------------------------------------------- Compare
+// -------------------------------------------
+
+// Diff two slices of lines.  The lines are processed as if they had been read
+// from files with the same options, so tabs are expanded and so on.  Pairs of
+// lines which are too dissimilar to be considered changed versions of each
+// other are treated as a deletion and an insertion.
+
+func Compare(left, right []string, opts Options) *DiffResult {
+
+	makeLines := func (texts []string) ComparableLines {
+		lines := make(ComparableLines, len(texts))
+		nextLine := newLineMaker(opts)
+		for index, text := range texts {
+			lines[index] = nextLine(text)
+		}
+		return lines
+	}
+
+	result := &DiffResult{Left: makeLines(left), Right: makeLines(right)}
+	result.Distance, result.Alignment = Diff_v2(result.Left, result.Right)
+	result.Alignment = result.Alignment.RealignUsingThreshold(result.Left, result.Right, DEFAULT_REALIGN_THRESHOLD)
+	return result
+}
+
+// ------------------------------------------- CompareBytes
+
+// Like Compare, for text in memory such as a test's generated output, which
+// is split into lines just as ReadLines splits a file, after decoding it as
+// its byte order mark says, if it has one.  A side which doesn't end with a
+// newline is marked as missing it, and its last line is a change if the
+// other side's isn't missing it too, just as in a patch.
+
+func CompareBytes(left, right []byte, opts Options) *DiffResult {
+
+	readBytes := func (content []byte) (ComparableLines, bool) {
+		text, _, err := etc.DecodeText(content, "")
+		if err != nil {
+			text = string(content)		// UTF-16 with an odd number of bytes, which is better compared as it is
+		}
+		lines, finalNewline, _ := ReadLines(strings.NewReader(text), opts)	// reading from memory can't fail
+		return lines, finalNewline
+	}
+
+	result := &DiffResult{}
+	var leftFinalNewline, rightFinalNewline bool
+	result.Left, leftFinalNewline = readBytes(left)
+	result.Right, rightFinalNewline = readBytes(right)
+	result.LeftMissingNewline, result.RightMissingNewline = !leftFinalNewline, !rightFinalNewline
+	result.Distance, result.Alignment = Diff_v2(result.Left, result.Right)
+	result.Alignment = result.Alignment.RealignUsingThreshold(result.Left, result.Right, DEFAULT_REALIGN_THRESHOLD)
+	result.Alignment = MarkFinalNewlineChange(result.Alignment, leftFinalNewline, rightFinalNewline)
+	return result
+}
+
+// ------------------------------------------- DiffResult Similarity method
+
+// Similarity is between 0.0 (nothing in common) and 1.0 (identical).
+func (result *DiffResult) Similarity() float32 {
+	return SequenceSimilarity(result.Distance, len(result.Left), len(result.Right))
+}
+
+// -------------------------------------------
+// ------------------------------------------- type Hunk
+// -------------------------------------------
+
+// A Hunk is a run of changes along with some unchanged lines of context on
+// either side.  The starts are zero-based line indexes, and the counts may be
+// zero, e.g. for a hunk which only inserts lines into an empty file.
+
+type Hunk struct {
+	LeftStart, LeftCount int
+	RightStart, RightCount int
+	Links []Link
+}
+
+// ------------------------------------------- DiffResult Hunks method
+
+// Group the changes into hunks with DEFAULT_CONTEXT lines of context.
+func (result *DiffResult) Hunks() []Hunk {
+	return result.HunksWithContext(DEFAULT_CONTEXT)
+}
+
+// ------------------------------------------- DiffResult HunksWithContext method
+
+// Group the changes into hunks with "context" lines of context.  Changes
+// which are close enough together that their context would touch or overlap
+// share a single hunk.
+func (result *DiffResult) HunksWithContext(context int) []Hunk {
+	return GroupHunks(result.Alignment, context)
+}
+
+// ------------------------------------------- GroupHunks
+
+// Group the changes of an alignment into hunks with "context" links of
+// context around each change.
+func GroupHunks(alignment *Alignment, context int) []Hunk {
+	return GroupHunksWithContext(alignment, HunkContext{Before: context, After: context})
+}
+
+// ------------------------------------------- GroupHunksWithContext
+
+// Like GroupHunks, with "context.Before" links of context before each change
+// and "context.After" after it.  Changes share a hunk when the context after
+// one would touch or overlap the context before the next, or when there are
+// fewer than "context.MinMatchRun" matching links between them.
+func GroupHunksWithContext(alignment *Alignment, context HunkContext) []Hunk {
+
+	links := alignment.Links
+	var hunks []Hunk
+
+	for start := 0; start < len(links); {
+
+		// Find the next change.
+		if links[start].LinkType == Matching {
+			start++
+			continue
+		}
+
+		// Extend the hunk until there are more matching links in a row than both
+		// contexts, and at least the minimum run.
+		end := start + 1
+		for matchingRun := 0; end < len(links); end++ {
+			if links[end].LinkType == Matching {
+				matchingRun++
+				if matchingRun > context.Before + context.After && matchingRun >= context.MinMatchRun {
+					break
+				}
+			} else {
+				matchingRun = 0
+			}
+		}
+		for end > start && links[end - 1].LinkType == Matching {
+			end--
+		}
+
+		// Add the context.
+		hunkStart, hunkEnd := start - context.Before, end + context.After
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		if hunkEnd > len(links) {
+			hunkEnd = len(links)
+		}
+		hunks = append(hunks, makeHunk(links, hunkStart, hunkEnd))
+		start = end
+	}
+
+	return hunks
+}
+
+// ------------------------------------------- makeHunk
+
+func makeHunk(links []Link, start, end int) Hunk {
+	hunk := Hunk{Links: links[start:end]}
+
+	// A side with no lines in the hunk starts where its next line would be.
+	hunk.LeftStart, hunk.RightStart = -1, -1
+	for _, link := range links[:end] {
+		if link.LeftIndex >= 0 {
+			hunk.LeftStart = link.LeftIndex
+		}
+		if link.RightIndex >= 0 {
+			hunk.RightStart = link.RightIndex
+		}
+	}
+	for _, link := range hunk.Links {
+		if link.LeftIndex >= 0 {
+			hunk.LeftCount++
+		}
+		if link.RightIndex >= 0 {
+			hunk.RightCount++
+		}
+	}
+	hunk.LeftStart = hunk.LeftStart - hunk.LeftCount + 1
+	hunk.RightStart = hunk.RightStart - hunk.RightCount + 1
+	return hunk
+}
+
+// -------------------------------------------
+// ------------------------------------------- type Edit
+// -------------------------------------------
+
+// An Edit replaces the left lines [LeftStart, LeftEnd) with the right lines
+// [RightStart, RightEnd).  Either range may be empty, for a pure insertion or
+// a pure deletion.
+
+type EditOp int
+
+const (
+	EditDelete EditOp = iota	// left lines are removed
+	EditInsert					// right lines are added
+	EditReplace					// left lines are replaced by right lines
+)
+
+type Edit struct {
+	Op EditOp
+	LeftStart, LeftEnd int
+	RightStart, RightEnd int
+}
+
+// ------------------------------------------- DiffResult EditScript method
+
+// The edits which turn the left lines into the right lines, in order.  Each
+// maximal run of changed lines is one edit.
+func (result *DiffResult) EditScript() []Edit {
+
+	var edits []Edit
+	links := result.Alignment.Links
+	leftNext, rightNext := 0, 0
+
+	for start := 0; start < len(links); {
+		if links[start].LinkType == Matching {
+			leftNext, rightNext = links[start].LeftIndex + 1, links[start].RightIndex + 1
+			start++
+			continue
+		}
+		edit := Edit{LeftStart: leftNext, LeftEnd: leftNext, RightStart: rightNext, RightEnd: rightNext}
+		end := start
+		for ; end < len(links) && links[end].LinkType != Matching; end++ {
+			if links[end].LeftIndex >= 0 {
+				edit.LeftEnd = links[end].LeftIndex + 1
+			}
+			if links[end].RightIndex >= 0 {
+				edit.RightEnd = links[end].RightIndex + 1
+			}
+		}
+		switch {
+		case edit.LeftStart == edit.LeftEnd:
+			edit.Op = EditInsert
+		case edit.RightStart == edit.RightEnd:
+			edit.Op = EditDelete
+		default:
+			edit.Op = EditReplace
+		}
+		edits = append(edits, edit)
+		leftNext, rightNext = edit.LeftEnd, edit.RightEnd
+		start = end
+	}
+
+	return edits
+}
+
+// ------------------------------------------- DiffResult Reverse method
+
+// The result of diffing the other way around, right to left, so that its edit
+// script and unified diff undo this one's, as with "patch -R".
+func (result *DiffResult) Reverse() *DiffResult {
+	return &DiffResult{Left: result.Right, Right: result.Left, Distance: result.Distance, Alignment: result.Alignment.Swap(),
+		LeftMissingNewline: result.RightMissingNewline, RightMissingNewline: result.LeftMissingNewline}
+}
+
+// ------------------------------------------- DiffResult UnifiedString method
+
+// The hunks in the style of "diff -u", without the "---" and "+++" file
+// headers.  Identical inputs give the empty string.  Within each run of
+// changes, the removed lines come before the added lines.
+func (result *DiffResult) UnifiedString() string {
+	var builder strings.Builder
+	for _, hunk := range result.Hunks() {
+		builder.WriteString(FormatUnifiedHunkWithNewlines(hunk, result.Left, result.Right, !result.LeftMissingNewline, !result.RightMissingNewline))
+	}
+	return builder.String()
+}
+
+// ------------------------------------------- FormatUnifiedHunk
+
+// Format one hunk in the style of "diff -u", showing the raw text of each line.
+func FormatUnifiedHunk(hunk Hunk, left, right ComparableLines) string {
+	return FormatUnifiedHunkWithNewlines(hunk, left, right, true, true)
+}
+
+// ------------------------------------------- FormatUnifiedHunkWithNewlines
+
+// Like FormatUnifiedHunk, for files which may not end with a newline.  As in
+// GNU diff and git, the last line of a file without one is followed by "\ No
+// newline at end of file", whether it's removed, added, or context.  Context
+// can only be shared when both files are missing the newline; the caller has
+// to make the line a change when just one of them is (see
+// MarkFinalNewlineChange).
+func FormatUnifiedHunkWithNewlines(hunk Hunk, left, right ComparableLines, leftFinalNewline, rightFinalNewline bool) string {
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "@@ -%s +%s @@\n",
+		formatUnifiedRange(hunk.LeftStart, hunk.LeftCount), formatUnifiedRange(hunk.RightStart, hunk.RightCount))
+
+	rawText := func (line *TextLine) string {
+		if line.RawText != "" {
+			return line.RawText
+		}
+		return line.Text
+	}
+
+	// Each line keeps the ending it was read with, so a patch of a file with
+	// CRLF line endings applies to it.  The marker goes after the last line of
+	// a file with no final newline.
+	writeLine := func (prefix string, line *TextLine, missingNewline bool) {
+		ending := line.LineEnding
+		if !strings.HasSuffix(ending, "\n") {
+			ending += "\n"
+		}
+		builder.WriteString(prefix + rawText(line) + ending)
+		if missingNewline {
+			builder.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	leftMissingNewline := func (index int) bool { return !leftFinalNewline && index == len(left) - 1 }
+	rightMissingNewline := func (index int) bool { return !rightFinalNewline && index == len(right) - 1 }
+
+	var removed, added []int
+	flush := func () {
+		for _, index := range removed {
+			writeLine("-", left[index], leftMissingNewline(index))
+		}
+		for _, index := range added {
+			writeLine("+", right[index], rightMissingNewline(index))
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	for _, link := range hunk.Links {
+		if link.LinkType == Matching {
+			flush()
+			writeLine(" ", left[link.LeftIndex], leftMissingNewline(link.LeftIndex) || rightMissingNewline(link.RightIndex))
+			continue
+		}
+		if link.LeftIndex >= 0 {
+			removed = append(removed, link.LeftIndex)
+		}
+		if link.RightIndex >= 0 {
+			added = append(added, link.RightIndex)
+		}
+	}
+	flush()
+
+	return builder.String()
+}
+
+// ------------------------------------------- MarkFinalNewlineChange
+
+// When only one of two files ends with a newline, a last line with the same
+// text on both sides still differs in a patch.  Return the alignment with any
+// Matching link that pairs a line missing its newline with one that isn't
+// made Different, so it shows up as a change.  Otherwise return the
+// alignment as it is.
+func MarkFinalNewlineChange(alignment *Alignment, leftFinalNewline, rightFinalNewline bool) *Alignment {
+	if leftFinalNewline == rightFinalNewline {
+		return alignment
+	}
+	leftLast, rightLast := -1, -1
+	for _, link := range alignment.Links {
+		if link.LeftIndex > leftLast {
+			leftLast = link.LeftIndex
+		}
+		if link.RightIndex > rightLast {
+			rightLast = link.RightIndex
+		}
+	}
+
+	var newLinks []Link
+	for index, link := range alignment.Links {
+		leftMissing := !leftFinalNewline && link.LeftIndex == leftLast
+		rightMissing := !rightFinalNewline && link.RightIndex == rightLast
+		if link.LinkType == Matching && leftMissing != rightMissing {
+			if newLinks == nil {
+				newLinks = append([]Link(nil), alignment.Links...)
+			}
+			newLinks[index].LinkType = Different
+		}
+	}
+	if newLinks == nil {
+		return alignment
+	}
+	return &Alignment{Links: newLinks}
+}
+
+// ------------------------------------------- formatUnifiedRange
+
+// Line numbers are one-based.  An empty range is given as the line before it,
+// and a single line range has no count, just like GNU diff.
+func formatUnifiedRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start + 1, count)
+}
@@ -0,0 +1,328 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"diffy/etc"
+)
+
+// ------------------------------------------- helper functions
+
+// Apply an edit script to "left" and return the result.
+func applyEdits(left, right []string, edits []Edit) []string {
+	var result []string
+	leftNext := 0
+	for _, edit := range edits {
+		result = append(result, left[leftNext:edit.LeftStart]...)
+		result = append(result, right[edit.RightStart:edit.RightEnd]...)
+		leftNext = edit.LeftEnd
+	}
+	return append(result, left[leftNext:]...)
+}
+
+// ------------------------------------------- TestCompareIdentical
+
+func TestCompareIdentical(t *testing.T) {
+	lines := []string{"one", "two", "three"}
+	result := Compare(lines, lines, Options{})
+
+	if result.Similarity() != 1.0 {
+		t.Errorf("Expected a similarity of 1, got %v", result.Similarity())
+	}
+	if len(result.Hunks()) != 0 || len(result.EditScript()) != 0 || result.UnifiedString() != "" {
+		t.Errorf("Expected no hunks, edits, or unified diff, got %v %v %q", result.Hunks(), result.EditScript(), result.UnifiedString())
+	}
+}
+
+// ------------------------------------------- TestCompareFullyDifferent
+
+func TestCompareFullyDifferent(t *testing.T) {
+	left := []string{"alpha", "bravo"}
+	right := []string{"1234567", "89012345", "67890"}
+	result := Compare(left, right, Options{})
+
+	if result.Similarity() != 0.0 {
+		t.Errorf("Expected a similarity of 0, got %v", result.Similarity())
+	}
+
+	edits := result.EditScript()
+	if len(edits) != 1 || edits[0] != (Edit{EditReplace, 0, 2, 0, 3}) {
+		t.Errorf("Expected a single replacement of everything, got %v", edits)
+	}
+
+	expected := "@@ -1,2 +1,3 @@\n-alpha\n-bravo\n+1234567\n+89012345\n+67890\n"
+	if unified := result.UnifiedString(); unified != expected {
+		t.Errorf("Expected unified diff\n%s\ngot\n%s", expected, unified)
+	}
+
+	// Against nothing at all.
+	result = Compare(nil, right, Options{})
+	if unified := result.UnifiedString(); !strings.HasPrefix(unified, "@@ -0,0 +1,3 @@\n") {
+		t.Errorf("Expected an insertion into an empty file, got\n%s", unified)
+	}
+}
+
+// ------------------------------------------- TestComparePartialOverlap
+
+func TestComparePartialOverlap(t *testing.T) {
+
+	var left []string
+	for letter := 'a'; letter <= 't'; letter++ {
+		left = append(left, strings.Repeat(string(letter), 12))
+	}
+	right := append([]string(nil), left...)
+	right[1] = left[1] + "!"					// a change near the start
+	right = append(right[:15], right[16:]...)	// line 16 deleted
+	right = append(right, "a brand new line")	// and one added at the end
+
+	result := Compare(left, right, Options{})
+
+	similarity := result.Similarity()
+	if similarity <= 0.8 || similarity >= 1.0 {
+		t.Errorf("Expected a high but imperfect similarity, got %v", similarity)
+	}
+
+	edits := result.EditScript()
+	if fmt.Sprint(applyEdits(left, right, edits)) != fmt.Sprint(right) {
+		t.Errorf("Applying the edit script didn't reproduce the right side: %v", edits)
+	}
+	expectedOps := []EditOp{EditReplace, EditDelete, EditInsert}
+	if len(edits) != len(expectedOps) {
+		t.Fatalf("Expected %d edits, got %v", len(expectedOps), edits)
+	}
+	for i, edit := range edits {
+		if edit.Op != expectedOps[i] {
+			t.Errorf("Edit %d: expected op %v, got %v", i, expectedOps[i], edit.Op)
+		}
+	}
+
+	// The deletion at line 16 and the insertion after line 20 share a hunk.
+	hunks := result.Hunks()
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(hunks))
+	}
+	if hunks[0].LeftStart != 0 || hunks[0].LeftCount != 5 || hunks[0].RightStart != 0 || hunks[0].RightCount != 5 {
+		t.Errorf("Unexpected first hunk %+v", hunks[0])
+	}
+	if hunks[1].LeftStart != 12 || hunks[1].LeftCount != 8 || hunks[1].RightStart != 12 || hunks[1].RightCount != 8 {
+		t.Errorf("Unexpected second hunk %+v", hunks[1])
+	}
+
+	unified := result.UnifiedString()
+	for _, expected := range []string{
+		"@@ -1,5 +1,5 @@\n aaaaaaaaaaaa\n-bbbbbbbbbbbb\n+bbbbbbbbbbbb!\n cccccccccccc\n",
+		"@@ -13,8 +13,8 @@\n",
+		"-pppppppppppp\n",
+		" tttttttttttt\n+a brand new line\n",
+	} {
+		if !strings.Contains(unified, expected) {
+			t.Errorf("Expected the unified diff to contain %q, got\n%s", expected, unified)
+		}
+	}
+
+	// No context splits everything up.
+	if hunks := result.HunksWithContext(0); len(hunks) != 3 {
+		t.Errorf("Expected 3 hunks without context, got %d", len(hunks))
+	}
+}
+
+// ------------------------------------------- TestCompareNormalizeTypography
+
+func TestCompareNormalizeTypography(t *testing.T) {
+	left := []string{"He’s Alive!", "“Quoted” — and ‘single’…", "unchanged"}
+	right := []string{"He's Alive!", "\"Quoted\" - and 'single'...", "unchanged"}
+
+	result := Compare(left, right, Options{NormalizeTypography: true})
+	for _, link := range result.Alignment.Links {
+		if link.LinkType != Matching {
+			t.Errorf("Expected every line to match, got %v", result.Alignment.Links)
+			break
+		}
+	}
+
+	// The original text is kept for display.
+	if result.Left[0].Text != "He’s Alive!" || result.Right[0].Text != "He's Alive!" {
+		t.Errorf("Expected the original text, got %q and %q", result.Left[0].Text, result.Right[0].Text)
+	}
+
+	// Without normalizing, the punctuation is a change.
+	result = Compare(left, right, Options{})
+	if result.Similarity() == 1.0 {
+		t.Errorf("Expected the typographic punctuation to count as a change")
+	}
+}
+
+// ------------------------------------------- TestCompareIgnoreComments
+
+func TestCompareIgnoreComments(t *testing.T) {
+	left := []string{"x = 1;  // one", "/* old", "   notes */", "y = 2;", "z = 3; /* three */"}
+	right := []string{"x = 1;  // uno", "/* new", "   remarks */", "y = 2; // two", "z = 4; /* three */"}
+
+	syntax, err := etc.ParseCommentSyntax("//,/* */")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	result := Compare(left, right, Options{CommentSyntax: syntax})
+
+	// Only the change to the code counts.
+	expected := []LinkType{Matching, Matching, Matching, Matching, Different}
+	if len(result.Alignment.Links) != len(expected) {
+		t.Fatalf("Expected %d links, got %v", len(expected), result.Alignment.Links)
+	}
+	for index, link := range result.Alignment.Links {
+		if link.LinkType != expected[index] {
+			t.Errorf("Link %d: expected %v, got %v", index, expected[index], link)
+		}
+	}
+
+	// The comments are still there for display.
+	if result.Right[3].Text != "y = 2; // two" {
+		t.Errorf("Expected the original text, got %q", result.Right[3].Text)
+	}
+
+	// Without a comment syntax, the comments are changes too.
+	result = Compare(left, right, Options{})
+	if result.Alignment.Links[0].LinkType == Matching {
+		t.Errorf("Expected the comment change to count without a comment syntax")
+	}
+}
+
+// ------------------------------------------- TestCompareIgnoreStringLiterals
+
+func TestCompareIgnoreStringLiterals(t *testing.T) {
+	left := []string{`log("starting up")`, `name := "x"`, `f("a", 1)`}
+	right := []string{`log("Starting the server...")`, `label := "x"`, `f("b", 2)`}
+
+	syntax, err := etc.ParseQuoteSyntax(`"`)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	result := Compare(left, right, Options{StringLiterals: syntax})
+
+	// Only the changes outside the strings count.
+	expected := []LinkType{Matching, Different, Different}
+	if len(result.Alignment.Links) != len(expected) {
+		t.Fatalf("Expected %d links, got %v", len(expected), result.Alignment.Links)
+	}
+	for index, link := range result.Alignment.Links {
+		if link.LinkType != expected[index] {
+			t.Errorf("Link %d: expected %v, got %v", index, expected[index], link)
+		}
+	}
+
+	// The strings are still there for display.
+	if result.Right[0].Text != `log("Starting the server...")` {
+		t.Errorf("Expected the original text, got %q", result.Right[0].Text)
+	}
+
+	// Without a quote syntax, the strings' contents are changes too.
+	result = Compare(left, right, Options{})
+	if result.Alignment.Links[0].LinkType == Matching {
+		t.Errorf("Expected the string change to count without a quote syntax")
+	}
+}
+
+// ------------------------------------------- TestCompareReverse
+
+func TestCompareReverse(t *testing.T) {
+	left := []string{"one", "two", "three", "four", "five"}
+	right := []string{"one", "2", "three", "five", "six"}
+
+	result := Compare(left, right, Options{})
+	reversed := result.Reverse()
+
+	// Applying the diff and then its reverse gets back to the start.
+	applied := applyEdits(left, right, result.EditScript())
+	if fmt.Sprint(applyEdits(applied, left, reversed.EditScript())) != fmt.Sprint(left) {
+		t.Errorf("Applying the reversed edit script didn't restore the left side: %v", reversed.EditScript())
+	}
+
+	// The unified diff has its "+" and "-" lines swapped.
+	if !strings.Contains(reversed.UnifiedString(), "-2\n+two\n") {
+		t.Errorf("Expected the changed line to be changed back, got\n%s", reversed.UnifiedString())
+	}
+}
+
+// ------------------------------------------- TestGroupHunksWithContext
+
+func TestGroupHunksWithContext(t *testing.T) {
+
+	// Changes at lines 6 and 11, with four unchanged lines between them.
+	var left, right []string
+	for index := 1; index <= 16; index++ {
+		left = append(left, fmt.Sprintf("line %d", index))
+		right = append(right, fmt.Sprintf("line %d", index))
+	}
+	right[5], right[10] = "changed 6", "changed 11"
+	result := Compare(left, right, Options{})
+
+	testCases := []struct {
+		context HunkContext
+		expected []string		// each hunk's left lines, "start,count", 1-based
+	}{
+		{DefaultHunkContext, []string{"3,12"}},
+		{HunkContext{Before: 2, After: 1}, []string{"4,4", "9,4"}},
+		{HunkContext{Before: 1, After: 2}, []string{"5,4", "10,4"}},
+		{HunkContext{Before: 2, After: 2}, []string{"4,10"}},
+		{HunkContext{Before: 0, After: 0}, []string{"6,1", "11,1"}},
+		{HunkContext{Before: 5, After: 0}, []string{"1,11"}},
+		{HunkContext{Before: 0, After: 9}, []string{"6,11"}},
+		{HunkContext{Before: 0, After: 0, MinMatchRun: 5}, []string{"6,6"}},		// the four lines between are too few to split
+		{HunkContext{Before: 0, After: 0, MinMatchRun: 4}, []string{"6,1", "11,1"}},
+		{HunkContext{Before: 3, After: 3, MinMatchRun: 20}, []string{"3,12"}},		// the context is unaffected
+	}
+	for _, testCase := range testCases {
+		var got []string
+		for _, hunk := range GroupHunksWithContext(result.Alignment, testCase.context) {
+			got = append(got, fmt.Sprintf("%d,%d", hunk.LeftStart + 1, hunk.LeftCount))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(testCase.expected) {
+			t.Errorf("%+v: expected hunks %v, got %v", testCase.context, testCase.expected, got)
+		}
+	}
+
+	// The same context either side is what GroupHunks gives.
+	if fmt.Sprint(GroupHunksWithContext(result.Alignment, HunkContext{Before: 1, After: 1})) != fmt.Sprint(GroupHunks(result.Alignment, 1)) {
+		t.Errorf("Expected GroupHunks to keep the same context either side")
+	}
+}
+
+// ------------------------------------------- TestCompareBytes
+
+func TestCompareBytes(t *testing.T) {
+
+	testCases := []struct {
+		name string
+		left, right string
+		unified string
+	}{
+		{"identical", "one\ntwo\n", "one\ntwo\n", ""},
+		{"changed", "one\ntwo\nthree\n", "one\n2\nthree\n", "@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"},
+		{"both without a final newline", "one\ntwo", "one\n2", "@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+2\n\\ No newline at end of file\n"},
+		{"one without a final newline", "one\ntwo\n", "one\ntwo", "@@ -1,2 +1,2 @@\n one\n-two\n+two\n\\ No newline at end of file\n"},
+		{"line endings", "one\r\ntwo\r\n", "one\ntwo\n", ""},
+		{"line endings and a change", "one\r\ntwo\r\n", "one\n2\n", "@@ -1,2 +1,2 @@\n one\r\n-two\r\n+2\n"},		// each line keeps its ending
+		{"byte order mark", "\xFF\xFEo\x00n\x00e\x00\n\x00", "one\n", ""},
+		{"empty", "", "one\n", "@@ -0,0 +1 @@\n+one\n"},
+	}
+	for _, testCase := range testCases {
+		result := CompareBytes([]byte(testCase.left), []byte(testCase.right), Options{})
+		if unified := result.UnifiedString(); unified != testCase.unified {
+			t.Errorf("%s: expected\n%s\ngot\n%s", testCase.name, testCase.unified, unified)
+		}
+	}
+
+	// The normalization options apply, as they do to files.
+	result := CompareBytes([]byte("He’s here\n"), []byte("He's here\n"), Options{NormalizeTypography: true})
+	if unified := result.UnifiedString(); unified != "" {
+		t.Errorf("Expected the typography to be normalized, got\n%s", unified)
+	}
+
+	// Reversed, the missing newline moves with its side.
+	reversed := CompareBytes([]byte("one\ntwo\n"), []byte("one\ntwo"), Options{}).Reverse()
+	if expected := "@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+two\n"; reversed.UnifiedString() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, reversed.UnifiedString())
+	}
+}
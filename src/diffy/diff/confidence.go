@@ -0,0 +1,39 @@
+package diff
+
+// "confidence.go" - How sure the alignment is about each pairing, so tools
+// downstream can flag the uncertain ones.
+
+// The confidence of a link with only one side, for which it isn't defined.
+const NO_CONFIDENCE = -1.0
+
+// ------------------------------------------- LinkConfidence
+
+// The confidence of a link is the similarity of the items it pairs, which is
+// the same similarity Diff_v2 and RealignUsingThreshold classified the link
+// with.  A Matching link is 1.0, and a Different one is somewhere below that:
+// a one character typo in a long line is close to 1.0, and a pair of lines
+// which are barely similar enough to stay paired is close to 1.0 minus the
+// realign threshold.  LeftOnly and RightOnly links have NO_CONFIDENCE.
+
+func LinkConfidence(link Link, left, right ComparableSequence) float32 {
+	switch link.LinkType {
+	case Matching:
+		return 1.0
+	case Different:
+		return 1.0 - left.GetItemAt(link.LeftIndex).Compare(right.GetItemAt(link.RightIndex))
+	case LeftOnly, RightOnly:
+		return NO_CONFIDENCE
+	}
+	panic("not reached")
+}
+
+// ------------------------------------------- Alignment Confidences method
+
+// The confidence of each link, parallel to the links.
+func (alignment *Alignment) Confidences(left, right ComparableSequence) []float32 {
+	confidences := make([]float32, len(alignment.Links))
+	for index, link := range alignment.Links {
+		confidences[index] = LinkConfidence(link, left, right)
+	}
+	return confidences
+}
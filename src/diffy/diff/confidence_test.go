@@ -0,0 +1,46 @@
+package diff
+
+import (
+	"testing"
+)
+
+// ------------------------------------------- TestLinkConfidence
+
+func TestLinkConfidence(t *testing.T) {
+
+	// Each right line is further from its left partner than the one before.
+	left := ComparableLines{
+		NewTextLine("the quick brown fox jumps over the lazy dog"),
+		NewTextLine("the quick brown fox jumps over the lazy dog"),
+		NewTextLine("the quick brown fox jumps over the lazy dog"),
+	}
+	right := ComparableLines{
+		NewTextLine("the quick brown fox jumps over the lazy dog"),
+		NewTextLine("the quick brown fox jumps over the lazy hog"),
+		NewTextLine("the quick brown cat naps under the lazy dog"),
+	}
+	alignment := &Alignment{Links: []Link{
+		{Matching, 0, 0},
+		{Different, 1, 1},
+		{Different, 2, 2},
+		{LeftOnly, 0, -1},
+		{RightOnly, -1, 0},
+	}}
+	confidences := alignment.Confidences(left, right)
+
+	if confidences[0] != 1.0 {
+		t.Errorf("Expected a matching link to have a confidence of 1, got %v", confidences[0])
+	}
+	if !(confidences[0] > confidences[1] && confidences[1] > confidences[2] && confidences[2] > 0.0) {
+		t.Errorf("Expected the confidence to fall with the similarity, got %v", confidences)
+	}
+	for index, link := range alignment.Links[1:3] {
+		similarity := left[link.LeftIndex].Similarity(right[link.RightIndex])
+		if confidences[index + 1] != similarity {
+			t.Errorf("Link %d: expected the line similarity %v, got %v", index + 1, similarity, confidences[index + 1])
+		}
+	}
+	if confidences[3] != NO_CONFIDENCE || confidences[4] != NO_CONFIDENCE {
+		t.Errorf("Expected one-sided links to have no confidence, got %v", confidences[3:])
+	}
+}
@@ -402,6 +402,48 @@ func LevenshteinDistance_v4(s, t string) int {
 	return matrix[offset(m, n)]
 }
 
+// -------------------------------------------
+// ------------------------------------------- LevenshteinSimilarity
+// -------------------------------------------
+
+// LevenshteinSimilarity converts the exact Levenshtein distance between two
+// strings into a similarity factor between 0.0 and 1.0, on the same scale as
+// DiffHash.Similarity.  It works on runes rather than bytes, and uses the two
+// row trick from LevenshteinDistance_v4.  It is much slower than a DiffHash
+// comparison, but it makes a good yardstick for measuring DiffHash accuracy.
+
+func LevenshteinSimilarity(s, t string) float32 {
+
+	sRunes, tRunes := []rune(s), []rune(t)
+	m, n := len(sRunes), len(tRunes)
+
+	maxLen := m
+	if n > maxLen {
+		maxLen = n
+	}
+	if maxLen == 0 {
+		return 1.0				// the empty string is 100% similar to the empty string!
+	}
+
+	prevRow, currRow := make([]int, n + 1), make([]int, n + 1)
+	for j := 0; j < n + 1; j++ {
+		prevRow[j] = j
+	}
+	for i := 0; i < m; i++ {
+		currRow[0] = i + 1
+		for j := 0; j < n; j++ {
+			cost := 1
+			if sRunes[i] == tRunes[j] {
+				cost = 0
+			}
+			currRow[j + 1] = min_int_3(prevRow[j] + cost, prevRow[j + 1] + 1, currRow[j] + 1)
+		}
+		prevRow, currRow = currRow, prevRow
+	}
+
+	return 1.0 - float32(prevRow[n]) / float32(maxLen)
+}
+
 // -------------------------------------------
 // ------------------------------------------- LevenshteinDistance_v5
 // -------------------------------------------
@@ -536,9 +578,96 @@ func LevenshteinDistance_v6(s, t string) int {
 // -------------------------------------------
 // -------------------------------------------
 
+// Diff_v2 takes a couple of shortcuts before falling back on the full matrix
+// computation, so that e.g. an empty file against a huge one doesn't allocate
+// a huge matrix.  The shortcuts always produce exactly the alignment the full
+// computation would have.
+//
+// Items matching at the end of both sequences are trimmed off before computing
+// the matrix.  That's safe because the alignment is extracted back to front,
+// and always takes a zero-cost diagonal step when there is one.  Items matching
+// at the start can't be trimmed the same way: where there are several equally
+// good alignments, e.g. "abbc" vs "ab", trimming them can change which one we
+// end up with.
+//
+// Sequences of items which are only ever equal or not, like runes and tokens,
+// get a matrix of uint16s instead of float32s, when the sizes allow.  See
+// "compact-matrix.go".
+
 func Diff_v2(s, t ComparableSequence) (distance float32, alignment *Alignment) {
 
-	alignment = new(Alignment)
+	// Weighted items can make it cheaper to pair a trailing item with an
+	// earlier one than with its match, so they don't get any shortcuts, except
+	// when one side is empty and there's nothing to pair anything with.
+	sWeights, sWeighted := itemWeights(s)
+	tWeights, tWeighted := itemWeights(t)
+	if (sWeighted || tWeighted) && s.Length() > 0 && t.Length() > 0 {
+		return diff_v2Sized(s, t)
+	}
+
+	m, n := s.Length(), t.Length()
+	for m > 0 && n > 0 && s.GetItemAt(m - 1).Compare(t.GetItemAt(n - 1)) == 0.0 {
+		m, n = m - 1, n - 1
+	}
+
+	switch {
+	case m == 0 || n == 0:
+		// Everything left over is on one side only, so there's no need for
+		// the matrix.  One link per remaining item, plus one per trimmed item.
+		alignment = &Alignment{Links: make([]Link, 0, n + s.Length())}
+		for i := 0; i < m; i++ {
+			alignment.Links = append(alignment.Links, Link{LeftOnly, i, -1})
+			distance += sWeights[i]
+		}
+		for j := 0; j < n; j++ {
+			alignment.Links = append(alignment.Links, Link{RightOnly, -1, j})
+			distance += tWeights[j]
+		}
+	case m == s.Length():
+		return diff_v2Sized(s, t)
+	default:
+		distance, alignment = diff_v2Sized(NewSubSequence(s, 0, m), NewSubSequence(t, 0, n))
+	}
+
+	// Put back the trimmed matching items.
+	for i, j := m, n; i < s.Length(); i, j = i + 1, j + 1 {
+		alignment.Links = append(alignment.Links, Link{Matching, i, j})
+	}
+	return distance, alignment
+}
+
+// -------------------------------------------
+
+// A MatrixRowFunc is called with each row of the edit distance matrix as soon
+// as the row has been computed.  Row i holds the distances for the first i
+// items of "s" against each prefix of "t".  The row slice is only valid for
+// the duration of the call.
+
+type MatrixRowFunc func(i int, row []float32)
+
+// Diff_v2WithRowFunc is Diff_v2 with a debugging hook for watching the edit
+// distance matrix being filled in.  The hook may be nil.  It always computes
+// the full matrix, skipping Diff_v2's shortcuts, so it also serves as the
+// reference implementation those shortcuts are checked against.
+
+func Diff_v2WithRowFunc(s, t ComparableSequence, rowFn MatrixRowFunc) (distance float32, alignment *Alignment) {
+	return diff_v2WithHooks(s, t, rowFn, nil)
+}
+
+// Diff_v2Explained is Diff_v2 which also records why each link was chosen,
+// for diagnosing surprising alignments.  The explanations are parallel to the
+// alignment's links.  Like Diff_v2WithRowFunc, it always computes the full
+// matrix.
+
+func Diff_v2Explained(s, t ComparableSequence) (distance float32, alignment *Alignment, explanations []LinkExplanation) {
+	distance, alignment = diff_v2WithHooks(s, t, nil, &explanations)
+	return distance, alignment, explanations
+}
+
+// The full Diff_v2 algorithm, with its optional hooks.  When "explanations" is
+// not nil, one explanation per link is appended to it.
+
+func diff_v2WithHooks(s, t ComparableSequence, rowFn MatrixRowFunc, explanations *[]LinkExplanation) (distance float32, alignment *Alignment) {
 
 	// --- compute the edit distance matrix
 
@@ -551,26 +680,53 @@ func Diff_v2(s, t ComparableSequence) (distance float32, alignment *Alignment) {
 	matrix := make([]float32, (m + 1) * (n + 1))	// number of rows * number of columns
 	offset := func (i, j int) int { return i * (n + 1) + j }
 
-	for j := 0; j < n + 1; j++ {
-		matrix[offset(0, j)] = float32(j)
+	// Deleting an item of "s" or inserting one of "t" usually costs 1, but
+	// weighted items can cost more or less.
+	sWeights, _ := itemWeights(s)
+	tWeights, _ := itemWeights(t)
+
+	for j := 1; j < n + 1; j++ {
+		matrix[offset(0, j)] = matrix[offset(0, j - 1)] + tWeights[j - 1]
 	}
 	for i := 1; i < m + 1; i++ {
-		matrix[offset(i, 0)] = float32(i)
+		matrix[offset(i, 0)] = matrix[offset(i - 1, 0)] + sWeights[i - 1]
+	}
+	if rowFn != nil {
+		rowFn(0, matrix[offset(0, 0):offset(1, 0)])
 	}
 
 	for i := 0; i < m; i++ {
 		for j := 0; j < n; j++ {
-			cost := s.GetItemAt(i).Compare(t.GetItemAt(j))
+			cost := substitutionCost(s.GetItemAt(i), t.GetItemAt(j), sWeights[i], tWeights[j])
 			matrix[offset(i + 1, j + 1)] = min_float32_3(
 				matrix[offset(i, j)] + cost,
-				matrix[offset(i, j + 1)] + 1,
-				matrix[offset(i + 1, j)] + 1,
+				matrix[offset(i, j + 1)] + sWeights[i],
+				matrix[offset(i + 1, j)] + tWeights[j],
 			)
 		}
+		if rowFn != nil {
+			rowFn(i + 1, matrix[offset(i + 1, 0):offset(i + 2, 0)])
+		}
 	}
 
 	// --- extract an alignment from the computed matrix ---
 
+	cell := func (i, j int) float32 { return matrix[offset(i, j)] }
+	return matrix[offset(m, n)], backtraceAlignment(s, t, cell, explanations)
+}
+
+// ------------------------------------------- backtraceAlignment
+
+// Walk back through the filled in edit distance matrix for "s" and "t", read
+// with "cell", from the bottom right corner to the top left, collecting the
+// links of the cheapest alignment.  When "explanations" is not nil, one
+// explanation per link is appended to it.
+
+func backtraceAlignment(s, t ComparableSequence, cell func (i, j int) float32, explanations *[]LinkExplanation) *Alignment {
+
+	alignment := new(Alignment)
+	m, n := s.Length(), t.Length()
+
 	for i, j := m, n; i > 0 || j > 0; {
 
 		var iNext, jNext int
@@ -583,17 +739,24 @@ func Diff_v2(s, t ComparableSequence) (distance float32, alignment *Alignment) {
 		sIndex := i - 1
 		tIndex := j - 1
 
+		// Only recorded when explaining; an edge of the matrix has just the one way to go.
+		explanation := LinkExplanation{Substitute: NO_PATH, Delete: NO_PATH, Insert: NO_PATH}
+
 		if i < 1 {
 			link, iNext, jNext = Link{RightOnly, -1, tIndex}, 0, j - 1
+			explanation.Op, explanation.Insert = BacktraceInsert, cell(i, j - 1) + itemWeight(t.GetItemAt(tIndex))
 		} else if j < 1 {
 			link, iNext, jNext = Link{LeftOnly, sIndex, -1}, i - 1, 0
+			explanation.Op, explanation.Delete = BacktraceDelete, cell(i - 1, j) + itemWeight(s.GetItemAt(sIndex))
 		} else {
 
-			cost := s.GetItemAt(i - 1).Compare(t.GetItemAt(j - 1))
+			sWeight, tWeight := itemWeight(s.GetItemAt(sIndex)), itemWeight(t.GetItemAt(tIndex))
+			cost := substitutionCost(s.GetItemAt(sIndex), t.GetItemAt(tIndex), sWeight, tWeight)
 
-			a := matrix[offset(i - 1, j - 1)] + cost
-			b := matrix[offset(i - 1, j)] + 1
-			c := matrix[offset(i, j - 1)] + 1
+			a := cell(i - 1, j - 1) + cost
+			b := cell(i - 1, j) + sWeight
+			c := cell(i, j - 1) + tWeight
+			explanation.Cost, explanation.Substitute, explanation.Delete, explanation.Insert = cost, a, b, c
 
 			// Another readability improvement: Use boolean temporaries rather than inlining the expressions.  
 			aIsOK := a <= b && a <= c
@@ -606,16 +769,22 @@ func Diff_v2(s, t ComparableSequence) (distance float32, alignment *Alignment) {
 				} else {
 					link, iNext, jNext = Link{Different, sIndex, tIndex}, i - 1, j - 1
 				}
+				explanation.Op = BacktraceSubstitute
 			} else if bIsOK {
 				link, iNext, jNext = Link{LeftOnly, sIndex, -1}, i - 1, j
+				explanation.Op = BacktraceDelete
 			} else if cIsOK {
 				link, iNext, jNext = Link{RightOnly, -1, tIndex}, i, j - 1
+				explanation.Op = BacktraceInsert
 			} else {
 				panic("not reached")
 			}
 		}
 
 		alignment.Links = append(alignment.Links, link)
+		if explanations != nil {
+			*explanations = append(*explanations, explanation)
+		}
 
 		i, j = iNext, jNext
 	}
@@ -625,8 +794,14 @@ func Diff_v2(s, t ComparableSequence) (distance float32, alignment *Alignment) {
 	for low, high := 0, len(alignment.Links) - 1; low < high; low, high = low + 1, high - 1 {
 		alignment.Links[low], alignment.Links[high] = alignment.Links[high], alignment.Links[low]
 	}
+	if explanations != nil {
+		reversed := *explanations
+		for low, high := 0, len(reversed) - 1; low < high; low, high = low + 1, high - 1 {
+			reversed[low], reversed[high] = reversed[high], reversed[low]
+		}
+	}
 
-	return matrix[offset(m, n)], alignment
+	return alignment
 }
 
 // -------------------------------------------
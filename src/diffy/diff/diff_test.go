@@ -1,7 +1,11 @@
 package diff
 
 import (
+	"fmt"
 	"math"
+	"math/rand"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -643,3 +647,159 @@ func (self *DiffTestCase) execute(tester *tTester) {
 	alignment.Dump(self.leftLines, self.rightLines, int(distance), tester)
 	// TODO: Short of a panic, the test will never actually fail!
 }
+
+// -------------------------------------------
+// ------------------------------------------- TestIndentStyleChanged
+// -------------------------------------------
+
+func TestIndentStyleChanged(t *testing.T) {
+
+	// Build a TextLine the way the file reader does, with tabs expanded
+	// to four spaces but with the raw indentation preserved.
+	makeLine := func (rawIndent, content string) *TextLine {
+		line := NewTextLine(strings.Replace(rawIndent, "\t", "    ", -1) + content)
+		line.RawIndent = rawIndent
+		return line
+	}
+
+	testCases := []struct {
+		left, right *TextLine
+		changed bool
+		note string
+	}{
+		{makeLine("\t", "return x"), makeLine("    ", "return x"), true, "tab to spaces"},
+		{makeLine("    ", "return x"), makeLine("\t", "return x"), true, "spaces to tab"},
+		{makeLine("\t    ", "return x"), makeLine("\t\t", "return x"), true, "mixed to tabs"},
+		{makeLine("\t ", "return x"), makeLine("\t", "return x"), false, "mixed to narrower tabs"},
+		{makeLine("\t", "return x"), makeLine("        ", "return x"), false, "tab to spaces, a level deeper"},
+		{makeLine("\t", "return x"), makeLine("\t", "return x"), false, "same tabs"},
+		{makeLine("    ", "return x"), makeLine("  ", "return x"), false, "same style, different width"},
+		{makeLine("\t", "return x"), makeLine("    ", "return y"), false, "content change"},
+		{makeLine("", "return x"), makeLine("    ", "return x"), false, "newly indented"},
+	}
+
+	for _, testCase := range testCases {
+		changed := IndentStyleChanged(testCase.left, testCase.right)
+		if changed != testCase.changed {
+			t.Errorf("IndentStyleChanged (%s): got %v, expected %v", testCase.note, changed, testCase.changed)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestDiff2Shortcuts
+// -------------------------------------------
+
+// Diff_v2's shortcuts must produce exactly the same result as the full
+// computation, which Diff_v2WithRowFunc always does.
+func TestDiff2Shortcuts(t *testing.T) {
+
+	check := func (s, u ComparableSequence) {
+		distance, alignment := Diff_v2(s, u)
+		expectedDistance, expectedAlignment := Diff_v2WithRowFunc(s, u, nil)
+		if distance != expectedDistance || fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+			t.Errorf("%s/%s: got %v %v, expected %v %v", s.GetDescription(), u.GetDescription(),
+				distance, alignment.Links, expectedDistance, expectedAlignment.Links)
+		}
+	}
+
+	pairs := [][2]string{
+		{"", ""}, {"", "abc"}, {"abc", ""}, {"abc", "abc"},
+		{"aa", "a"}, {"a", "aa"}, {"abbc", "ab"}, {"ab", "abbc"},
+		{"xyz", "abcxyz"}, {"abcxyz", "xyz"}, {"cat", "hat"},
+	}
+	for _, pair := range pairs {
+		check(MakeComparableString(pair[0]), MakeComparableString(pair[1]))
+	}
+
+	rng := rand.New(rand.NewSource(1141))
+	charSet := []rune(CHAR_SET)
+	for i := 0; i < 500; i++ {
+		s := randomString(rng, charSet, rng.Intn(8))
+		check(MakeComparableString(s), MakeComparableString(mutateString(rng, charSet, s, rng.Intn(4))))
+	}
+
+	for i := 0; i < 20; i++ {
+		leftLines, rightLines := generateFilePair(rng, 30)
+		check(leftLines, rightLines)
+	}
+}
+
+// ------------------------------------------- TestDiff2ShortcutAllocation
+
+func TestDiff2ShortcutAllocation(t *testing.T) {
+
+	bytesAllocated := func (fn func ()) uint64 {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		fn()
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+
+	const hugeLength = 20000
+	const tinyLength = 100
+	huge := MakeComparableString(strings.Repeat("abcdefghij", hugeLength / 10))
+	tiny := huge[hugeLength - tinyLength:]
+	matrixSize := uint64((tinyLength + 1) * (hugeLength + 1) * 4)
+
+	for _, pair := range [][2]ComparableString{{nil, huge}, {huge, nil}, {tiny, huge}, {huge, tiny}} {
+		var alignment *Alignment
+		allocated := bytesAllocated(func () { _, alignment = Diff_v2(pair[0], pair[1]) })
+		if len(alignment.Links) != hugeLength {
+			t.Errorf("%d/%d: expected %d links, got %d", len(pair[0]), len(pair[1]), hugeLength, len(alignment.Links))
+		}
+		if allocated > matrixSize / 4 {
+			t.Errorf("%d/%d: allocated %d bytes, expected well under the %d byte matrix", len(pair[0]), len(pair[1]), allocated, matrixSize)
+		}
+	}
+}
+
+// ------------------------------------------- TestDiff2EmptyInputs
+
+// An item which can't be compared, to prove the matrix is never computed.
+type uncomparableItem struct {
+	weight float32
+}
+
+func (item uncomparableItem) Compare(other Comparable) float32 { panic("compared an item against an empty sequence") }
+func (item uncomparableItem) Stringify(maxWidth int) string   { return "?" }
+func (item uncomparableItem) Weight() float32                 { return item.weight }
+
+type uncomparableItems []uncomparableItem
+
+func (items uncomparableItems) Length() int                  { return len(items) }
+func (items uncomparableItems) GetItemAt(index int) Comparable { return items[index] }
+func (items uncomparableItems) GetDescription() string       { return "uncomparable items" }
+
+func TestDiff2EmptyInputs(t *testing.T) {
+
+	items := uncomparableItems{{1.0}, {2.5}, {1.0}}
+
+	testCases := []struct {
+		name string
+		s, u uncomparableItems
+		linkType LinkType
+		distance float32
+	}{
+		{"empty left", nil, items, RightOnly, 4.5},
+		{"empty right", items, nil, LeftOnly, 4.5},
+		{"both empty", nil, nil, Matching, 0.0},
+	}
+
+	for _, testCase := range testCases {
+		distance, alignment := Diff_v2(testCase.s, testCase.u)
+		if distance != testCase.distance {
+			t.Errorf("%s: expected a distance of %v, got %v", testCase.name, testCase.distance, distance)
+		}
+		if len(alignment.Links) != len(testCase.s) + len(testCase.u) {
+			t.Errorf("%s: expected %d links, got %d", testCase.name, len(testCase.s) + len(testCase.u), len(alignment.Links))
+		}
+		for index, link := range alignment.Links {
+			if link.LinkType != testCase.linkType || (link.LinkType == LeftOnly && link.LeftIndex != index) || (link.LinkType == RightOnly && link.RightIndex != index) {
+				t.Errorf("%s: unexpected link %d: %v", testCase.name, index, link)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"bytes"
+	"io"
+)
+
+// "equal.go" - Telling whether two files are byte for byte the same, which is
+// much cheaper than diffing them to find out.
+
+// How much of each file FilesEqual reads at a time.
+const EQUAL_BUFFER_SIZE = 32 * 1024
+
+// -------------------------------------------
+// ------------------------------------------- FilesEqual
+// -------------------------------------------
+
+// Whether "left" and "right" read the same bytes.  They're read in lockstep,
+// a buffer at a time, and the reading stops at the first buffer which
+// differs, in its bytes or in its length, so two big files which differ near
+// the start are told apart after reading just their starts.
+
+func FilesEqual(left, right io.Reader) (bool, error) {
+	leftBuffer, rightBuffer := make([]byte, EQUAL_BUFFER_SIZE), make([]byte, EQUAL_BUFFER_SIZE)
+	for {
+		leftCount, leftErr := io.ReadFull(left, leftBuffer)
+		if leftErr != nil && leftErr != io.EOF && leftErr != io.ErrUnexpectedEOF {
+			return false, leftErr
+		}
+		rightCount, rightErr := io.ReadFull(right, rightBuffer)
+		if rightErr != nil && rightErr != io.EOF && rightErr != io.ErrUnexpectedEOF {
+			return false, rightErr
+		}
+		if leftCount != rightCount || !bytes.Equal(leftBuffer[:leftCount], rightBuffer[:rightCount]) {
+			return false, nil
+		}
+
+		// A short read is the end of both, since they're the same length.
+		if leftErr != nil {
+			return true, nil
+		}
+	}
+}
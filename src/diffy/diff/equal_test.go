@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------- tCountingReader
+
+// A reader which counts the bytes read from it.
+type tCountingReader struct {
+	reader io.Reader
+	count int
+}
+
+func (reader *tCountingReader) Read(p []byte) (int, error) {
+	count, err := reader.reader.Read(p)
+	reader.count += count
+	return count, err
+}
+
+// ------------------------------------------- TestFilesEqual
+
+func TestFilesEqual(t *testing.T) {
+
+	big := strings.Repeat("all work and no play\n", 50000)		// about a megabyte, many buffers' worth
+	testCases := []struct {
+		name, left, right string
+		equal bool
+	}{
+		{"both empty", "", "", true},
+		{"equal", "one\ntwo\n", "one\ntwo\n", true},
+		{"equal and big", big, big, true},
+		{"exactly a buffer each", strings.Repeat("x", EQUAL_BUFFER_SIZE), strings.Repeat("x", EQUAL_BUFFER_SIZE), true},
+		{"one empty", "", "one\n", false},
+		{"the first byte differs", "one\n", "One\n", false},
+		{"the last byte differs", big + "x", big + "y", false},
+		{"one is a prefix of the other", big, big + "more\n", false},
+		{"a buffer and a bit more", strings.Repeat("x", EQUAL_BUFFER_SIZE), strings.Repeat("x", EQUAL_BUFFER_SIZE + 1), false},
+		{"line endings", "one\n", "one\r\n", false},
+	}
+	for _, testCase := range testCases {
+		equal, err := FilesEqual(strings.NewReader(testCase.left), strings.NewReader(testCase.right))
+		if err != nil || equal != testCase.equal {
+			t.Errorf("%s: expected %v, got %v (%v)", testCase.name, testCase.equal, equal, err)
+		}
+	}
+
+	// Files which differ early, in their bytes or their lengths, aren't read to the end.
+	for _, other := range []string{"X" + big[1:], big[:100]} {
+		left, right := &tCountingReader{reader: strings.NewReader(big)}, &tCountingReader{reader: strings.NewReader(other)}
+		if equal, _ := FilesEqual(left, right); equal {
+			t.Errorf("Expected the files to differ")
+		}
+		if left.count > EQUAL_BUFFER_SIZE || right.count > EQUAL_BUFFER_SIZE {
+			t.Errorf("Expected at most a buffer read from each, got %d and %d of %d and %d bytes", left.count, right.count, len(big), len(other))
+		}
+	}
+
+	// A read error is passed on.
+	if _, err := FilesEqual(strings.NewReader("one"), io.MultiReader(strings.NewReader("o"), tFailingReader{})); err == nil || err.Error() != "read failed" {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+}
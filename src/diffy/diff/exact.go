@@ -0,0 +1,75 @@
+package diff
+
+import (
+	"fmt"
+)
+
+// "exact.go" - An equality-only diff, where two lines either match exactly or
+// have nothing to do with each other, as in GNU diff.  It finds a longest
+// common subsequence of the lines, which makes it a reference to check the
+// similarity-graded alignments against.
+
+// Pairing up two lines which aren't equal costs as much as deleting one and
+// inserting the other, so it's never worth it.
+const EXACT_MISMATCH_COST = 2.0
+
+// -------------------------------------------
+// ------------------------------------------- type exactLines
+// -------------------------------------------
+
+// Lines which compare as equal or not, on their compare text.
+type exactLines ComparableLines
+
+// Assert that ComparableSequence is implemented by exactLines.
+var _ ComparableSequence = exactLines(nil)
+
+type exactLine struct {
+	*TextLine
+}
+
+func (line exactLine) Compare(other Comparable) float32 {
+	if line.compareText == other.(exactLine).compareText {
+		return 0.0
+	}
+	return EXACT_MISMATCH_COST
+}
+
+func (lines exactLines) Length() int                  { return len(lines) }
+func (lines exactLines) GetItemAt(index int) Comparable { return exactLine{lines[index]} }
+func (lines exactLines) GetDescription() string       { return fmt.Sprintf("%d exact lines", len(lines)) }
+
+// -------------------------------------------
+// ------------------------------------------- DiffExact
+// -------------------------------------------
+
+// The alignment of "left" and "right" with as many matching lines as possible,
+// where lines match when their compare text is the same.  There are no
+// Different links: the lines of a changed run are all deleted, and then all
+// inserted, as in a unified diff.
+
+func DiffExact(left, right ComparableLines) *Alignment {
+
+	_, alignment := Diff_v2(exactLines(left), exactLines(right))
+
+	// A mismatched pair costs no less than deleting and inserting, so a
+	// cheapest alignment might still have some; they're split up all the same.
+	var links, inserted []Link
+	for _, link := range alignment.Links {
+		switch link.LinkType {
+		case Matching:
+			links = append(links, inserted...)
+			links, inserted = append(links, link), inserted[:0]
+		case Different:
+			links = append(links, Link{LeftOnly, link.LeftIndex, -1})
+			inserted = append(inserted, Link{RightOnly, -1, link.RightIndex})
+		case LeftOnly:
+			links = append(links, link)
+		case RightOnly:
+			inserted = append(inserted, link)
+		default:
+			panic("not reached")
+		}
+	}
+	links = append(links, inserted...)
+	return &Alignment{Links: links}
+}
@@ -0,0 +1,34 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- TestDiffExact
+
+func TestDiffExact(t *testing.T) {
+
+	testCases := []struct {
+		left, right []string
+		expected []Link
+	}{
+		// Similar lines aren't paired up; a changed run is deleted, then inserted.
+		{[]string{"total := 0", "for i := range xs {"}, []string{"total := 1", "for i := range ys {"},
+			[]Link{{LeftOnly, 0, -1}, {LeftOnly, 1, -1}, {RightOnly, -1, 0}, {RightOnly, -1, 1}}},
+		{[]string{"a", "b", "x"}, []string{"b", "c", "x"},
+			[]Link{{LeftOnly, 0, -1}, {Matching, 1, 0}, {RightOnly, -1, 1}, {Matching, 2, 2}}},
+		{[]string{"same"}, []string{"same"}, []Link{{Matching, 0, 0}}},
+		{nil, []string{"new"}, []Link{{RightOnly, -1, 0}}},
+	}
+	for _, testCase := range testCases {
+		left, right := makeTestLines(testCase.left...), makeTestLines(testCase.right...)
+		alignment := DiffExact(left, right)
+		if fmt.Sprint(alignment.Links) != fmt.Sprint(testCase.expected) {
+			t.Errorf("%q vs %q: expected %v, got %v", testCase.left, testCase.right, testCase.expected, alignment.Links)
+		}
+		if err := alignment.Validate(left, right); err != nil {
+			t.Errorf("%q vs %q: %v", testCase.left, testCase.right, err)
+		}
+	}
+}
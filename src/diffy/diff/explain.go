@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"fmt"
+	"math"
+)
+
+// "explain.go" - Explaining why Diff_v2 aligned things the way it did, for
+// anyone trying to understand a surprising alignment or tune a threshold.
+
+// -------------------------------------------
+// ------------------------------------------- type LinkExplanation
+// -------------------------------------------
+
+// The three ways back through the edit distance matrix from a cell.
+type BacktraceOp int
+
+const (
+	BacktraceSubstitute BacktraceOp = iota	// diagonally, pairing two items (Matching or Different)
+	BacktraceDelete							// up, leaving a left item on its own (LeftOnly)
+	BacktraceInsert							// left, leaving a right item on its own (RightOnly)
+)
+
+func (op BacktraceOp) String() string {
+	switch op {
+	case BacktraceSubstitute:
+		return "substitute"
+	case BacktraceDelete:
+		return "delete"
+	case BacktraceInsert:
+		return "insert"
+	}
+	panic("not reached")
+}
+
+// The total for a way back which doesn't exist, at the edge of the matrix.
+var NO_PATH = float32(math.Inf(1))
+
+// A LinkExplanation records how Diff_v2 chose one link: the total edit distance
+// along each of the three ways back from the link's cell, and the one taken.
+// Ties go to substitute, then delete, then insert.  "Cost" is the cost of
+// pairing the two items, which is only known when both exist.
+
+type LinkExplanation struct {
+	Op BacktraceOp
+	Cost float32
+	Substitute, Delete, Insert float32
+}
+
+// -------------------------------------------
+// ------------------------------------------- ExplainAlignment
+// -------------------------------------------
+
+// Log one line for each link of "alignment" which isn't Matching, saying what
+// Diff_v2 chose, why, and whether RealignUsingThreshold will split the pair at
+// "threshold".  The explanations must come from Diff_v2Explained, for the same
+// alignment.  Line numbers start from 1.
+
+func ExplainAlignment(alignment *Alignment, explanations []LinkExplanation, threshold float32, logger SimpleLogger) {
+
+	formatTotal := func (total float32) string {
+		if total == NO_PATH {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", total)
+	}
+
+	for index, link := range alignment.Links {
+		if link.LinkType == Matching {
+			continue
+		}
+		explanation := explanations[index]
+
+		var subject string
+		switch link.LinkType {
+		case Different:
+			subject = fmt.Sprintf("left %d ~ right %d: different, similarity %.2f", link.LeftIndex + 1, link.RightIndex + 1, 1.0 - explanation.Cost)
+		case LeftOnly:
+			subject = fmt.Sprintf("left %d: left only", link.LeftIndex + 1)
+		case RightOnly:
+			subject = fmt.Sprintf("right %d: right only", link.RightIndex + 1)
+		default:
+			panic("not reached")
+		}
+
+		logger.Printf("%s; chose %s (substitute %s, delete %s, insert %s)",
+			subject, explanation.Op, formatTotal(explanation.Substitute), formatTotal(explanation.Delete), formatTotal(explanation.Insert))
+		if link.LinkType == Different && explanation.Cost > threshold {
+			logger.Printf("; split by the %.2f realign threshold", threshold)
+		}
+		logger.Println()
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- type SimilarityHistogram
+// -------------------------------------------
+
+// The default number of buckets, each a tenth of the range from 0 to 1.
+const DEFAULT_HISTOGRAM_BUCKETS = 10
+
+// A SimilarityHistogram counts the Different links of an alignment by the
+// similarity of their two items.  For lines, that's their RawSimilarity, since
+// the Similarity Diff_v2 goes by counts everything below 0.6 as 0, which would
+// leave the buckets in between empty.  Bucket i holds similarities from
+// i / len(Counts) up to (i + 1) / len(Counts), and the last bucket includes 1.
+// It shows where a threshold would fall for a particular kind of file.
+
+type SimilarityHistogram struct {
+	Counts []int
+}
+
+func NewSimilarityHistogram(alignment *Alignment, left, right ComparableSequence, bucketCount int) *SimilarityHistogram {
+	histogram := &SimilarityHistogram{Counts: make([]int, bucketCount)}
+	for _, link := range alignment.Links {
+		if link.LinkType == Different {
+			histogram.Counts[histogram.Bucket(rawSimilarity(left.GetItemAt(link.LeftIndex), right.GetItemAt(link.RightIndex)))]++
+		}
+	}
+	return histogram
+}
+
+// The similarity of two items before any flooring, where there's such a thing.
+func rawSimilarity(leftItem, rightItem Comparable) float32 {
+	leftLine, leftIsLine := leftItem.(*TextLine)
+	rightLine, rightIsLine := rightItem.(*TextLine)
+	if leftIsLine && rightIsLine {
+		return leftLine.RawSimilarity(rightLine)
+	}
+	return 1.0 - leftItem.Compare(rightItem)
+}
+
+// The bucket "similarity" falls in, clamped to the range from 0 to 1.
+func (histogram *SimilarityHistogram) Bucket(similarity float32) int {
+	bucket := int(similarity * float32(len(histogram.Counts)))
+	if bucket < 0 {
+		return 0
+	}
+	if bucket >= len(histogram.Counts) {
+		return len(histogram.Counts) - 1
+	}
+	return bucket
+}
+
+// The number of similarities counted, which is the number of Different links.
+func (histogram *SimilarityHistogram) Total() int {
+	total := 0
+	for _, count := range histogram.Counts {
+		total += count
+	}
+	return total
+}
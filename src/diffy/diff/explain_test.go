@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------- TestDiffV2Explained
+
+func TestDiffV2Explained(t *testing.T) {
+
+	// The edit distance matrix for "ab" vs "xb" is
+	//
+	//        ""  x  b
+	//    ""   0  1  2
+	//    a    1  1  2
+	//    b    2  2  1
+	//
+	// Backing out from the bottom right: "b" matches "b" diagonally, and then
+	// from cell (a, x) substituting costs 1, while deleting or inserting cost 2.
+	distance, alignment, explanations := Diff_v2Explained(MakeComparableString("ab"), MakeComparableString("xb"))
+	if distance != 1.0 || len(explanations) != len(alignment.Links) {
+		t.Fatalf("Unexpected result %v %v %v", distance, alignment.Links, explanations)
+	}
+	expected := []LinkExplanation{
+		{BacktraceSubstitute, 1.0, 1.0, 2.0, 2.0},
+		{BacktraceSubstitute, 0.0, 1.0, 3.0, 3.0},
+	}
+	if fmt.Sprint(explanations) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, explanations)
+	}
+
+	// "a" vs "": the only way back is to delete.
+	_, _, explanations = Diff_v2Explained(MakeComparableString("a"), MakeComparableString(""))
+	if len(explanations) != 1 || explanations[0].Op != BacktraceDelete || explanations[0].Substitute != NO_PATH || explanations[0].Delete != 1.0 {
+		t.Errorf("Expected a forced delete, got %v", explanations)
+	}
+
+	// "abc" vs "ac": substituting "b" for "c" would cost more than deleting it.
+	_, alignment, explanations = Diff_v2Explained(MakeComparableString("abc"), MakeComparableString("ac"))
+	if alignment.Links[1] != (Link{LeftOnly, 1, -1}) || explanations[1].Op != BacktraceDelete || explanations[1].Delete >= explanations[1].Substitute {
+		t.Errorf("Expected the delete path for \"b\", got %v %v", alignment.Links, explanations)
+	}
+
+	// The same alignment as without explanations.
+	s, u := MakeComparableString("kitten"), MakeComparableString("sitting")
+	_, plainAlignment := Diff_v2WithRowFunc(s, u, nil)
+	if _, alignment, _ = Diff_v2Explained(s, u); fmt.Sprint(alignment.Links) != fmt.Sprint(plainAlignment.Links) {
+		t.Errorf("Expected explaining not to change the alignment")
+	}
+}
+
+// ------------------------------------------- TestExplainAlignment
+
+func TestExplainAlignment(t *testing.T) {
+	left := makeTestLines("same", "the quick brown fox jumps", "gone")
+	right := makeTestLines("same", "the quick brown cat naps")
+	_, alignment, explanations := Diff_v2Explained(left, right)
+
+	logger := &tCaptureLogger{}
+	ExplainAlignment(alignment, explanations, 0.3, logger)
+	lines := strings.Split(strings.TrimRight(logger.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line per change, got\n%s", logger.String())
+	}
+	if !strings.HasPrefix(lines[0], "left 2 ~ right 2: different, similarity 0.68; chose substitute") || !strings.HasSuffix(lines[0], "; split by the 0.30 realign threshold") {
+		t.Errorf("Unexpected explanation %q", lines[0])
+	}
+	if lines[1] != "left 3: left only; chose delete (substitute 2.00, delete 1.32, insert 3.00)" {
+		t.Errorf("Unexpected explanation %q", lines[1])
+	}
+}
+
+// ------------------------------------------- TestSimilarityHistogram
+
+func TestSimilarityHistogram(t *testing.T) {
+
+	left := makeTestLines("same", "a changed line", "another line", "gone", "tail")
+	right := makeTestLines("same", "a changed lint", "another lime", "tail", "added")
+	_, alignment := Diff_v2(left, right)
+
+	differentCount := 0
+	for _, link := range alignment.Links {
+		if link.LinkType == Different {
+			differentCount++
+		}
+	}
+	if differentCount == 0 {
+		t.Fatalf("Expected some different links, got %v", alignment.Links)
+	}
+
+	for _, bucketCount := range []int{1, 4, DEFAULT_HISTOGRAM_BUCKETS, 100} {
+		histogram := NewSimilarityHistogram(alignment, left, right, bucketCount)
+		if len(histogram.Counts) != bucketCount {
+			t.Errorf("%d buckets: got %d", bucketCount, len(histogram.Counts))
+		}
+		if total := histogram.Total(); total != differentCount {
+			t.Errorf("%d buckets: expected the counts to sum to %d different links, got %d", bucketCount, differentCount, total)
+		}
+	}
+
+	// A pair less than 0.6 similar isn't counted as having nothing in common.
+	left, right = makeTestLines("the quick brown fox jumps"), makeTestLines("the quick red fox sleeps")
+	paired := &Alignment{Links: []Link{{Different, 0, 0}}}
+	similarity := left[0].RawSimilarity(right[0])
+	if similarity <= 0.1 || similarity >= 0.6 {
+		t.Fatalf("Expected a similarity between 0.1 and 0.6, got %v", similarity)
+	}
+	if histogram := NewSimilarityHistogram(paired, left, right, DEFAULT_HISTOGRAM_BUCKETS); histogram.Counts[0] != 0 || histogram.Total() != 1 {
+		t.Errorf("Expected the pair outside the first bucket, got %v", histogram.Counts)
+	}
+
+	// Similarities fall in the bucket they start, and 1 falls in the last one.
+	histogram := &SimilarityHistogram{Counts: make([]int, 10)}
+	for _, testCase := range []struct {
+		similarity float32
+		bucket int
+	}{
+		{0.0, 0}, {0.05, 0}, {0.5, 5}, {0.95, 9}, {1.0, 9}, {-0.1, 0}, {1.1, 9},
+	} {
+		if bucket := histogram.Bucket(testCase.similarity); bucket != testCase.bucket {
+			t.Errorf("Expected %v in bucket %d, got %d", testCase.similarity, testCase.bucket, bucket)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package diff
+
+import (
+	"regexp"
+)
+
+// "generated.go" - Recognizing generated files, such as Go's "Code generated
+// ... DO NOT EDIT." files, whose diffs are usually just noise.
+
+// ------------------------------------------- DEFAULT_GENERATED_PATTERN
+
+// The marker Go's tools write at the top of a generated file, and which git
+// and the linters look for.
+const DEFAULT_GENERATED_PATTERN = `^// Code generated .* DO NOT EDIT\.$`
+
+// -------------------------------------------
+// ------------------------------------------- IsGenerated
+// -------------------------------------------
+
+// Whether any of "lines" matches "pattern", marking the file as generated.
+// The lines are matched as they were read, before anything such as
+// --ignore-comments took parts of them out of the comparison.
+
+func IsGenerated(lines ComparableLines, pattern *regexp.Regexp) bool {
+	for _, line := range lines {
+		text := line.Text
+		if line.RawText != "" {
+			text = line.RawText
+		}
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package diff
+
+import (
+	"regexp"
+	"testing"
+)
+
+// ------------------------------------------- TestIsGenerated
+
+func TestIsGenerated(t *testing.T) {
+
+	pattern := regexp.MustCompile(DEFAULT_GENERATED_PATTERN)
+	testCases := []struct {
+		lines []string
+		expected bool
+	}{
+		{[]string{"// Code generated by stringer; DO NOT EDIT.", "", "package main"}, true},
+		{[]string{"// Copyright 2024", "", "// Code generated by protoc-gen-go. DO NOT EDIT.", "package pb"}, true},
+		{[]string{"package main", "", "func main() {}"}, false},
+		// Only the whole marker line counts, not a mention of it.
+		{[]string{"const marker = \"// Code generated x DO NOT EDIT.\""}, false},
+		{[]string{"// Code generated by hand, feel free to edit."}, false},
+		{nil, false},
+	}
+	for _, testCase := range testCases {
+		if generated := IsGenerated(makeTestLines(testCase.lines...), pattern); generated != testCase.expected {
+			t.Errorf("%q: expected %v, got %v", testCase.lines, testCase.expected, generated)
+		}
+	}
+
+	// The marker is matched as the line was read, even if it's compared without its comment.
+	line := NewTextLine("")
+	line.RawText = "// Code generated by go generate; DO NOT EDIT."
+	if !IsGenerated(ComparableLines{line}, pattern) {
+		t.Errorf("Expected the raw text to be matched")
+	}
+}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// "gnu-diff_test.go" - Cross-checking DiffExact, which is what "--exact"
+// compares with, against GNU diff on the pairs of files in
+// "testdata/gnu-diff", each a "NAME.left" and a "NAME.right".
+// They have to agree on which lines are removed and added, though not
+// necessarily on where, since a line can often be matched in more than one
+// place.
+
+// ------------------------------------------- TestDiffExactAgreesWithGnuDiff
+
+func TestDiffExactAgreesWithGnuDiff(t *testing.T) {
+
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff isn't installed")
+	}
+	leftPaths, err := filepath.Glob(filepath.Join("testdata", "gnu-diff", "*.left"))
+	if err != nil || len(leftPaths) == 0 {
+		t.Fatalf("Expected some pairs of files, got %v (%v)", leftPaths, err)
+	}
+
+	for _, leftPath := range leftPaths {
+		rightPath := strings.TrimSuffix(leftPath, ".left") + ".right"
+		name := filepath.Base(strings.TrimSuffix(leftPath, ".left"))
+
+		expectedRemoved, expectedAdded, err := gnuDiffChanges(leftPath, rightPath)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		removed, added, err := diffExactChanges(leftPath, rightPath)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if fmt.Sprintf("%q", removed) != fmt.Sprintf("%q", expectedRemoved) {
+			t.Errorf("%s: expected the removed lines\n%q\ngot\n%q", name, expectedRemoved, removed)
+		}
+		if fmt.Sprintf("%q", added) != fmt.Sprintf("%q", expectedAdded) {
+			t.Errorf("%s: expected the added lines\n%q\ngot\n%q", name, expectedAdded, added)
+		}
+	}
+}
+
+// ------------------------------------------- gnuDiffChanges
+
+// The removed and added lines in the unified diff GNU diff gives for the two
+// files, each sorted.
+func gnuDiffChanges(leftPath, rightPath string) (removed, added []string, err error) {
+	var output bytes.Buffer
+	command := exec.Command("diff", "-U0", leftPath, rightPath)
+	command.Stdout = &output
+	if err := command.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return nil, nil, fmt.Errorf("diff failed: %v", err)
+		}
+	}
+
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++"):
+		case strings.HasPrefix(line, "-"):
+			removed = append(removed, line[1:])
+		case strings.HasPrefix(line, "+"):
+			added = append(added, line[1:])
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added, scanner.Err()
+}
+
+// ------------------------------------------- diffExactChanges
+
+// Likewise for DiffExact, with a last line which only differs in its newline
+// counted as changed, as it is in diffy's own unified diffs.
+func diffExactChanges(leftPath, rightPath string) (removed, added []string, err error) {
+	read := func (path string) (ComparableLines, bool, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, false, err
+		}
+		defer file.Close()
+		return ReadLines(file, Options{})
+	}
+	left, leftFinalNewline, err := read(leftPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, rightFinalNewline, err := read(rightPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alignment := MarkFinalNewlineChange(DiffExact(left, right), leftFinalNewline, rightFinalNewline)
+	for _, link := range alignment.Links {
+		if link.LinkType == Matching {
+			continue
+		}
+		if link.LeftIndex >= 0 {
+			removed = append(removed, left[link.LeftIndex].RawText)
+		}
+		if link.RightIndex >= 0 {
+			added = append(added, right[link.RightIndex].RawText)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added, nil
+}
@@ -0,0 +1,165 @@
+package diff
+
+// "incremental.go" - Updating a diff after a small edit, such as a keystroke
+// in an editor, by re-diffing only the part of it the edit touched.
+
+// -------------------------------------------
+// ------------------------------------------- type LineEdit
+// -------------------------------------------
+
+// A LineEdit inserts, deletes or replaces one line of one side of a diff.
+// "Index" is the index of the line before the edit: the line deleted or
+// replaced, or the line the new one is inserted before, which may be the
+// length of the side to add a line at the end.
+
+type LineEdit struct {
+	Kind EditKind
+	OnRight bool		// edit the right side rather than the left
+	Index int
+	Line *TextLine		// the new line, for InsertLine and ReplaceLine
+}
+
+type EditKind int
+
+const (
+	InsertLine EditKind = iota
+	DeleteLine
+	ReplaceLine
+)
+
+// The number of matching lines around the lines an edit touches which are
+// re-diffed along with them, so a change can slide to a better place nearby.
+const INCREMENTAL_CONTEXT = 3
+
+// ------------------------------------------- LineEdit Apply method
+
+// A copy of "left" and "right" with the edit made to one of them.  The other
+// is returned as it is.
+func (edit LineEdit) Apply(left, right ComparableLines) (ComparableLines, ComparableLines) {
+	if edit.OnRight {
+		return left, edit.applyTo(right)
+	}
+	return edit.applyTo(left), right
+}
+
+func (edit LineEdit) applyTo(lines ComparableLines) ComparableLines {
+	edited := make(ComparableLines, 0, len(lines) + 1)
+	edited = append(edited, lines[:edit.Index]...)
+	switch edit.Kind {
+	case InsertLine:
+		edited = append(edited, edit.Line)
+		edited = append(edited, lines[edit.Index:]...)
+	case DeleteLine:
+		edited = append(edited, lines[edit.Index + 1:]...)
+	case ReplaceLine:
+		edited = append(edited, edit.Line)
+		edited = append(edited, lines[edit.Index + 1:]...)
+	default:
+		panic("not reached")
+	}
+	return edited
+}
+
+// The change in the length of the edited side.
+func (edit LineEdit) delta() int {
+	switch edit.Kind {
+	case InsertLine:
+		return 1
+	case DeleteLine:
+		return -1
+	case ReplaceLine:
+		return 0
+	}
+	panic("not reached")
+}
+
+// -------------------------------------------
+// ------------------------------------------- Incremental
+// -------------------------------------------
+
+// The alignment of "prev" after "edit", without re-diffing all of it.  Only
+// the changed lines around the edit are diffed again, along with up to
+// INCREMENTAL_CONTEXT matching lines on either side, and the result is
+// stitched into the rest of the previous alignment, whose indexes are shifted
+// to make room.  Like Compare, the new part is realigned with the default
+// threshold.  "prev" is left alone; use the edit's Apply method for the lines
+// the new alignment is of.
+//
+// The result is the same as a full diff unless the edit makes a better
+// alignment possible beyond the context, which for small edits in files of
+// any size is rare.
+
+func Incremental(prev *DiffResult, edit LineEdit) *Alignment {
+
+	left, right := edit.Apply(prev.Left, prev.Right)
+	links := prev.Alignment.Links
+
+	// Find the links around the edit: the edited line's, or for an insertion,
+	// the one it goes in front of, if any.
+	editIndex := func (link Link) int {
+		if edit.OnRight {
+			return link.RightIndex
+		}
+		return link.LeftIndex
+	}
+	start := len(links)
+	for index, link := range links {
+		if editIndex(link) >= edit.Index {
+			start = index
+			break
+		}
+	}
+	end := start
+	if edit.Kind != InsertLine {
+		end++
+	}
+
+	// Widen them to take in the changes on either side, and some context.
+	for matches := 0; start > 0 && (matches < INCREMENTAL_CONTEXT || links[start - 1].LinkType != Matching); start-- {
+		if links[start - 1].LinkType == Matching {
+			matches++
+		}
+	}
+	for matches := 0; end < len(links) && (matches < INCREMENTAL_CONTEXT || links[end].LinkType != Matching); end++ {
+		if links[end].LinkType == Matching {
+			matches++
+		}
+	}
+
+	// The lines those links cover, before and after the edit.
+	leftStart, rightStart := countSides(links[:start])
+	leftCount, rightCount := countSides(links[start:end])
+	leftDelta, rightDelta := 0, 0
+	if edit.OnRight {
+		rightDelta = edit.delta()
+	} else {
+		leftDelta = edit.delta()
+	}
+	leftEnd, rightEnd := leftStart + leftCount + leftDelta, rightStart + rightCount + rightDelta
+
+	// Re-diff them, and put the pieces back together.
+	leftWindow, rightWindow := left[leftStart:leftEnd], right[rightStart:rightEnd]
+	_, window := Diff_v2(leftWindow, rightWindow)
+	window = window.RealignUsingThreshold(leftWindow, rightWindow, DEFAULT_REALIGN_THRESHOLD)
+
+	newLinks := make([]Link, 0, start + len(window.Links) + len(links) - end)
+	newLinks = append(newLinks, links[:start]...)
+	newLinks = append(newLinks, window.Shift(leftStart, rightStart).Links...)
+	newLinks = append(newLinks, (&Alignment{Links: links[end:]}).Shift(leftDelta, rightDelta).Links...)
+	return &Alignment{Links: newLinks}
+}
+
+// ------------------------------------------- countSides
+
+// The number of links with a left index, and with a right index.
+func countSides(links []Link) (leftCount, rightCount int) {
+	for _, link := range links {
+		if link.LeftIndex >= 0 {
+			leftCount++
+		}
+		if link.RightIndex >= 0 {
+			rightCount++
+		}
+	}
+	return leftCount, rightCount
+}
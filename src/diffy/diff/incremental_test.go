@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- TestIncremental
+
+func TestIncremental(t *testing.T) {
+
+	left := []string{
+		"package main", "", "import \"fmt\"", "", "func main() {", "	total := 0",
+		"	for i := 0; i < 10; i++ {", "		total += i", "	}", "	fmt.Println(total)", "}",
+		"", "func helper(x int) int {", "	return x * 2", "}",
+	}
+	right := []string{
+		"package main", "", "import \"fmt\"", "", "func main() {", "	sum := 0",
+		"	for i := 0; i < 10; i++ {", "		sum += i", "	}", "	fmt.Println(sum)", "}",
+		"", "func helper(x int) int {", "	return x * 2", "}",
+	}
+	prev := Compare(left, right, Options{})
+
+	// Edit the texts the same way, for the full diff to check against.
+	editTexts := func (texts []string, edit LineEdit, text string) []string {
+		edited := append([]string(nil), texts[:edit.Index]...)
+		switch edit.Kind {
+		case InsertLine:
+			edited = append(append(edited, text), texts[edit.Index:]...)
+		case DeleteLine:
+			edited = append(edited, texts[edit.Index + 1:]...)
+		case ReplaceLine:
+			edited = append(append(edited, text), texts[edit.Index + 1:]...)
+		}
+		return edited
+	}
+
+	// Every single-line edit, at every line of either side, comes out as a full re-diff would.
+	for _, onRight := range []bool{false, true} {
+		for _, kind := range []EditKind{InsertLine, DeleteLine, ReplaceLine} {
+			sideLength := len(left)
+			if onRight {
+				sideLength = len(right)
+			}
+			for index := 0; index <= sideLength; index++ {
+				if kind != InsertLine && index == sideLength {
+					continue
+				}
+				text := "	fmt.Println(\"debug\")"
+				edit := LineEdit{Kind: kind, OnRight: onRight, Index: index, Line: Compare([]string{text}, nil, Options{}).Left[0]}
+				editedLeft, editedRight := left, right
+				if onRight {
+					editedRight = editTexts(right, edit, text)
+				} else {
+					editedLeft = editTexts(left, edit, text)
+				}
+
+				expected := Compare(editedLeft, editedRight, Options{}).Alignment
+				alignment := Incremental(prev, edit)
+				if fmt.Sprint(alignment.Links) != fmt.Sprint(expected.Links) {
+					t.Errorf("%+v: expected\n%v\ngot\n%v", edit, expected.Links, alignment.Links)
+				}
+			}
+		}
+	}
+
+	// Edits can be chained, one keystroke after another, and "prev" is left alone.
+	result := prev
+	for _, text := range []string{"	fmt.Println(t)", "	fmt.Println(to)", "	fmt.Println(tot)"} {
+		edit := LineEdit{Kind: ReplaceLine, Index: 9, Line: Compare([]string{text}, nil, Options{}).Left[0]}
+		editedLeft, editedRight := edit.Apply(result.Left, result.Right)
+		result = &DiffResult{Left: editedLeft, Right: editedRight, Alignment: Incremental(result, edit)}
+		checkAlignmentCoverage(t, result.Alignment, len(result.Left), len(result.Right))
+		left[9] = text
+	}
+	if expected := Compare(left, right, Options{}).Alignment; fmt.Sprint(result.Alignment.Links) != fmt.Sprint(expected.Links) {
+		t.Errorf("Expected\n%v\ngot\n%v", expected.Links, result.Alignment.Links)
+	}
+	if prev.Left[9].RawText != "	fmt.Println(total)" {
+		t.Errorf("Expected the previous result to be left alone, got %q", prev.Left[9].RawText)
+	}
+}
@@ -0,0 +1,108 @@
+package diff
+
+// "lcs.go" - An alternative to Diff_v2 which finds the longest common
+// subsequence, weighted by similarity, rather than the smallest edit distance.
+
+// Items which cost no more than this to turn into each other count as common.
+const DEFAULT_LCS_THRESHOLD = DEFAULT_REALIGN_THRESHOLD
+
+// -------------------------------------------
+// ------------------------------------------- Diff_LCS
+// -------------------------------------------
+
+// Diff_LCS aligns "s" and "t" by maximizing the total similarity of the pairs
+// of items it links, where only items similar enough to count as "common" may
+// be linked at all.  Everything else is a deletion or an insertion.
+//
+// Diff_v2 minimizes edit distance instead, and to Diff_v2 a substitution
+// always costs at most 1, whereas a deletion plus an insertion costs 2.  So it
+// will happily pair up lines which have nothing in common, e.g. replacing a
+// function with an unrelated one reads as a long run of "changed" lines.
+// Diff_LCS never does that: between two common items the deletions come first,
+// then the insertions, which is usually what you want for code.
+//
+// Diff_v2 is the better choice when the pairing is the point, e.g. for prose
+// where most lines have been edited a little, and for the characters within a
+// line.  RealignUsingThreshold gets Diff_v2's line alignments much of the way
+// to Diff_LCS's after the fact, but it can only split pairs, not re-pair them.
+//
+// The distance returned is the cost of the alignment found, which is never less
+// than the edit distance.  Like Diff_v2, it takes O(mn) time and memory.
+
+func Diff_LCS(s, t ComparableSequence) (distance float32, alignment *Alignment) {
+	return Diff_LCSWithThreshold(s, t, DEFAULT_LCS_THRESHOLD)
+}
+
+// ------------------------------------------- Diff_LCSWithThreshold
+
+// Diff_LCS, with items counting as common when they cost at most "threshold".
+func Diff_LCSWithThreshold(s, t ComparableSequence, threshold float32) (distance float32, alignment *Alignment) {
+
+	m, n := s.Length(), t.Length()
+
+	// The item costs are needed twice, so they're computed once.  A negative
+	// weight means the items aren't common.
+	weights := make([]float32, m * n)
+	weight := func (i, j int) float32 { return weights[i * n + j] }
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			cost := s.GetItemAt(i).Compare(t.GetItemAt(j))
+			if cost <= threshold {
+				weights[i * n + j] = 1.0 - cost
+			} else {
+				weights[i * n + j] = -1.0
+			}
+		}
+	}
+
+	// matrix(i, j) is the best total weight for the first i items of "s" and
+	// the first j items of "t".  Row and column zero are all zeros.
+	matrix := make([]float32, (m + 1) * (n + 1))
+	offset := func (i, j int) int { return i * (n + 1) + j }
+
+	for i := 1; i < m + 1; i++ {
+		for j := 1; j < n + 1; j++ {
+			best := matrix[offset(i - 1, j)]
+			if left := matrix[offset(i, j - 1)]; left > best {
+				best = left
+			}
+			if w := weight(i - 1, j - 1); w >= 0.0 {
+				if diagonal := matrix[offset(i - 1, j - 1)] + w; diagonal > best {
+					best = diagonal
+				}
+			}
+			matrix[offset(i, j)] = best
+		}
+	}
+
+	// --- extract an alignment from the computed matrix ---
+
+	// Working backwards, insertions are taken before deletions, so that going
+	// forwards the deletions come first.
+	alignment = new(Alignment)
+	for i, j := m, n; i > 0 || j > 0; {
+		var link Link
+		switch {
+		case i > 0 && j > 0 && weight(i - 1, j - 1) >= 0.0 && matrix[offset(i, j)] == matrix[offset(i - 1, j - 1)] + weight(i - 1, j - 1):
+			if weight(i - 1, j - 1) == 1.0 {
+				link = Link{Matching, i - 1, j - 1}
+			} else {
+				link = Link{Different, i - 1, j - 1}
+			}
+			i, j = i - 1, j - 1
+		case j > 0 && (i == 0 || matrix[offset(i, j)] == matrix[offset(i, j - 1)]):
+			link = Link{RightOnly, -1, j - 1}
+			j = j - 1
+		default:
+			link = Link{LeftOnly, i - 1, -1}
+			i = i - 1
+		}
+		alignment.Links = append(alignment.Links, link)
+	}
+
+	for low, high := 0, len(alignment.Links) - 1; low < high; low, high = low + 1, high - 1 {
+		alignment.Links[low], alignment.Links[high] = alignment.Links[high], alignment.Links[low]
+	}
+
+	return alignment.Cost(s, t), alignment
+}
@@ -0,0 +1,69 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- TestDiffLCSCleanRuns
+
+func TestDiffLCSCleanRuns(t *testing.T) {
+
+	// A function body replaced by an unrelated one.
+	left := makeTestLines("func main() {", "    loadConfiguration()", "    startTheServer(port)", "}")
+	right := makeTestLines("func main() {", "    x := 42", "    fmt.Println(x * 2)", "}")
+
+	// Edit distance pairs the unrelated lines up as changes.
+	_, alignment := Diff_v2(left, right)
+	if counts := countLinkTypes(alignment); counts[Different] != 2 {
+		t.Errorf("Expected Diff_v2 to pair up the unrelated lines, got %v", alignment.Links)
+	}
+
+	// LCS deletes the old lines and then inserts the new ones.
+	distance, alignment := Diff_LCS(left, right)
+	expected := []Link{{Matching, 0, 0}, {LeftOnly, 1, -1}, {LeftOnly, 2, -1}, {RightOnly, -1, 1}, {RightOnly, -1, 2}, {Matching, 3, 3}}
+	if fmt.Sprint(alignment.Links) != fmt.Sprint(expected) {
+		t.Errorf("Expected clean delete and insert runs %v, got %v", expected, alignment.Links)
+	}
+	if distance != 4.0 {
+		t.Errorf("Expected a distance of 4, got %v", distance)
+	}
+
+	// Lines similar enough to be common are still paired up as changes.
+	left = makeTestLines("alpha", "the quick brown fox jumps", "omega")
+	right = makeTestLines("alpha", "the quick brown cat naps", "omega")
+	_, alignment = Diff_LCS(left, right)
+	if alignment.Links[1] != (Link{Different, 1, 1}) {
+		t.Errorf("Expected the similar lines to be paired, got %v", alignment.Links)
+	}
+}
+
+// ------------------------------------------- TestDiffLCSVersusDiffV2
+
+func TestDiffLCSVersusDiffV2(t *testing.T) {
+
+	// On the characters of the similar string pairs, LCS finds a valid
+	// alignment which matches at least as many characters as Diff_v2's, at a
+	// cost no less than the edit distance.
+	for _, stringPair := range pairsOfSimilarStrings {
+		s, u := MakeComparableString(stringPair[0]), MakeComparableString(stringPair[1])
+		v2Distance, v2Alignment := Diff_v2(s, u)
+		lcsDistance, lcsAlignment := Diff_LCS(s, u)
+
+		checkAlignmentCoverage(t, lcsAlignment, s.Length(), u.Length())
+		if countLinkTypes(lcsAlignment)[Matching] < countLinkTypes(v2Alignment)[Matching] {
+			t.Errorf("%q: expected LCS to match at least as many characters as Diff_v2", stringPair)
+		}
+		if lcsDistance < v2Distance {
+			t.Errorf("%q: expected the LCS cost %v to be at least the edit distance %v", stringPair, lcsDistance, v2Distance)
+		}
+		if lcsAlignment.Cost(s, u) != lcsDistance {
+			t.Errorf("%q: expected the distance to be the cost of the alignment", stringPair)
+		}
+	}
+
+	// Empty sequences.
+	if distance, alignment := Diff_LCS(MakeComparableString(""), MakeComparableString("abc")); distance != 3.0 || len(alignment.Links) != 3 {
+		t.Errorf("Expected three insertions, got %v %v", distance, alignment.Links)
+	}
+}
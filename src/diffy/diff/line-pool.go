@@ -0,0 +1,64 @@
+package diff
+
+// "line-pool.go" - Sharing one TextLine between identical lines, so that
+// repetitive files (blank lines, closing braces, boilerplate) don't compute
+// the same DiffHash over and over.
+
+// -------------------------------------------
+// ------------------------------------------- type LinePool
+// -------------------------------------------
+
+// A LinePool interns lines as they're read: every line with the same text,
+// read with the same options, gets the same *TextLine.  That's safe because
+// lines are compared by value, never by identity, and nothing changes a
+// TextLine once it's been read.  Pointer-keyed caches such as SimilarityCache
+// only get more hits.
+//
+// Set Options.LinePool to intern the lines read with those options.  Sharing
+// one pool between both files dedupes lines across them as well.  A pool
+// isn't safe for concurrent use.
+
+type LinePool struct {
+	lines map[tPoolKey]*TextLine
+	Hits, Misses int
+}
+
+// The options are part of the key, since they change how a line is read.  So
+// is whether the line starts inside a block comment, and its line ending,
+// which a unified diff writes back.
+type tPoolKey struct {
+	text string
+	openBlockEnd string
+	opts Options
+}
+
+// ------------------------------------------- NewLinePool LinePool factory function
+
+func NewLinePool() *LinePool {
+	return &LinePool{lines: make(map[tPoolKey]*TextLine)}
+}
+
+// ------------------------------------------- LinePool Len method
+
+// The number of distinct lines in the pool.
+func (pool *LinePool) Len() int {
+	return len(pool.lines)
+}
+
+// ------------------------------------------- LinePool intern method
+
+// Return the pooled line for "text" read with "opts", and starting inside
+// the block comment ending with "openBlockEnd" if that isn't empty, making it
+// with "makeLine" the first time.
+func (pool *LinePool) intern(text string, openBlockEnd string, opts Options, makeLine func () *TextLine) *TextLine {
+	opts.LinePool = nil
+	key := tPoolKey{text, openBlockEnd, opts}
+	if line, found := pool.lines[key]; found {
+		pool.Hits++
+		return line
+	}
+	pool.Misses++
+	line := makeLine()
+	pool.lines[key] = line
+	return line
+}
@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------- TestLinePool
+
+func TestLinePool(t *testing.T) {
+
+	// A repetitive file: every line is one of four, give or take an edit.
+	var leftText, rightText strings.Builder
+	for i := 0; i < 50; i++ {
+		for _, line := range []string{"func example() {", "\treturn nil", "}", ""} {
+			leftText.WriteString(line + "\n")
+			if i == 25 && line == "\treturn nil" {
+				line = "\treturn err"
+			}
+			rightText.WriteString(line + "\n")
+		}
+	}
+
+	read := func (text string, opts Options) ComparableLines {
+		lines, _, err := ReadLines(strings.NewReader(text), opts)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		return lines
+	}
+
+	pool := NewLinePool()
+	opts := Options{LinePool: pool}
+	left, right := read(leftText.String(), opts), read(rightText.String(), opts)
+
+	if pool.Len() != 5 || pool.Misses != 5 || pool.Hits != len(left) + len(right) - 5 {
+		t.Errorf("Expected 5 distinct lines, got %d (%d hits, %d misses)", pool.Len(), pool.Hits, pool.Misses)
+	}
+	if left[0] != left[4] || left[0] != right[0] {
+		t.Errorf("Expected identical lines to share a TextLine")
+	}
+
+	// The diff is just the same as without interning.
+	plainLeft, plainRight := read(leftText.String(), Options{}), read(rightText.String(), Options{})
+	distance, alignment := Diff_v2(left, right)
+	expectedDistance, expectedAlignment := Diff_v2(plainLeft, plainRight)
+	if distance != expectedDistance || fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+		t.Errorf("Interning changed the diff")
+	}
+
+	// Lines read with different options aren't shared, even from the same pool.
+	tabbed := read("\tx\n", Options{LinePool: pool, TabSize: 8})
+	if tabbed[0] == read("\tx\n", Options{LinePool: pool})[0] || tabbed[0].Text != "        x" {
+		t.Errorf("Expected the tab size to keep the lines apart")
+	}
+}
@@ -56,3 +56,60 @@ func (s tSimpleStderrLogger) Printf(format string, a ...interface{}) {
 func (s tSimpleStderrLogger) Println(a ...interface{}) {
 	fmt.Fprintln(os.Stderr, a...)
 }
+
+// -------------------------------------------
+// ------------------------------------------- type LeveledLogger
+// -------------------------------------------
+
+// A LeveledLogger wraps a SimpleLogger and adds messages with priorities.
+// Messages below the logger's level are dropped, so diagnostics can be left
+// in place and switched on with "-v".  The plain Println and Printf methods
+// of the wrapped logger are still available, and are never filtered.
+
+type LogLevel int
+
+const (
+	LogQuiet LogLevel = iota - 1	// nothing shown, with -q
+	LogWarn						// shown unless quiet
+	LogInfo						// shown with -v
+	LogDebug					// shown with -v -v
+)
+
+type LeveledLogger struct {
+	SimpleLogger
+	Level LogLevel
+}
+
+// Assert that SimpleLogger is implemented by LeveledLogger.
+var _ SimpleLogger = (*LeveledLogger)(nil)
+
+// ------------------------------------------- NewLeveledLogger LeveledLogger factory function
+
+func NewLeveledLogger(logger SimpleLogger, level LogLevel) *LeveledLogger {
+	return &LeveledLogger{logger, level}
+}
+
+// ------------------------------------------- LeveledLogger methods
+
+// Is a message at "level" going to be shown?
+func (logger *LeveledLogger) Enabled(level LogLevel) bool {
+	return level <= logger.Level
+}
+
+func (logger *LeveledLogger) logf(level LogLevel, prefix string, format string, a ...interface{}) {
+	if logger.Enabled(level) {
+		logger.Printf(prefix + format + "\n", a...)
+	}
+}
+
+func (logger *LeveledLogger) Warnf(format string, a ...interface{}) {
+	logger.logf(LogWarn, "warning: ", format, a...)
+}
+
+func (logger *LeveledLogger) Infof(format string, a ...interface{}) {
+	logger.logf(LogInfo, "", format, a...)
+}
+
+func (logger *LeveledLogger) Debugf(format string, a ...interface{}) {
+	logger.logf(LogDebug, "debug: ", format, a...)
+}
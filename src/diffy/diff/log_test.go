@@ -0,0 +1,41 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------- TestLeveledLogger
+
+func TestLeveledLogger(t *testing.T) {
+
+	testCases := []struct {
+		level LogLevel
+		expected []string
+	}{
+		{LogQuiet, []string{""}},
+		{LogWarn, []string{"warning: w 1"}},
+		{LogInfo, []string{"warning: w 1", "i 2"}},
+		{LogDebug, []string{"warning: w 1", "i 2", "debug: d 3"}},
+	}
+
+	for _, testCase := range testCases {
+		capture := new(tCaptureLogger)
+		logger := NewLeveledLogger(capture, testCase.level)
+		logger.Warnf("w %d", 1)
+		logger.Infof("i %d", 2)
+		logger.Debugf("d %d", 3)
+
+		got := strings.Split(strings.TrimSuffix(capture.String(), "\n"), "\n")
+		if strings.Join(got, "|") != strings.Join(testCase.expected, "|") {
+			t.Errorf("Level %d: expected %q, got %q", testCase.level, testCase.expected, got)
+		}
+	}
+
+	// The plain SimpleLogger methods are never filtered.
+	capture := new(tCaptureLogger)
+	NewLeveledLogger(capture, LogWarn).Println("plain")
+	if capture.String() != "plain\n" {
+		t.Errorf("Expected Println to pass straight through, got %q", capture.String())
+	}
+}
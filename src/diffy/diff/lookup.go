@@ -0,0 +1,72 @@
+package diff
+
+// "lookup.go" - Finding the link for a given line, e.g. for an editor which
+// wants to know what became of the line under the cursor.
+
+// ------------------------------------------- type LinkLookup
+
+// A LinkLookup finds the link covering a line of either side of an alignment.
+// It's keyed by the lines' actual indexes, so it works just as well for part
+// of an alignment, e.g. from TopChanges, which skips lines.  It's a snapshot:
+// changing the alignment's links afterwards doesn't change it.
+type LinkLookup struct {
+	links []Link
+	left, right map[int]int		// line index => link index
+}
+
+// ------------------------------------------- NewLinkLookup
+//
+// Index the links of "alignment", which takes time proportional to their
+// number; every lookup after that takes constant time.
+//
+func NewLinkLookup(alignment *Alignment) *LinkLookup {
+	lookup := &LinkLookup{links: append([]Link(nil), alignment.Links...), left: make(map[int]int), right: make(map[int]int)}
+	for linkIndex, link := range alignment.Links {
+		if link.LeftIndex >= 0 {
+			lookup.left[link.LeftIndex] = linkIndex
+		}
+		if link.RightIndex >= 0 {
+			lookup.right[link.RightIndex] = linkIndex
+		}
+	}
+	return lookup
+}
+
+// ------------------------------------------- LinkLookup Left and Right methods
+
+// The link covering line "index" of the left sequence, and whether there is one.
+func (lookup *LinkLookup) Left(index int) (Link, bool) {
+	return lookup.find(lookup.left, index)
+}
+
+// The link covering line "index" of the right sequence, and whether there is one.
+func (lookup *LinkLookup) Right(index int) (Link, bool) {
+	return lookup.find(lookup.right, index)
+}
+
+func (lookup *LinkLookup) find(linkIndexes map[int]int, index int) (Link, bool) {
+	linkIndex, found := linkIndexes[index]
+	if !found {
+		return Link{}, false
+	}
+	return lookup.links[linkIndex], true
+}
+
+// ------------------------------------------- Alignment LookupLeft
+//
+// The link covering line "index" of the left sequence, and whether there is
+// one.  This indexes the links afresh each time, so to look up more than a
+// line or two, use a LinkLookup.
+//
+func (alignment *Alignment) LookupLeft(index int) (Link, bool) {
+	return NewLinkLookup(alignment).Left(index)
+}
+
+// ------------------------------------------- Alignment LookupRight
+//
+// The link covering line "index" of the right sequence, and whether there is
+// one.  See LookupLeft.
+//
+func (alignment *Alignment) LookupRight(index int) (Link, bool) {
+	return NewLinkLookup(alignment).Right(index)
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"testing"
+)
+
+// ------------------------------------------- TestLookup
+
+func TestLookup(t *testing.T) {
+
+	// left:  0 1 2 3 -
+	// right: 0 - 1 2 3
+	alignment := makeTestAlignment(" -* +")
+
+	testCases := []struct {
+		index int
+		left, right Link
+		haveLeft, haveRight bool
+	}{
+		{0, Link{Matching, 0, 0}, Link{Matching, 0, 0}, true, true},
+		{1, Link{LeftOnly, 1, -1}, Link{Different, 2, 1}, true, true},
+		{2, Link{Different, 2, 1}, Link{Matching, 3, 2}, true, true},
+		{3, Link{Matching, 3, 2}, Link{RightOnly, -1, 3}, true, true},
+		{4, Link{}, Link{}, false, false},
+		{-1, Link{}, Link{}, false, false},
+	}
+	for _, testCase := range testCases {
+		if link, ok := alignment.LookupLeft(testCase.index); link != testCase.left || ok != testCase.haveLeft {
+			t.Errorf("LookupLeft(%d): expected %v, %v, got %v, %v", testCase.index, testCase.left, testCase.haveLeft, link, ok)
+		}
+		if link, ok := alignment.LookupRight(testCase.index); link != testCase.right || ok != testCase.haveRight {
+			t.Errorf("LookupRight(%d): expected %v, %v, got %v, %v", testCase.index, testCase.right, testCase.haveRight, link, ok)
+		}
+	}
+
+	// Part of an alignment is looked up by the lines it covers.
+	part := &Alignment{Links: alignment.Links[2:]}
+	if link, ok := part.LookupLeft(2); link != (Link{Different, 2, 1}) || !ok {
+		t.Errorf("Expected left line 2 of the partial alignment to be found, got %v, %v", link, ok)
+	}
+	if _, ok := part.LookupLeft(1); ok {
+		t.Errorf("Expected left line 1 to be outside the partial alignment")
+	}
+	if link, ok := part.LookupRight(3); link != (Link{RightOnly, -1, 3}) || !ok {
+		t.Errorf("Expected right line 3 of the partial alignment to be found, got %v, %v", link, ok)
+	}
+
+	// Part of an alignment may skip lines, as TopChanges' does, and a skipped
+	// line has no link, rather than the next one's.
+	gapped := &Alignment{Links: []Link{{Matching, 2, 2}, {Different, 3, 3}, {LeftOnly, 7, -1}, {Matching, 8, 7}}}
+	lookup := NewLinkLookup(gapped)
+	for _, index := range []int{0, 4, 6, 9} {
+		if link, ok := lookup.Left(index); ok {
+			t.Errorf("Expected no link for skipped left line %d, got %v", index, link)
+		}
+	}
+	if link, ok := lookup.Left(7); link != (Link{LeftOnly, 7, -1}) || !ok {
+		t.Errorf("Expected left line 7 to be found, got %v, %v", link, ok)
+	}
+	if link, ok := lookup.Right(7); link != (Link{Matching, 8, 7}) || !ok {
+		t.Errorf("Expected right line 7 to be found, got %v, %v", link, ok)
+	}
+
+	// The lookup is a snapshot, and doesn't change the alignment.
+	gapped.Links[0] = Link{LeftOnly, 2, -1}
+	if link, _ := lookup.Left(2); link != (Link{Matching, 2, 2}) {
+		t.Errorf("Expected the lookup to keep the links it was made from, got %v", link)
+	}
+	if link, _ := gapped.LookupLeft(2); link != (Link{LeftOnly, 2, -1}) {
+		t.Errorf("Expected a fresh lookup to see the changed links, got %v", link)
+	}
+
+	// An empty alignment has nothing to find.
+	if _, ok := new(Alignment).LookupLeft(0); ok {
+		t.Errorf("Expected nothing in an empty alignment")
+	}
+}
@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// "matrix-dump.go" - Printing the Diff_v2 edit distance matrix, for debugging
+// alignments which come out differently than expected.
+
+// -------------------------------------------
+// ------------------------------------------- NewMatrixDumper
+// -------------------------------------------
+
+// Return a MatrixRowFunc for Diff_v2WithRowFunc which prints the edit distance
+// matrix of "s" against "t" to "logger", one row at a time.  Rows and columns
+// are labeled with the items they stand for.  When either side is longer than
+// "maxSize", the matrix is downsampled by printing only every k-th row or
+// column, so it still fits on a screen.  The first and last rows and columns
+// are always printed.
+
+func NewMatrixDumper(s, t ComparableSequence, logger SimpleLogger, maxSize int) MatrixRowFunc {
+
+	const labelWidth = 8
+	const cellFormat = "%6.2f"
+
+	rowIsShown := sampleMatrixIndexes(s.Length() + 1, maxSize)
+	colIsShown := sampleMatrixIndexes(t.Length() + 1, maxSize)
+
+	label := func (seq ComparableSequence, index int) string {
+		if index == 0 {
+			return ""
+		}
+		return seq.GetItemAt(index - 1).Stringify(labelWidth)
+	}
+
+	return func (i int, row []float32) {
+		if i == 0 {
+			logger.Printf("edit distance matrix: %s x %s\n", s.GetDescription(), t.GetDescription())
+			var header strings.Builder
+			header.WriteString(fmt.Sprintf("%5s %-*s", "", labelWidth, ""))
+			for j := range row {
+				if colIsShown[j] {
+					header.WriteString(fmt.Sprintf(" %6s", label(t, j)))
+				}
+			}
+			logger.Println(header.String())
+		}
+		if !rowIsShown[i] {
+			return
+		}
+		var line strings.Builder
+		line.WriteString(fmt.Sprintf("%5d %-*s", i, labelWidth, label(s, i)))
+		for j, value := range row {
+			if colIsShown[j] {
+				line.WriteString(" " + fmt.Sprintf(cellFormat, value))
+			}
+		}
+		logger.Println(line.String())
+	}
+}
+
+// ------------------------------------------- sampleMatrixIndexes
+
+// Choose which of "count" matrix indexes to show so that no more than about
+// "maxSize" are shown.  A "maxSize" of zero or less shows everything.
+func sampleMatrixIndexes(count int, maxSize int) []bool {
+	isShown := make([]bool, count)
+	stride := 1
+	if maxSize > 0 && count > maxSize {
+		stride = (count + maxSize - 1) / maxSize
+	}
+	for index := 0; index < count; index += stride {
+		isShown[index] = true
+	}
+	isShown[count - 1] = true
+	return isShown
+}
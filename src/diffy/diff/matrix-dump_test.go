@@ -0,0 +1,87 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------- type tCaptureLogger
+
+type tCaptureLogger struct {
+	strings.Builder
+}
+
+func (logger *tCaptureLogger) Printf(format string, a ...interface{}) {
+	logger.WriteString(fmt.Sprintf(format, a...))
+}
+
+func (logger *tCaptureLogger) Println(a ...interface{}) {
+	logger.WriteString(fmt.Sprintln(a...))
+}
+
+// ------------------------------------------- TestDiffRowFunc
+
+func TestDiffRowFunc(t *testing.T) {
+
+	// Worked by hand: "cat" -> "hat" is one substitution.
+	expectedRows := [][]float32{
+		{0, 1, 2, 3},
+		{1, 1, 2, 3},
+		{2, 2, 1, 2},
+		{3, 3, 2, 1},
+	}
+
+	var rows [][]float32
+	rowFn := func (i int, row []float32) {
+		if i != len(rows) {
+			t.Errorf("Expected row %d, got row %d", len(rows), i)
+		}
+		rows = append(rows, append([]float32(nil), row...))
+	}
+
+	s, u := MakeComparableString("cat"), MakeComparableString("hat")
+	distance, alignment := Diff_v2WithRowFunc(s, u, rowFn)
+	_, expectedAlignment := Diff_v2(s, u)
+
+	if distance != 1 {
+		t.Errorf("Expected distance 1, got %v", distance)
+	}
+	if fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+		t.Errorf("Expected the same alignment as Diff_v2, got %v", alignment.Links)
+	}
+	if fmt.Sprint(rows) != fmt.Sprint(expectedRows) {
+		t.Errorf("Expected rows %v, got %v", expectedRows, rows)
+	}
+}
+
+// ------------------------------------------- TestMatrixDumper
+
+func TestMatrixDumper(t *testing.T) {
+
+	s, u := MakeComparableString("cat"), MakeComparableString("hat")
+	logger := new(tCaptureLogger)
+	Diff_v2WithRowFunc(s, u, NewMatrixDumper(s, u, logger, 0))
+
+	lines := strings.Split(strings.TrimRight(logger.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected a title, a header and 4 rows, got:\n%s", logger.String())
+	}
+	lastRow := strings.Fields(lines[5])
+	if strings.Join(lastRow, " ") != "3 t 3.00 3.00 2.00 1.00" {
+		t.Errorf("Unexpected last row %q", lines[5])
+	}
+
+	// Downsampled, only some rows and columns are shown, but always the last ones.
+	long := MakeComparableString(strings.Repeat("x", 20))
+	logger = new(tCaptureLogger)
+	Diff_v2WithRowFunc(long, long, NewMatrixDumper(long, long, logger, 5))
+	lines = strings.Split(strings.TrimRight(logger.String(), "\n"), "\n")
+	lastRow = strings.Fields(lines[len(lines) - 1])
+	if lastRow[0] != "20" || lastRow[len(lastRow) - 1] != "0.00" {
+		t.Errorf("Unexpected last row %q", lines[len(lines) - 1])
+	}
+	if len(lines) - 2 > 7 || len(lastRow) - 2 > 7 {
+		t.Errorf("Expected the matrix to be downsampled, got:\n%s", logger.String())
+	}
+}
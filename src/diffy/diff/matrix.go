@@ -0,0 +1,184 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// "matrix.go" - Diffing every pair of several files, for a matrix report of
+// which variants diverge from which.
+
+// -------------------------------------------
+// ------------------------------------------- SequenceSimilarity
+// -------------------------------------------
+
+// Convert the edit distance between two sequences of lengths "m" and "n" into
+// a similarity between 0.0 (nothing in common) and 1.0 (identical).  Two empty
+// sequences are identical.
+
+func SequenceSimilarity(distance float32, m, n int) float32 {
+	longest := m
+	if n > longest {
+		longest = n
+	}
+	if longest == 0 {
+		return 1.0
+	}
+	return 1.0 - distance / float32(longest)
+}
+
+// -------------------------------------------
+// ------------------------------------------- type MatrixCell
+// -------------------------------------------
+
+// A MatrixCell holds the result of diffing one file (the row) against another
+// (the column).  The alignment has the row file on the left.
+
+type MatrixCell struct {
+	Distance float32
+	Similarity float32
+	Identical bool
+	Alignment *Alignment
+	Err error			// set, with no alignment, if diffing the pair failed
+	Generated bool		// set, with no alignment, if either file is generated and the pair wasn't diffed
+}
+
+// -------------------------------------------
+// ------------------------------------------- DiffMatrix
+// -------------------------------------------
+
+// Diff every unordered pair of "files" with "diffFn" and return the N x N
+// matrix of results.  Each pair is only diffed once: cell [j][i] is the mirror
+// image of cell [i][j].  The diagonal, and any pair of identical files, are
+// filled in without running the diff at all.  Identical files are found by
+// prefiltering with a DiffHash of each whole file, since files whose hashes
+// aren't 100% similar can't possibly be identical.
+
+func DiffMatrix(files []ComparableLines, diffFn DiffFunc) [][]MatrixCell {
+	return DiffMatrixWithJobs(files, diffFn, 1)
+}
+
+// ------------------------------------------- DiffMatrixWithJobs
+
+// DiffMatrix, running up to "jobs" diffs at once.  Each diff has its own
+// distance matrix, so "jobs" bounds the memory used as well as the CPUs.  The
+// result is the same whatever the number of jobs, since every pair has its own
+// cells to fill in.  A pair whose diff panics gets an error in its cells, and
+// the other pairs carry on regardless.
+
+func DiffMatrixWithJobs(files []ComparableLines, diffFn DiffFunc, jobs int) [][]MatrixCell {
+	return DiffMatrixSkippingGenerated(files, diffFn, jobs, nil)
+}
+
+// ------------------------------------------- DiffMatrixSkippingGenerated
+
+// DiffMatrixWithJobs, without diffing the pairs where either file is marked
+// in "generated".  Their cells are marked Generated instead, unless the files
+// are identical, which is cheap enough to find out anyway.
+
+func DiffMatrixSkippingGenerated(files []ComparableLines, diffFn DiffFunc, jobs int, generated []bool) [][]MatrixCell {
+
+	fileHashes := make([]DiffHash, len(files))
+	fileTexts := make([]string, len(files))
+	for i, lines := range files {
+		texts := make([]string, len(lines))
+		for index, line := range lines {
+			texts[index] = line.Text
+		}
+		fileTexts[i] = strings.Join(texts, "\n")
+		fileHashes[i].Init(fileTexts[i])
+	}
+
+	identical := func (i, j int) bool {
+		return len(files[i]) == len(files[j]) &&
+			fileHashes[i].Similarity(fileHashes[j]) == 1.0 &&
+			fileTexts[i] == fileTexts[j]
+	}
+
+	matrix := make([][]MatrixCell, len(files))
+	for i := range matrix {
+		matrix[i] = make([]MatrixCell, len(files))
+	}
+
+	setCells := func (i, j int, cell MatrixCell) {
+		matrix[i][j] = cell
+		if cell.Alignment != nil {
+			cell.Alignment = cell.Alignment.Swap()
+		}
+		matrix[j][i] = cell
+	}
+
+	diffPair := func (i, j int) (cell MatrixCell) {
+		defer func () {
+			if r := recover(); r != nil {
+				cell = MatrixCell{Err: fmt.Errorf("diffing file %d with file %d failed: %v", i, j, r)}
+			}
+		}()
+		distance, alignment := diffFn(files[i], files[j])
+		return MatrixCell{
+			Distance: distance,
+			Similarity: SequenceSimilarity(distance, len(files[i]), len(files[j])),
+			Alignment: alignment,
+		}
+	}
+
+	// The cheap cells are filled in directly; the rest are queued for the workers.
+	type tPair struct{ i, j int }
+	var pairs []tPair
+	for i := range files {
+		for j := i; j < len(files); j++ {
+			if i == j || identical(i, j) {
+				setCells(i, j, MatrixCell{Distance: 0.0, Similarity: 1.0, Identical: true, Alignment: matchingAlignment(len(files[i]))})
+			} else if generated != nil && (generated[i] || generated[j]) {
+				setCells(i, j, MatrixCell{Generated: true})
+			} else {
+				pairs = append(pairs, tPair{i, j})
+			}
+		}
+	}
+
+	RunJobs(len(pairs), jobs, func (index int) {
+		setCells(pairs[index].i, pairs[index].j, diffPair(pairs[index].i, pairs[index].j))
+	})
+
+	return matrix
+}
+
+// ------------------------------------------- RunJobs
+
+// Call "job" with each index from 0 to "count" - 1, on up to "jobs"
+// goroutines at once, and return once every call has.  The calls may finish
+// in any order, so a job should store its result by its index.
+func RunJobs(count, jobs int, job func (index int)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	queue := make(chan int)
+	var waitGroup sync.WaitGroup
+	for worker := 0; worker < jobs && worker < count; worker++ {
+		waitGroup.Add(1)
+		go func () {
+			defer waitGroup.Done()
+			for index := range queue {
+				job(index)
+			}
+		}()
+	}
+	for index := 0; index < count; index++ {
+		queue <- index
+	}
+	close(queue)
+	waitGroup.Wait()
+}
+
+// ------------------------------------------- matchingAlignment
+
+// Return the alignment of a sequence of "length" items with itself.
+func matchingAlignment(length int) *Alignment {
+	links := make([]Link, length)
+	for index := range links {
+		links[index] = Link{Matching, index, index}
+	}
+	return &Alignment{Links: links}
+}
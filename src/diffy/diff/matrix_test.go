@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- TestDiffMatrix
+
+func TestDiffMatrix(t *testing.T) {
+
+	files := []ComparableLines{
+		makeTestLines("host = alpha", "port = 80", "debug = false"),
+		makeTestLines("host = alpha", "port = 8080", "debug = false"),
+		makeTestLines("host = alpha", "port = 80", "debug = false"),
+		makeTestLines("host = beta", "timeout = 30"),
+	}
+
+	diffCount := 0
+	countingDiff := func (s, u ComparableSequence) (float32, *Alignment) {
+		diffCount++
+		return Diff_v2(s, u)
+	}
+	matrix := DiffMatrix(files, countingDiff)
+
+	for i := range files {
+		if matrix[i][i].Distance != 0.0 || matrix[i][i].Similarity != 1.0 {
+			t.Errorf("Expected a zero distance on the diagonal, got %v at [%d][%d]", matrix[i][i].Distance, i, i)
+		}
+		for j := range files {
+			if matrix[i][j].Distance != matrix[j][i].Distance || matrix[i][j].Similarity != matrix[j][i].Similarity {
+				t.Errorf("Expected [%d][%d] and [%d][%d] to be symmetric, got %v and %v", i, j, j, i, matrix[i][j], matrix[j][i])
+			}
+			checkAlignmentCoverage(t, matrix[i][j].Alignment, len(files[i]), len(files[j]))
+		}
+	}
+
+	// Files 0 and 2 are identical, which is found without diffing them.
+	if !matrix[0][2].Identical || matrix[0][2].Distance != 0.0 {
+		t.Errorf("Expected files 0 and 2 to be identical, got %v", matrix[0][2])
+	}
+	if diffCount != 5 {
+		t.Errorf("Expected 5 of the 6 pairs to be diffed, got %d", diffCount)
+	}
+	if matrix[0][1].Distance <= 0.0 || matrix[0][1].Distance >= matrix[0][3].Distance {
+		t.Errorf("Expected file 1 to be closer to file 0 than file 3 is, got %v and %v", matrix[0][1].Distance, matrix[0][3].Distance)
+	}
+
+	// The mirrored cell's alignment has the sides swapped.
+	if fmt.Sprint(matrix[3][0].Alignment.Swap().Links) != fmt.Sprint(matrix[0][3].Alignment.Links) {
+		t.Errorf("Expected the alignment at [3][0] to mirror the one at [0][3]")
+	}
+}
+
+// ------------------------------------------- TestDiffMatrixWithJobs
+
+func TestDiffMatrixWithJobs(t *testing.T) {
+
+	var files []ComparableLines
+	for i := 0; i < 6; i++ {
+		files = append(files, makeTestLines("header", fmt.Sprintf("version = %d", i), "footer", fmt.Sprint(i * i)))
+	}
+	files = append(files, files[2])
+
+	describe := func (matrix [][]MatrixCell) string {
+		var description string
+		for i := range matrix {
+			for _, cell := range matrix[i] {
+				description += fmt.Sprint(cell.Distance, cell.Similarity, cell.Identical, cell.Alignment.Links, "\n")
+			}
+		}
+		return description
+	}
+
+	expected := describe(DiffMatrix(files, Diff_v2))
+	for _, jobs := range []int{0, 2, 4, 16} {
+		if result := describe(DiffMatrixWithJobs(files, Diff_v2, jobs)); result != expected {
+			t.Errorf("Expected the same matrix with %d jobs", jobs)
+		}
+	}
+
+	// A pair which fails doesn't stop the others.
+	failingDiff := func (s, u ComparableSequence) (float32, *Alignment) {
+		if s.Length() > 0 && s.GetItemAt(1).(*TextLine).Text == "version = 1" && u.GetItemAt(1).(*TextLine).Text == "version = 3" {
+			panic("out of memory")
+		}
+		return Diff_v2(s, u)
+	}
+	matrix := DiffMatrixWithJobs(files, failingDiff, 4)
+	for i := range matrix {
+		for j := range matrix[i] {
+			failed := (i == 1 && j == 3) || (i == 3 && j == 1)
+			if (matrix[i][j].Err != nil) != failed {
+				t.Errorf("Unexpected error state at [%d][%d]: %v", i, j, matrix[i][j].Err)
+			}
+			if !failed && matrix[i][j].Alignment == nil {
+				t.Errorf("Expected an alignment at [%d][%d]", i, j)
+			}
+		}
+	}
+}
+
+// ------------------------------------------- TestDiffMatrixSkippingGenerated
+
+func TestDiffMatrixSkippingGenerated(t *testing.T) {
+
+	files := []ComparableLines{
+		makeTestLines("// Code generated by stringer; DO NOT EDIT.", "const a = 1"),
+		makeTestLines("// Code generated by stringer; DO NOT EDIT.", "const a = 2"),
+		makeTestLines("const a = 1"),
+		makeTestLines("const a = 3"),
+		makeTestLines("// Code generated by stringer; DO NOT EDIT.", "const a = 1"),
+	}
+	generated := []bool{true, true, false, false, true}
+
+	diffCount := 0
+	countingDiff := func (s, u ComparableSequence) (float32, *Alignment) {
+		diffCount++
+		return Diff_v2(s, u)
+	}
+	matrix := DiffMatrixSkippingGenerated(files, countingDiff, 1, generated)
+
+	// Only the pair with neither file generated is diffed.
+	if diffCount != 1 || matrix[2][3].Generated || matrix[2][3].Alignment == nil {
+		t.Errorf("Expected just the ungenerated pair to be diffed, got %d diffs", diffCount)
+	}
+	for _, pair := range [][2]int{{0, 1}, {0, 2}, {1, 3}, {3, 4}} {
+		for _, cell := range []MatrixCell{matrix[pair[0]][pair[1]], matrix[pair[1]][pair[0]]} {
+			if !cell.Generated || cell.Identical || cell.Alignment != nil {
+				t.Errorf("Expected %v to be skipped as generated, got %+v", pair, cell)
+			}
+		}
+	}
+
+	// Identical generated files are still identical.
+	if !matrix[0][4].Identical || matrix[0][4].Generated || !matrix[1][1].Identical {
+		t.Errorf("Expected identical cells for identical files")
+	}
+
+	// Without anything generated, it's the usual matrix.
+	usual := DiffMatrix(files, Diff_v2)
+	for i, row := range DiffMatrixSkippingGenerated(files, Diff_v2, 1, nil) {
+		for j, cell := range row {
+			if cell.Generated || cell.Alignment == nil || cell.Distance != usual[i][j].Distance {
+				t.Errorf("Expected the usual cell at [%d][%d] with nothing generated, got %+v", i, j, cell)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package diff
+
+// "multiset.go" - Comparing two files as multisets of lines, ignoring their
+// order entirely, e.g. for two lists of entries which may or may not have
+// been sorted.
+
+// -------------------------------------------
+// ------------------------------------------- type SetDiff
+// -------------------------------------------
+
+// The SetDiff type records which lines two files have in common, by count,
+// and which are left over on either side.  It uses the same links as an
+// Alignment, but since order is ignored, the right indexes of the Matching
+// links aren't ascending and the links don't make a valid Alignment.
+//
+// * "Matching" links pair a left line with an identical right line
+// * "LeftOnly" links are the copies of a line the left file has in excess
+// * "RightOnly" links are the copies of a line the right file has in excess
+//
+// The Matching and LeftOnly links come first, in left file order, followed
+// by the RightOnly links, in right file order.
+
+type SetDiff struct {
+	Links []Link
+}
+
+// ------------------------------------------- DiffSets
+//
+// Compare "left" and "right" as multisets of lines.  Lines are the same when
+// their compare texts are exactly equal.  If a line appears three times on
+// the left and once on the right, the first copy on each side is paired and
+// the other two left copies are LeftOnly.
+//
+func DiffSets(left, right ComparableLines) *SetDiff {
+
+	// The right indexes of each line, which are used up in order.
+	unpaired := make(map[string][]int)
+	for rightIndex, line := range right {
+		unpaired[line.CompareText()] = append(unpaired[line.CompareText()], rightIndex)
+	}
+
+	setDiff := &SetDiff{}
+	for leftIndex, line := range left {
+		if rightIndexes := unpaired[line.CompareText()]; len(rightIndexes) > 0 {
+			setDiff.Links = append(setDiff.Links, Link{Matching, leftIndex, rightIndexes[0]})
+			unpaired[line.CompareText()] = rightIndexes[1:]
+		} else {
+			setDiff.Links = append(setDiff.Links, Link{LeftOnly, leftIndex, -1})
+		}
+	}
+	for rightIndex, line := range right {
+		if rightIndexes := unpaired[line.CompareText()]; len(rightIndexes) > 0 && rightIndexes[0] == rightIndex {
+			setDiff.Links = append(setDiff.Links, Link{RightOnly, -1, rightIndex})
+			unpaired[line.CompareText()] = rightIndexes[1:]
+		}
+	}
+	return setDiff
+}
+
+// ------------------------------------------- SetDiff Counts
+
+// The number of lines in common, only in the left file, and only in the right file.
+func (setDiff *SetDiff) Counts() (common, leftOnly, rightOnly int) {
+	for _, link := range setDiff.Links {
+		switch link.LinkType {
+		case Matching:
+			common++
+		case LeftOnly:
+			leftOnly++
+		case RightOnly:
+			rightOnly++
+		default:
+			panic("not reached")
+		}
+	}
+	return common, leftOnly, rightOnly
+}
+
+// ------------------------------------------- SetDiff HasDifferences
+
+// Whether either file has a line, or a copy of a line, the other doesn't.
+func (setDiff *SetDiff) HasDifferences() bool {
+	_, leftOnly, rightOnly := setDiff.Counts()
+	return leftOnly > 0 || rightOnly > 0
+}
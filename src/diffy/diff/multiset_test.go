@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"testing"
+)
+
+// ------------------------------------------- TestDiffSets
+
+func TestDiffSets(t *testing.T) {
+
+	makeLines := func (texts ...string) ComparableLines {
+		lines := make(ComparableLines, len(texts))
+		for index, text := range texts {
+			lines[index] = NewTextLine(text)
+		}
+		return lines
+	}
+
+	// Reordered but otherwise identical files have everything in common.
+	setDiff := DiffSets(makeLines("pear", "apple", "fig", "apple"), makeLines("apple", "apple", "fig", "pear"))
+	if common, leftOnly, rightOnly := setDiff.Counts(); common != 4 || leftOnly != 0 || rightOnly != 0 {
+		t.Errorf("Expected 4 lines in common and none left over, got %d, %d, %d", common, leftOnly, rightOnly)
+	}
+	if setDiff.HasDifferences() {
+		t.Errorf("Expected reordered files not to differ")
+	}
+	for _, link := range setDiff.Links {
+		if link.LinkType != Matching {
+			t.Errorf("Expected only Matching links, got %v", link)
+		}
+	}
+
+	// Duplicates count: the extra copies on either side are left over.
+	left := makeLines("apple", "apple", "apple", "fig", "kiwi")
+	right := makeLines("kiwi", "fig", "apple", "fig", "plum")
+	setDiff = DiffSets(left, right)
+	if common, leftOnly, rightOnly := setDiff.Counts(); common != 3 || leftOnly != 2 || rightOnly != 2 {
+		t.Errorf("Expected 3 in common, 2 only on the left, and 2 only on the right, got %d, %d, %d", common, leftOnly, rightOnly)
+	}
+	expectedLinks := []Link{
+		{Matching, 0, 2},
+		{LeftOnly, 1, -1},
+		{LeftOnly, 2, -1},
+		{Matching, 3, 1},
+		{Matching, 4, 0},
+		{RightOnly, -1, 3},
+		{RightOnly, -1, 4},
+	}
+	if len(setDiff.Links) != len(expectedLinks) {
+		t.Fatalf("Expected %v, got %v", expectedLinks, setDiff.Links)
+	}
+	for index, link := range setDiff.Links {
+		if link != expectedLinks[index] {
+			t.Errorf("Link %d: expected %v, got %v", index, expectedLinks[index], link)
+		}
+	}
+	if !setDiff.HasDifferences() {
+		t.Errorf("Expected files with different duplicate counts to differ")
+	}
+}
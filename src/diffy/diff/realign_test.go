@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// "realign_test.go" - The contract of RealignUsingThreshold, checked on
+// hand-built alignments.
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+// Build an alignment and the lines it links from a string of codes, one per
+// link: " " for a matching pair, "s" for a similar Different pair, "d" for a
+// dissimilar Different pair, "-" for LeftOnly and "+" for RightOnly.
+func makeRealignTestCase(codes string) (*Alignment, ComparableLines, ComparableLines) {
+	var left, right ComparableLines
+	alignment := new(Alignment)
+	for index, code := range codes {
+		text := fmt.Sprintf("%02d the quick brown fox jumps over the lazy dog", index)
+		switch code {
+		case ' ':
+			alignment.Links = append(alignment.Links, Link{Matching, len(left), len(right)})
+			left, right = append(left, NewTextLine(text)), append(right, NewTextLine(text))
+		case 's':
+			alignment.Links = append(alignment.Links, Link{Different, len(left), len(right)})
+			left, right = append(left, NewTextLine(text)), append(right, NewTextLine(text + "!"))
+		case 'd':
+			alignment.Links = append(alignment.Links, Link{Different, len(left), len(right)})
+			left, right = append(left, NewTextLine(text)), append(right, NewTextLine(fmt.Sprintf("%02d zzz 123456789 +=+=+= QQQ", index)))
+		case '-':
+			alignment.Links = append(alignment.Links, Link{LeftOnly, len(left), -1})
+			left = append(left, NewTextLine(text))
+		case '+':
+			alignment.Links = append(alignment.Links, Link{RightOnly, -1, len(right)})
+			right = append(right, NewTextLine(text))
+		default:
+			panic("unknown link code")
+		}
+	}
+	return alignment, left, right
+}
+
+// Check that "realigned" keeps RealignUsingThreshold's promises about
+// "alignment", and report any broken ones as test errors.
+func checkRealignInvariants(t *testing.T, name string, alignment, realigned *Alignment, left, right ComparableSequence, threshold float32) {
+
+	// The present indexes on each side are still strictly ascending.
+	lastLeft, lastRight := -1, -1
+	for _, link := range realigned.Links {
+		if link.LeftIndex >= 0 {
+			if link.LeftIndex <= lastLeft {
+				t.Errorf("%s: left index %d follows %d", name, link.LeftIndex, lastLeft)
+			}
+			lastLeft = link.LeftIndex
+		}
+		if link.RightIndex >= 0 {
+			if link.RightIndex <= lastRight {
+				t.Errorf("%s: right index %d follows %d", name, link.RightIndex, lastRight)
+			}
+			lastRight = link.RightIndex
+		}
+	}
+
+	// Every index which appeared still appears, and no others.
+	indexes := func (alignment *Alignment) (leftIndexes, rightIndexes []int) {
+		for _, link := range alignment.Links {
+			if link.LeftIndex >= 0 {
+				leftIndexes = append(leftIndexes, link.LeftIndex)
+			}
+			if link.RightIndex >= 0 {
+				rightIndexes = append(rightIndexes, link.RightIndex)
+			}
+		}
+		return leftIndexes, rightIndexes
+	}
+	leftBefore, rightBefore := indexes(alignment)
+	leftAfter, rightAfter := indexes(realigned)
+	if fmt.Sprint(leftBefore) != fmt.Sprint(leftAfter) || fmt.Sprint(rightBefore) != fmt.Sprint(rightAfter) {
+		t.Errorf("%s: the indexes changed from %v/%v to %v/%v", name, leftBefore, rightBefore, leftAfter, rightAfter)
+	}
+
+	// No Different pair costs more than the threshold, and the pairs which
+	// survive are the ones which were there before.
+	pairs := make(map[Link]bool)
+	for _, link := range alignment.Links {
+		pairs[link] = true
+	}
+	for _, link := range realigned.Links {
+		if link.LinkType == Different && left.GetItemAt(link.LeftIndex).Compare(right.GetItemAt(link.RightIndex)) > threshold {
+			t.Errorf("%s: the pair %v is too dissimilar to survive", name, link)
+		}
+		if (link.LinkType == Matching || link.LinkType == Different) && !pairs[link] {
+			t.Errorf("%s: the pair %v is new", name, link)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestRealignUsingThreshold
+// -------------------------------------------
+
+func TestRealignUsingThreshold(t *testing.T) {
+
+	threshold := float32(DEFAULT_REALIGN_THRESHOLD)
+
+	// Make sure the similar and dissimilar pairs really are on either side of
+	// the threshold.
+	_, left, right := makeRealignTestCase("sd")
+	if cost := left[0].Compare(right[0]); cost > threshold {
+		t.Fatalf("Expected the similar pair to cost at most %v, got %v", threshold, cost)
+	}
+	if cost := left[1].Compare(right[1]); cost <= threshold {
+		t.Fatalf("Expected the dissimilar pair to cost more than %v, got %v", threshold, cost)
+	}
+
+	// The codes after realigning, in the same notation as Dump.
+	testCases := []struct {
+		codes, expected string
+	}{
+		{"", ""},
+		{"   ", "   "},
+		{" s ", " * "},
+		{" d ", " -+ "},
+		{"-+", "-+"},
+
+		// Consecutive splits put all the deletions before the insertions.
+		{"ddd", "---+++"},
+		{" ddd ", " ---+++ "},
+
+		// A similar pair or a matching one ends a run of splits.
+		{"ddsdd", "--++*--++"},
+		{"dd dd", "--++ --++"},
+
+		// Deletions and insertions already in the alignment stay in place.
+		// Like any link which isn't split, they end a run of splits, so the
+		// split ones interleave with them.
+		{"d-d", "-+--+"},
+		{"d+d", "-++-+"},
+		{"-d+d-", "--++-+-"},
+		{"+dsd-", "+-+*-+-"},
+	}
+	for _, testCase := range testCases {
+		alignment, left, right := makeRealignTestCase(testCase.codes)
+		realigned := alignment.RealignUsingThreshold(left, right, threshold)
+		if codes := alignmentCodes(realigned); codes != testCase.expected {
+			t.Errorf("%q: expected %q, got %q", testCase.codes, testCase.expected, codes)
+		}
+		checkRealignInvariants(t, fmt.Sprintf("%q", testCase.codes), alignment, realigned, left, right, threshold)
+	}
+
+	// A selection of an alignment, with gaps in its indexes, is realigned
+	// just the same.
+	alignment, left, right := makeRealignTestCase(" dd  s  d-d  +d ")
+	selected := alignment.OnlyChanges(Different, 1)
+	checkRealignInvariants(t, "selection", selected, selected.RealignUsingThreshold(left, right, threshold), left, right, threshold)
+
+	// Whatever the threshold, on whatever Diff_v2 comes up with.
+	rng := rand.New(rand.NewSource(1199))
+	for trial := 0; trial < 20; trial++ {
+		left, right := generateFilePair(rng, 60)
+		_, alignment := Diff_v2(left, right)
+		for _, threshold := range []float32{0.0, 0.2, DEFAULT_REALIGN_THRESHOLD, 0.8, 1.0} {
+			realigned := alignment.RealignUsingThreshold(left, right, threshold)
+			checkRealignInvariants(t, fmt.Sprintf("trial %d at %v", trial, threshold), alignment, realigned, left, right, threshold)
+		}
+	}
+}
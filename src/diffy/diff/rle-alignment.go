@@ -0,0 +1,99 @@
+package diff
+
+// "rle-alignment.go" - A run-length encoded form of Alignment, for files which
+// are mostly identical, where one Link per matching line adds up to a lot of
+// memory for very little information.
+
+// -------------------------------------------
+// ------------------------------------------- type RLEAlignment
+// -------------------------------------------
+
+// An RLEAlignment holds the same links as an Alignment, but each run of
+// consecutive links of the same type, with contiguous indexes, is stored as a
+// single LinkRun.  E.g. a million matching lines is a single run.
+
+type RLEAlignment struct {
+	Runs []LinkRun
+}
+
+// A LinkRun is "Count" links, starting with "First".  Each link after the
+// first has the same type, and each present index is one more than the one
+// before.
+
+type LinkRun struct {
+	First Link
+	Count int
+}
+
+// ------------------------------------------- LinkRun LinkAt method
+
+// The link at "offset" within the run, counting from zero.
+func (run LinkRun) LinkAt(offset int) Link {
+	link := run.First
+	if link.LeftIndex >= 0 {
+		link.LeftIndex += offset
+	}
+	if link.RightIndex >= 0 {
+		link.RightIndex += offset
+	}
+	return link
+}
+
+// ------------------------------------------- LinkRun Last method
+
+// The last link in the run.
+func (run LinkRun) Last() Link {
+	return run.LinkAt(run.Count - 1)
+}
+
+// ------------------------------------------- Alignment ToRLE method
+
+// Run-length encode the alignment.
+func (alignment *Alignment) ToRLE() *RLEAlignment {
+	rle := &RLEAlignment{}
+	for _, link := range alignment.Links {
+		if last := len(rle.Runs) - 1; last >= 0 {
+			run := &rle.Runs[last]
+			if run.LinkAt(run.Count) == link {
+				run.Count++
+				continue
+			}
+		}
+		rle.Runs = append(rle.Runs, LinkRun{link, 1})
+	}
+	return rle
+}
+
+// ------------------------------------------- RLEAlignment Len method
+
+// The number of links, i.e. the length of the equivalent Alignment's Links.
+func (rle *RLEAlignment) Len() int {
+	length := 0
+	for _, run := range rle.Runs {
+		length += run.Count
+	}
+	return length
+}
+
+// ------------------------------------------- RLEAlignment ForEachLink method
+
+// Call "fn" with each link in order, without materializing the whole Links
+// slice.
+func (rle *RLEAlignment) ForEachLink(fn func (link Link)) {
+	for _, run := range rle.Runs {
+		for offset := 0; offset < run.Count; offset++ {
+			fn(run.LinkAt(offset))
+		}
+	}
+}
+
+// ------------------------------------------- RLEAlignment ToAlignment method
+
+// Expand the runs back into a flat Alignment.
+func (rle *RLEAlignment) ToAlignment() *Alignment {
+	alignment := &Alignment{Links: make([]Link, 0, rle.Len())}
+	rle.ForEachLink(func (link Link) {
+		alignment.Links = append(alignment.Links, link)
+	})
+	return alignment
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// ------------------------------------------- TestRLEAlignmentRoundTrip
+
+func TestRLEAlignmentRoundTrip(t *testing.T) {
+
+	testCases := []struct {
+		alignment *Alignment
+		runCount int
+	}{
+		{&Alignment{}, 0},
+		{&Alignment{Links: []Link{{Matching, 0, 0}}}, 1},
+		{&Alignment{Links: []Link{{Matching, 0, 0}, {Matching, 1, 1}, {Matching, 2, 2}}}, 1},
+		{&Alignment{Links: []Link{{Matching, 0, 0}, {Different, 1, 1}, {Different, 2, 2}, {Matching, 3, 3}}}, 3},
+		{&Alignment{Links: []Link{{LeftOnly, 0, -1}, {LeftOnly, 1, -1}, {RightOnly, -1, 0}, {RightOnly, -1, 1}, {Matching, 2, 2}}}, 3},
+		{&Alignment{Links: []Link{{LeftOnly, 0, -1}, {Matching, 1, 0}, {Matching, 2, 1}, {RightOnly, -1, 2}}}, 3},
+	}
+
+	for _, testCase := range testCases {
+		rle := testCase.alignment.ToRLE()
+		if len(rle.Runs) != testCase.runCount {
+			t.Errorf("%v: expected %d runs, got %v", testCase.alignment.Links, testCase.runCount, rle.Runs)
+		}
+		if rle.Len() != len(testCase.alignment.Links) {
+			t.Errorf("%v: expected %d links, got %d", testCase.alignment.Links, len(testCase.alignment.Links), rle.Len())
+		}
+		if roundTrip := rle.ToAlignment(); fmt.Sprint(roundTrip.Links) != fmt.Sprint(testCase.alignment.Links) {
+			t.Errorf("Expected %v to round trip, got %v", testCase.alignment.Links, roundTrip.Links)
+		}
+	}
+
+	// Real alignments of random files round trip too.
+	rng := rand.New(rand.NewSource(1157))
+	charSet := []rune(ACCURACY_CHAR_SET)
+	for i := 0; i < 20; i++ {
+		s := randomString(rng, charSet, 30)
+		_, alignment := Diff_v2(MakeComparableString(s), MakeComparableString(mutateString(rng, charSet, s, 1 + rng.Intn(10))))
+		if roundTrip := alignment.ToRLE().ToAlignment(); fmt.Sprint(roundTrip.Links) != fmt.Sprint(alignment.Links) {
+			t.Errorf("Expected %v to round trip, got %v", alignment.Links, roundTrip.Links)
+		}
+	}
+}
+
+// ------------------------------------------- TestRLEAlignmentLargeRun
+
+func TestRLEAlignmentLargeRun(t *testing.T) {
+	const lineCount = 1000000
+	alignment := matchingAlignment(lineCount)
+	alignment.Links[lineCount / 2].LinkType = Different
+
+	rle := alignment.ToRLE()
+	if len(rle.Runs) != 3 || rle.Runs[2].First != (Link{Matching, lineCount / 2 + 1, lineCount / 2 + 1}) || rle.Runs[2].Last() != (Link{Matching, lineCount - 1, lineCount - 1}) {
+		t.Errorf("Expected a matching run either side of the change, got %v", rle.Runs)
+	}
+}
@@ -0,0 +1,215 @@
+package diff
+
+import "fmt"
+
+// "segment.go" - Plumbing for diffing sequences piecewise.  A big diff can be
+// broken up into smaller, independent diffs over sub-sequences, and the
+// resulting alignments can then be shifted back into place and stitched
+// together into one alignment covering the original sequences.
+
+// -------------------------------------------
+// ------------------------------------------- type DiffFunc
+// -------------------------------------------
+
+// DiffFunc is the signature shared by the sequence diff algorithms, such as
+// Diff_v2.  Passes which break a diff up into pieces take a DiffFunc so they
+// can be used with any algorithm.
+
+type DiffFunc func(s, t ComparableSequence) (float32, *Alignment)
+
+// -------------------------------------------
+// ------------------------------------------- type SubSequence
+// -------------------------------------------
+
+// A SubSequence is a view onto selected items of a larger sequence.  Item i
+// of the SubSequence is item Indexes[i] of the underlying sequence.  The
+// indexes must be ascending, but need not be contiguous, which makes it
+// possible to skip over individual items.
+
+type SubSequence struct {
+	Sequence ComparableSequence
+	Indexes []int
+}
+
+// Assert that ComparableSequence is implemented by SubSequence.
+var _ ComparableSequence = (*SubSequence)(nil)
+
+// ------------------------------------------- NewSubSequence SubSequence factory function
+
+// Create a SubSequence covering the contiguous items [start, end) of "seq".
+func NewSubSequence(seq ComparableSequence, start, end int) *SubSequence {
+	indexes := make([]int, 0, end - start)
+	for index := start; index < end; index++ {
+		indexes = append(indexes, index)
+	}
+	return &SubSequence{Sequence: seq, Indexes: indexes}
+}
+
+// -------------------------------------------
+
+func (sub *SubSequence) Length() int {
+	return len(sub.Indexes)
+}
+
+// -------------------------------------------
+
+func (sub *SubSequence) GetItemAt(index int) Comparable {
+	return sub.Sequence.GetItemAt(sub.Indexes[index])
+}
+
+// -------------------------------------------
+
+func (sub *SubSequence) GetDescription() string {
+	return fmt.Sprintf("%d of %s", len(sub.Indexes), sub.Sequence.GetDescription())
+}
+
+// -------------------------------------------
+
+// A view onto a sequence compares its items the same way.
+func (sub *SubSequence) EqualityOnly() bool {
+	return isEqualityOnly(sub.Sequence)
+}
+
+// ------------------------------------------- Alignment Shift
+
+// Return a copy of the alignment with "leftOffset" added to every present left
+// index and "rightOffset" added to every present right index.  This moves an
+// alignment computed over contiguous sub-sequences back into the coordinates
+// of the full sequences.
+func (alignment *Alignment) Shift(leftOffset, rightOffset int) *Alignment {
+	newLinks := make([]Link, len(alignment.Links))
+	for i, link := range alignment.Links {
+		if link.LeftIndex >= 0 {
+			link.LeftIndex += leftOffset
+		}
+		if link.RightIndex >= 0 {
+			link.RightIndex += rightOffset
+		}
+		newLinks[i] = link
+	}
+	return &Alignment{Links: newLinks}
+}
+
+// ------------------------------------------- Alignment Remap
+
+// Return a copy of the alignment with every present left index i replaced by
+// leftIndexes[i] and every present right index j replaced by rightIndexes[j].
+// This is the SubSequence equivalent of Shift.
+func (alignment *Alignment) Remap(leftIndexes, rightIndexes []int) *Alignment {
+	newLinks := make([]Link, len(alignment.Links))
+	for i, link := range alignment.Links {
+		if link.LeftIndex >= 0 {
+			link.LeftIndex = leftIndexes[link.LeftIndex]
+		}
+		if link.RightIndex >= 0 {
+			link.RightIndex = rightIndexes[link.RightIndex]
+		}
+		newLinks[i] = link
+	}
+	return &Alignment{Links: newLinks}
+}
+
+// -------------------------------------------
+// ------------------------------------------- DiffBetweenAnchors
+// -------------------------------------------
+
+// Diff two sequences piecewise.  The "anchors" are links (ascending, with both
+// indexes present) which are locked into the final alignment as-is.  The gaps
+// between consecutive anchors, along with the gaps before the first anchor and
+// after the last one, are each diffed independently with "diffFn".
+//
+// Items listed in "leftExcluded" or "rightExcluded" are left out of the gap
+// diffs entirely and show up in the final alignment as LeftOnly or RightOnly
+// links, so they can never be paired with anything.
+//
+// The returned distance is the sum of the gap distances plus the costs of any
+// anchors whose items aren't identical.
+func DiffBetweenAnchors(s, t ComparableSequence, anchors []Link, leftExcluded, rightExcluded []int, diffFn DiffFunc) (float32, *Alignment) {
+
+	leftIsExcluded := make(map[int]bool)
+	for _, index := range leftExcluded {
+		leftIsExcluded[index] = true
+	}
+	rightIsExcluded := make(map[int]bool)
+	for _, index := range rightExcluded {
+		rightIsExcluded[index] = true
+	}
+
+	// Collect the items in [start, end) which haven't been excluded.
+	selectIndexes := func (start, end int, isExcluded map[int]bool) []int {
+		var indexes []int
+		for index := start; index < end; index++ {
+			if !isExcluded[index] {
+				indexes = append(indexes, index)
+			}
+		}
+		return indexes
+	}
+
+	var totalDistance float32
+	var links []Link
+
+	// Diff the gap [leftStart, leftEnd) x [rightStart, rightEnd) and append the result.
+	diffGap := func (leftStart, leftEnd, rightStart, rightEnd int) {
+		leftIndexes := selectIndexes(leftStart, leftEnd, leftIsExcluded)
+		rightIndexes := selectIndexes(rightStart, rightEnd, rightIsExcluded)
+		gapLinks := []Link{}
+		if len(leftIndexes) > 0 || len(rightIndexes) > 0 {
+			distance, alignment := diffFn(&SubSequence{s, leftIndexes}, &SubSequence{t, rightIndexes})
+			totalDistance += distance
+			gapLinks = alignment.Remap(leftIndexes, rightIndexes).Links
+		}
+		links = append(links, insertExcludedLinks(gapLinks, leftStart, leftEnd, rightStart, rightEnd, leftIsExcluded, rightIsExcluded)...)
+	}
+
+	leftStart, rightStart := 0, 0
+	for _, anchor := range anchors {
+		diffGap(leftStart, anchor.LeftIndex, rightStart, anchor.RightIndex)
+		cost := s.GetItemAt(anchor.LeftIndex).Compare(t.GetItemAt(anchor.RightIndex))
+		totalDistance += cost
+		links = append(links, anchor)
+		leftStart, rightStart = anchor.LeftIndex + 1, anchor.RightIndex + 1
+	}
+	diffGap(leftStart, s.Length(), rightStart, t.Length())
+
+	return totalDistance, &Alignment{Links: links}
+}
+
+// ------------------------------------------- insertExcludedLinks
+
+// Merge single-sided links for the excluded items in a gap into the gap's
+// links.  Each excluded item is placed just after the last link which refers
+// to an earlier item on the same side, keeping the indexes ascending.
+func insertExcludedLinks(links []Link, leftStart, leftEnd, rightStart, rightEnd int, leftIsExcluded, rightIsExcluded map[int]bool) []Link {
+
+	insert := func (links []Link, newLink Link, isBefore func (link Link) bool) []Link {
+		position := 0
+		for i, link := range links {
+			if isBefore(link) {
+				position = i + 1
+			}
+		}
+		links = append(links, Link{})
+		copy(links[position + 1:], links[position:])
+		links[position] = newLink
+		return links
+	}
+
+	for index := leftStart; index < leftEnd; index++ {
+		if leftIsExcluded[index] {
+			leftIndex := index
+			links = insert(links, Link{LeftOnly, leftIndex, -1}, func (link Link) bool {
+				return link.LeftIndex >= 0 && link.LeftIndex < leftIndex
+			})
+		}
+	}
+	for index := rightStart; index < rightEnd; index++ {
+		if rightIsExcluded[index] {
+			rightIndex := index
+			links = insert(links, Link{RightOnly, -1, rightIndex}, func (link Link) bool {
+				return link.RightIndex >= 0 && link.RightIndex < rightIndex
+			})
+		}
+	}
+	return links
+}
@@ -0,0 +1,66 @@
+package diff
+
+// "similarity-cache.go" - Memoizing comparisons between items, for passes
+// which compare the same pairs of lines over and over.
+
+// -------------------------------------------
+// ------------------------------------------- type CompareFunc
+// -------------------------------------------
+
+// A CompareFunc compares two items the same way Comparable.Compare does.
+// Passes which compare items take a CompareFunc so that the comparisons can
+// be cached, or counted.
+
+type CompareFunc func(left, right Comparable) float32
+
+// Compare two items directly, with no caching.
+func DirectCompare(left, right Comparable) float32 {
+	return left.Compare(right)
+}
+
+// -------------------------------------------
+// ------------------------------------------- type SimilarityCache
+// -------------------------------------------
+
+// A SimilarityCache remembers the result of comparing each pair of TextLines,
+// so that later passes over the same pair get the answer for free.  Lines are
+// identified by pointer, so a cache is only good for a single diff run, over
+// a single set of lines.  Items which aren't TextLines are compared directly,
+// without caching.
+
+type SimilarityCache struct {
+	entries map[tLinePair]float32
+	Hits, Misses int
+}
+
+type tLinePair struct {
+	left, right *TextLine
+}
+
+// ------------------------------------------- NewSimilarityCache SimilarityCache factory function
+
+func NewSimilarityCache() *SimilarityCache {
+	return &SimilarityCache{entries: make(map[tLinePair]float32)}
+}
+
+// ------------------------------------------- SimilarityCache Compare method
+
+// Compare is a CompareFunc; pass "cache.Compare" to the passes which should
+// share the cache.
+func (cache *SimilarityCache) Compare(left, right Comparable) float32 {
+	leftLine, leftIsLine := left.(*TextLine)
+	rightLine, rightIsLine := right.(*TextLine)
+	if !leftIsLine || !rightIsLine {
+		return left.Compare(right)
+	}
+
+	key := tLinePair{leftLine, rightLine}
+	if cost, found := cache.entries[key]; found {
+		cache.Hits++
+		return cost
+	}
+	cache.Misses++
+	cost := left.Compare(right)
+	cache.entries[key] = cost
+	return cost
+}
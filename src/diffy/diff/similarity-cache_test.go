@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// ------------------------------------------- helper functions
+
+// Generate a pair of files in which every line has been edited, so the
+// alignment is nearly all Different links and realigning has lots to compare.
+func generateRealignHeavyPair(rng *rand.Rand, lineCount int) (ComparableLines, ComparableLines) {
+	charSet := []rune(ACCURACY_CHAR_SET)
+	var leftLines, rightLines ComparableLines
+	for i := 0; i < lineCount; i++ {
+		text := randomString(rng, charSet, 40)
+		leftLines = append(leftLines, NewTextLine(text))
+		rightLines = append(rightLines, NewTextLine(mutateString(rng, charSet, text, 1 + rng.Intn(20))))
+	}
+	return leftLines, rightLines
+}
+
+// The thresholds used by the realignment passes in the cache tests.
+var realignThresholds = []float32{0.2, 0.4, 0.6, 0.8}
+
+// ------------------------------------------- TestSimilarityCache
+
+func TestSimilarityCache(t *testing.T) {
+
+	left, right := generateRealignHeavyPair(rand.New(rand.NewSource(1147)), 200)
+	_, alignment := Diff_v2(left, right)
+
+	differentCount := 0
+	for _, link := range alignment.Links {
+		if link.LinkType == Different {
+			differentCount++
+		}
+	}
+
+	cache := NewSimilarityCache()
+	for _, threshold := range realignThresholds {
+		expected := alignment.RealignUsingThreshold(left, right, threshold)
+		cached := alignment.RealignUsingThresholdWith(left, right, threshold, cache.Compare)
+		if fmt.Sprint(cached.Links) != fmt.Sprint(expected.Links) {
+			t.Errorf("Threshold %v: the cache changed the result", threshold)
+		}
+	}
+
+	if cache.Misses != differentCount {
+		t.Errorf("Expected one real comparison per Different link (%d), got %d", differentCount, cache.Misses)
+	}
+	if cache.Hits != differentCount * (len(realignThresholds) - 1) {
+		t.Errorf("Expected every later pass to hit the cache, got %d hits", cache.Hits)
+	}
+
+	// Anything but a pair of TextLines goes straight through.
+	if cost := cache.Compare(ComparableRune('a'), ComparableRune('b')); cost != 1.0 {
+		t.Errorf("Expected a direct comparison of runes, got %v", cost)
+	}
+}
+
+// ------------------------------------------- BenchmarkRealignPasses
+
+func BenchmarkRealignPasses(b *testing.B) {
+
+	left, right := generateRealignHeavyPair(rand.New(rand.NewSource(1147)), 1000)
+	_, alignment := Diff_v2(left, right)
+
+	for _, useCache := range []bool{false, true} {
+		name := "uncached"
+		if useCache {
+			name = "cached"
+		}
+		b.Run(name, func (b *testing.B) {
+			compareCount := 0
+			countingCompare := func (leftItem, rightItem Comparable) float32 {
+				compareCount++
+				return leftItem.Compare(rightItem)
+			}
+			for i := 0; i < b.N; i++ {
+				compare := countingCompare
+				var cache *SimilarityCache
+				if useCache {
+					cache = NewSimilarityCache()
+					compare = cache.Compare
+				}
+				for _, threshold := range realignThresholds {
+					alignment.RealignUsingThresholdWith(left, right, threshold, compare)
+				}
+				if cache != nil {
+					compareCount += cache.Misses
+				}
+			}
+			b.ReportMetric(float64(compareCount) / float64(b.N), "similarity-calls/op")
+		})
+	}
+}
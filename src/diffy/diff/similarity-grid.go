@@ -0,0 +1,28 @@
+package diff
+
+// "similarity-grid.go" - How similar every line of one file is to every line
+// of the other, for seeing why Diff_v2 paired the lines it did.
+
+// The most items on either side worth putting in a grid.  The grid grows with
+// the product of the lengths, and past this size it's too big to look at.
+const MAX_SIMILARITY_GRID_SIZE = 100
+
+// -------------------------------------------
+// ------------------------------------------- SimilarityGrid
+// -------------------------------------------
+
+// The similarity of every item of "s" with every item of "t", where grid[i][j]
+// is 1 - Compare of s[i] with t[j]: 1 for identical items, and 0 for items
+// with nothing in common.  Every pair is compared, unlike in Diff_v2, which
+// only compares the pairs it has to, so keep "s" and "t" small.
+
+func SimilarityGrid(s, t ComparableSequence) [][]float32 {
+	grid := make([][]float32, s.Length())
+	for i := range grid {
+		grid[i] = make([]float32, t.Length())
+		for j := range grid[i] {
+			grid[i][j] = 1.0 - s.GetItemAt(i).Compare(t.GetItemAt(j))
+		}
+	}
+	return grid
+}
@@ -0,0 +1,31 @@
+package diff
+
+import (
+	"testing"
+)
+
+// ------------------------------------------- TestSimilarityGrid
+
+func TestSimilarityGrid(t *testing.T) {
+
+	left := makeTestLines("alpha beta", "gamma")
+	right := makeTestLines("gamma", "alpha beta", "zzz")
+	grid := SimilarityGrid(left, right)
+
+	if len(grid) != 2 || len(grid[0]) != 3 || len(grid[1]) != 3 {
+		t.Fatalf("Expected a 2 x 3 grid, got %v", grid)
+	}
+	for i := range grid {
+		for j, similarity := range grid[i] {
+			if expected := 1.0 - left[i].Compare(right[j]); similarity != expected {
+				t.Errorf("[%d][%d]: expected %v, got %v", i, j, expected, similarity)
+			}
+		}
+	}
+	if grid[0][1] != 1.0 || grid[1][0] != 1.0 {
+		t.Errorf("Expected identical lines to be 1.0 similar, got %v", grid)
+	}
+	if grid[0][2] >= grid[0][1] {
+		t.Errorf("Expected unrelated lines to be less similar than identical ones, got %v", grid)
+	}
+}
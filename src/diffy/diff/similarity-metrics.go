@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"sort"
+)
+
+// "similarity-metrics.go" - Alternative ways of measuring how similar two lines
+// are, selectable by name with Options.Similarity, for experimenting with
+// alignment quality.
+
+// -------------------------------------------
+// ------------------------------------------- similarityMetrics
+// -------------------------------------------
+
+// A SimilarityFunc rates the similarity of two strings between 0.0 (nothing
+// alike) and 1.0 (identical).
+type SimilarityFunc func(a, b string) float32
+
+const DEFAULT_SIMILARITY_METRIC = "diffhash"
+
+// The metrics, by name.  Lines using the default "diffhash" metric use their
+// precomputed DiffHashes rather than this entry, which is much slower.
+var similarityMetrics = map[string]SimilarityFunc{
+	"diffhash": diffHashSimilarity,
+	"levenshtein": LevenshteinSimilarity,
+	"trigram": TrigramSimilarity,
+}
+
+// ------------------------------------------- SimilarityMetricNames
+
+// The names of the available metrics, in alphabetical order.
+func SimilarityMetricNames() []string {
+	var names []string
+	for name := range similarityMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ------------------------------------------- IsSimilarityMetric
+
+func IsSimilarityMetric(name string) bool {
+	_, found := similarityMetrics[name]
+	return found
+}
+
+// ------------------------------------------- lookupSimilarityMetric
+
+// The metric a line should use for "name", or nil to use its DiffHash.
+func lookupSimilarityMetric(name string) SimilarityFunc {
+	if name == "" || name == DEFAULT_SIMILARITY_METRIC {
+		return nil
+	}
+	return similarityMetrics[name]
+}
+
+// ------------------------------------------- diffHashSimilarity
+
+func diffHashSimilarity(a, b string) float32 {
+	var aHash, bHash DiffHash
+	aHash.Init(a)
+	bHash.Init(b)
+	return aHash.Similarity(bHash)
+}
+
+// ------------------------------------------- TrigramSimilarity
+
+// The Jaccard similarity of the sets of three rune substrings of "a" and "b":
+// the number of trigrams they share over the number they have between them.
+// A string too short to have any trigrams counts as a single trigram.
+func TrigramSimilarity(a, b string) float32 {
+	trigrams := func (s string) map[string]bool {
+		runes := []rune(s)
+		set := make(map[string]bool)
+		if len(runes) < 3 {
+			set[s] = true
+		}
+		for index := 0; index + 3 <= len(runes); index++ {
+			set[string(runes[index:index + 3])] = true
+		}
+		return set
+	}
+	aTrigrams, bTrigrams := trigrams(a), trigrams(b)
+	shared := 0
+	for trigram := range aTrigrams {
+		if bTrigrams[trigram] {
+			shared++
+		}
+	}
+	return float32(shared) / float32(len(aTrigrams) + len(bTrigrams) - shared)
+}
@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"testing"
+)
+
+// ------------------------------------------- TestSimilarityMetrics
+
+func TestSimilarityMetrics(t *testing.T) {
+
+	pairs := [][2]string{
+		{"", ""},
+		{"x", "x"},
+		{"return nil", "return nil"},
+		{"", "abc"},
+		{"ab", "ba"},
+		{"the quick brown fox", "the quick brown box"},
+		{"abcdefgh", "zyxwvuts"},
+		{"He’s Alive!", "It’s Alive!"},
+	}
+
+	// Every metric rates identical strings 1 and everything else between 0 and 1.
+	for _, name := range SimilarityMetricNames() {
+		metric := similarityMetrics[name]
+		for _, pair := range pairs {
+			similarity := metric(pair[0], pair[1])
+			if pair[0] == pair[1] && similarity != 1.0 {
+				t.Errorf("%s(%q, %q): expected 1.0 for identical strings, got %v", name, pair[0], pair[1], similarity)
+			}
+			if similarity < 0.0 || similarity > 1.0 {
+				t.Errorf("%s(%q, %q): expected a similarity between 0 and 1, got %v", name, pair[0], pair[1], similarity)
+			}
+			if reversed := metric(pair[1], pair[0]); reversed != similarity {
+				t.Errorf("%s(%q, %q): expected a symmetric similarity, got %v and %v", name, pair[0], pair[1], similarity, reversed)
+			}
+		}
+	}
+
+	if !IsSimilarityMetric(DEFAULT_SIMILARITY_METRIC) || !IsSimilarityMetric("trigram") || IsSimilarityMetric("soundex") {
+		t.Errorf("Unexpected metric names %v", SimilarityMetricNames())
+	}
+}
+
+// ------------------------------------------- TestSimilarityMetricSelection
+
+func TestSimilarityMetricSelection(t *testing.T) {
+
+	// "ab" and "ba" have the same DiffHash, but no trigrams in common.
+	similarity := func (name string) float32 {
+		result := Compare([]string{"ab"}, []string{"ba"}, Options{Similarity: name})
+		return result.Left[0].Similarity(result.Right[0])
+	}
+	if got := similarity(""); got != 1.0 {
+		t.Errorf("Expected the default DiffHash to rate \"ab\" and \"ba\" as identical, got %v", got)
+	}
+	if got := similarity(DEFAULT_SIMILARITY_METRIC); got != 1.0 {
+		t.Errorf("Expected %q to be the DiffHash, got %v", DEFAULT_SIMILARITY_METRIC, got)
+	}
+	if got := similarity("trigram"); got != 0.0 {
+		t.Errorf("Expected the trigram metric to rate \"ab\" and \"ba\" as different, got %v", got)
+	}
+	if got := similarity("levenshtein"); got != 0.0 {
+		t.Errorf("Expected the Levenshtein metric, floored, to rate \"ab\" and \"ba\" as different, got %v", got)
+	}
+}
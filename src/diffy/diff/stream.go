@@ -0,0 +1,320 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"diffy/etc"
+)
+
+// "stream.go" - The programmatic interface: read two texts, diff them, and
+// deliver the result as a stream of per-line events, with no HTML involved.
+
+// -------------------------------------------
+// ------------------------------------------- type Options
+// -------------------------------------------
+
+// Options control how text is read and compared.  The zero value gives the
+// defaults.
+
+type Options struct {
+	TabSize int			// tab stops for expanding tabs; zero means 4
+	StripAnsi bool		// remove ANSI color and other CSI escape sequences before comparing
+	NormalizeTypography bool	// compare curly quotes, dashes and ellipses as their ASCII equivalents
+	MinHashLen int		// lines shorter than this many runes are only similar when identical
+	LinePool *LinePool	// if set, identical lines share one TextLine from the pool
+	CommentSyntax *etc.CommentSyntax	// if set, compare lines with their comments stripped
+	StringLiterals *etc.QuoteSyntax	// if set, compare lines with the contents of their string literals replaced by a placeholder
+	Similarity string	// the name of the similarity metric; empty means DEFAULT_SIMILARITY_METRIC
+	MaxLineLength int	// if positive, truncate longer lines to this many runes, and compare them that way
+	TrailingToken *regexp.Regexp	// if set, compare lines without the token it matches at the end of each, e.g. a timestamp; see etc.CompileTrailingToken
+	StripLinePrefix string	// if set, compare lines which start with this without it, e.g. a "> " quote prefix
+	StripLineSuffix string	// likewise for lines which end with this
+	PreSplit bool		// take each line literally, as an already split token or record, with none of the above applied
+	Sentences bool		// read prose one sentence at a time rather than one line at a time; see ReadSentences
+}
+
+const DEFAULT_TAB_SIZE = 4
+
+// Shown after a line truncated by "MaxLineLength", with the number of runes cut off.
+const TRUNCATION_MARKER_FORMAT = "…[truncated %d chars]"
+
+func (opts Options) tabSize() int {
+	if opts.TabSize <= 0 {
+		return DEFAULT_TAB_SIZE
+	}
+	return opts.TabSize
+}
+
+// -------------------------------------------
+// ------------------------------------------- ReadLines
+// -------------------------------------------
+
+// Read all the lines from "reader".  Tabs are expanded and line endings are
+// stripped for comparison, but each line keeps its raw text and indentation
+// for display.  Besides the lines, report whether the text ends with a
+// newline.  Empty text is considered to end with a newline, since it isn't
+// missing one.
+
+func ReadLines(reader io.Reader, opts Options) (ComparableLines, bool, error) {
+
+	if opts.Sentences {
+		return ReadSentences(reader, opts)
+	}
+
+	bufferedReader := bufio.NewReader(reader)
+
+	var lines ComparableLines
+	finalNewline := true
+	nextLine := newLineMaker(opts)
+	for {
+		strLine, err := bufferedReader.ReadString('\n')
+		if len(strLine) > 0 {
+			finalNewline = strings.HasSuffix(strLine, "\n")
+			lines = append(lines, nextLine(strLine))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return lines, finalNewline, nil
+}
+
+// ------------------------------------------- ReadSentences
+
+// Read all the sentences from "reader", as split by etc.SplitSentences, one
+// TextLine per sentence.  A reflowed paragraph has the same sentences as
+// before, so each sentence is read with its runs of whitespace, line breaks
+// included, collapsed to single spaces.  As with ReadLines, also report
+// whether the text ends with a newline.
+
+func ReadSentences(reader io.Reader, opts Options) (ComparableLines, bool, error) {
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, false, err
+	}
+	text := string(content)
+
+	var sentences ComparableLines
+	nextLine := newLineMaker(opts)
+	for _, sentence := range etc.SplitSentences(text) {
+		sentences = append(sentences, nextLine(strings.Join(strings.Fields(sentence), " ")))
+	}
+	return sentences, text == "" || strings.HasSuffix(text, "\n"), nil
+}
+
+// ------------------------------------------- newLineMaker
+
+// Return a function which makes the TextLines for successive lines of a text.
+// Lines aren't quite independent: a block comment can run from one line into
+// the next, which matters when comparing without comments.
+func newLineMaker(opts Options) func (text string) *TextLine {
+	openBlockEnd := ""
+	return func (text string) *TextLine {
+		line := newLine(text, opts, openBlockEnd)
+		if opts.CommentSyntax != nil && !opts.PreSplit {
+			_, openBlockEnd = opts.CommentSyntax.StripComments(line.Text, openBlockEnd)
+		}
+		return line
+	}
+}
+
+// ------------------------------------------- newLine
+
+// Make a TextLine from a line of text as read, possibly with its line ending,
+// which starts inside a block comment ending with "openBlockEnd", if that
+// isn't empty.  The line comes from the pool, if there is one.
+func newLine(text string, opts Options, openBlockEnd string) *TextLine {
+	if opts.LinePool != nil {
+		return opts.LinePool.intern(lineKey(text, opts) + lineEnding(text), openBlockEnd, opts, func () *TextLine { return makeLine(text, opts, openBlockEnd) })
+	}
+	return makeLine(text, opts, openBlockEnd)
+}
+
+// ------------------------------------------- makeLine
+
+func makeLine(text string, opts Options, openBlockEnd string) *TextLine {
+	if opts.PreSplit {
+		return makeLiteralLine(text, opts)
+	}
+	if opts.StripAnsi {
+		text = etc.StripAnsiEscapes(text)
+	}
+	rawText, marker := truncateLine(stripLineEndings(text), opts.MaxLineLength)
+	expandedText := etc.ExpandTabs(rawText, opts.tabSize())
+	compareText := strings.TrimSuffix(strings.TrimPrefix(expandedText, opts.StripLinePrefix), opts.StripLineSuffix)
+	if opts.NormalizeTypography {
+		compareText = etc.NormalizeTypography(compareText)
+	}
+	if opts.CommentSyntax != nil {
+		compareText, _ = opts.CommentSyntax.StripComments(compareText, openBlockEnd)
+	}
+	if opts.StringLiterals != nil {
+		compareText = opts.StringLiterals.ReplaceStringLiterals(compareText)
+	}
+	if opts.TrailingToken != nil {
+		compareText = etc.ReplaceTrailingToken(compareText, opts.TrailingToken)
+	}
+	expandedText, rawText = expandedText + marker, rawText + marker
+	line := NewNormalizedTextLine(expandedText, compareText)
+	line.MinHashLen = opts.MinHashLen
+	line.similarity = lookupSimilarityMetric(opts.Similarity)
+	line.RawIndent = leadingWhitespace(rawText)
+	line.RawText = rawText
+	line.LineEnding = lineEnding(text)
+	return line
+}
+
+// ------------------------------------------- truncateLine
+
+// Cut "text" down to "maxLength" runes, if it's longer and "maxLength" is
+// positive, and return it along with the marker to show after it, which is
+// "" if nothing was cut.  The marker isn't compared, so two lines which only
+// differ past the cut compare as identical.
+func truncateLine(text string, maxLength int) (string, string) {
+	if maxLength <= 0 || len(text) <= maxLength {
+		return text, ""
+	}
+	runeCount := 0
+	for index := range text {
+		if runeCount == maxLength {
+			return text[:index], fmt.Sprintf(TRUNCATION_MARKER_FORMAT, utf8.RuneCountInString(text[index:]))
+		}
+		runeCount++
+	}
+	return text, ""
+}
+
+// ------------------------------------------- makeLiteralLine
+
+// A pre-split line is compared byte for byte as it reads.  Only the newline
+// separating it from the next line is dropped: a carriage return, a tab or an
+// escape sequence is part of the token like any other character.
+func makeLiteralLine(text string, opts Options) *TextLine {
+	rawText := lineKey(text, opts)
+	line := NewTextLine(rawText)
+	line.MinHashLen = opts.MinHashLen
+	line.similarity = lookupSimilarityMetric(opts.Similarity)
+	line.RawIndent = leadingWhitespace(rawText)
+	line.RawText = rawText
+	line.LineEnding = text[len(rawText):]
+	return line
+}
+
+// ------------------------------------------- lineKey
+
+// The text of a line as read, without the line ending that isn't part of it.
+func lineKey(text string, opts Options) string {
+	if opts.PreSplit {
+		return strings.TrimSuffix(text, "\n")
+	}
+	return stripLineEndings(text)
+}
+
+// ------------------------------------------- lineEnding
+
+// The line ending at the end of "text", e.g. "\r\n", or "" if it has none.
+func lineEnding(text string) string {
+	return text[len(strings.TrimRight(text, "\r\n")):]
+}
+
+// ------------------------------------------- stripLineEndings
+
+func stripLineEndings(s string) string {
+	return strings.Map(func (char rune) rune {
+		if char == '\n' || char == '\r' {
+			return -1
+		}
+		return char
+	}, s)
+}
+
+// ------------------------------------------- leadingWhitespace
+
+func leadingWhitespace(s string) string {
+	for index, char := range s {
+		if char != ' ' && char != '\t' {
+			return s[:index]
+		}
+	}
+	return s
+}
+
+// -------------------------------------------
+// ------------------------------------------- type LineEvent
+// -------------------------------------------
+
+// A LineEvent describes what happened to one line, or one pair of lines.  The
+// Kind is the type of the underlying alignment link.  Left and Right are nil
+// when the line doesn't exist on that side, in which case the index is -1.
+// The Confidence is the link's LinkConfidence.
+
+type LineEvent struct {
+	Kind LinkType
+	Left, Right *TextLine
+	LeftIndex, RightIndex int
+	Confidence float32
+}
+
+// -------------------------------------------
+// ------------------------------------------- Stream
+// -------------------------------------------
+
+// Read "left" and "right" in full, diff them, and stream one LineEvent per
+// alignment link, in order.  Pairs of lines which are too dissimilar to be
+// considered changed versions of each other are reported as a deletion and
+// an insertion, just as the HTML shows them.
+//
+// The events channel is closed when the last event has been sent.  If either
+// reader fails, the error is sent on the error channel instead and no events
+// are sent.  Either way the error channel is closed at the end, so it's safe
+// to read from it after draining the events.
+
+func Stream(left, right io.Reader, opts Options) (<-chan LineEvent, <-chan error) {
+
+	events := make(chan LineEvent, 64)
+	errs := make(chan error, 1)
+
+	go func () {
+		defer close(errs)
+		defer close(events)
+
+		leftLines, _, err := ReadLines(left, opts)
+		if err != nil {
+			errs <- err
+			return
+		}
+		rightLines, _, err := ReadLines(right, opts)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		_, alignment := Diff_v2(leftLines, rightLines)
+		alignment = alignment.RealignUsingThreshold(leftLines, rightLines, DEFAULT_REALIGN_THRESHOLD)
+
+		for _, link := range alignment.Links {
+			event := LineEvent{Kind: link.LinkType, LeftIndex: link.LeftIndex, RightIndex: link.RightIndex}
+			event.Confidence = LinkConfidence(link, leftLines, rightLines)
+			if link.LeftIndex >= 0 {
+				event.Left = leftLines[link.LeftIndex]
+			}
+			if link.RightIndex >= 0 {
+				event.Right = rightLines[link.RightIndex]
+			}
+			events <- event
+		}
+	}()
+
+	return events, errs
+}
@@ -0,0 +1,295 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"diffy/etc"
+)
+
+// ------------------------------------------- TestStream
+
+func TestStream(t *testing.T) {
+
+	type tEvent struct {
+		kind LinkType
+		left, right string
+	}
+
+	testCases := []struct {
+		left, right string
+		expected []tEvent
+	}{
+		{
+			"alpha\nbravo\ncharlie\ndelta\n",
+			"alpha\nbravo!\ndelta\n",
+			[]tEvent{
+				{Matching, "alpha", "alpha"},
+				{Different, "bravo", "bravo!"},
+				{LeftOnly, "charlie", ""},
+				{Matching, "delta", "delta"},
+			},
+		},
+		{
+			"alpha\ndelta\n",
+			"alpha\ndelta\necho\n",
+			[]tEvent{
+				{Matching, "alpha", "alpha"},
+				{Matching, "delta", "delta"},
+				{RightOnly, "", "echo"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		events, errs := Stream(strings.NewReader(testCase.left), strings.NewReader(testCase.right), Options{})
+
+		var got []tEvent
+		for event := range events {
+			item := tEvent{kind: event.Kind}
+			if event.Left != nil {
+				item.left = event.Left.Text
+			} else if event.LeftIndex != -1 {
+				t.Errorf("Expected a missing left line to have index -1, got %d", event.LeftIndex)
+			}
+			if event.Right != nil {
+				item.right = event.Right.Text
+			} else if event.RightIndex != -1 {
+				t.Errorf("Expected a missing right line to have index -1, got %d", event.RightIndex)
+			}
+			got = append(got, item)
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(testCase.expected) {
+			t.Errorf("Expected events %v, got %v", testCase.expected, got)
+		}
+	}
+}
+
+// ------------------------------------------- TestStreamError
+
+type tFailingReader struct{}
+
+func (reader tFailingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestStreamError(t *testing.T) {
+	events, errs := Stream(strings.NewReader("a\n"), tFailingReader{}, Options{})
+	for event := range events {
+		t.Errorf("Expected no events, got %v", event)
+	}
+	if err := <-errs; err == nil || err.Error() != "read failed" {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+}
+
+// ------------------------------------------- TestReadLines
+
+func TestReadLines(t *testing.T) {
+	lines, finalNewline, err := ReadLines(strings.NewReader("\tx\r\n  y"), Options{TabSize: 8})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if finalNewline {
+		t.Errorf("Expected no final newline")
+	}
+	if len(lines) != 2 || lines[0].Text != "        x" || lines[0].RawText != "\tx" || lines[0].RawIndent != "\t" || lines[1].RawIndent != "  " {
+		t.Errorf("Unexpected lines %q", []string{lines[0].Text, lines[0].RawText, lines[1].Text})
+	}
+}
+
+// ------------------------------------------- TestReadLinesPreSplit
+
+func TestReadLinesPreSplit(t *testing.T) {
+
+	// Everything that would normally be expanded, stripped or normalized is
+	// kept, except the newlines between the lines.
+	text := "\tx\r\n\x1b[31mred\x1b[0m\n“quoted”\ncode // comment\n"
+	opts := Options{TabSize: 8, StripAnsi: true, NormalizeTypography: true, CommentSyntax: &etc.CommentSyntax{LineMarkers: []string{"//"}}, PreSplit: true, LinePool: NewLinePool()}
+	lines, _, err := ReadLines(strings.NewReader(text), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := []string{"\tx\r", "\x1b[31mred\x1b[0m", "“quoted”", "code // comment"}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d", len(expected), len(lines))
+	}
+	for index, textLine := range lines {
+		if textLine.Text != expected[index] || textLine.RawText != expected[index] || textLine.CompareText() != expected[index] {
+			t.Errorf("Line %d: expected %q, got %q compared as %q", index, expected[index], textLine.Text, textLine.CompareText())
+		}
+	}
+
+	// So lines which differ only by what's normally ignored don't match.
+	otherLines, _, _ := ReadLines(strings.NewReader("\tx\nred\n\"quoted\"\ncode\n"), opts)
+	for index := range otherLines {
+		if cost := lines[index].Compare(otherLines[index]); cost == 0.0 {
+			t.Errorf("Line %d: expected %q and %q to differ", index, lines[index].Text, otherLines[index].Text)
+		}
+	}
+}
+
+// ------------------------------------------- TestReadLinesMaxLineLength
+
+func TestReadLinesMaxLineLength(t *testing.T) {
+
+	opts := Options{MaxLineLength: 5}
+	lines, _, err := ReadLines(strings.NewReader("short\nlonger line\n\tésumé résumé\n"), opts)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// Lines over the limit are cut to that many runes, tabs included, with
+	// a marker saying how much was cut.  The marker isn't compared.
+	expected := []struct {
+		text, rawText, compareText string
+	}{
+		{"short", "short", "short"},
+		{"longe…[truncated 6 chars]", "longe…[truncated 6 chars]", "longe"},
+		{"    ésum…[truncated 8 chars]", "\tésum…[truncated 8 chars]", "    ésum"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d", len(expected), len(lines))
+	}
+	for index, line := range lines {
+		if line.Text != expected[index].text || line.RawText != expected[index].rawText || line.CompareText() != expected[index].compareText {
+			t.Errorf("Line %d: expected %q (raw %q) compared as %q, got %q (raw %q) compared as %q", index,
+				expected[index].text, expected[index].rawText, expected[index].compareText, line.Text, line.RawText, line.CompareText())
+		}
+	}
+
+	// Lines which only differ past the cut compare as equal, even with a
+	// different amount cut off.
+	leftLines, _, _ := ReadLines(strings.NewReader("same\nabcdefghij\n"), opts)
+	rightLines, _, _ := ReadLines(strings.NewReader("same\nabcdeXYZ\n"), opts)
+	if cost := leftLines[1].Compare(rightLines[1]); cost != 0.0 {
+		t.Errorf("Expected %q and %q to compare as equal, got a cost of %v", leftLines[1].Text, rightLines[1].Text, cost)
+	}
+	if distance, _ := Diff_v2(leftLines, rightLines); distance != 0.0 {
+		t.Errorf("Expected no differences, got a distance of %v", distance)
+	}
+
+	// Without a limit, nothing is cut.
+	lines, _, _ = ReadLines(strings.NewReader("longer line\n"), Options{})
+	if lines[0].Text != "longer line" {
+		t.Errorf("Expected the whole line, got %q", lines[0].Text)
+	}
+}
+
+// ------------------------------------------- TestReadLinesTrailingToken
+
+func TestReadLinesTrailingToken(t *testing.T) {
+
+	token, _ := etc.CompileTrailingToken(`[0-9a-f]{7}`)
+	opts := Options{TrailingToken: token}
+	read := func (text string) ComparableLines {
+		lines, _, err := ReadLines(strings.NewReader(text), opts)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		return lines
+	}
+
+	// The bodies match, so only the hashes differ, and they aren't compared.
+	left := read("step one 3f9a2c1\nstep two 0be11a7\nstep three 77c0d13\n")
+	right := read("step one 9d8e7f6\nstep two a1b2c3d\nstep three 77c0d13\n")
+	_, alignment := Diff_v2(left, right)
+	expected := []Link{{Matching, 0, 0}, {Matching, 1, 1}, {Matching, 2, 2}}
+	if fmt.Sprint(alignment.Links) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, alignment.Links)
+	}
+
+	// The hash is still shown.
+	if left[0].Text != "step one 3f9a2c1" || left[0].RawText != "step one 3f9a2c1" {
+		t.Errorf("Expected the token to be kept for display, got %q (raw %q)", left[0].Text, left[0].RawText)
+	}
+
+	// Where the bodies differ, so do the lines, and a line with a token differs from one without.
+	right = read("step one 9d8e7f6\nstep 2 a1b2c3d\nstep three\n")
+	_, alignment = Diff_v2(left, right)
+	for index, linkType := range []LinkType{Matching, Different, Different} {
+		if link := alignment.Links[index]; link.LinkType != linkType {
+			t.Errorf("Expected link %d to be %v, got %v", index, linkType, link)
+		}
+	}
+}
+
+// ------------------------------------------- TestReadLinesStripLineAffix
+
+func TestReadLinesStripLineAffix(t *testing.T) {
+
+	read := func (text string, opts Options) ComparableLines {
+		lines, _, err := ReadLines(strings.NewReader(text), opts)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		return lines
+	}
+
+	// A quoted reply lines up with the original, line for line.
+	left := read("> Thanks for the patch.\n> It looks good,\n>\n> but needs tests.\n", Options{StripLinePrefix: "> "})
+	right := read("Thanks for the patch.\nIt looks good,\n>\nbut needs tests.\n", Options{StripLinePrefix: "> "})
+	_, alignment := Diff_v2(left, right)
+	expected := []Link{{Matching, 0, 0}, {Matching, 1, 1}, {Matching, 2, 2}, {Matching, 3, 3}}
+	if fmt.Sprint(alignment.Links) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, alignment.Links)
+	}
+
+	// The prefix is still shown, and a line without it, like the bare ">", is left alone.
+	if left[0].Text != "> Thanks for the patch." || left[0].RawText != "> Thanks for the patch." {
+		t.Errorf("Expected the prefix to be kept for display, got %q (raw %q)", left[0].Text, left[0].RawText)
+	}
+	if left[2].CompareText() != ">" {
+		t.Errorf("Expected a line without the prefix to be compared as it is, got %q", left[2].CompareText())
+	}
+
+	// Likewise a suffix, such as a line number annotation.
+	left = read("alpha  // 1\nbeta  // 2\n", Options{StripLineSuffix: "  // 1"})
+	if left[0].CompareText() != "alpha" || left[1].CompareText() != "beta  // 2" {
+		t.Errorf("Expected just the matching suffix to be stripped, got %q and %q", left[0].CompareText(), left[1].CompareText())
+	}
+}
+
+// ------------------------------------------- TestReadSentences
+
+func TestReadSentences(t *testing.T) {
+
+	original := "The quick brown fox jumps over the lazy dog. It was not amused.\nNeither was the\nfox, to be fair. The end.\n"
+	reflowed := "The quick brown fox\njumps over the lazy dog. It was\nnot amused. Neither was the fox, to\nbe fair. The end.\n"
+
+	// Line by line, every line has changed.
+	left, _, _ := ReadLines(strings.NewReader(original), Options{})
+	right, _, _ := ReadLines(strings.NewReader(reflowed), Options{})
+	_, alignment := Diff_v2(left, right)
+	if counts := countLinkTypes(alignment); counts[Matching] != 0 {
+		t.Errorf("Expected no matching lines, got %v", alignment.Links)
+	}
+
+	// Sentence by sentence, nothing has.
+	left, leftFinalNewline, err := ReadLines(strings.NewReader(original), Options{Sentences: true})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	right, _, _ = ReadLines(strings.NewReader(reflowed), Options{Sentences: true})
+	if len(left) != 4 || left[2].Text != "Neither was the fox, to be fair." || !leftFinalNewline {
+		t.Errorf("Unexpected sentences %q", []string{left[0].Text, left[1].Text, left[2].Text})
+	}
+	distance, alignment := Diff_v2(left, right)
+	if counts := countLinkTypes(alignment); distance != 0 || counts[Matching] != 4 {
+		t.Errorf("Expected the reflowed sentences to match, got %v", alignment.Links)
+	}
+
+	// An edited sentence is a changed pair.
+	edited := strings.Replace(reflowed, "lazy dog", "lazy cat", 1)
+	right, _, _ = ReadLines(strings.NewReader(edited), Options{Sentences: true})
+	_, alignment = Diff_v2(left, right)
+	if counts := countLinkTypes(alignment); counts[Matching] != 3 || counts[Different] != 1 {
+		t.Errorf("Expected one changed sentence, got %v", alignment.Links)
+	}
+}
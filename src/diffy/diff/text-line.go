@@ -3,6 +3,8 @@ package diff
 import (
 	"fmt"
 	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 // "text-line.go" - Types, methods, and functions for working with lines of text.
@@ -164,32 +166,139 @@ func (slice uint32_slice_sortAdaptor) Less(i, j int) bool { return slice[i] < sl
 // The TextLine type is used to represent a single line of text.
 // Notably, each TextLine has a precomputed DiffHash so rapid 
 // similarity computations can be made between two TextLines.
+//
+// "RawIndent" is the line's leading whitespace exactly as it was read,
+// before any tab expansion.  It is optional, and is only used to detect
+// tabs-vs-spaces indentation changes.
+//
+// "RawText" is the whole line as it was read, tabs and all, minus the line
+// ending.  It is also optional, and is only used for display.  Comparisons
+// always use the expanded "Text".
+//
+// "LineEnding" is the line ending the line was read with, e.g. "\r\n", so a
+// unified diff can write the line back as it was.  It's empty for the last
+// line of a file without a final newline, or a line which wasn't read.
+//
+// "MinHashLen" is the shortest line, in runes, whose DiffHash can be trusted.
+// Short lines have few hashes, so two unrelated short lines (e.g. "ab" and
+// "ba") can look very similar.  If either of two lines is shorter than the
+// larger of their MinHashLens, they are only similar if they are identical.
+// Zero means always use the DiffHash.
+//
+// "similarity" is the metric selected with Options.Similarity, if it isn't
+// the default DiffHash one.
+//
+// "weight" scales what it costs Diff_v2 to insert, delete or replace the
+// line.  Zero means 1.  See NewTextLineWeighted.
 
 type TextLine struct {
 	Text string
+	RawIndent string
+	RawText string
+	LineEnding string
+	MinHashLen int
 	diffHash DiffHash
+	compareText string		// the text the DiffHash was computed from
+	compareLength int		// the length of "compareText" in runes
+	similarity SimilarityFunc	// if set, used instead of the DiffHash
+	weight float32			// the cost of inserting or deleting the line; zero means 1
 }
 
 // ------------------------------------------- NewTextLine TextLine factory function
 
 func NewTextLine(text string) *TextLine {
-	line := TextLine{Text:text}
-	line.diffHash.Init(text)
+	return NewNormalizedTextLine(text, text)
+}
+
+// ------------------------------------------- NewNormalizedTextLine
+
+// Make a TextLine which displays "text" but compares as "normalized", e.g.
+// with typographic quotes replaced by plain ones.
+func NewNormalizedTextLine(text, normalized string) *TextLine {
+	line := TextLine{Text:text, compareText:normalized, compareLength:utf8.RuneCountInString(normalized)}
+	line.diffHash.Init(normalized)
 	return &line
 }
 
+// ------------------------------------------- NewTextLineWeighted
+
+// Make a TextLine which costs "weight" to insert or delete, rather than 1, so
+// that Diff_v2 goes further out of its way to keep a heavy line matched, e.g.
+// a function signature rather than a blank line.  Pairing the line up with a
+// different one is scaled by the weight as well, from nothing for an
+// identical line up to the whole weight.  The weight must be positive.
+func NewTextLineWeighted(text string, weight float32) *TextLine {
+	if weight <= 0 {
+		panic(fmt.Sprintf("NewTextLineWeighted: the weight must be positive, got %v", weight))
+	}
+	line := NewTextLine(text)
+	line.weight = weight
+	return line
+}
+
+// ------------------------------------------- TextLine Weight method
+
+func (line *TextLine) Weight() float32 {
+	if line.weight == 0 {
+		return 1.0
+	}
+	return line.weight
+}
+
+// ------------------------------------------- TextLine CompareText method
+
+// The text the line is compared as, e.g. with typography normalized.  For a
+// line made by NewTextLine, it's just the text.
+func (line *TextLine) CompareText() string {
+	return line.compareText
+}
+
 // ------------------------------------------- TextLine Similarity method
 
+// The similarity Diff_v2 goes by: the RawSimilarity, with anything below 0.6
+// counted as nothing in common.
 func (line1 *TextLine) Similarity(line2 *TextLine) float32 {
-	similarityFactor := line1.diffHash.Similarity(line2.diffHash)
+	similarityFactor := line1.RawSimilarity(line2)
 	if similarityFactor < 0.6 { similarityFactor = 0.0 }
 	return similarityFactor
 }
 
+// ------------------------------------------- TextLine RawSimilarity method
+
+// The similarity of two lines as their metric measures it, between 0.0 and
+// 1.0.  Lines too short for their DiffHashes to be trusted are either
+// identical or have nothing in common.
+func (line1 *TextLine) RawSimilarity(line2 *TextLine) float32 {
+	minHashLen := line1.MinHashLen
+	if line2.MinHashLen > minHashLen {
+		minHashLen = line2.MinHashLen
+	}
+	if line1.compareLength < minHashLen || line2.compareLength < minHashLen {
+		if line1.compareText == line2.compareText {
+			return 1.0
+		}
+		return 0.0
+	}
+	var similarityFactor float32
+	if line1.similarity != nil {
+		similarityFactor = line1.similarity(line1.compareText, line2.compareText)
+	} else {
+		similarityFactor = line1.diffHash.Similarity(line2.diffHash)
+	}
+	return similarityFactor
+}
+
 // ------------------------------------------- TextLine Compare method
 
+// Most lines in a typical diff are unchanged, so identical lines skip the
+// DiffHash comparison.  They'd be 100% similar anyway.  The check is on the
+// text the lines are compared as, so normalized lines take the fast path too.
 func (line1 *TextLine) Compare(line2 Comparable) float32 {
-	return 1.0 - line1.Similarity(line2.(*TextLine))
+	other := line2.(*TextLine)
+	if line1.compareText == other.compareText {
+		return 0.0
+	}
+	return 1.0 - line1.Similarity(other)
 }
 
 // ------------------------------------------- TextLine Stringify method
@@ -207,6 +316,44 @@ func (line *TextLine) Stringify(maxWidth int) string {
 	return string(runes)
 }
 
+// ------------------------------------------- IndentStyle
+
+// Classify the raw leading whitespace of a line as "tabs", "spaces", or
+// "mixed".  An empty indent has no style and yields the empty string.
+func IndentStyle(rawIndent string) string {
+	hasTabs := strings.ContainsRune(rawIndent, '\t')
+	hasSpaces := strings.ContainsRune(rawIndent, ' ')
+	switch {
+	case hasTabs && hasSpaces:
+		return "mixed"
+	case hasTabs:
+		return "tabs"
+	case hasSpaces:
+		return "spaces"
+	}
+	return ""
+}
+
+// ------------------------------------------- IndentStyleChanged
+
+// Report whether the only difference between two lines is the style of their
+// indentation, e.g. the line used to be indented with tabs but is now indented
+// with spaces.  Both lines must be indented, to the same width once tabs are
+// expanded, and their content following the indentation must be identical.
+// A line which was also indented a level further has had more than its style
+// changed.
+func IndentStyleChanged(line1, line2 *TextLine) bool {
+	style1, style2 := IndentStyle(line1.RawIndent), IndentStyle(line2.RawIndent)
+	if style1 == "" || style2 == "" || style1 == style2 {
+		return false
+	}
+	indent1, indent2 := leadingWhitespace(line1.Text), leadingWhitespace(line2.Text)
+	if len(indent1) != len(indent2) {
+		return false
+	}
+	return line1.Text[len(indent1):] == line2.Text[len(indent2):]
+}
+
 // ------------------------------------------- type ComparableLines
 
 // Type ComparableLines is a TextLine slice subtype which implements the
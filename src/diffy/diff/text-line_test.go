@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// ------------------------------------------- TestMinHashLen
+
+func TestMinHashLen(t *testing.T) {
+
+	makeLine := func (text string, minHashLen int) *TextLine {
+		line := NewTextLine(text)
+		line.MinHashLen = minHashLen
+		return line
+	}
+
+	// Without a minimum, "ab" and "ba" have the same DiffHash.
+	if similarity := makeLine("ab", 0).Similarity(makeLine("ba", 0)); similarity != 1.0 {
+		t.Errorf("Expected the DiffHash to mistake \"ab\" for \"ba\", got %v", similarity)
+	}
+
+	testCases := []struct {
+		left, right string
+		similarity float32
+	}{
+		{"ab", "ba", 0.0},
+		{"ab", "ab", 1.0},
+		{"}", "}", 1.0},
+		{"}", "{", 0.0},
+		{"});", "})", 0.0},
+		{"", "", 1.0},
+		{"abcd", "abcde", 0.0},			// "abcd" is short, so only an exact match will do
+		{"abcdefg", "abcdefgh", 0.8},	// both long enough for the DiffHash
+	}
+	for _, testCase := range testCases {
+		left, right := makeLine(testCase.left, 5), makeLine(testCase.right, 5)
+		if similarity := left.Similarity(right); similarity < testCase.similarity - 0.1 || similarity > testCase.similarity + 0.1 {
+			t.Errorf("%q vs %q: expected a similarity of about %v, got %v", testCase.left, testCase.right, testCase.similarity, similarity)
+		}
+	}
+
+	// The larger minimum of the two lines applies.
+	if similarity := makeLine("ab", 0).Similarity(makeLine("ba", 3)); similarity != 0.0 {
+		t.Errorf("Expected either line's minimum to apply, got %v", similarity)
+	}
+
+	// Normalized lines compare their normalized text exactly.
+	left, right := NewNormalizedTextLine("“a”", "\"a\""), NewTextLine("\"a\"")
+	left.MinHashLen = 5
+	if similarity := left.Similarity(right); similarity != 1.0 {
+		t.Errorf("Expected normalized short lines to match, got %v", similarity)
+	}
+}
+
+// ------------------------------------------- TestCompareIdenticalFastPath
+
+func TestCompareIdenticalFastPath(t *testing.T) {
+
+	// Identical lines, including empty and normalized ones, cost nothing.
+	for _, pair := range [][2]*TextLine{
+		{NewTextLine("same"), NewTextLine("same")},
+		{NewTextLine(""), NewTextLine("")},
+		{NewNormalizedTextLine("“a”", "\"a\""), NewTextLine("\"a\"")},
+	} {
+		if cost := pair[0].Compare(pair[1]); cost != 0.0 {
+			t.Errorf("%q vs %q: expected a cost of 0, got %v", pair[0].Text, pair[1].Text, cost)
+		}
+	}
+
+	// The fast path doesn't change any alignments.
+	rng := rand.New(rand.NewSource(1171))
+	for trial := 0; trial < 10; trial++ {
+		left, right := generateFilePair(rng, 100)
+		distance, alignment := Diff_v2(left, right)
+		expectedDistance, expectedAlignment := Diff_v2(makeHashOnlyLines(left), makeHashOnlyLines(right))
+		if distance != expectedDistance || fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+			t.Errorf("Trial %d: the fast path changed the alignment", trial)
+		}
+	}
+}
+
+// ------------------------------------------- TestWeightedLines
+
+func TestWeightedLines(t *testing.T) {
+
+	// The signature has moved from the top to the bottom, past three other lines.
+	makeLines := func (signatureWeight float32, texts ...string) ComparableLines {
+		var lines ComparableLines
+		for _, text := range texts {
+			if text == "func main() {" && signatureWeight != 1.0 {
+				lines = append(lines, NewTextLineWeighted(text, signatureWeight))
+			} else {
+				lines = append(lines, NewTextLine(text))
+			}
+		}
+		return lines
+	}
+	leftTexts := []string{"func main() {", "first := 1", "second := 2", "third := 3"}
+	rightTexts := []string{"first := 1", "second := 2", "third := 3", "func main() {"}
+	signatureLink := func (alignment *Alignment) (Link, bool) {
+		for _, link := range alignment.Links {
+			if link.LeftIndex == 0 {
+				return link, link.LinkType == Matching && link.RightIndex == 3
+			}
+		}
+		return Link{}, false
+	}
+
+	// Unweighted, it's cheapest to delete the signature and insert it again.
+	distance, alignment := Diff_v2(makeLines(1.0, leftTexts...), makeLines(1.0, rightTexts...))
+	if link, matched := signatureLink(alignment); matched || distance != 2.0 {
+		t.Errorf("Expected the unweighted signature to be deleted at a cost of 2, got %v at a cost of %v", link, distance)
+	}
+
+	// Heavy enough, it's kept matched, at the cost of the lines in between.
+	distance, alignment = Diff_v2(makeLines(10.0, leftTexts...), makeLines(10.0, rightTexts...))
+	if link, matched := signatureLink(alignment); !matched || distance != 6.0 {
+		t.Errorf("Expected the weighted signature to stay matched at a cost of 6, got %v at a cost of %v", link, distance)
+	}
+	if _, expectedAlignment := Diff_v2WithRowFunc(makeLines(10.0, leftTexts...), makeLines(10.0, rightTexts...), nil); fmt.Sprint(alignment.Links) != fmt.Sprint(expectedAlignment.Links) {
+		t.Errorf("Expected Diff_v2 to agree with the full matrix computation")
+	}
+
+	// Deleting or inserting a weighted line costs its weight.
+	if distance, _ := Diff_v2(makeLines(2.5, "func main() {"), ComparableLines{}); distance != 2.5 {
+		t.Errorf("Expected deleting the weighted line to cost 2.5, got %v", distance)
+	}
+	if distance, _ := Diff_v2(ComparableLines{}, makeLines(2.5, "func main() {", "x")); distance != 3.5 {
+		t.Errorf("Expected inserting the lines to cost 3.5, got %v", distance)
+	}
+}
@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// "token.go" - Splitting text into word-level tokens, for word-level diffs.
+
+// -------------------------------------------
+// ------------------------------------------- type WordChars
+// -------------------------------------------
+
+// A WordChars says which runes are word characters, which join up into a
+// single token.  What makes a word depends on the text: an identifier in code
+// includes its underscores, and a path includes its slashes.
+
+type WordChars func (char rune) bool
+
+// Letters, digits, and underscores, as in the identifiers of most languages.
+func CodeWordChars(char rune) bool {
+	return char == '_' || unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+// Letters and digits only, so "foo_bar" is two words.
+func ProseWordChars(char rune) bool {
+	return unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+// As for code, along with the separators and punctuation of file paths and
+// URLs, so "src/main.go" is one word.
+func PathWordChars(char rune) bool {
+	return CodeWordChars(char) || strings.ContainsRune("/\\.-~:", char)
+}
+
+var wordCharsNames = map[string]WordChars{
+	"": CodeWordChars,
+	"code": CodeWordChars,
+	"prose": ProseWordChars,
+	"path": PathWordChars,
+}
+
+// Look up a WordChars by its "--word-chars" name.
+func ParseWordChars(name string) (WordChars, bool) {
+	wordChars, found := wordCharsNames[name]
+	return wordChars, found
+}
+
+// -------------------------------------------
+// ------------------------------------------- Tokenize
+// -------------------------------------------
+
+// Split "text" into tokens.  A token is a run of word characters (letters,
+// digits, and underscores), a run of whitespace, or a single character of
+// anything else, e.g. punctuation.  Concatenating the tokens reproduces the
+// original text exactly.
+//
+// Tokenize("foo(bar, 42)")	=> {"foo", "(", "bar", ",", " ", "42", ")"}
+// Tokenize("a  b")			=> {"a", "  ", "b"}
+
+func Tokenize(text string) []string {
+	return TokenizeWith(text, CodeWordChars)
+}
+
+// ------------------------------------------- TokenizeWith
+
+// Tokenize, with "wordChars" saying which runes are word characters.  Nil
+// means CodeWordChars.  Whitespace is never a word character.
+//
+// TokenizeWith("a_b/c", ProseWordChars)	=> {"a", "_", "b", "/", "c"}
+// TokenizeWith("a_b/c", PathWordChars)	=> {"a_b/c"}
+
+func TokenizeWith(text string, wordChars WordChars) []string {
+
+	if wordChars == nil {
+		wordChars = CodeWordChars
+	}
+
+	const (
+		wordClass = iota
+		spaceClass
+		otherClass
+	)
+
+	classify := func (char rune) int {
+		switch {
+		case unicode.IsSpace(char):
+			return spaceClass
+		case wordChars(char):
+			return wordClass
+		}
+		return otherClass
+	}
+
+	var tokens []string
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		class := classify(runes[start])
+		end := start + 1
+		if class != otherClass {
+			for end < len(runes) && classify(runes[end]) == class {
+				end++
+			}
+		}
+		tokens = append(tokens, string(runes[start:end]))
+		start = end
+	}
+	return tokens
+}
+
+// -------------------------------------------
+// ------------------------------------------- type ComparableToken
+// -------------------------------------------
+
+type ComparableToken string
+
+// Assert that Comparable is implemented by ComparableToken.
+var _ Comparable = ComparableToken("")
+
+// -------------------------------------------
+
+func (token ComparableToken) Compare(other Comparable) float32 {
+	if token == other.(ComparableToken) {
+		return 0.0
+	}
+	return 1.0
+}
+
+// -------------------------------------------
+
+func (token ComparableToken) Stringify(maxWidth int) string {
+	return NewTextLine(string(token)).Stringify(maxWidth)
+}
+
+// -------------------------------------------
+// ------------------------------------------- type ComparableTokens
+// -------------------------------------------
+
+// Type ComparableTokens is a string slice subtype which implements the
+// ComparableSequence interface, one token per item.
+
+type ComparableTokens []string
+
+// Assert that ComparableSequence is implemented by ComparableTokens.
+var _ ComparableSequence = ComparableTokens(nil)
+
+// -------------------------------------------
+
+func (tokens ComparableTokens) Length() int {
+	return len(tokens)
+}
+
+// -------------------------------------------
+
+func (tokens ComparableTokens) GetItemAt(index int) Comparable {
+	return ComparableToken(tokens[index])
+}
+
+// -------------------------------------------
+
+func (tokens ComparableTokens) GetDescription() string {
+	return fmt.Sprintf("%d tokens", len(tokens))
+}
+
+// -------------------------------------------
+
+// Tokens are either equal or not.
+func (tokens ComparableTokens) EqualityOnly() bool {
+	return true
+}
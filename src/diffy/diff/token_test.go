@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestTokenize
+// -------------------------------------------
+
+func TestTokenize(t *testing.T) {
+
+	testCases := []struct {
+		text string
+		tokens []string
+	}{
+		{"", nil},
+		{"foo", []string{"foo"}},
+		{"foo(bar, 42)", []string{"foo", "(", "bar", ",", " ", "42", ")"}},
+		{"a  b", []string{"a", "  ", "b"}},
+		{"foo_bar->baz", []string{"foo_bar", "-", ">", "baz"}},
+		{"naïve café", []string{"naïve", " ", "café"}},
+	}
+
+	for _, testCase := range testCases {
+		tokens := Tokenize(testCase.text)
+		if !reflect.DeepEqual(tokens, testCase.tokens) {
+			t.Errorf("Tokenize(%q): got %q, expected %q", testCase.text, tokens, testCase.tokens)
+		}
+		if joined := strings.Join(tokens, ""); joined != testCase.text {
+			t.Errorf("Tokenize(%q): tokens join to %q", testCase.text, joined)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestTokenizeWith
+// -------------------------------------------
+
+func TestTokenizeWith(t *testing.T) {
+
+	testCases := []struct {
+		text string
+		profile string
+		tokens []string
+	}{
+		{"foo_bar", "code", []string{"foo_bar"}},
+		{"foo_bar", "prose", []string{"foo", "_", "bar"}},
+		{"foo_bar", "", []string{"foo_bar"}},
+		{"see src/main.go:12", "code", []string{"see", " ", "src", "/", "main", ".", "go", ":", "12"}},
+		{"see src/main.go:12", "path", []string{"see", " ", "src/main.go:12"}},
+		{"margin-top: 4px", "path", []string{"margin-top:", " ", "4px"}},
+		{"don't  stop", "prose", []string{"don", "'", "t", "  ", "stop"}},
+	}
+
+	for _, testCase := range testCases {
+		wordChars, ok := ParseWordChars(testCase.profile)
+		if !ok {
+			t.Fatalf("Unknown profile %q", testCase.profile)
+		}
+		tokens := TokenizeWith(testCase.text, wordChars)
+		if !reflect.DeepEqual(tokens, testCase.tokens) {
+			t.Errorf("TokenizeWith(%q, %s): got %q, expected %q", testCase.text, testCase.profile, tokens, testCase.tokens)
+		}
+		if joined := strings.Join(tokens, ""); joined != testCase.text {
+			t.Errorf("TokenizeWith(%q, %s): tokens join to %q", testCase.text, testCase.profile, joined)
+		}
+	}
+
+	// Nil is the default, and there are only the named profiles.
+	if tokens := TokenizeWith("foo_bar baz", nil); !reflect.DeepEqual(tokens, Tokenize("foo_bar baz")) {
+		t.Errorf("Expected nil to tokenize like Tokenize, got %q", tokens)
+	}
+	if _, ok := ParseWordChars("css"); ok {
+		t.Errorf("Expected no css profile")
+	}
+}
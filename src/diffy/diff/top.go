@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"sort"
+)
+
+// "top.go" - Picking out the most changed lines of a big diff, for a quick
+// look at where the biggest changes are.
+
+// ------------------------------------------- RankChangedLinks
+//
+// The indexes of the Different links, most changed first.  A link's change is
+// the cost of the pair, which is one minus its LinkConfidence.  Ties go to
+// the link that comes first, so the ranking is the same every time.
+//
+func RankChangedLinks(alignment *Alignment, left, right ComparableSequence) []int {
+	var ranked []int
+	costs := make(map[int]float32)
+	for index, link := range alignment.Links {
+		if link.LinkType == Different {
+			ranked = append(ranked, index)
+			costs[index] = 1.0 - LinkConfidence(link, left, right)
+		}
+	}
+	sort.SliceStable(ranked, func (i, j int) bool {
+		return costs[ranked[i]] > costs[ranked[j]]
+	})
+	return ranked
+}
+
+// ------------------------------------------- Alignment TopChanges method
+//
+// An alignment with just the "n" most changed Different links (see
+// RankChangedLinks) and up to "context" links either side of each, in their
+// original order.  Everything else is left out, so the result is a selection
+// of the alignment rather than a complete one.  Realign the alignment first,
+// so pairs too dissimilar to be shown as pairs aren't ranked.
+//
+func (alignment *Alignment) TopChanges(left, right ComparableSequence, n, context int) *Alignment {
+	return alignment.TopChangesWithContext(left, right, n, HunkContext{Before: context, After: context})
+}
+
+// ------------------------------------------- Alignment TopChangesWithContext method
+//
+// Like TopChanges, with "context.Before" links before each change and
+// "context.After" after it, as in a hunk.
+//
+func (alignment *Alignment) TopChangesWithContext(left, right ComparableSequence, n int, context HunkContext) *Alignment {
+	ranked := RankChangedLinks(alignment, left, right)
+	if n < len(ranked) {
+		ranked = ranked[:n]
+	}
+	return alignment.selectWithContext(ranked, context)
+}
+
+// ------------------------------------------- Alignment OnlyChanges method
+//
+// An alignment with just the links of type "linkType", e.g. only the
+// insertions, and up to "context" links either side of each, in their
+// original order.  Like TopChanges, the result is a selection of the
+// alignment, and it should be realigned first.
+//
+func (alignment *Alignment) OnlyChanges(linkType LinkType, context int) *Alignment {
+	return alignment.OnlyChangesWithContext(linkType, HunkContext{Before: context, After: context})
+}
+
+// ------------------------------------------- Alignment OnlyChangesWithContext method
+//
+// Like OnlyChanges, with "context.Before" links before each change and
+// "context.After" after it, as in a hunk.
+//
+func (alignment *Alignment) OnlyChangesWithContext(linkType LinkType, context HunkContext) *Alignment {
+	var selected []int
+	for index, link := range alignment.Links {
+		if link.LinkType == linkType {
+			selected = append(selected, index)
+		}
+	}
+	return alignment.selectWithContext(selected, context)
+}
+
+// ------------------------------------------- Alignment selectWithContext method
+//
+// The links at the "selected" indexes, plus up to "context.Before" links
+// before each and "context.After" after it.
+//
+func (alignment *Alignment) selectWithContext(selected []int, context HunkContext) *Alignment {
+	keep := make([]bool, len(alignment.Links))
+	for _, index := range selected {
+		for i := index - context.Before; i <= index + context.After; i++ {
+			if i >= 0 && i < len(keep) {
+				keep[i] = true
+			}
+		}
+	}
+
+	var links []Link
+	for index, link := range alignment.Links {
+		if keep[index] {
+			links = append(links, link)
+		}
+	}
+	return &Alignment{Links: links}
+}
+
+// ------------------------------------------- GroupByLinkType
+//
+// Sort the links of "alignment" into one alignment per one of "linkTypes",
+// each with just the links of its type, in their original order.  Links of
+// any other type aren't in any of them, so like TopChanges, each is a
+// selection of the alignment rather than a complete one.
+//
+func GroupByLinkType(alignment *Alignment, linkTypes ...LinkType) []*Alignment {
+	groups := make([]*Alignment, len(linkTypes))
+	groupIndexes := map[LinkType]int{}
+	for index, linkType := range linkTypes {
+		groups[index] = &Alignment{Links: []Link{}}
+		groupIndexes[linkType] = index
+	}
+	for _, link := range alignment.Links {
+		if index, found := groupIndexes[link.LinkType]; found {
+			groups[index].Links = append(groups[index].Links, link)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- TestTopChanges
+
+func TestTopChanges(t *testing.T) {
+
+	// Twenty unchanged lines, with pairs changed a little or a lot at 3, 8,
+	// 12, and 17.  The changes at 3 and 17 are the same, so they tie.
+	base := "the quick brown fox jumps over the lazy dog"
+	changed := map[int]string{
+		3: "the quick brown fox jumps over the lazy hog",
+		8: "the quick brown cat naps over the lazy dog",
+		12: "the quick brown fox jumps over the lazy dig",
+		17: "the quick brown fox jumps over the lazy hog",
+	}
+	var left, right ComparableLines
+	alignment := &Alignment{}
+	for i := 0; i < 20; i++ {
+		text := fmt.Sprintf("%02d %s", i, base)
+		left = append(left, NewTextLine(text))
+		linkType := Matching
+		if changedText, found := changed[i]; found {
+			text = fmt.Sprintf("%02d %s", i, changedText)
+			linkType = Different
+		}
+		right = append(right, NewTextLine(text))
+		alignment.Links = append(alignment.Links, Link{linkType, i, i})
+	}
+
+	// The biggest change comes first, and the tie goes to the earlier line.
+	ranked := RankChangedLinks(alignment, left, right)
+	if fmt.Sprint(ranked) != "[8 3 17 12]" {
+		t.Errorf("Expected the links ranked [8 3 17 12], got %v", ranked)
+	}
+	cost := func (index int) float32 { return left[index].Compare(right[index]) }
+	if !(cost(8) > cost(3) && cost(3) == cost(17) && cost(17) > cost(12)) {
+		t.Errorf("Unexpected costs %v %v %v %v", cost(8), cost(3), cost(17), cost(12))
+	}
+
+	// The top two, with a line of context either side.
+	top := alignment.TopChanges(left, right, 2, 1)
+	var indexes []int
+	for _, link := range top.Links {
+		indexes = append(indexes, link.LeftIndex)
+	}
+	if fmt.Sprint(indexes) != "[2 3 4 7 8 9]" {
+		t.Errorf("Expected lines 3 and 8 with their context, got %v", indexes)
+	}
+
+	// Asking for more than there are gives them all.
+	if top := alignment.TopChanges(left, right, 10, 0); len(top.Links) != 4 {
+		t.Errorf("Expected all 4 changes, got %v", top.Links)
+	}
+}
+
+// ------------------------------------------- TestOnlyChanges
+
+func TestOnlyChanges(t *testing.T) {
+
+	alignment := &Alignment{Links: []Link{
+		{Matching, 0, 0},
+		{LeftOnly, 1, -1},
+		{Matching, 2, 1},
+		{Matching, 3, 2},
+		{Matching, 4, 3},
+		{RightOnly, -1, 4},
+		{RightOnly, -1, 5},
+		{Matching, 5, 6},
+		{Different, 6, 7},
+	}}
+
+	// The insertions, with a link of context either side.
+	added := alignment.OnlyChanges(RightOnly, 1)
+	if fmt.Sprint(added.Links) != fmt.Sprint(alignment.Links[4:8]) {
+		t.Errorf("Expected the insertions with their context, got %v", added.Links)
+	}
+
+	// The deletions alone.
+	removed := alignment.OnlyChanges(LeftOnly, 0)
+	if fmt.Sprint(removed.Links) != fmt.Sprint(alignment.Links[1:2]) {
+		t.Errorf("Expected just the deletion, got %v", removed.Links)
+	}
+
+	// More context after than before.
+	added = alignment.OnlyChangesWithContext(RightOnly, HunkContext{Before: 0, After: 2})
+	if fmt.Sprint(added.Links) != fmt.Sprint(alignment.Links[5:9]) {
+		t.Errorf("Expected the insertions with two links after, got %v", added.Links)
+	}
+}
+
+// ------------------------------------------- TestGroupByLinkType
+
+func TestGroupByLinkType(t *testing.T) {
+
+	alignment := &Alignment{Links: []Link{
+		{Matching, 0, 0}, {LeftOnly, 1, -1}, {Different, 2, 1}, {RightOnly, -1, 2},
+		{Matching, 3, 3}, {RightOnly, -1, 4}, {LeftOnly, 4, -1}, {Different, 5, 5},
+	}}
+	groups := GroupByLinkType(alignment, RightOnly, LeftOnly, Different)
+
+	expected := [][]Link{
+		{{RightOnly, -1, 2}, {RightOnly, -1, 4}},
+		{{LeftOnly, 1, -1}, {LeftOnly, 4, -1}},
+		{{Different, 2, 1}, {Different, 5, 5}},
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("Expected %d groups, got %d", len(expected), len(groups))
+	}
+	for index, group := range groups {
+		if fmt.Sprint(group.Links) != fmt.Sprint(expected[index]) {
+			t.Errorf("Group %d: expected %v, got %v", index, expected[index], group.Links)
+		}
+	}
+
+	// A type with no links gets an empty group.
+	if groups := GroupByLinkType(&Alignment{Links: []Link{{Matching, 0, 0}}}, Different); len(groups) != 1 || len(groups[0].Links) != 0 {
+		t.Errorf("Expected one empty group, got %v", groups)
+	}
+}
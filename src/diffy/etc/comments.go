@@ -0,0 +1,104 @@
+package etc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ------------------------------------------- type CommentSyntax
+// A language's comment syntax: the markers which start a comment running to
+// the end of the line, e.g. "//" or "#", and the pairs of delimiters around
+// block comments, e.g. "/*" and "*/".
+
+type CommentSyntax struct {
+	LineMarkers []string
+	BlockDelimiters [][2]string
+}
+
+// ------------------------------------------- ParseCommentSyntax
+// Parse a comma separated list of comment markers.  A single marker starts a
+// line comment, and two markers separated by a space delimit a block
+// comment.
+//
+// ParseCommentSyntax(`#`)			=> line comments start with "#"
+// ParseCommentSyntax(`//,/* */`)	=> C style line and block comments
+// ParseCommentSyntax(`--,{- -}`)	=> Haskell style line and block comments
+//
+func ParseCommentSyntax(spec string) (*CommentSyntax, error) {
+	syntax := &CommentSyntax{}
+	for _, entry := range strings.Split(spec, ",") {
+		switch markers := strings.Fields(entry); len(markers) {
+		case 1:
+			syntax.LineMarkers = append(syntax.LineMarkers, markers[0])
+		case 2:
+			syntax.BlockDelimiters = append(syntax.BlockDelimiters, [2]string{markers[0], markers[1]})
+		default:
+			return nil, fmt.Errorf("%q is neither a line comment marker nor a pair of block comment delimiters", entry)
+		}
+	}
+	return syntax, nil
+}
+
+// ------------------------------------------- StripComments
+// Remove the comments from a line of "text", along with any whitespace left
+// trailing, so "x = 1  // set x" becomes "x = 1".  Comment markers inside
+// double quoted strings don't count.
+//
+// A block comment can span lines.  "openBlockEnd" is the end delimiter of a
+// block comment still open at the start of the line, or "" if there isn't
+// one.  Besides the stripped text, return the end delimiter of a block
+// comment still open at the end of the line, to pass in with the next line.
+//
+func (syntax *CommentSyntax) StripComments(text string, openBlockEnd string) (string, string) {
+	var result strings.Builder
+	inString := false
+	for index := 0; index < len(text); {
+		rest := text[index:]
+		if openBlockEnd != "" {
+			end := strings.Index(rest, openBlockEnd)
+			if end < 0 {
+				break
+			}
+			index += end + len(openBlockEnd)
+			openBlockEnd = ""
+			continue
+		}
+		if inString {
+			if rest[0] == '\\' && len(rest) > 1 {
+				result.WriteString(rest[:2])
+				index += 2
+				continue
+			}
+			inString = rest[0] != '"'
+		} else if rest[0] == '"' {
+			inString = true
+		} else if syntax.startsLineComment(rest) {
+			break
+		} else if delimiters, found := syntax.startsBlockComment(rest); found {
+			openBlockEnd = delimiters[1]
+			index += len(delimiters[0])
+			continue
+		}
+		result.WriteByte(rest[0])
+		index += 1
+	}
+	return strings.TrimRight(result.String(), " \t"), openBlockEnd
+}
+
+func (syntax *CommentSyntax) startsLineComment(text string) bool {
+	for _, marker := range syntax.LineMarkers {
+		if strings.HasPrefix(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (syntax *CommentSyntax) startsBlockComment(text string) ([2]string, bool) {
+	for _, delimiters := range syntax.BlockDelimiters {
+		if strings.HasPrefix(text, delimiters[0]) {
+			return delimiters, true
+		}
+	}
+	return [2]string{}, false
+}
@@ -0,0 +1,55 @@
+package etc
+
+import (
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestStripComments
+// -------------------------------------------
+
+func TestStripComments(t *testing.T) {
+
+	cSyntax, err := ParseCommentSyntax("//,/* */")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	shellSyntax, err := ParseCommentSyntax("#")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	testCases := []struct {
+		syntax *CommentSyntax
+		input, openBlockEnd string
+		expected, expectedOpenBlockEnd string
+	}{
+		{cSyntax, "x = 1;", "", "x = 1;", ""},
+		{cSyntax, "// just a comment", "", "", ""},
+		{cSyntax, "    // indented comment", "", "", ""},
+		{cSyntax, "x = 1;  // trailing comment", "", "x = 1;", ""},
+		{cSyntax, "x = 1; /* inline */ y = 2;", "", "x = 1;  y = 2;", ""},
+		{cSyntax, "x = 1; /* opens", "", "x = 1;", "*/"},
+		{cSyntax, "   still in the comment", "*/", "", "*/"},
+		{cSyntax, "closes */ y = 2;", "*/", " y = 2;", ""},
+		{cSyntax, `url = "http://example.com"; // home`, "", `url = "http://example.com";`, ""},
+		{cSyntax, `s = "a \" // b"; // c`, "", `s = "a \" // b";`, ""},
+		{shellSyntax, "echo hi # greet", "", "echo hi", ""},
+		{shellSyntax, "# comment", "", "", ""},
+		{shellSyntax, "x = 1 // not a shell comment", "", "x = 1 // not a shell comment", ""},
+	}
+	for _, testCase := range testCases {
+		result, openBlockEnd := testCase.syntax.StripComments(testCase.input, testCase.openBlockEnd)
+		if result != testCase.expected || openBlockEnd != testCase.expectedOpenBlockEnd {
+			t.Errorf("StripComments(%q, %q): got %q, %q, expected %q, %q", testCase.input, testCase.openBlockEnd,
+						result, openBlockEnd, testCase.expected, testCase.expectedOpenBlockEnd)
+		}
+	}
+
+	if _, err := ParseCommentSyntax("/* */ extra"); err == nil {
+		t.Errorf("Expected three markers in one entry to be an error")
+	}
+	if _, err := ParseCommentSyntax("#,"); err == nil {
+		t.Errorf("Expected an empty entry to be an error")
+	}
+}
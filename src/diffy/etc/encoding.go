@@ -0,0 +1,83 @@
+package etc
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf16"
+)
+
+// ------------------------------------------- encodings
+// The text encodings we can read.  UTF-16 files are recognized by their byte
+// order marks; anything else is read as UTF-8.
+
+const ENCODING_UTF8 = "utf-8"
+const ENCODING_UTF16LE = "utf-16le"
+const ENCODING_UTF16BE = "utf-16be"
+
+var byteOrderMarks = []struct {
+	encoding string
+	bom []byte
+}{
+	{ENCODING_UTF8, []byte{0xEF, 0xBB, 0xBF}},
+	{ENCODING_UTF16LE, []byte{0xFF, 0xFE}},
+	{ENCODING_UTF16BE, []byte{0xFE, 0xFF}},
+}
+
+// ------------------------------------------- IsEncoding
+
+func IsEncoding(name string) bool {
+	for _, mark := range byteOrderMarks {
+		if name == mark.encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------- DecodeText
+// Decode "content" as UTF-8, UTF-16LE, or UTF-16BE, as "encoding" says, or as
+// its byte order mark says if "encoding" is empty.  A byte order mark for the
+// encoding used is dropped.  Besides the text, return a description of the
+// encoding, e.g. "utf-16le with BOM", for telling the user when two files
+// differ in encoding.
+//
+// DecodeText([]byte("\xFF\xFEh\x00i\x00"), "")	=> "hi", "utf-16le with BOM"
+// DecodeText([]byte("hi"), "")					=> "hi", "utf-8"
+//
+func DecodeText(content []byte, encoding string) (string, string, error) {
+	if encoding == "" {
+		encoding = ENCODING_UTF8
+		for _, mark := range byteOrderMarks {
+			if bytes.HasPrefix(content, mark.bom) {
+				encoding = mark.encoding
+			}
+		}
+	}
+	description := encoding
+	for _, mark := range byteOrderMarks {
+		if mark.encoding == encoding && bytes.HasPrefix(content, mark.bom) {
+			content = content[len(mark.bom):]
+			description += " with BOM"
+		}
+	}
+
+	switch encoding {
+	case ENCODING_UTF8:
+		return string(content), description, nil
+	case ENCODING_UTF16LE, ENCODING_UTF16BE:
+		if len(content) % 2 != 0 {
+			return "", description, errors.New("odd number of bytes in " + encoding + " text")
+		}
+		units := make([]uint16, len(content) / 2)
+		for index := range units {
+			low, high := content[2 * index], content[2 * index + 1]
+			if encoding == ENCODING_UTF16BE {
+				low, high = high, low
+			}
+			units[index] = uint16(low) | uint16(high) << 8
+		}
+		return string(utf16.Decode(units)), description, nil
+	default:
+		return "", description, errors.New("unknown encoding " + encoding)
+	}
+}
@@ -0,0 +1,39 @@
+package etc
+
+import (
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestDecodeText
+// -------------------------------------------
+
+func TestDecodeText(t *testing.T) {
+
+	testCases := []struct {
+		content, encoding string
+		expected, description string
+	}{
+		{"plain", "", "plain", "utf-8"},
+		{"\xEF\xBB\xBFwith BOM", "", "with BOM", "utf-8 with BOM"},
+		{"\xFF\xFEh\x00\xe9\x00\n\x00", "", "hé\n", "utf-16le with BOM"},
+		{"\xFE\xFF\x00h\x00\xe9\x00\n", "", "hé\n", "utf-16be with BOM"},
+		{"h\x00i\x00", ENCODING_UTF16LE, "hi", "utf-16le"},
+		{"\xFF\xFEh\x00i\x00", ENCODING_UTF16LE, "hi", "utf-16le with BOM"},
+		{"\x3d\xd8\x00\xde", ENCODING_UTF16LE, "😀", "utf-16le"},	// a surrogate pair
+	}
+	for _, testCase := range testCases {
+		text, description, err := DecodeText([]byte(testCase.content), testCase.encoding)
+		if err != nil || text != testCase.expected || description != testCase.description {
+			t.Errorf("DecodeText(%q, %q): got %q, %q, %v, expected %q, %q", testCase.content, testCase.encoding,
+						text, description, err, testCase.expected, testCase.description)
+		}
+	}
+
+	if _, _, err := DecodeText([]byte("\xFF\xFEh"), ""); err == nil {
+		t.Errorf("Expected an error for an odd number of UTF-16 bytes")
+	}
+	if !IsEncoding(ENCODING_UTF16BE) || IsEncoding("ebcdic") {
+		t.Errorf("Unexpected IsEncoding results")
+	}
+}
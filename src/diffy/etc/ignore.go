@@ -0,0 +1,151 @@
+package etc
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// "ignore.go" - Matching paths against ".gitignore" style patterns.
+
+// The name of the ignore file looked for at the root of each tree.
+const IGNORE_FILE_NAME = ".diffyignore"
+
+// -------------------------------------------
+// ------------------------------------------- type IgnoreList
+// -------------------------------------------
+
+// An IgnoreList is a list of ".gitignore" style patterns, one per line:
+//
+//   - Blank lines and lines starting with "#" are skipped.
+//   - "*" matches anything but "/", and "?" matches any one character but "/".
+//   - "**" matches across directories: "**/name", "dir/**", and "a/**/b".
+//   - A pattern with a "/" at the start or in the middle is anchored to the
+//     root.  Otherwise it matches at any depth.
+//   - A pattern ending in "/" only matches directories.
+//   - A pattern starting with "!" re-includes what an earlier one ignored.
+//
+// The last pattern that matches wins.  As with git, a file in an ignored
+// directory is ignored, whatever the patterns say about the file itself, so
+// "dir/*" and "!dir/keep" keeps "dir/keep" but "dir/" and "!dir/keep" don't.
+
+type IgnoreList struct {
+	patterns []tIgnorePattern
+}
+
+type tIgnorePattern struct {
+	regexp *regexp.Regexp
+	negated bool
+	dirOnly bool
+}
+
+// ------------------------------------------- ParseIgnorePatterns
+
+func ParseIgnorePatterns(text string) *IgnoreList {
+	list := &IgnoreList{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var pattern tIgnorePattern
+		if strings.HasPrefix(line, "!") {
+			pattern.negated, line = true, line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pattern.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		pattern.regexp = compileIgnorePattern(line)
+		list.patterns = append(list.patterns, pattern)
+	}
+	return list
+}
+
+// ------------------------------------------- LoadIgnoreFile
+
+func LoadIgnoreFile(path string) (*IgnoreList, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIgnorePatterns(string(content)), nil
+}
+
+// ------------------------------------------- IgnoreList Concat method
+
+// The patterns of "list" followed by those of "other", which so have the last
+// word.
+func (list *IgnoreList) Concat(other *IgnoreList) *IgnoreList {
+	patterns := append(append([]tIgnorePattern(nil), list.patterns...), other.patterns...)
+	return &IgnoreList{patterns: patterns}
+}
+
+// ------------------------------------------- compileIgnorePattern
+
+// Translate a pattern into an anchored regular expression over "/" separated
+// paths relative to the root.
+func compileIgnorePattern(pattern string) *regexp.Regexp {
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var builder strings.Builder
+	builder.WriteString("^")
+	if !anchored {
+		builder.WriteString("(?:.*/)?")
+	}
+	for index := 0; index < len(pattern); {
+		switch rest := pattern[index:]; {
+		case strings.HasPrefix(rest, "**/") && (index == 0 || pattern[index - 1] == '/'):
+			builder.WriteString("(?:.*/)?")
+			index += 3
+		case rest == "**" && (index == 0 || pattern[index - 1] == '/'):
+			builder.WriteString(".*")
+			index += 2
+		case rest[0] == '*':
+			builder.WriteString("[^/]*")
+			index++
+		case rest[0] == '?':
+			builder.WriteString("[^/]")
+			index++
+		default:
+			builder.WriteString(regexp.QuoteMeta(rest[:1]))
+			index++
+		}
+	}
+	builder.WriteString("$")
+	return regexp.MustCompile(builder.String())
+}
+
+// ------------------------------------------- IgnoreList Match method
+
+// Is "path" ignored?  The path is relative to the root and "/" separated, and
+// "isDir" says whether it's a directory.
+func (list *IgnoreList) Match(path string, isDir bool) bool {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for count := 1; count < len(parts); count++ {
+		if list.matchOne(strings.Join(parts[:count], "/"), true) {
+			return true
+		}
+	}
+	return list.matchOne(strings.Join(parts, "/"), isDir)
+}
+
+// ------------------------------------------- IgnoreList matchOne method
+
+// Match "path" against the patterns alone, ignoring its parent directories.
+func (list *IgnoreList) matchOne(path string, isDir bool) bool {
+	ignored := false
+	for _, pattern := range list.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if pattern.regexp.MatchString(path) {
+			ignored = !pattern.negated
+		}
+	}
+	return ignored
+}
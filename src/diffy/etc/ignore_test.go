@@ -0,0 +1,66 @@
+package etc
+
+import (
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestIgnoreList
+// -------------------------------------------
+
+func TestIgnoreList(t *testing.T) {
+
+	list := ParseIgnorePatterns(`
+# Generated code, except for the one file we edit by hand.
+generated/*
+!generated/keep.go
+
+# Anywhere in the tree.
+*.min.js
+node_modules/
+
+# Only at the root.
+/build
+
+docs/**/draft-?.md
+`)
+
+	testCases := []struct {
+		path string
+		isDir bool
+		ignored bool
+	}{
+		{"generated/parser.go", false, true},
+		{"generated/keep.go", false, false},			// re-included
+		{"generated/sub/deep.go", false, true},			// in an ignored directory
+		{"main.go", false, false},
+		{"app.min.js", false, true},
+		{"web/static/app.min.js", false, true},
+		{"web/node_modules", true, true},
+		{"web/node_modules/left-pad/index.js", false, true},
+		{"node_modules", false, false},					// a file, not a directory
+		{"build", true, true},
+		{"build/output.txt", false, true},
+		{"src/build", true, false},						// anchored to the root
+		{"docs/draft-1.md", false, true},
+		{"docs/2024/q1/draft-2.md", false, true},
+		{"docs/draft-10.md", false, false},
+	}
+	for _, testCase := range testCases {
+		if ignored := list.Match(testCase.path, testCase.isDir); ignored != testCase.ignored {
+			t.Errorf("Match(%q, %v): got %v, expected %v", testCase.path, testCase.isDir, ignored, testCase.ignored)
+		}
+	}
+
+	// Re-including a file doesn't work when its whole directory is ignored.
+	list = ParseIgnorePatterns("vendor/\n!vendor/keep.go\n")
+	if !list.Match("vendor/keep.go", false) {
+		t.Errorf("Expected a file in an ignored directory to stay ignored")
+	}
+
+	// The patterns of a concatenated list have the last word.
+	list = ParseIgnorePatterns("*.log\n").Concat(ParseIgnorePatterns("!keep.log\n"))
+	if !list.Match("debug.log", false) || list.Match("keep.log", false) {
+		t.Errorf("Expected the second list to re-include \"keep.log\" only")
+	}
+}
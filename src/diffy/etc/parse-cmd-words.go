@@ -1,5 +1,9 @@
 package etc
 
+import (
+	"fmt"
+)
+
 // ------------------------------------------- parseWords
 // Parse the contents of "text" into a list of "words" and return the words
 // as a slice of strings.  We're using "word" in the Unix shell sense:
@@ -9,10 +13,11 @@ package etc
 // these cases words may also contain whitespace.
 //
 // The "ParseWords()" function allows the nesting of alternating single and
-// double quotes (or alternating double and single quotes) as many levels
-// deep as you may want.  This capability is probably overkill, but it's
-// there if you want it.  Note that top-level quotes are removed and nested
-// quotes are preserved.
+// double quotes (or alternating double and single quotes) up to
+// DEFAULT_MAX_QUOTE_DEPTH levels deep.  This capability is probably
+// overkill, but it's there if you want it.  Quotes nested any deeper are
+// taken literally; use "ParseWordsStrict()" to treat that as an error.
+// Note that top-level quotes are removed and nested quotes are preserved.
 //
 // ParseWords(`abc`) 			=> {`abc`}
 // ParseWords(` abc `) 			=> {`abc`}
@@ -25,10 +30,23 @@ package etc
 // etc.
 //
 func ParseWords(text string) []string {
+	words, _ := ParseWordsStrict(text, DEFAULT_MAX_QUOTE_DEPTH)
+	return words
+}
+
+// ------------------------------------------- ParseWordsStrict
+// Parse "text" like "ParseWords()" does, allowing quotes to be nested at most
+// "maxQuoteDepth" levels deep, counting top-level quotes as the first level.
+// Deeper quotes are an error, although the words are still returned, with
+// those quotes taken literally.  The limit keeps the mutual recursion
+// between the quoted string parsers bounded, whatever the input.
+//
+func ParseWordsStrict(text string, maxQuoteDepth int) ([]string, error) {
 	var words []string
+	limit := &tQuoteDepthLimit{max: maxQuoteDepth}
 	runes := []rune(text)
 	for index := 0; index < len(runes); {
-		if word, next, matched := parseTopLevelWord(runes, index); matched {
+		if word, next, matched := parseTopLevelWord(runes, index, limit); matched {
 			words = append(words, string(word))
 			index = next
 		} else if char := runes[index]; char == ' ' || char == '\t' {
@@ -37,7 +55,29 @@ func ParseWords(text string) []string {
 			panic("not reached")
 		}
 	}
-	return words
+	if limit.exceeded {
+		return words, fmt.Errorf("quotes are nested more than %d levels deep in %q", maxQuoteDepth, text)
+	}
+	return words, nil
+}
+
+// ------------------------------------------- type tQuoteDepthLimit
+// How deep quotes may be nested, and whether any were nested deeper.
+
+const DEFAULT_MAX_QUOTE_DEPTH = 64
+
+type tQuoteDepthLimit struct {
+	max int
+	exceeded bool
+}
+
+// Whether a quoted string may start at nesting level "depth", noting it if not.
+func (limit *tQuoteDepthLimit) allows(depth int) bool {
+	if depth > limit.max {
+		limit.exceeded = true
+		return false
+	}
+	return true
 }
 
 // ------------------------------------------- parseTopLevelWord
@@ -57,16 +97,16 @@ func ParseWords(text string) []string {
 // * Whitespace may only appear within quoted parts.  Any other whitespace would mark
 //   the end of the word.
 //
-func parseTopLevelWord(runes []rune, start int) ([]rune, int, bool) {
+func parseTopLevelWord(runes []rune, start int, limit *tQuoteDepthLimit) ([]rune, int, bool) {
 	var accumulator []rune
 	matchedSomething := false
 	index := start
 	for ; index < len(runes); {
-		if next, matched := parseDoubleQuotedString(runes, index); matched {
+		if next, matched := parseDoubleQuotedString(runes, index, 1, limit); matched {
 			matchedSomething = true		// but we might have matched a quoted empty string!
 			accumulator = append(accumulator, runes[index + 1:next - 1]...)
 			index = next
-		} else if next, matched := parseSingleQuotedString(runes, index); matched {
+		} else if next, matched := parseSingleQuotedString(runes, index, 1, limit); matched {
 			matchedSomething = true		// but we might have matched a quoted empty string!
 			accumulator = append(accumulator, runes[index + 1:next - 1]...)
 			index = next
@@ -84,19 +124,23 @@ func parseTopLevelWord(runes []rune, start int) ([]rune, int, bool) {
 // ------------------------------------------- parseDoubleQuotedString
 // Parse a double quoted string starting at position "start" in the "runes" slice.
 // If a string is matched, return the next position in the "runes" slice *after*
-// the last matched rune and true.  Otherwise return false.
+// the last matched rune and true.  Otherwise return false.  The string is at
+// nesting level "depth", and isn't matched if that's deeper than "limit" allows.
 //
-func parseDoubleQuotedString(runes []rune, start int) (int, bool) {
+func parseDoubleQuotedString(runes []rune, start int, depth int, limit *tQuoteDepthLimit) (int, bool) {
 	// We must start with a double quote, otherwise we're done.
 	if start < len(runes) && runes[start] != '"' {
 		return start, false		// no starting quote
 	}
+	if !limit.allows(depth) {
+		return start, false		// nested too deep
+	}
 
 	// Find the matching end quote, skipping over any single quoted substrings.
 	for index := start + 1; index < len(runes); {
 		if runes[index] == '"' {
 			return index + 1, true
-		} else if next, matched := parseSingleQuotedString(runes, index); matched {
+		} else if next, matched := parseSingleQuotedString(runes, index, depth + 1, limit); matched {
 			index = next
 		} else {
 			index += 1
@@ -108,19 +152,23 @@ func parseDoubleQuotedString(runes []rune, start int) (int, bool) {
 // ------------------------------------------- parseSingleQuotedString
 // Parse a single quoted string starting at position "start" in the "runes" slice.
 // If a string is matched, return the next position in the "runes" slice *after*
-// the last matched rune and true.  Otherwise return false.
+// the last matched rune and true.  Otherwise return false.  The string is at
+// nesting level "depth", and isn't matched if that's deeper than "limit" allows.
 //
-func parseSingleQuotedString(runes []rune, start int) (int, bool) {
+func parseSingleQuotedString(runes []rune, start int, depth int, limit *tQuoteDepthLimit) (int, bool) {
 	// We must start with a single quote, otherwise we're done.
 	if start < len(runes) && runes[start] != '\'' {
 		return start, false		// no starting quote
 	}
+	if !limit.allows(depth) {
+		return start, false		// nested too deep
+	}
 
 	// Find the matching end quote, skipping over any double quoted substrings.
 	for index := start + 1; index < len(runes); {
 		if runes[index] == '\'' {
 			return index + 1, true
-		} else if next, matched := parseDoubleQuotedString(runes, index); matched {
+		} else if next, matched := parseDoubleQuotedString(runes, index, depth + 1, limit); matched {
 			index = next
 		} else {
 			index += 1
@@ -325,3 +325,41 @@ func TestParseWords(t *testing.T) {
 	parsedDoubleQuotes_L3 := sentences(1, containsSingleQuotes_L2, wsEnds0x1xParsed, nil)
 	run_ParseWords_Tests(t, containsDoubleQuotes_L3, parsedDoubleQuotes_L3, ",")
 }
+
+// ------------------------------------------- TestParseWordsStrict
+
+func TestParseWordsStrict(t *testing.T) {
+
+	// Build "text" nested in "depth" levels of alternating quotes.
+	nest := func (text string, depth int) string {
+		for level := 0; level < depth; level++ {
+			quote := `"`
+			if level % 2 == 1 {
+				quote = `'`
+			}
+			text = quote + text + quote
+		}
+		return text
+	}
+
+	// Within the limit, only the top-level quotes are stripped.
+	words, err := ParseWordsStrict("open " + nest("a b", 3), 3)
+	if err != nil || len(words) != 2 || words[1] != nest("a b", 2) {
+		t.Errorf("Expected quotes nested 3 deep to parse, got %q, %v", words, err)
+	}
+
+	// Beyond it, the parse fails cleanly, even for absurdly deep nesting.
+	for _, depth := range []int{4, 10000} {
+		if _, err := ParseWordsStrict("open " + nest("a b", depth), 3); err == nil {
+			t.Errorf("Expected quotes nested %d deep to be an error", depth)
+		}
+	}
+	if _, err := ParseWordsStrict(nest("x", DEFAULT_MAX_QUOTE_DEPTH + 1), DEFAULT_MAX_QUOTE_DEPTH); err == nil {
+		t.Errorf("Expected the default limit to be enforced")
+	}
+
+	// The lenient ParseWords still returns words, with the deep quotes taken literally.
+	if words := ParseWords(nest("x", 10000)); len(words) != 1 {
+		t.Errorf("Expected one word, got %d", len(words))
+	}
+}
@@ -0,0 +1,118 @@
+package etc
+
+import (
+	"path"
+	"strings"
+)
+
+// "path-pairing.go" - Pairing up the files of two trees by their paths
+// relative to each root, optionally overlooking differences in the path
+// separators and the case of the names.
+
+// -------------------------------------------
+// ------------------------------------------- type PathNormalization
+// -------------------------------------------
+
+// How much of a path is normalized before it's paired.
+
+type PathNormalization int
+
+const (
+	NormalizeNothing PathNormalization = iota	// paths pair when they're the same
+	NormalizeSeparators							// "\" and "/" are the same, and "a//b" and "./a/b" are "a/b"
+	NormalizeCase								// as NormalizeSeparators, and letters pair whatever their case
+)
+
+var pathNormalizationNames = map[string]PathNormalization{
+	"": NormalizeNothing,
+	"separators": NormalizeSeparators,
+	"case": NormalizeCase,
+}
+
+// Look up a PathNormalization by its "--path-normalize" name.
+func ParsePathNormalization(name string) (PathNormalization, bool) {
+	normalization, found := pathNormalizationNames[name]
+	return normalization, found
+}
+
+// ------------------------------------------- PathPairingKey
+// The key two relative paths must share to be paired, under "normalization".
+// It's only for pairing: the paths themselves are shown as they are.
+//
+// PathPairingKey("src\\Foo.go", NormalizeSeparators)	=> "src/Foo.go"
+// PathPairingKey("src\\Foo.go", NormalizeCase)			=> "src/foo.go"
+//
+func PathPairingKey(relativePath string, normalization PathNormalization) string {
+	switch normalization {
+	case NormalizeNothing:
+		return relativePath
+	case NormalizeSeparators:
+		return path.Clean(strings.Replace(relativePath, "\\", "/", -1))
+	case NormalizeCase:
+		return strings.ToLower(PathPairingKey(relativePath, NormalizeSeparators))
+	}
+	panic("not reached")
+}
+
+// -------------------------------------------
+// ------------------------------------------- PairPaths
+// -------------------------------------------
+
+// A PathPair is a path in the left tree and the path in the right tree it
+// pairs with, each relative to its root.
+
+type PathPair struct {
+	Left, Right string
+}
+
+// Pair up "leftPaths" and "rightPaths" by their keys under "normalization",
+// in the order of "leftPaths", and return the pairs along with the paths on
+// either side which have no partner.  A path pairs with the same path first,
+// and only failing that with another which shares its key, so "foo.go" pairs
+// with "foo.go" rather than "FOO.go" under NormalizeCase.  If several paths
+// on a side share a key, they're paired in order and any left over have no
+// partner.
+
+func PairPaths(leftPaths, rightPaths []string, normalization PathNormalization) (pairs []PathPair, leftOnly, rightOnly []string) {
+
+	// Exact matches first, then normalized ones among the paths left over.
+	leftPartners := make([]int, len(leftPaths))
+	for index := range leftPartners {
+		leftPartners[index] = -1
+	}
+	paired := make([]bool, len(rightPaths))
+	for _, pass := range []PathNormalization{NormalizeNothing, normalization} {
+		rightIndexesByKey := map[string][]int{}
+		for index, rightPath := range rightPaths {
+			if !paired[index] {
+				key := PathPairingKey(rightPath, pass)
+				rightIndexesByKey[key] = append(rightIndexesByKey[key], index)
+			}
+		}
+		for leftIndex, leftPath := range leftPaths {
+			if leftPartners[leftIndex] >= 0 {
+				continue
+			}
+			key := PathPairingKey(leftPath, pass)
+			if indexes := rightIndexesByKey[key]; len(indexes) > 0 {
+				leftPartners[leftIndex] = indexes[0]
+				paired[indexes[0]] = true
+				rightIndexesByKey[key] = indexes[1:]
+			}
+		}
+	}
+
+	for leftIndex, leftPath := range leftPaths {
+		if rightIndex := leftPartners[leftIndex]; rightIndex >= 0 {
+			pairs = append(pairs, PathPair{leftPath, rightPaths[rightIndex]})
+		} else {
+			leftOnly = append(leftOnly, leftPath)
+		}
+	}
+	for index, rightPath := range rightPaths {
+		if !paired[index] {
+			rightOnly = append(rightOnly, rightPath)
+		}
+	}
+	return pairs, leftOnly, rightOnly
+}
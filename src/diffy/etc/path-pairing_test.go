@@ -0,0 +1,75 @@
+package etc
+
+import (
+	"fmt"
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestPathPairingKey
+// -------------------------------------------
+
+func TestPathPairingKey(t *testing.T) {
+
+	testCases := []struct {
+		path string
+		normalization PathNormalization
+		expected string
+	}{
+		{"src\\Foo.go", NormalizeNothing, "src\\Foo.go"},
+		{"src\\Foo.go", NormalizeSeparators, "src/Foo.go"},
+		{"./src//Foo.go", NormalizeSeparators, "src/Foo.go"},
+		{"src\\Foo.go", NormalizeCase, "src/foo.go"},
+		{"SRC/ÜBER.go", NormalizeCase, "src/über.go"},
+	}
+	for _, testCase := range testCases {
+		if key := PathPairingKey(testCase.path, testCase.normalization); key != testCase.expected {
+			t.Errorf("PathPairingKey(%q, %v): got %q, expected %q", testCase.path, testCase.normalization, key, testCase.expected)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestPairPaths
+// -------------------------------------------
+
+func TestPairPaths(t *testing.T) {
+
+	left := []string{"src/Foo.go", "src/bar.go", "README.md", "docs/Guide.md"}
+	right := []string{"src\\foo.go", "src\\bar.go", "readme.md", "docs/Guide.md", "NEW.md"}
+
+	testCases := []struct {
+		normalization PathNormalization
+		pairs, leftOnly, rightOnly string
+	}{
+		{NormalizeNothing, "[{docs/Guide.md docs/Guide.md}]", "[src/Foo.go src/bar.go README.md]", "[src\\foo.go src\\bar.go readme.md NEW.md]"},
+		{NormalizeSeparators, "[{src/bar.go src\\bar.go} {docs/Guide.md docs/Guide.md}]", "[src/Foo.go README.md]", "[src\\foo.go readme.md NEW.md]"},
+		{NormalizeCase, "[{src/Foo.go src\\foo.go} {src/bar.go src\\bar.go} {README.md readme.md} {docs/Guide.md docs/Guide.md}]", "[]", "[NEW.md]"},
+	}
+	for _, testCase := range testCases {
+		pairs, leftOnly, rightOnly := PairPaths(left, right, testCase.normalization)
+		if fmt.Sprint(pairs) != testCase.pairs || fmt.Sprint(leftOnly) != testCase.leftOnly || fmt.Sprint(rightOnly) != testCase.rightOnly {
+			t.Errorf("%v: expected %s, %s and %s, got %v, %v and %v", testCase.normalization, testCase.pairs, testCase.leftOnly, testCase.rightOnly, pairs, leftOnly, rightOnly)
+		}
+	}
+
+	// Paths which only differ in case on one side pair in order, and the rest are left over.
+	pairs, leftOnly, rightOnly := PairPaths([]string{"Foo.go", "foo.go"}, []string{"FOO.go"}, NormalizeCase)
+	if fmt.Sprint(pairs) != "[{Foo.go FOO.go}]" || fmt.Sprint(leftOnly) != "[foo.go]" || len(rightOnly) != 0 {
+		t.Errorf("Unexpected pairing %v, %v and %v", pairs, leftOnly, rightOnly)
+	}
+
+	// A path pairs with the same path before one which only shares its key.
+	pairs, leftOnly, rightOnly = PairPaths([]string{"Foo.go", "foo.go"}, []string{"foo.go", "FOO.go"}, NormalizeCase)
+	if fmt.Sprint(pairs) != "[{Foo.go FOO.go} {foo.go foo.go}]" || len(leftOnly) != 0 || len(rightOnly) != 0 {
+		t.Errorf("Unexpected pairing %v, %v and %v", pairs, leftOnly, rightOnly)
+	}
+
+	// Only the names it has are normalizations.
+	if normalization, ok := ParsePathNormalization("case"); !ok || normalization != NormalizeCase {
+		t.Errorf("Expected the case normalization")
+	}
+	if _, ok := ParsePathNormalization("unicode"); ok {
+		t.Errorf("Expected no unicode normalization")
+	}
+}
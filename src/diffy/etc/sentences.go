@@ -0,0 +1,101 @@
+package etc
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ------------------------------------------- SplitSentences
+// Split prose into sentences.  A sentence ends with a run of ".", "?" or "!",
+// and any closing quotes or brackets, followed by whitespace or the end of
+// the text.  A blank line ends a sentence too, so that headings and list
+// items without a full stop stay on their own.  Each sentence keeps the
+// whitespace after it, so concatenating the sentences reproduces the text
+// exactly.
+//
+// A full stop doesn't end a sentence after a common abbreviation ("Dr.",
+// "e.g.") or a single letter initial ("J. Smith"), nor in the middle of a
+// word or number ("3.14", "example.com").
+//
+// SplitSentences("Hi there. How are you?")	=> {"Hi there. ", "How are you?"}
+// SplitSentences("Ask Dr. Smith.\n\nOK")		=> {"Ask Dr. Smith.\n\n", "OK"}
+//
+func SplitSentences(text string) []string {
+
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for index := 0; index < len(runes); {
+
+		// Find the end of a candidate sentence: terminators and closers
+		// followed by whitespace, or a blank line.
+		end, isEnd := index, false
+		switch {
+		case strings.ContainsRune(".?!", runes[index]):
+			end = index + 1
+			for end < len(runes) && strings.ContainsRune(".?!", runes[end]) {
+				end++
+			}
+			for end < len(runes) && strings.ContainsRune("\"')]”’", runes[end]) {
+				end++
+			}
+			isEnd = (end == len(runes) || unicode.IsSpace(runes[end])) && !isAbbreviation(runes[start:index + 1])
+		case runes[index] == '\n' && startsBlankLine(runes[index + 1:]):
+			end, isEnd = index, true
+		}
+		if !isEnd {
+			index++
+			continue
+		}
+
+		// The sentence takes the whitespace after it with it.
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		sentences = append(sentences, string(runes[start:end]))
+		start, index = end, end
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+// Abbreviations which usually end with a full stop without ending a
+// sentence, in lower case.
+var sentenceAbbreviations = map[string]bool{
+	"mr.": true, "mrs.": true, "ms.": true, "dr.": true, "prof.": true, "st.": true, "jr.": true, "sr.": true,
+	"vs.": true, "etc.": true, "e.g.": true, "i.e.": true, "cf.": true, "no.": true, "fig.": true, "approx.": true,
+}
+
+// Whether "text", up to and including a full stop, ends with an abbreviation
+// or an initial rather than the end of a sentence.
+func isAbbreviation(text []rune) bool {
+	if len(text) == 0 || text[len(text) - 1] != '.' {
+		return false
+	}
+	wordStart := len(text) - 1
+	for wordStart > 0 && !unicode.IsSpace(text[wordStart - 1]) && text[wordStart - 1] != '(' {
+		wordStart--
+	}
+	word := string(text[wordStart:])
+	if sentenceAbbreviations[strings.ToLower(word)] {
+		return true
+	}
+	letters := []rune(strings.TrimSuffix(word, "."))
+	return len(letters) == 1 && unicode.IsUpper(letters[0])
+}
+
+// Whether the text after a newline starts with a blank line, i.e. nothing but
+// spaces and tabs before the next newline.
+func startsBlankLine(text []rune) bool {
+	for _, char := range text {
+		if char == '\n' {
+			return true
+		}
+		if char != ' ' && char != '\t' && char != '\r' {
+			return false
+		}
+	}
+	return false
+}
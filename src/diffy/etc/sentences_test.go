@@ -0,0 +1,46 @@
+package etc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestSplitSentences
+// -------------------------------------------
+
+func TestSplitSentences(t *testing.T) {
+
+	testCases := []struct {
+		input string
+		expected []string
+	}{
+		{"", nil},
+		{"No full stop", []string{"No full stop"}},
+		{"Hi there. How are you?", []string{"Hi there. ", "How are you?"}},
+		{"Wow!! Really?! Yes.", []string{"Wow!! ", "Really?! ", "Yes."}},
+		{"One.\nTwo.\n", []string{"One.\n", "Two.\n"}},
+		{"He said \"Stop.\" Then left.", []string{"He said \"Stop.\" ", "Then left."}},
+		{"(An aside.) More.", []string{"(An aside.) ", "More."}},
+
+		// Abbreviations, initials, numbers and names don't end sentences.
+		{"Ask Dr. Smith, e.g. today. Done.", []string{"Ask Dr. Smith, e.g. today. ", "Done."}},
+		{"J. R. R. Tolkien wrote it. The end.", []string{"J. R. R. Tolkien wrote it. ", "The end."}},
+		{"Pi is 3.14 or so. See example.com now.", []string{"Pi is 3.14 or so. ", "See example.com now."}},
+
+		// A blank line ends a sentence without a full stop.
+		{"A Heading\n\nThe text.", []string{"A Heading\n\n", "The text."}},
+		{"Item one\n  \nItem two", []string{"Item one\n  \n", "Item two"}},
+	}
+
+	for _, testCase := range testCases {
+		sentences := SplitSentences(testCase.input)
+		if fmt.Sprintf("%q", sentences) != fmt.Sprintf("%q", testCase.expected) {
+			t.Errorf("SplitSentences(%q): got %q, expected %q", testCase.input, sentences, testCase.expected)
+		}
+		if joined := strings.Join(sentences, ""); joined != testCase.input {
+			t.Errorf("SplitSentences(%q) doesn't reproduce the text: %q", testCase.input, joined)
+		}
+	}
+}
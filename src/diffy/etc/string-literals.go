@@ -0,0 +1,88 @@
+package etc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stands in for the contents of a string literal replaced by ReplaceStringLiterals.
+const STRING_LITERAL_PLACEHOLDER = "\uFFFC"		// the object replacement character
+
+// ------------------------------------------- type QuoteSyntax
+// A language's string literal syntax: the pairs of quotes around a literal,
+// e.g. `"` and `"`, inside which a backslash escapes the next character.
+
+type QuoteSyntax struct {
+	Quotes [][2]string
+}
+
+// ------------------------------------------- ParseQuoteSyntax
+// Parse a comma separated list of quotes, like ParseCommentSyntax.  A single
+// quote both opens and closes a literal, and two quotes separated by a space
+// are an opening and a closing quote.
+//
+// ParseQuoteSyntax(`"`)				=> double quoted strings
+// ParseQuoteSyntax(`",'`)				=> double or single quoted strings
+// ParseQuoteSyntax(`""",",R"( )"`)		=> Python style triple quotes, plain quotes, and C++ style raw strings
+//
+func ParseQuoteSyntax(spec string) (*QuoteSyntax, error) {
+	syntax := &QuoteSyntax{}
+	for _, entry := range strings.Split(spec, ",") {
+		switch quotes := strings.Fields(entry); len(quotes) {
+		case 1:
+			syntax.Quotes = append(syntax.Quotes, [2]string{quotes[0], quotes[0]})
+		case 2:
+			syntax.Quotes = append(syntax.Quotes, [2]string{quotes[0], quotes[1]})
+		default:
+			return nil, fmt.Errorf("%q is neither a quote nor a pair of opening and closing quotes", entry)
+		}
+	}
+	return syntax, nil
+}
+
+// ------------------------------------------- ReplaceStringLiterals
+// Replace the contents of each string literal in a line of "text" with a
+// placeholder, keeping the quotes, so that lines which only differ inside
+// their strings, such as a reworded message, compare equal.  An escaped
+// quote doesn't close a literal.  A literal which isn't closed by the end of
+// the line is taken to run to the end of it.  The earlier of two quotes is
+// tried first, so list the longer one first when one starts with the other.
+//
+// ReplaceStringLiterals(`log("a \"b\"", x)`)	=> "log(\"\uFFFC\", x)"
+//
+func (syntax *QuoteSyntax) ReplaceStringLiterals(text string) string {
+	var result strings.Builder
+	for index := 0; index < len(text); {
+		rest := text[index:]
+		quotes, found := syntax.startsLiteral(rest)
+		if !found {
+			result.WriteByte(rest[0])
+			index += 1
+			continue
+		}
+
+		// Find the closing quote, skipping escaped characters.
+		result.WriteString(quotes[0] + STRING_LITERAL_PLACEHOLDER)
+		index += len(quotes[0])
+		for index < len(text) && !strings.HasPrefix(text[index:], quotes[1]) {
+			if text[index] == '\\' {
+				index++
+			}
+			index++
+		}
+		if index < len(text) {
+			result.WriteString(quotes[1])
+			index += len(quotes[1])
+		}
+	}
+	return result.String()
+}
+
+func (syntax *QuoteSyntax) startsLiteral(text string) ([2]string, bool) {
+	for _, quotes := range syntax.Quotes {
+		if strings.HasPrefix(text, quotes[0]) {
+			return quotes, true
+		}
+	}
+	return [2]string{}, false
+}
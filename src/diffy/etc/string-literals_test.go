@@ -0,0 +1,46 @@
+package etc
+
+import (
+	"testing"
+)
+
+// ------------------------------------------- TestReplaceStringLiterals
+
+func TestReplaceStringLiterals(t *testing.T) {
+
+	parse := func (spec string) *QuoteSyntax {
+		syntax, err := ParseQuoteSyntax(spec)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		return syntax
+	}
+	doubleQuotes, bothQuotes, pythonQuotes := parse(`"`), parse(`",'`), parse(`""",",'`)
+	const P = STRING_LITERAL_PLACEHOLDER
+
+	testCases := []struct {
+		syntax *QuoteSyntax
+		input, expected string
+	}{
+		{doubleQuotes, `x = 1`, `x = 1`},
+		{doubleQuotes, `log("starting up")`, `log("` + P + `")`},
+		{doubleQuotes, `f("a", "b", c)`, `f("` + P + `", "` + P + `", c)`},
+		{doubleQuotes, `s = ""`, `s = "` + P + `"`},
+		{doubleQuotes, `s = "say \"hi\"" + t`, `s = "` + P + `" + t`},
+		{doubleQuotes, `s = "ends in a backslash\\" + t`, `s = "` + P + `" + t`},
+		{doubleQuotes, `s = "runs on`, `s = "` + P},
+		{doubleQuotes, `c = 'x'`, `c = 'x'`},
+		{bothQuotes, `c = 'x'; s = "it's"`, `c = '` + P + `'; s = "` + P + `"`},
+		{pythonQuotes, `doc = """a "quoted" word"""`, `doc = """` + P + `"""`},
+		{parse(`R"( )"`), `s = R"(a "raw" string)";`, `s = R"(` + P + `)";`},
+	}
+	for _, testCase := range testCases {
+		if replaced := testCase.syntax.ReplaceStringLiterals(testCase.input); replaced != testCase.expected {
+			t.Errorf("%q: expected %q, got %q", testCase.input, testCase.expected, replaced)
+		}
+	}
+
+	if _, err := ParseQuoteSyntax(`" ' "`); err == nil {
+		t.Errorf("Expected an error for three quotes in one entry")
+	}
+}
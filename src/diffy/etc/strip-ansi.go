@@ -0,0 +1,58 @@
+package etc
+
+// ------------------------------------------- StripAnsiEscapes
+// Remove ANSI CSI escape sequences (which includes the SGR sequences used for
+// terminal colors, such as "\x1b[31m") from "text" and return the result.
+// Only the visible text remains.
+//
+// A CSI sequence looks like this:
+//
+//     ESC "["  parameter bytes  intermediate bytes  final byte
+//
+// where the parameter bytes are in the range 0x30-0x3F, the intermediate
+// bytes are in the range 0x20-0x2F, and the single final byte is in the
+// range 0x40-0x7E.  An incomplete sequence at the end of the text is
+// removed as well.  A lone ESC that doesn't start a CSI sequence is left
+// alone.
+//
+// StripAnsiEscapes("\x1b[31mred\x1b[0m")		=> "red"
+// StripAnsiEscapes("\x1b[1;32mbold green")		=> "bold green"
+// StripAnsiEscapes("no escapes")				=> "no escapes"
+//
+func StripAnsiEscapes(text string) string {
+	runes := []rune(text)
+	result := make([]rune, 0, len(runes))
+	for index := 0; index < len(runes); {
+		if next, matched := parseCsiSequence(runes, index); matched {
+			index = next
+		} else {
+			result = append(result, runes[index])
+			index += 1
+		}
+	}
+	return string(result)
+}
+
+// ------------------------------------------- parseCsiSequence
+// Parse a CSI escape sequence starting at position "start" in the "runes" slice.
+// If a sequence is matched, return the next position in the "runes" slice *after*
+// the last matched rune and true.  Otherwise return false.
+//
+func parseCsiSequence(runes []rune, start int) (int, bool) {
+	// We must start with ESC "[", otherwise we're done.
+	if start + 1 >= len(runes) || runes[start] != '\x1b' || runes[start + 1] != '[' {
+		return start, false
+	}
+
+	index := start + 2
+	for index < len(runes) && runes[index] >= 0x30 && runes[index] <= 0x3F {
+		index += 1		// parameter bytes
+	}
+	for index < len(runes) && runes[index] >= 0x20 && runes[index] <= 0x2F {
+		index += 1		// intermediate bytes
+	}
+	if index < len(runes) && runes[index] >= 0x40 && runes[index] <= 0x7E {
+		index += 1		// final byte
+	}
+	return index, true
+}
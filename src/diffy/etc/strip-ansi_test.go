@@ -0,0 +1,58 @@
+package etc
+
+import (
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestStripAnsiEscapes
+// -------------------------------------------
+
+func TestStripAnsiEscapes(t *testing.T) {
+
+	testCases := []struct {
+		input, expected string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"\x1b[31mred\x1b[0m", "red"},
+		{"\x1b[1;32mbold green\x1b[m and plain", "bold green and plain"},
+		{"\x1b[38;5;208morange\x1b[39m", "orange"},
+		{"cursor\x1b[2Kcleared", "cursorcleared"},
+		{"lone \x1b escape", "lone \x1b escape"},
+		{"truncated \x1b[1;3", "truncated "},
+		{"caf\x1b[4mé\x1b[24m", "café"},
+	}
+
+	for _, testCase := range testCases {
+		if result := StripAnsiEscapes(testCase.input); result != testCase.expected {
+			t.Errorf("StripAnsiEscapes(%q): got %q, expected %q", testCase.input, result, testCase.expected)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestStripAnsiEscapesMakesLinesIdentical
+// -------------------------------------------
+
+func TestStripAnsiEscapesMakesLinesIdentical(t *testing.T) {
+
+	// The same compiler output, once with colors and once without.
+	coloredLines := []string{
+		"\x1b[1mmain.go:12:5: \x1b[31merror:\x1b[0m undefined: foo",
+		"\x1b[32mok\x1b[0m  \tdiffy/etc\t0.004s",
+	}
+	plainLines := []string{
+		"main.go:12:5: error: undefined: foo",
+		"ok  \tdiffy/etc\t0.004s",
+	}
+
+	for index := range coloredLines {
+		if coloredLines[index] == plainLines[index] {
+			t.Fatalf("test data is broken, line %d should differ before stripping", index)
+		}
+		if stripped := StripAnsiEscapes(coloredLines[index]); stripped != plainLines[index] {
+			t.Errorf("line %d: got %q after stripping, expected %q", index, stripped, plainLines[index])
+		}
+	}
+}
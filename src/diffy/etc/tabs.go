@@ -0,0 +1,55 @@
+package etc
+
+import "strings"
+
+// ------------------------------------------- ExpandTabs
+// Replace each tab in "text" with enough spaces to reach the next tab stop,
+// where tab stops are every "tabSize" columns.  Columns are counted in runes.
+//
+// ExpandTabs("a\tb", 4)		=> "a   b"
+// ExpandTabs("\tx", 4)			=> "    x"
+//
+func ExpandTabs(text string, tabSize int) string {
+	var result strings.Builder
+	column := 0
+	for _, char := range text {
+		if char == '\t' {
+			spaceCount := tabSize - column % tabSize
+			result.WriteString(strings.Repeat(" ", spaceCount))
+			column += spaceCount
+		} else {
+			result.WriteRune(char)
+			column++
+		}
+	}
+	return result.String()
+}
+
+// ------------------------------------------- ExpandedRuneIndexes
+// Map rune positions in ExpandTabs(text, tabSize) back to rune positions in
+// "text".  Element i of the result is the index of the rune in "text" which
+// produced rune i of the expanded text, so all the spaces a tab expands into
+// map back to the tab.  There is one extra element at the end, mapping the end
+// of the expanded text to the end of "text", so that half-open ranges can be
+// mapped as easily as single positions.
+//
+// ExpandedRuneIndexes("a\tb", 4)	=> {0, 1, 1, 1, 2, 3}
+//
+func ExpandedRuneIndexes(text string, tabSize int) []int {
+	var indexes []int
+	column, runeIndex := 0, 0
+	for _, char := range text {
+		if char == '\t' {
+			spaceCount := tabSize - column % tabSize
+			for i := 0; i < spaceCount; i++ {
+				indexes = append(indexes, runeIndex)
+			}
+			column += spaceCount
+		} else {
+			indexes = append(indexes, runeIndex)
+			column++
+		}
+		runeIndex++
+	}
+	return append(indexes, runeIndex)
+}
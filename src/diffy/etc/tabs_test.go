@@ -0,0 +1,31 @@
+package etc
+
+import (
+	"fmt"
+	"testing"
+)
+
+// ------------------------------------------- TestExpandTabs
+
+func TestExpandTabs(t *testing.T) {
+	testCases := []struct {
+		text string
+		expanded string
+		indexes []int
+	}{
+		{"", "", []int{0}},
+		{"a\tb", "a   b", []int{0, 1, 1, 1, 2, 3}},
+		{"\tx", "    x", []int{0, 0, 0, 0, 1, 2}},
+		{"abcd\te", "abcd    e", []int{0, 1, 2, 3, 4, 4, 4, 4, 5, 6}},
+		{"é\tb", "é   b", []int{0, 1, 1, 1, 2, 3}},
+	}
+	for _, testCase := range testCases {
+		if expanded := ExpandTabs(testCase.text, 4); expanded != testCase.expanded {
+			t.Errorf("ExpandTabs(%q): got %q, expected %q", testCase.text, expanded, testCase.expanded)
+		}
+		indexes := ExpandedRuneIndexes(testCase.text, 4)
+		if fmt.Sprint(indexes) != fmt.Sprint(testCase.indexes) {
+			t.Errorf("ExpandedRuneIndexes(%q): got %v, expected %v", testCase.text, indexes, testCase.indexes)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package etc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Stands in for a trailing token replaced by ReplaceTrailingToken.
+const TRAILING_TOKEN_PLACEHOLDER = "\uFFFC"		// the object replacement character
+
+// ------------------------------------------- CompileTrailingToken
+// Compile a pattern for ReplaceTrailingToken, anchored so that it only
+// matches a token with nothing but whitespace after it.
+//
+func CompileTrailingToken(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`(?:` + pattern + `)\s*$`)
+}
+
+// ------------------------------------------- ReplaceTrailingToken
+// Replace the token at the end of "text", as matched by a "pattern" from
+// CompileTrailingToken, with a placeholder, so that lines which only differ
+// in a volatile token at the end, such as a timestamp or a hash, compare
+// equal.  The token is replaced rather than removed, so a line which has one
+// still differs from a line which doesn't.  Text without a token at the end,
+// or with only an empty match, is left alone.
+//
+// ReplaceTrailingToken("built 3f9a2c1", `[0-9a-f]{7}`)	=> "built \uFFFC"
+// ReplaceTrailingToken("3f9a2c1 built", `[0-9a-f]{7}`)	=> "3f9a2c1 built"
+// ReplaceTrailingToken("at 12:34:56", `\d\d:\d\d`)		=> "at 12:\uFFFC"
+//
+func ReplaceTrailingToken(text string, pattern *regexp.Regexp) string {
+	match := pattern.FindStringIndex(text)
+	if match == nil || strings.TrimSpace(text[match[0]:match[1]]) == "" {
+		return text
+	}
+	return text[:match[0]] + TRAILING_TOKEN_PLACEHOLDER
+}
@@ -0,0 +1,57 @@
+package etc
+
+import (
+	"regexp"
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestReplaceTrailingToken
+// -------------------------------------------
+
+func TestReplaceTrailingToken(t *testing.T) {
+
+	compile := func (pattern string) *regexp.Regexp {
+		compiled, err := CompileTrailingToken(pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		return compiled
+	}
+
+	hash := compile(`[0-9a-f]{7,40}`)
+	testCases := []struct {
+		input, expected string
+	}{
+		{"", ""},
+		{"built 3f9a2c1", "built " + TRAILING_TOKEN_PLACEHOLDER},
+		{"built 3f9a2c1  ", "built " + TRAILING_TOKEN_PLACEHOLDER},
+		{"built 3f9a2c1 after 0be11a7", "built 3f9a2c1 after " + TRAILING_TOKEN_PLACEHOLDER},
+		{"3f9a2c1 built", "3f9a2c1 built"},
+		{"no hash here", "no hash here"},
+		{"built 3f9a2c1 ", "built " + TRAILING_TOKEN_PLACEHOLDER},
+	}
+	for _, testCase := range testCases {
+		if result := ReplaceTrailingToken(testCase.input, hash); result != testCase.expected {
+			t.Errorf("ReplaceTrailingToken(%q): got %q, expected %q", testCase.input, result, testCase.expected)
+		}
+	}
+
+	// An empty match isn't a token.
+	if result := ReplaceTrailingToken("abc  ", compile(`\d*`)); result != "abc  " {
+		t.Errorf("Expected an empty match to be left alone, got %q", result)
+	}
+
+	// The match is the one at the end, even where an earlier one would take
+	// its place.
+	for _, testCase := range []struct {
+		pattern, input, expected string
+	}{
+		{`\d\d:\d\d`, "at 12:34:56", "at 12:" + TRAILING_TOKEN_PLACEHOLDER},
+		{`[0-9a-f]{7}`, "built 3f9a2c1d4", "built 3f" + TRAILING_TOKEN_PLACEHOLDER},
+	} {
+		if result := ReplaceTrailingToken(testCase.input, compile(testCase.pattern)); result != testCase.expected {
+			t.Errorf("%q on %q: got %q, expected %q", testCase.pattern, testCase.input, result, testCase.expected)
+		}
+	}
+}
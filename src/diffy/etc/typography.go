@@ -0,0 +1,27 @@
+package etc
+
+import (
+	"strings"
+)
+
+// ------------------------------------------- NormalizeTypography
+// Replace typographic punctuation in "text" with its plain ASCII equivalent,
+// so that text which has been through a "smart quotes" filter compares equal
+// to text which hasn't.
+//
+// NormalizeTypography("“Quoted”")		=> "\"Quoted\""
+// NormalizeTypography("He’s — well…")	=> "He's - well..."
+//
+func NormalizeTypography(text string) string {
+	return typographyReplacer.Replace(text)
+}
+
+var typographyReplacer = strings.NewReplacer(
+	"“", "\"",		// left double quotation mark
+	"”", "\"",		// right double quotation mark
+	"‘", "'",		// left single quotation mark
+	"’", "'",		// right single quotation mark, also used as an apostrophe
+	"—", "-",		// em dash
+	"–", "-",		// en dash
+	"…", "...",	// horizontal ellipsis
+)
@@ -0,0 +1,30 @@
+package etc
+
+import (
+	"testing"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestNormalizeTypography
+// -------------------------------------------
+
+func TestNormalizeTypography(t *testing.T) {
+
+	testCases := []struct {
+		input, expected string
+	}{
+		{"", ""},
+		{"plain \"text\" - isn't it...", "plain \"text\" - isn't it..."},
+		{"“Quoted”", "\"Quoted\""},
+		{"‘single’", "'single'"},
+		{"He’s Alive!", "He's Alive!"},
+		{"em—dash, en–dash", "em-dash, en-dash"},
+		{"wait for it…", "wait for it..."},
+	}
+
+	for _, testCase := range testCases {
+		if result := NormalizeTypography(testCase.input); result != testCase.expected {
+			t.Errorf("NormalizeTypography(%q): got %q, expected %q", testCase.input, result, testCase.expected)
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 
+	"diffy/adapter"
 	"diffy/diff"
 	"diffy/etc"
 	"diffy/output"
@@ -18,75 +25,1647 @@ import (
 // ------------------------------------------- flags
 
 var openWithPtr = flag.String("open-with", "", "open with")
+var maxQuoteDepthPtr = flag.Int("max-quote-depth", etc.DEFAULT_MAX_QUOTE_DEPTH, "how deeply alternating quotes may be nested in the --open-with command")
+var teePtr = flag.Bool("tee", false, "with --open-with, also write the output to stdout")
+var outputPtr = flag.String("output", "", "write the diff to this file instead of stdout, e.g. for --format=png")
+var formatPtr = flag.String("format", "html", "output format: " + strings.Join(outputFormats, ", "))
+var markdownHunkHeadersPtr = flag.Bool("markdown-hunk-headers", true, "with --format=markdown, start each hunk with its \"@@\" line")
+var detectIndentChangePtr = flag.Bool("detect-indent-change", false, "flag lines whose only change is tabs vs spaces indentation")
+var anchorPtr = flag.String("anchor", "", "regular expression matching anchor lines which divide the files into independently diffed sections")
+var contextPtr = flag.String("context", "", "\"func\" to cut the files into top-level blocks, e.g. functions, and diff each matched pair of blocks independently")
+var blockRegexPtr = flag.String("block-regex", "", "regular expression matching the first line of each block; implies --context=func")
+var exactPtr = flag.Bool("exact", false, "only match lines which are the same, as GNU diff does, rather than pairing up lines which are similar")
+var anchorUniquePtr = flag.Bool("anchor-unique", false, "anchor the alignment at lines which appear exactly once in each file, as patience diff does")
+var tsvKeyColsPtr = flag.String("tsv-key-cols", "", "compare tab separated lines on these columns only, e.g. \"1,3\"")
+var fixedColsPtr = flag.String("fixed-cols", "", "compare fixed-width lines on these character columns only, e.g. \"1-10,25-30\"")
+var noAdapterPtr = flag.Bool("no-adapter", false, "always compare line by line, even for file types with a registered adapter (e.g. CSV)")
+var minMatchRunPtr = flag.Int("min-match-run", 0, "keep changes separated by fewer than N matching lines in the same hunk")
+var stripAnsiPtr = flag.Bool("strip-ansi", false, "remove ANSI color and other CSI escape sequences before comparing")
+var normalizeTypographyPtr = flag.Bool("normalize-typography", false, "compare curly quotes, dashes and ellipses as their plain ASCII equivalents")
+var modePtr = flag.String("mode", "line", "what to compare the files by: line, or sentence for prose, so reflowing a paragraph isn't a change")
+var preSplitPtr = flag.Bool("pre-split", false, "take each line literally as an already split token or record: no tab expansion, --strip-ansi, --normalize-typography or --ignore-comments")
+var ignoreCommentsPtr = flag.Bool("ignore-comments", false, "compare lines without their comments, so changes confined to comments don't count")
+var ignoreStringLiteralsPtr = flag.Bool("ignore-string-literals", false, "compare lines with the contents of their string literals replaced by a placeholder, so changes confined to strings, such as reworded messages, don't count")
+var quoteSyntaxPtr = flag.String("quote-syntax", "\"", "with --ignore-string-literals, the quotes: a comma separated list of quotes, or of space separated opening and closing quotes, e.g. \"'\" for single quotes")
+var commentSyntaxPtr = flag.String("comment-syntax", "//,/* */", "with --ignore-comments, the comment markers: a comma separated list of line comment markers and space separated pairs of block comment delimiters")
+var similarityPtr = flag.String("similarity", diff.DEFAULT_SIMILARITY_METRIC, "how to measure the similarity of two lines: " + strings.Join(diff.SimilarityMetricNames(), ", "))
+var stripLinePrefixPtr = flag.String("strip-line-prefix", "", "compare lines which start with this literal text without it, e.g. \"> \" for quoted text, though it's still shown")
+var stripLineSuffixPtr = flag.String("strip-line-suffix", "", "compare lines which end with this literal text without it, though it's still shown")
+var stripTrailingTokenPtr = flag.String("strip-trailing-token", "", "compare lines without the match of this regular expression at the end of each, e.g. a timestamp or a hash, though it's still shown")
+var maxLineLengthPtr = flag.Int("max-line-length", 0, "truncate lines longer than N characters as they're read, with a marker, and compare them that way; 0 for no limit")
+var minHashLenPtr = flag.Int("min-hash-len", 0, "only consider lines shorter than N characters similar when they're identical")
+var internLinesPtr = flag.Bool("intern-lines", false, "share one in-memory line between identical lines, to save time and memory on repetitive files")
+var setPtr = flag.Bool("set", false, "compare the files as sets of lines, ignoring order, and print the lines (by count) only in one or the other")
+var matrixPtr = flag.Bool("matrix", false, "compare every pair of two or more files and report a matrix of their similarities")
+var jobsPtr = flag.Int("jobs", 1, "with --matrix or two directories, diff up to N pairs of files at once")
+var skipGeneratedPtr = flag.Bool("skip-generated", false, "with --matrix or two directories, don't diff a pair of files if either is generated, but mark it as such in the report")
+var generatedRegexPtr = flag.String("generated-regex", diff.DEFAULT_GENERATED_PATTERN, "with --skip-generated, the regular expression matching a line which marks a file as generated")
+var preserveTabsPtr = flag.Bool("preserve-tabs", false, "show the original tabs in the HTML instead of the spaces they were expanded to")
+var tabGuidesPtr = flag.Bool("tab-guides", false, "draw each tab as a faint indentation guide in the HTML; implies --preserve-tabs")
+var ignoreBlankAtEofPtr = flag.Bool("ignore-blank-at-eof", false, "ignore blank lines added or removed at the end of a file")
+var charsetPtr = flag.String("charset", "utf-8", "character set of the HTML page: utf-8 or iso-8859-1 (latin-1)")
+var bomPtr = flag.Bool("bom", false, "start a UTF-8 HTML page with a byte order mark, for tools which expect one")
+var breakpointPtr = flag.Int("breakpoint", 800, "viewport width in pixels below which the HTML switches to an inline view; 0 to always stay side by side")
+var encodingPtr = flag.String("encoding", "", "read the files as utf-8, utf-16le, or utf-16be; by default UTF-16 is recognized by its byte order mark")
+var tabSizePtr = flag.Int("tab-size", 4, "expand tabs to this many columns before comparing")
+var leftTabSizePtr = flag.Int("left-tab-size", 0, "tab size for the first file, if different from --tab-size")
+var rightTabSizePtr = flag.Int("right-tab-size", 0, "tab size for the second file, if different from --tab-size")
+var verbosityPtr = newCountFlag("v", "verbose", "log what diffy is doing to stderr; repeat (-v -v) for debugging detail")
+var detectBlockIndentPtr = flag.Bool("detect-block-indent", false, "treat blocks whose only change is a uniform shift in indentation as matching")
+var wholeWordHighlightPtr = flag.Bool("whole-word-highlight", false, "highlight whole changed words within a line, rather than just the changed characters")
+var wordCharsPtr = flag.String("word-chars", "code", "what words are made of, for --format=color-words and --whole-word-highlight: code (letters, digits and underscores), prose (letters and digits), or path (code, plus / \\ . - ~ :)")
+var gradedRunsPtr = flag.Bool("graded-runs", false, "shade changed parts of a line by size, so big changes stand out from small ones")
+var runTooltipsPtr = flag.Bool("run-tooltips", false, "in the HTML, give each changed part of a line a tooltip saying how many characters changed and how similar the lines are")
+var showStatsPtr = flag.Bool("show-stats", false, "show each file's line count and the percentage of lines changed in the heading")
+var groupByPtr = flag.String("group-by", "", "in the HTML, show the changed lines in sections by the type of change rather than in file order: type")
+var groupChangesPtr = flag.Bool("group-changes", false, "in the HTML, draw each run of changed lines as a single bordered block")
+var compactDiffPtr = flag.Bool("compact-diff", false, "in the HTML, show a long start or end shared by a changed pair of lines once, below the pair, and just the differing middles side by side")
+var stableLineIdsPtr = flag.Bool("stable-line-ids", false, "in the HTML and JSON, give each line an id based on its content, which stays the same when the diff is run again after lines are added above it, for anchoring review comments")
+var cssFilePtr = flag.String("css-file", "", "in the HTML, link to this style sheet and use class names in place of the default inline styles")
+var dumpCssPtr = flag.Bool("dump-css", false, "print the default styles as a style sheet, to start a \"--css-file\" from, and exit")
+var noRealignPtr = flag.Bool("no-realign", false, "show the alignment exactly as the diff algorithm produced it, without splitting dissimilar pairs of lines")
+var adaptiveRealignPtr = flag.Bool("adaptive-realign", false, "split dissimilar line pairs more eagerly when the files are similar overall")
+var pathDisplayPtr = flag.String("path-display", "absolute", "what the HTML shows under each file name: absolute, relative, or name-only")
+var baseDirPtr = flag.String("base-dir", "", "with --path-display=relative, the directory paths are relative to; the current directory by default")
+var followSymlinksPtr = flag.String("follow-symlinks", "yes", "whether to read through symbolic links to files: yes or no")
+var statPtr = flag.Bool("stat", false, "print a summary of the changes like \"git diff --stat\" instead of the diff (which is still opened with --open-with)")
+var explainPtr = flag.Bool("explain", false, "print why each changed line was aligned the way it was to stderr, for tuning thresholds")
+var stripCommonAffixPtr = flag.Bool("strip-common-affix", false, "strip any prefix and suffix shared by every line of both files, such as a logging tag, and show it once in the heading (HTML formats only)")
+var summaryJsonPtr = flag.String("summary-json", "", "also write a one-line JSON summary of the changes to this file, e.g. for a CI pipeline to check, whatever the output format")
+var histogramPtr = flag.Bool("histogram", false, "print a histogram of the similarities of the changed pairs of lines instead of the diff, for choosing a realign threshold")
+var replaceThresholdPtr = flag.Float64("replace-threshold", 0.05, "report files less similar than this (0 to 1) as entirely different, without the full diff")
+var forceFullPtr = flag.Bool("force-full", false, "always show the full diff, even for files which are entirely different")
+var contextBeforePtr = flag.Int("context-before", diff.DEFAULT_CONTEXT, "in hunks (unified, markdown and split output), keep this many unchanged lines before each change")
+var contextAfterPtr = flag.Int("context-after", diff.DEFAULT_CONTEXT, "in hunks, keep this many unchanged lines after each change")
+var onlyPtr = flag.String("only", "", "for a focused review, show only the added or only the removed lines, with a little context: added or removed")
+var viewPtr = flag.String("view", "", "show the diff another way, in place of the output format's: additions-in-context, the first file with the second's added and changed lines slotted in and its deleted lines left out")
+var topPtr = flag.Int("top", 0, "only show the N most changed pairs of lines, with a little context, for a quick triage")
+var splitOutputPtr = flag.String("split-output", "", "write the HTML diff to DIR as one page per hunk, plus an index page")
+var splitHunksPtr = flag.Int("split-hunks", 1, "with --split-output, put N hunks on each page")
+var portPtr = flag.Int("port", 8080, "with the serve subcommand, the port to listen on")
+var rootPtr = flag.String("root", ".", "with the serve subcommand, the directory the served files must be in")
+var reversePtr = flag.Bool("reverse", false, "show the diff that undoes the change, from the second file back to the first, like \"patch -R\"")
+var checkPtr = flag.Bool("check", false, "check that the alignment accounts for every line of both files, in order, and fail if it doesn't, for catching bugs")
+var quietPtr = newBoolFlag("q", "quiet", "print nothing, and only exit with 1 if the files differ or 0 if they don't; files which are byte for byte the same aren't diffed at all")
+var dumpMatrixPtr = flag.Bool("dump-matrix", false, "print the edit distance matrix to stderr, for debugging (large matrices are downsampled)")
+var pathNormalizePtr = flag.String("path-normalize", "", "comparing two directories, pair the files whose paths only differ in their separators (separators), or in their case as well (case)")
+var ignoreFilePtr = flag.String("ignore-file", "", "comparing two directories, also skip the paths matched by the .gitignore style patterns in this file, after those in each root's " + etc.IGNORE_FILE_NAME)
+var debugHeatmapPtr = flag.Bool("debug-heatmap", false, "print an HTML heatmap of how similar every line of the first file is to every line of the second instead of the diff, with the alignment outlined, for debugging small files")
+
+// ------------------------------------------- outputFormats
+
+// The values accepted by "--format".
+var outputFormats = []string{"html", "html-fragment", "color-words", "json", "linemap", "markdown", "png", "unified"}
+
+// ------------------------------------------- type tCountFlag
+
+// A boolean-style flag which counts how many times it was given, so "-v -v"
+// means more than "-v".
+type tCountFlag int
+
+func (count *tCountFlag) String() string   { return fmt.Sprint(int(*count)) }
+func (count *tCountFlag) Set(string) error { *count++; return nil }
+func (count *tCountFlag) IsBoolFlag() bool { return true }
+
+// Register a counting flag under a short and a long name.
+func newCountFlag(shortName, longName, usage string) *tCountFlag {
+	count := new(tCountFlag)
+	flag.Var(count, shortName, usage)
+	flag.Var(count, longName, usage)
+	return count
+}
+
+// ------------------------------------------- newBoolFlag
+
+// Register a boolean flag under a short and a long name.
+func newBoolFlag(shortName, longName, usage string) *bool {
+	value := flag.Bool(longName, false, usage)
+	flag.BoolVar(value, shortName, false, usage)
+	return value
+}
+
+// ------------------------------------------- stdout and stderr
+
+// Where Run writes, which is the real stdout and stderr except in tests.
+var stdout io.Writer = os.Stdout
+var stderr io.Writer = os.Stderr
+
+// A SimpleLogger which writes to "stderr", whatever it is at the time.
+type tStderrLogger struct {}
+
+var stderrLogger tStderrLogger
+
+// The jobs of "--jobs" log at the same time, so one line is written at once.
+var stderrMutex sync.Mutex
+
+func (logger tStderrLogger) Printf(format string, a ...interface{}) {
+	stderrMutex.Lock()
+	defer stderrMutex.Unlock()
+	fmt.Fprintf(stderr, format, a...)
+}
+
+func (logger tStderrLogger) Println(a ...interface{}) {
+	stderrMutex.Lock()
+	defer stderrMutex.Unlock()
+	fmt.Fprintln(stderr, a...)
+}
+
+// ------------------------------------------- logger
+
+// Diagnostics go to stderr, filtered by the "-v" count.
+var logger = diff.NewLeveledLogger(stderrLogger, diff.LogWarn)
 
 // ------------------------------------------- main
 
-func main() {
+func main() {
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// ------------------------------------------- Run
+
+// Everything main does, given the command line arguments (without the program
+// name) and where to write, and returning the exit code.  The flags are reset
+// to their defaults first, so Run can be called more than once, e.g. by tests.
+func Run(args []string, stdoutWriter, stderrWriter io.Writer) (exitCode int) {
+	stdout, stderr = stdoutWriter, stderrWriter
+
+	// An exit from anywhere below ends up here.
+	defer func () {
+		if recovered := recover(); recovered != nil {
+			exited, ok := recovered.(tExit)
+			if !ok {
+				panic(recovered)
+			}
+			exitCode = exited.exitCode
+		}
+	}()
+
+	// We must parse the flags before we do anything else.
+	resetFlags()
+	parseFlags(args)
+	logger.Level = diff.LogLevel(*verbosityPtr)
+	if *quietPtr {
+		logger.Level = diff.LogQuiet
+	}
+
+	// "normalize" is a subcommand, which takes flags of its own after it.
+	if flag.Arg(0) == "normalize" {
+		parseFlags(flag.Args()[1:])
+		mainNormalize(flag.Args())
+		return 0
+	}
+
+	// So is "serve", which serves diffs over HTTP.
+	if flag.Arg(0) == "serve" {
+		parseFlags(flag.Args()[1:])
+		mainServe(flag.Args())
+		return 0
+	}
+
+	// So is "diff-runs", which compares the JSON output of two runs.
+	if flag.Arg(0) == "diff-runs" {
+		parseFlags(flag.Args()[1:])
+		mainDiffRuns(flag.Args())
+		return 0
+	}
+
+	// The default style sheet needs no files at all.
+	if *dumpCssPtr {
+		output.GenerateStyleSheet(stdout, makeHtmlOptions(diff.Options{TabSize: *tabSizePtr}))
+		return 0
+	}
+
+	// Do we have the right number of arguments?
+	if *matrixPtr && len(flag.Args()) < 2 {
+		fmt.Fprintf(stderr, "Usage: %s --matrix FILE1 FILE2 [FILE...]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Exit 1.")
+		return 1
+	}
+	if !*matrixPtr && len(flag.Args()) != 2 {
+		fmt.Fprintf(stderr, "Usage: %s FILE1 FILE2\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(stderr, "       %s DIR1 DIR2\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(stderr, "       %s normalize FILE\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(stderr, "       %s diff-runs BEFORE.json AFTER.json\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(stderr, "       %s serve [--port PORT] [--root DIR]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Exit 1.")
+		return 1
+	}
+
+	// Is the symlink policy one we know about?
+	if *followSymlinksPtr != "yes" && *followSymlinksPtr != "no" {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected yes or no.\n", "--follow-symlinks", *followSymlinksPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the path display one we know about?
+	if _, ok := output.ParsePathDisplay(*pathDisplayPtr); !ok {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected absolute, relative, or name-only.\n", "--path-display", *pathDisplayPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the mode one we know about?
+	if *modePtr != "line" && *modePtr != "sentence" {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected line or sentence.\n", "--mode", *modePtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the context something we can show?
+	if *contextBeforePtr < 0 || *contextAfterPtr < 0 {
+		fmt.Fprintf(stderr, "The %q and %q values can't be negative.\n", "--context-before", "--context-after")
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the focus one we know about?
+	if _, ok := output.ParseFocus(*onlyPtr); !ok {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected added or removed.\n", "--only", *onlyPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the path normalization one we know about?
+	if _, ok := etc.ParsePathNormalization(*pathNormalizePtr); !ok {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected separators or case.\n", "--path-normalize", *pathNormalizePtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the grouping one we know about?
+	if _, ok := output.ParseGroupBy(*groupByPtr); !ok {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected type.\n", "--group-by", *groupByPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+	if *groupByPtr != "" && *formatPtr != "html" && *formatPtr != "html-fragment" {
+		logger.Warnf("%q only applies to the HTML formats", "--group-by")
+	}
+
+	// Is the word character profile one we know about?
+	if _, ok := diff.ParseWordChars(*wordCharsPtr); !ok {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected code, prose, or path.\n", "--word-chars", *wordCharsPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the view one we know about?
+	if _, ok := output.ParseView(*viewPtr); !ok {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected additions-in-context.\n", "--view", *viewPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the similarity metric one we know about?
+	checkSimilarityFlag()
+
+	// Is the encoding one we can read?
+	if *encodingPtr != "" && !etc.IsEncoding(*encodingPtr) {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected utf-8, utf-16le, or utf-16be.\n", "--encoding", *encodingPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the output format one we know about?
+	if !isOutputFormat(*formatPtr) {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected one of %s.\n", "--format", *formatPtr, strings.Join(outputFormats, ", "))
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Is the character set one we can write?  Only HTML pages have one.
+	if charset, ok := output.ParseCharset(*charsetPtr); !ok {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected utf-8 or iso-8859-1.\n", "--charset", *charsetPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	} else if *bomPtr && charset != output.CHARSET_UTF8 {
+		fmt.Fprintf(stderr, "%q is only for UTF-8, so it can't be used with %q %q.\n", "--bom", "--charset", *charsetPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	} else if (*bomPtr || charset != output.CHARSET_UTF8) && *formatPtr != "html" {
+		fmt.Fprintf(stderr, "%q and %q only apply to HTML pages, so they can't be used with %q %q.\n", "--charset", "--bom", "--format", *formatPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// Split output is HTML pages only.
+	if *splitOutputPtr != "" && *formatPtr != "html" {
+		fmt.Fprintf(stderr, "%q only writes HTML, so it can't be used with %q %q.\n", "--split-output", "--format", *formatPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// The stripped text is shown once in the HTML heading; any other output
+	// would write the lines without it.
+	if *stripCommonAffixPtr && *setPtr {
+		fmt.Fprintf(stderr, "%q only applies to the HTML formats, so it can't be used with %q.\n", "--strip-common-affix", "--set")
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	} else if *stripCommonAffixPtr && *formatPtr != "html" && *formatPtr != "html-fragment" {
+		fmt.Fprintf(stderr, "%q only applies to the HTML formats, so it can't be used with %q %q.\n", "--strip-common-affix", "--format", *formatPtr)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// The matrix report is a different beast altogether.
+	if *matrixPtr {
+		if *splitOutputPtr != "" {
+			logger.Warnf("%q is ignored with %q", "--split-output", "--matrix")
+		}
+		mainMatrix(flag.Args())
+		return 0
+	}
+
+	// Extract our arguments.
+	pathToFile1, pathToFile2 := flag.Arg(0), flag.Arg(1)
+
+	// Do the specified files exist?
+	if !checkThatPathExists(pathToFile1) || !checkThatPathExists(pathToFile2) {
+		exitWithNotification(1)
+	}
+
+	// Two directories are compared file by file.
+	if isADirectory(pathToFile1) && isADirectory(pathToFile2) {
+		mainDirectories(pathToFile1, pathToFile2)
+		return 0
+	}
+	if *ignoreFilePtr != "" {
+		logger.Warnf("%q only applies to comparing two directories", "--ignore-file")
+	}
+
+	// Are the files actually files?
+	if !checkThatPathIsAFile(pathToFile1) || !checkThatPathIsAFile(pathToFile2) {
+		exitWithNotification(1)
+	}
+
+	// Quietly, identical files are all there is to know about, unless there's
+	// a summary to write, and anything else is compared as usual, just without
+	// any output.
+	if *quietPtr {
+		equal, err := filesEqual(pathToFile1, pathToFile2)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not compare %q with %q; error = %v\n", pathToFile1, pathToFile2, err)
+			exitWithNotification(2)
+		}
+		if equal && *summaryJsonPtr == "" {
+			logger.Infof("the files are byte for byte the same")
+			return 0
+		}
+		stdout = ioutil.Discard
+	}
+
+	// Work out how to compare the files.
+	settings := makeCompareSettings()
+
+	// Try to read the files.
+	// Each file can have its own tab size, which defaults to the common one.
+	readOptions1 := makeReadOptions()
+	readOptions2 := readOptions1
+	if *leftTabSizePtr > 0 {
+		readOptions1.TabSize = *leftTabSizePtr
+	}
+	if *rightTabSizePtr > 0 {
+		readOptions2.TabSize = *rightTabSizePtr
+	}
+	lines1, finalNewline1, encoding1, err := readFileWithEncoding(pathToFile1, readOptions1)
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not read %q; error = %v\n", pathToFile1, err)
+		exitWithNotification(2)
+	}
+	lines2, finalNewline2, encoding2, err := readFileWithEncoding(pathToFile2, readOptions2)
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not read %q; error = %v\n", pathToFile2, err)
+		exitWithNotification(3)
+	}
+
+	// The heatmap has a cell for every pair of lines, so it's for small files only.
+	if *debugHeatmapPtr && (len(lines1) > diff.MAX_SIMILARITY_GRID_SIZE || len(lines2) > diff.MAX_SIMILARITY_GRID_SIZE) {
+		fmt.Fprintf(stderr, "The files are too big for %q (%d and %d lines); it takes at most %d lines each.\n", "--debug-heatmap", len(lines1), len(lines2), diff.MAX_SIMILARITY_GRID_SIZE)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+
+	// The decoded text is what's compared, so a difference in encoding alone
+	// doesn't show up in the diff.
+	if encoding1 != encoding2 {
+		logger.Warnf("the files differ in encoding: %q is %s and %q is %s", pathToFile1, encoding1, pathToFile2, encoding2)
+	}
+
+	logger.Infof("comparing %q (%d lines) with %q (%d lines)", pathToFile1, len(lines1), pathToFile2, len(lines2))
+
+	// An empty file makes for a trivial diff, which is worth saying outright.
+	if note := output.EmptyInputsNote(len(lines1), len(lines2)); note != "" {
+		logger.Infof("%s", note)
+	}
+
+	// Text on every line of both files is only noise in the diff.
+	lines1, lines2, strippedPrefix, strippedSuffix := stripCommonAffix(lines1, lines2)
+
+	// Comparing as sets ignores order, so there's no alignment to display.
+	if *setPtr {
+		setDiff := diff.DiffSets(lines1, lines2)
+		common, leftOnly, rightOnly := setDiff.Counts()
+		logger.Infof("%d lines in common, %d only in %q, %d only in %q", common, leftOnly, pathToFile1, rightOnly, pathToFile2)
+		output.GenerateSetDiff(stdout, setDiff, output.NewSourceLinesRec(lines1, pathToFile1), output.NewSourceLinesRec(lines2, pathToFile2))
+		if setDiff.HasDifferences() {
+			return 1
+		}
+		return 0
+	}
+
+	// Compare the files the way the flags say, just as "serve" does.
+	comparison, failed, err := compareFiles(pathToFile1, pathToFile2, lines1, lines2, settings)
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not parse %q; error = %v\n", []string{pathToFile1, pathToFile2}[failed], err)
+		exitWithNotification(2 + failed)
+	}
+	lines1, lines2 = comparison.lines1, comparison.lines2
+	distance, alignment := comparison.distance, comparison.alignment
+
+	if *checkPtr {
+		if err := alignment.Validate(lines1, lines2); err != nil {
+			fmt.Fprintf(stderr, "The alignment is invalid, which is a bug; error = %v\n", err)
+			exitWithNotification(2)
+		}
+		logger.Infof("the alignment is valid")
+	}
+	if logger.Enabled(diff.LogDebug) {
+		alignment.Dump(lines1, lines2, int(distance), logger)
+	}
+
+	sourceLines1 := output.NewSourceLinesRec(lines1, pathToFile1)
+	sourceLines2 := output.NewSourceLinesRec(lines2, pathToFile2)
+	sourceLines1.FinalNewline = finalNewline1
+	sourceLines2.FinalNewline = finalNewline2
+	sourceLines1.Items, sourceLines2.Items = comparison.items1, comparison.items2
+
+	// The reverse diff is the same alignment seen from the other side.
+	if *reversePtr {
+		alignment = alignment.Swap()
+		sourceLines1, sourceLines2 = sourceLines2, sourceLines1
+	}
+
+	// The summary goes to its own file, alongside whatever else is output.
+	if *summaryJsonPtr != "" {
+		writeSummaryJson(*summaryJsonPtr, alignment, distance, sourceLines1, sourceLines2)
+	}
+
+	// The histogram takes the place of the diff altogether.
+	if comparison.histogram != nil {
+		output.GenerateSimilarityHistogram(stdout, comparison.histogram, diff.DEFAULT_REALIGN_THRESHOLD, output.DEFAULT_HISTOGRAM_BAR_WIDTH)
+		if output.HasDifferences(alignment, sourceLines1, sourceLines2) {
+			return 1
+		}
+		return 0
+	}
+
+	// So does the heatmap.
+	if *debugHeatmapPtr {
+		grid := diff.SimilarityGrid(sourceLines1.Lines, sourceLines2.Lines)
+		output.GenerateSimilarityHeatmap(stdout, grid, alignment, sourceLines1, sourceLines2, makeHtmlOptions(readOptions1))
+		if output.HasDifferences(alignment, sourceLines1, sourceLines2) {
+			return 1
+		}
+		return 0
+	}
+
+	// The diffstat takes the place of the diff on stdout.
+	if *statPtr {
+		entry := output.NewDiffStatEntry(alignment, sourceLines1, sourceLines2)
+		output.GenerateDiffStat(stdout, []output.DiffStatEntry{entry}, output.DEFAULT_DIFFSTAT_BAR_WIDTH)
+	}
+
+	// A diff of two files with nothing in common is all deletions followed by
+	// all insertions, which isn't worth looking at.
+	if report, replaced := describeWholeFileReplacement(alignment, sourceLines1, sourceLines2, float32(*replaceThresholdPtr)); replaced && !*forceFullPtr && (*splitOutputPtr != "" || wantDiffOutput()) {
+		fmt.Fprint(stdout, report)
+		fmt.Fprintf(stdout, "Use %q to see the full diff anyway.\n", "--force-full")
+		return 1
+	}
+
+	htmlOptions := makeHtmlOptions(readOptions1)
+	htmlOptions.RightTabSize = readOptions2.TabSize
+	htmlOptions.StrippedPrefix, htmlOptions.StrippedSuffix = strippedPrefix, strippedSuffix
+
+	// Selecting and displaying the changes realign the same pairs of lines.
+	htmlOptions.Compare = diff.NewSimilarityCache().Compare
+
+	// The triage view or a focused review shows just some of the changes.
+	displayAlignment, htmlOptions := selectDisplayedChanges(alignment, sourceLines1, sourceLines2, htmlOptions)
+
+	// Split output goes to its own directory, in place of the usual output.
+	if *splitOutputPtr != "" {
+		indexPath, err := output.GenerateSplitHtml(*splitOutputPtr, displayAlignment, sourceLines1, sourceLines2, *splitHunksPtr, htmlOptions)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not write the split output to %q; error = %v\n", *splitOutputPtr, err)
+			exitWithNotification(4)
+		}
+		logger.Infof("wrote the split output; the index is %q", indexPath)
+		openOutputPath(indexPath)
+	} else if wantDiffOutput() {
+
+		// We will output to stdout or a temporary file (or both), depending.
+		outputFile := createOutputFile()
+		defer outputFile.Close()
+		writer := teeOutput(outputFile, stdout)
+
+		// A view takes the place of the format.
+		if view, _ := output.ParseView(*viewPtr); view == output.ViewAdditionsInContext {
+			output.GenerateAdditionsInContext(writer, displayAlignment, sourceLines1, sourceLines2, htmlOptions)
+		} else {
+			switch *formatPtr {
+			case "html":
+				output.GenerateHtmlDiffPage(writer, displayAlignment, sourceLines1, sourceLines2, htmlOptions)
+			case "html-fragment":
+				output.GenerateHtmlFragment(writer, displayAlignment, sourceLines1, sourceLines2, htmlOptions)
+			case "color-words":
+				output.GenerateColorWords(writer, sourceLines1, sourceLines2, output.AnsiWordDiffMarkers, htmlOptions.WordChars)
+			case "json":
+				if err := output.GenerateJsonDiff(writer, displayAlignment, sourceLines1, sourceLines2, htmlOptions); err != nil {
+					fmt.Fprintf(stderr, "Could not write the JSON; error = %v\n", err)
+					exitWithNotification(4)
+				}
+			case "linemap":
+				if err := output.GenerateLineMap(writer, displayAlignment, sourceLines1, sourceLines2, htmlOptions); err != nil {
+					fmt.Fprintf(stderr, "Could not write the line map; error = %v\n", err)
+					exitWithNotification(4)
+				}
+			case "png":
+				if err := output.GeneratePngDiff(writer, displayAlignment, sourceLines1, sourceLines2, htmlOptions); err != nil {
+					fmt.Fprintf(stderr, "Could not write the PNG; error = %v\n", err)
+					exitWithNotification(4)
+				}
+			case "markdown":
+				output.GenerateMarkdownDiff(writer, displayAlignment, sourceLines1, sourceLines2, *markdownHunkHeadersPtr, htmlOptions)
+			case "unified":
+				output.GenerateUnifiedDiff(writer, displayAlignment, sourceLines1, sourceLines2, htmlOptions)
+			default:
+				panic("not reached")
+			}
+		}
+
+		openOutputFile(outputFile)
+	}
+
+	// Like diff, we exit with 1 when the files differ and 0 when they don't.
+	if output.HasDifferences(alignment, sourceLines1, sourceLines2) {
+		return 1
+	}
+	return 0
+}
+
+// ------------------------------------------- mainMatrix
+
+// Compare every pair of files and generate the matrix report.  Exits with 1
+// if any of the files differ, like a two-file diff does, not counting the
+// pairs skipped as generated.
+func mainMatrix(paths []string) {
+
+	for _, path := range paths {
+		if !checkThatPathExists(path) || !checkThatPathIsAFile(path) {
+			exitWithNotification(1)
+		}
+	}
+
+	generatedRegexp := makeGeneratedRegexp()
+	readOptions := makeReadOptions()
+	var files []diff.ComparableLines
+	var sources []*output.SourceLinesRec
+	var generated []bool
+	for _, path := range paths {
+		lines, finalNewline, err := readFile(path, readOptions)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not read %q; error = %v\n", path, err)
+			exitWithNotification(2)
+		}
+		source := output.NewSourceLinesRec(lines, path)
+		source.FinalNewline = finalNewline
+		if generatedRegexp != nil {
+			source.Generated = diff.IsGenerated(lines, generatedRegexp)
+			if source.Generated {
+				logger.Infof("skipping the pairs with %q, which is generated", path)
+			}
+			generated = append(generated, source.Generated)
+		}
+		files = append(files, lines)
+		sources = append(sources, source)
+	}
+
+	matrix := diff.DiffMatrixSkippingGenerated(files, diff.Diff_v2, *jobsPtr, generated)
+	for i := range matrix {
+		for j := i + 1; j < len(matrix); j++ {
+			if matrix[i][j].Err != nil {
+				logger.Warnf("%v", matrix[i][j].Err)
+			}
+		}
+	}
+
+	if *statPtr {
+		var entries []output.DiffStatEntry
+		for i := range matrix {
+			for j := i + 1; j < len(matrix); j++ {
+				if !matrix[i][j].Identical && matrix[i][j].Err == nil && !matrix[i][j].Generated {
+					entries = append(entries, output.NewDiffStatEntry(matrix[i][j].Alignment, sources[i], sources[j]))
+				}
+			}
+		}
+		output.GenerateDiffStat(stdout, entries, output.DEFAULT_DIFFSTAT_BAR_WIDTH)
+	}
+
+	if wantDiffOutput() {
+		outputFile := createOutputFile()
+		defer outputFile.Close()
+		htmlOptions := makeHtmlOptions(readOptions)
+		output.GenerateHtmlMatrixPage(teeOutput(outputFile, stdout), sources, matrix, htmlOptions)
+		openOutputFile(outputFile)
+	}
+
+	for i := range matrix {
+		for j := range matrix[i] {
+			if !matrix[i][j].Identical && !matrix[i][j].Generated {
+				exit(1)
+			}
+		}
+	}
+}
+
+// ------------------------------------------- makeGeneratedRegexp
+
+// Compile the generated file marker if skipping generated files, or return
+// nil if not.
+func makeGeneratedRegexp() *regexp.Regexp {
+	if !*skipGeneratedPtr {
+		return nil
+	}
+	generatedRegexp, err := regexp.Compile(*generatedRegexPtr)
+	if err != nil {
+		fmt.Fprintf(stderr, "The %q pattern %q is not a valid regular expression; error = %v\n", "--generated-regex", *generatedRegexPtr, err)
+		exitWithNotification(1)
+	}
+	return generatedRegexp
+}
+
+// ------------------------------------------- mainDirectories
+
+// Compare the files of two trees which have the same path relative to their
+// roots, or the same up to "--path-normalize", skipping the paths the roots'
+// ignore files and "--ignore-file" match, and write the changes as one patch,
+// or with "--stat", as one diffstat.  Like a two-file diff, exits with 1 if any
+// of the files differ, or are only in one of the trees.  With
+// "--skip-generated", a pair with a generated file is only reported as such,
+// and doesn't count as a difference.  With "--quiet", there's only the exit
+// code, in any format.
+func mainDirectories(root1, root2 string) {
+	if !*quietPtr && !*statPtr && *formatPtr != "unified" {
+		fmt.Fprintf(stderr, "Two directories can only be compared with %q or %q.\n", "--format=unified", "--stat")
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+	extraIgnores := &etc.IgnoreList{}
+	if *ignoreFilePtr != "" {
+		var err error
+		if extraIgnores, err = etc.LoadIgnoreFile(*ignoreFilePtr); err != nil {
+			fmt.Fprintf(stderr, "Could not read %q; error = %v\n", *ignoreFilePtr, err)
+			exitWithNotification(1)
+		}
+	}
+	var trees [][]string
+	for index, root := range []string{root1, root2} {
+		paths, err := listTree(root, extraIgnores)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not list the files in %q; error = %v\n", root, err)
+			exitWithNotification(2 + index)
+		}
+		trees = append(trees, paths)
+	}
+
+	settings := makeCompareSettings()
+	generatedRegexp := makeGeneratedRegexp()
+	readOptions := makeReadOptions()
+	readSource := func (root, path string, index int) *output.SourceLinesRec {
+		source, err := readTreeFile(root, path, readOptions)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			exitWithNotification(2 + index)
+		}
+		return source
+	}
+
+	// Pair the files up by path, and compare the pairs on up to "--jobs"
+	// goroutines.  The results are kept in the order of the pairs, so the
+	// output is the same however many jobs there are.
+	var files []output.PatchFile
+	var entries []output.DiffStatEntry
+	differ := false
+	normalization, _ := etc.ParsePathNormalization(*pathNormalizePtr)
+	pairs, leftOnly, rightOnly := etc.PairPaths(trees[0], trees[1], normalization)
+	results := make([]tTreePairResult, len(pairs))
+	diff.RunJobs(len(pairs), *jobsPtr, func (index int) {
+		results[index] = compareTreePair(root1, root2, pairs[index], settings, generatedRegexp, readOptions)
+	})
+	for _, result := range results {
+		if result.failure != nil {
+			fmt.Fprintln(stderr, result.failure)
+			exitWithNotification(result.exitCode)
+		}
+		if result.file != nil {
+			files = append(files, *result.file)
+			differ = differ || !result.file.Generated
+		}
+	}
+	for _, path := range leftOnly {
+		files = append(files, output.PatchFile{Path: path, Left: readSource(root1, path, 0)})
+	}
+	for _, path := range rightOnly {
+		files = append(files, output.PatchFile{Path: path, Right: readSource(root2, path, 1)})
+	}
+	differ = differ || len(leftOnly) > 0 || len(rightOnly) > 0
+	sort.SliceStable(files, func (i, j int) bool { return files[i].Path < files[j].Path })
+
+	// Quietly, the exit status is all there is to report.
+	if *quietPtr {
+		if differ {
+			exit(1)
+		}
+		return
+	}
+	if *statPtr {
+		for _, file := range files {
+			left, right := file.Left, file.Right
+			if left == nil {
+				left = output.NewSourceLinesRec(nil, file.Path)
+			}
+			if right == nil {
+				right = output.NewSourceLinesRec(nil, file.Path)
+			}
+			if file.Generated {
+				entries = append(entries, output.DiffStatEntry{Path: file.Path, Generated: true})
+				continue
+			}
+			alignment := file.Alignment
+			if alignment == nil {
+				_, alignment = diff.Diff_v2(left.Lines, right.Lines)
+			}
+			entries = append(entries, output.NewDiffStatEntry(alignment, left, right))
+		}
+		output.GenerateDiffStat(stdout, entries, output.DEFAULT_DIFFSTAT_BAR_WIDTH)
+	} else {
+		output.GeneratePatchSeries(stdout, files, makeHtmlOptions(readOptions))
+	}
+	if differ {
+		exit(1)
+	}
+}
+
+// ------------------------------------------- compareTreePair
+
+// What became of comparing a pair of files in mainDirectories: the file to
+// show, if the pair differs, or else why it couldn't be compared and the exit
+// code to give for it.
+type tTreePairResult struct {
+	file *output.PatchFile
+	failure error
+	exitCode int
+}
+
+// Compare a pair of files of two trees, skipping them if they're byte for byte
+// the same.  It may run on any of the "--jobs" goroutines, so rather than
+// exiting, it returns any failure, and the lines are interned in a pool of
+// the pair's own.
+func compareTreePair(root1, root2 string, pair etc.PathPair, settings *tCompareSettings, generatedRegexp *regexp.Regexp, readOptions diff.Options) tTreePairResult {
+	path1, path2 := filepath.Join(root1, pair.Left), filepath.Join(root2, pair.Right)
+	equal, err := filesEqual(path1, path2)
+	if err != nil {
+		return tTreePairResult{failure: fmt.Errorf("Could not compare %q and %q; error = %v", path1, path2, err), exitCode: 2}
+	}
+	if equal {
+		return tTreePairResult{}
+	}
+
+	readOptions.LinePool = makeLinePool()
+	source1, err := readTreeFile(root1, pair.Left, readOptions)
+	if err != nil {
+		return tTreePairResult{failure: err, exitCode: 2}
+	}
+	source2, err := readTreeFile(root2, pair.Right, readOptions)
+	if err != nil {
+		return tTreePairResult{failure: err, exitCode: 3}
+	}
+	if generatedRegexp != nil && (diff.IsGenerated(source1.Lines, generatedRegexp) || diff.IsGenerated(source2.Lines, generatedRegexp)) {
+		logger.Infof("not diffing %q, which is generated", pair.Left)
+		return tTreePairResult{file: &output.PatchFile{Path: pair.Left, Left: source1, Right: source2, Generated: true}}
+	}
+
+	comparison, failed, err := compareFiles(path1, path2, source1.Lines, source2.Lines, settings)
+	if err != nil {
+		return tTreePairResult{failure: fmt.Errorf("Could not parse %q; error = %v", []string{path1, path2}[failed], err), exitCode: 2 + failed}
+	}
+	source1.Lines, source2.Lines = comparison.lines1, comparison.lines2
+	source1.Items, source2.Items = comparison.items1, comparison.items2
+	if !output.HasDifferences(comparison.alignment, source1, source2) {
+		return tTreePairResult{}
+	}
+	return tTreePairResult{file: &output.PatchFile{Path: pair.Left, Left: source1, Right: source2, Alignment: comparison.alignment}}
+}
+
+// ------------------------------------------- readTreeFile
+
+// Read the file at "path" under "root", for a PatchFile, which names it by
+// "path".
+func readTreeFile(root, path string, readOptions diff.Options) (*output.SourceLinesRec, error) {
+	lines, finalNewline, err := readFile(filepath.Join(root, path), readOptions)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read %q; error = %v", filepath.Join(root, path), err)
+	}
+	source := output.NewSourceLinesRec(lines, path)
+	source.FinalNewline = finalNewline
+	return source, nil
+}
+
+// ------------------------------------------- listTree
+
+// The paths of the files under "root", relative to it and "/" separated, in
+// order, less the ones ignored by the root's ignore file followed by "extra".
+// An ignored directory isn't looked in at all.  With "--follow-symlinks=yes",
+// a link to a file is listed and a link to a directory is looked in, unless
+// it leads back to a directory it's in; with "no", links are skipped.  A link
+// which is skipped is reported.
+func listTree(root string, extra *etc.IgnoreList) ([]string, error) {
+	ignores, err := etc.LoadIgnoreFile(filepath.Join(root, etc.IGNORE_FILE_NAME))
+	if os.IsNotExist(err) {
+		ignores = &etc.IgnoreList{}
+	} else if err != nil {
+		return nil, err
+	}
+	ignores = ignores.Concat(extra)
+
+	var paths []string
+	ancestors := make(map[string]bool)		// the real paths of the directories being listed
+	var walk func (dir, relativeDir string) error
+	walk = func (dir, relativeDir string) error {
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if ancestors[realDir] {
+			logger.Warnf("skipping %q, which links back to %q", dir, realDir)
+			return nil
+		}
+		ancestors[realDir] = true
+		defer delete(ancestors, realDir)
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, info := range entries {
+			entryPath, relativePath := filepath.Join(dir, info.Name()), path.Join(relativeDir, info.Name())
+			if info.Mode() & os.ModeSymlink != 0 {
+				if *followSymlinksPtr == "no" {
+					logger.Warnf("skipping %q, which is a symbolic link", entryPath)
+					continue
+				}
+				if info, err = os.Stat(entryPath); err != nil {
+					logger.Warnf("skipping %q, which is a broken symbolic link", entryPath)
+					continue
+				}
+			}
+			if ignores.Match(relativePath, info.IsDir()) {
+				continue
+			}
+			if info.IsDir() {
+				if err := walk(entryPath, relativePath); err != nil {
+					return err
+				}
+			} else if info.Mode().IsRegular() {
+				paths = append(paths, relativePath)
+			}
+		}
+		return nil
+	}
+	err = walk(root, "")
+	return paths, err
+}
+
+// ------------------------------------------- mainServe
+
+// Serve HTML diffs of the files under "--root" at "GET /diff?left=PATH&right=PATH",
+// where the paths are relative to the root, until killed.  The diffs use the
+// read and HTML flags given along with "serve".
+func mainServe(args []string) {
+	if len(args) != 0 {
+		fmt.Fprintf(stderr, "Usage: %s serve [--port PORT] [--root DIR]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+	checkSimilarityFlag()
+	root, err := filepath.Abs(*rootPtr)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not find the %q directory %q; error = %v\n", "--root", *rootPtr, err)
+		exitWithNotification(1)
+	}
+
+	// Each request reads its files afresh, and can't share a line pool with
+	// the others, since requests are served concurrently.
+	readOptions := makeReadOptions()
+	readOptions.LinePool = nil
+	settings := makeCompareSettings()
+	address := fmt.Sprintf(":%d", *portPtr)
+	logger.Infof("serving diffs of the files in %q on %q", root, address)
+	if err := http.ListenAndServe(address, newDiffHandler(root, readOptions, settings, makeHtmlOptions(readOptions))); err != nil {
+		fmt.Fprintf(stderr, "Could not serve on %q; error = %v\n", address, err)
+		exitWithNotification(4)
+	}
+}
+
+// ------------------------------------------- newDiffHandler
 
-	// We must parse the flags before we do anything else.
-	flag.Parse()
+// The handler for "serve".  The files are compared just as they would be on
+// the command line, with the same flags.  The paths shown in the page are
+// relative to "root", so as not to give away where it is.
+func newDiffHandler(root string, readOptions diff.Options, settings *tCompareSettings, htmlOptions output.HtmlOptions) http.Handler {
+	htmlOptions.PathDisplay, htmlOptions.BaseDir = output.PathRelative, root
+	mux := http.NewServeMux()
+	mux.HandleFunc("/diff", func (w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		var paths, requestedPaths []string
+		var lines []diff.ComparableLines
+		var finalNewlines []bool
+		for _, param := range []string{"left", "right"} {
+			requested := r.URL.Query().Get(param)
+			if requested == "" {
+				http.Error(w, fmt.Sprintf("the %q parameter is missing", param), http.StatusBadRequest)
+				return
+			}
+			path, err := resolveServedPath(root, requested)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			fileLines, finalNewline, err := readFile(path, readOptions)
+			if os.IsNotExist(err) {
+				http.Error(w, fmt.Sprintf("%q was not found", requested), http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, fmt.Sprintf("could not read %q", requested), http.StatusInternalServerError)
+				return
+			}
+			paths, requestedPaths = append(paths, path), append(requestedPaths, requested)
+			lines, finalNewlines = append(lines, fileLines), append(finalNewlines, finalNewline)
+		}
 
-	// Do we have the right number of arguments?
-	if len(flag.Args()) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s FILE1 FILE2\n", filepath.Base(os.Args[0]))
-		fmt.Fprintln(os.Stderr)
-		fmt.Fprintln(os.Stderr, "Exit 1.")
-		os.Exit(1)
+		pageOptions := htmlOptions
+		lines1, lines2, strippedPrefix, strippedSuffix := stripCommonAffix(lines[0], lines[1])
+		pageOptions.StrippedPrefix, pageOptions.StrippedSuffix = strippedPrefix, strippedSuffix
+		pageOptions.Compare = diff.NewSimilarityCache().Compare
+		comparison, failed, err := compareFiles(paths[0], paths[1], lines1, lines2, settings)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not parse %q", requestedPaths[failed]), http.StatusUnprocessableEntity)
+			return
+		}
+		source1, source2 := output.NewSourceLinesRec(comparison.lines1, paths[0]), output.NewSourceLinesRec(comparison.lines2, paths[1])
+		source1.FinalNewline, source2.FinalNewline = finalNewlines[0], finalNewlines[1]
+		source1.Items, source2.Items = comparison.items1, comparison.items2
+		displayAlignment, pageOptions := selectDisplayedChanges(comparison.alignment, source1, source2, pageOptions)
+		w.Header().Set("Content-Type", "text/html; charset=" + pageOptions.Charset)
+		output.GenerateHtmlDiffPage(w, displayAlignment, source1, source2, pageOptions)
+	})
+	return mux
+}
+
+// ------------------------------------------- resolveServedPath
+
+// The real path of "requested", a path relative to "root", which has to be an
+// absolute path with its symbolic links resolved.  Absolute paths, and paths
+// which lead outside the root, whether with ".." or through a symbolic link,
+// are rejected.  A file which doesn't exist resolves to where it would be.
+func resolveServedPath(root, requested string) (string, error) {
+	if filepath.IsAbs(requested) {
+		return "", fmt.Errorf("%q is an absolute path; paths must be relative to the root", requested)
+	}
+	path := filepath.Join(root, requested)
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		path = realPath
 	}
+	if relativePath, err := filepath.Rel(root, path); err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".." + string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is outside the root", requested)
+	}
+	return path, nil
+}
 
-	// Extract our arguments.
-	pathToFile1, pathToFile2 := flag.Arg(0), flag.Arg(1)
+// ------------------------------------------- mainNormalize
 
-	// Do the specified files exist?
-	if !checkThatPathExists(pathToFile1) || !checkThatPathExists(pathToFile2) {
+// Write a file to stdout exactly as diffy compares it, i.e. after the
+// transforms selected by the flags.  This is for pre-normalizing files, and
+// for seeing why lines did or didn't match.  To diff a file which is actually
+// called "normalize", call it "./normalize".
+func mainNormalize(paths []string) {
+	if len(paths) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s normalize FILE\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(stderr)
 		exitWithNotification(1)
 	}
+	if !checkThatPathExists(paths[0]) || !checkThatPathIsAFile(paths[0]) {
+		exitWithNotification(1)
+	}
+	readOptions := makeReadOptions()
+	if err := writeNormalizedFile(stdout, paths[0], readOptions); err != nil {
+		fmt.Fprintf(stderr, "Could not read %q; error = %v\n", paths[0], err)
+		exitWithNotification(2)
+	}
+}
 
-	// Are the files actually files?
-	if !checkThatPathIsAFile(pathToFile1) || !checkThatPathIsAFile(pathToFile2) {
+// ------------------------------------------- mainDiffRuns
+
+// Compare the alignments two runs wrote with "--format=json" and report which
+// links changed, e.g. to see what a normalization option did.  Like a diff,
+// exits with 1 when there are changes.  To diff a file which is actually
+// called "diff-runs", call it "./diff-runs".
+func mainDiffRuns(paths []string) {
+	if len(paths) != 2 {
+		fmt.Fprintf(stderr, "Usage: %s diff-runs BEFORE.json AFTER.json\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(stderr)
 		exitWithNotification(1)
 	}
+	var alignments []*diff.Alignment
+	for index, path := range paths {
+		if !checkThatPathExists(path) || !checkThatPathIsAFile(path) {
+			exitWithNotification(1)
+		}
+		alignment, err := readJsonAlignment(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not read the alignment in %q; error = %v\n", path, err)
+			exitWithNotification(2 + index)
+		}
+		alignments = append(alignments, alignment)
+	}
 
-	// Try to read the files.
-	lines1, err := readFile(pathToFile1)
+	changes, err := diff.CompareAlignments(alignments[0], alignments[1])
 	if err != nil {
-		exitWithNotification(2)
+		fmt.Fprintf(stderr, "Could not compare %q with %q; error = %v\n", paths[0], paths[1], err)
+		exitWithNotification(1)
+	}
+	output.GenerateAlignmentChanges(stdout, changes)
+	if len(changes) > 0 {
+		exit(1)
 	}
-	lines2, err := readFile(pathToFile2)
+}
+
+func readJsonAlignment(path string) (*diff.Alignment, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		exitWithNotification(3)
+		return nil, err
 	}
+	defer file.Close()
+	return output.ReadJsonAlignment(file)
+}
 
-	_, alignment := diff.Diff_v2(lines1, lines2)
-	// alignment.Dump(lines1, lines2, 0, diff.SimpleStderrLogger)
+// ------------------------------------------- writeNormalizedFile
 
-	sourceLines1 := output.NewSourceLinesRec(lines1, pathToFile1)
-	sourceLines2 := output.NewSourceLinesRec(lines2, pathToFile2)
+// Write the compared text of each line of a file, keeping a missing final
+// newline missing.
+func writeNormalizedFile(w io.Writer, path string, readOptions diff.Options) error {
+	lines, finalNewline, err := readFile(path, readOptions)
+	if err != nil {
+		return err
+	}
+	for index, line := range lines {
+		fmt.Fprint(w, line.CompareText())
+		if index < len(lines) - 1 || finalNewline {
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------- makeKeyFunc
 
-	// We will output to stdout or a temporary file, depending.
-	outputFile := os.Stdout
-	if *openWithPtr != "" {
-		outputFile, err = ioutil.TempFile("", "diffy")
+// Make the key function for "--tsv-key-cols" or "--fixed-cols".  Returns nil
+// when neither is given, and false after reporting a bad column spec.
+func makeKeyFunc() (adapter.KeyFunc, bool) {
+	if *tsvKeyColsPtr != "" && *fixedColsPtr != "" {
+		fmt.Fprintf(stderr, "The %q and %q options can't be used together.\n", "--tsv-key-cols", "--fixed-cols")
+		return nil, false
+	}
+	if *tsvKeyColsPtr != "" {
+		columns, err := adapter.ParseColumnList(*tsvKeyColsPtr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not open the temporary file; error = %v\n", err)
-			exitWithNotification(4)
+			fmt.Fprintf(stderr, "The %q value %q is not valid; error = %v\n", "--tsv-key-cols", *tsvKeyColsPtr, err)
+			return nil, false
 		}
-		defer outputFile.Close()
+		return adapter.TsvKeyColumns(columns), true
+	}
+	if *fixedColsPtr != "" {
+		ranges, err := adapter.ParseColumnRanges(*fixedColsPtr)
+		if err != nil {
+			fmt.Fprintf(stderr, "The %q value %q is not valid; error = %v\n", "--fixed-cols", *fixedColsPtr, err)
+			return nil, false
+		}
+		return adapter.FixedColumns(ranges), true
+	}
+	return nil, true
+}
+
+// ------------------------------------------- makeHtmlOptions
+
+// Assemble the HTML options from the command line flags.
+func makeHtmlOptions(readOptions diff.Options) output.HtmlOptions {
+	htmlOptions := output.HtmlOptions{
+		DetectIndentChange: *detectIndentChangePtr,
+		DetectBlockIndent: *detectBlockIndentPtr,
+		PreserveTabs: *preserveTabsPtr || *tabGuidesPtr,
+		TabGuides: *tabGuidesPtr,
+		TabSize: readOptions.TabSize,
+		Breakpoint: *breakpointPtr,
+		WholeWordHighlight: *wholeWordHighlightPtr,
+		GradedRuns: *gradedRunsPtr,
+		RunTooltips: *runTooltipsPtr,
+		ShowStats: *showStatsPtr,
+		BaseDir: *baseDirPtr,
+		Bom: *bomPtr,
+		NoRealign: *noRealignPtr,
+		GroupChanges: *groupChangesPtr,
+		CssFile: *cssFilePtr,
+		StableLineIds: *stableLineIdsPtr,
+		CompactDiff: *compactDiffPtr,
+	}
+	htmlOptions.Charset, _ = output.ParseCharset(*charsetPtr)
+	htmlOptions.PathDisplay, _ = output.ParsePathDisplay(*pathDisplayPtr)
+	htmlOptions.Focus, _ = output.ParseFocus(*onlyPtr)
+	htmlOptions.GroupBy, _ = output.ParseGroupBy(*groupByPtr)
+	htmlOptions.WordChars, _ = diff.ParseWordChars(*wordCharsPtr)
+	htmlOptions.HunkContext = &diff.HunkContext{Before: *contextBeforePtr, After: *contextAfterPtr, MinMatchRun: *minMatchRunPtr}
+	if *adaptiveRealignPtr {
+		htmlOptions.AdaptiveRealign = &diff.DefaultAdaptiveThreshold
+	}
+	return htmlOptions
+}
+
+// ------------------------------------------- wantDiffOutput
+
+// With "--stat", the diffstat goes to stdout instead of the diff, but the diff
+// is still generated if there's somewhere else for it to go.
+func wantDiffOutput() bool {
+	return !*quietPtr && (!*statPtr || *openWithPtr != "")
+}
+
+// ------------------------------------------- type tCompareSettings
+
+// The flags for comparing files which have to be compiled or parsed first,
+// so that a bad one is reported once, up front.  See makeCompareSettings.
+type tCompareSettings struct {
+	anchorRegexp *regexp.Regexp
+	blockStart diff.BlockStartFunc
+	keyFn adapter.KeyFunc
+}
+
+// ------------------------------------------- makeCompareSettings
+
+// Compile the patterns and parse the columns the flags give for comparing
+// files.  Anything which doesn't compile or parse is a usage error.
+func makeCompareSettings() *tCompareSettings {
+	settings := &tCompareSettings{}
+
+	// Compile the anchor pattern, if any.  The alignment is anchored one way or
+	// the other, not both.
+	if *anchorPtr != "" && *anchorUniquePtr {
+		fmt.Fprintf(stderr, "The %q and %q options can't be used together.\n", "--anchor", "--anchor-unique")
+		exitWithNotification(1)
+	}
+	if *anchorPtr != "" {
+		var err error
+		settings.anchorRegexp, err = regexp.Compile(*anchorPtr)
+		if err != nil {
+			fmt.Fprintf(stderr, "The %q pattern %q is not a valid regular expression; error = %v\n", "--anchor", *anchorPtr, err)
+			exitWithNotification(1)
+		}
+	}
+
+	// Work out how to find blocks, if diffing block by block.
+	switch strings.ToLower(*contextPtr) {
+	case "":
+	case "func":
+		settings.blockStart = diff.IsTopLevelBlockStart
+	default:
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected func.\n", "--context", *contextPtr)
+		exitWithNotification(1)
+	}
+	if *blockRegexPtr != "" {
+		blockRegexp, err := regexp.Compile(*blockRegexPtr)
+		if err != nil {
+			fmt.Fprintf(stderr, "The %q pattern %q is not a valid regular expression; error = %v\n", "--block-regex", *blockRegexPtr, err)
+			exitWithNotification(1)
+		}
+		settings.blockStart = diff.RegexBlockStart(blockRegexp)
+	}
+
+	// Parse the key columns, if any.
+	keyFn, ok := makeKeyFunc()
+	if !ok {
+		exitWithNotification(1)
+	}
+	settings.keyFn = keyFn
+	return settings
+}
+
+// ------------------------------------------- stripCommonAffix
+
+// With "--strip-common-affix", cut any prefix and suffix shared by every line
+// of both files, and return them along with the lines that are left.
+func stripCommonAffix(lines1, lines2 diff.ComparableLines) (diff.ComparableLines, diff.ComparableLines, string, string) {
+	if !*stripCommonAffixPtr {
+		return lines1, lines2, "", ""
+	}
+	prefix, suffix := diff.CommonAffix(lines1, lines2)
+	if description := output.DescribeStrippedAffix(prefix, suffix); description != "" {
+		logger.Infof("%s", description)
+	}
+	return diff.StripAffix(lines1, prefix, suffix), diff.StripAffix(lines2, prefix, suffix), prefix, suffix
+}
+
+// ------------------------------------------- type tComparison
+
+// Two files compared the way the flags say.  The lines are the ones the
+// alignment covers: with an adapter, the records rendered as lines, and with
+// "--ignore-blank-at-eof", less any blank lines it ignored at the end.
+type tComparison struct {
+	lines1, lines2 diff.ComparableLines
+	items1, items2 diff.ComparableSequence		// only with an adapter
+	distance float32
+	alignment *diff.Alignment
+	histogram *diff.SimilarityHistogram		// only with "--histogram"
+}
+
+// ------------------------------------------- compareFiles
+
+// Compare the lines of two files the way the flags say, whether for the
+// command line or for "serve": as records, if the files have an adapter, on
+// key columns, around anchors, or block by block, followed by the passes which
+// ignore some kinds of change.  If the files are compared as records and one
+// of them can't be parsed, return the error and which one it was, 0 or 1.
+func compareFiles(pathToFile1, pathToFile2 string, lines1, lines2 diff.ComparableLines, settings *tCompareSettings) (*tComparison, int, error) {
+
+	// Some file types, such as CSV, have an adapter which knows better than
+	// line-by-line comparison.  Both files have to be of the same type.
+	adapterFn, haveAdapter := adapter.Lookup(pathToFile1)
+	if _, sameType := adapter.Lookup(pathToFile2); !sameType || *noAdapterPtr {
+		haveAdapter = false
+	}
+
+	comparison := &tComparison{}
+	var distance float32
+	var alignment *diff.Alignment
+	var err error
+	if haveAdapter {
+		logger.Infof("comparing as %s records", filepath.Ext(pathToFile1))
+		comparison.items1, lines1, err = readAdaptedFile(pathToFile1, adapterFn)
+		if err != nil {
+			return nil, 0, err
+		}
+		comparison.items2, lines2, err = readAdaptedFile(pathToFile2, adapterFn)
+		if err != nil {
+			return nil, 1, err
+		}
+		distance, alignment = diff.Diff_v2(comparison.items1, comparison.items2)
+	} else if settings.keyFn != nil {
+		logger.Infof("comparing on the key columns only")
+		distance, alignment = diff.Diff_v2(adapter.KeyLines(lines1, settings.keyFn), adapter.KeyLines(lines2, settings.keyFn))
+	} else if settings.anchorRegexp != nil {
+		isAnchor := func (line *diff.TextLine) bool { return settings.anchorRegexp.MatchString(line.Text) }
+		distance, alignment = diff.DiffAnchored(lines1, lines2, isAnchor, diff.Diff_v2)
+	} else if *anchorUniquePtr {
+		distance, alignment = diff.DiffUniqueAnchored(lines1, lines2, diff.Diff_v2)
+	} else if settings.blockStart != nil {
+		logger.Infof("comparing block by block")
+		distance, alignment = diff.DiffBlocks(lines1, lines2, settings.blockStart, diff.Diff_v2)
+	} else if *exactPtr {
+		logger.Infof("comparing exactly, as GNU diff does")
+		alignment = diff.DiffExact(lines1, lines2)
+		distance = float32(alignment.EditOps())
+	} else if *dumpMatrixPtr {
+		dumper := diff.NewMatrixDumper(lines1, lines2, stderrLogger, 40)
+		distance, alignment = diff.Diff_v2WithRowFunc(lines1, lines2, dumper)
+	} else if *explainPtr || *histogramPtr {
+		var explanations []diff.LinkExplanation
+		distance, alignment, explanations = diff.Diff_v2Explained(lines1, lines2)
+		if *explainPtr {
+			diff.ExplainAlignment(alignment, explanations, diff.DEFAULT_REALIGN_THRESHOLD, stderrLogger)
+		}
+		comparison.histogram = diff.NewSimilarityHistogram(alignment, lines1, lines2, diff.DEFAULT_HISTOGRAM_BUCKETS)
+	} else {
+		distance, alignment = diff.Diff_v2(lines1, lines2)
+	}
+	if *exactPtr && (haveAdapter || settings.keyFn != nil || settings.anchorRegexp != nil || *anchorUniquePtr || settings.blockStart != nil) {
+		logger.Warnf("%q only applies to the plain line-by-line diff", "--exact")
+	}
+	if *explainPtr && (haveAdapter || settings.keyFn != nil || settings.anchorRegexp != nil || *anchorUniquePtr || settings.blockStart != nil || *exactPtr || *dumpMatrixPtr) {
+		logger.Warnf("%q only explains the plain line-by-line diff", "--explain")
+	}
+	if *histogramPtr && comparison.histogram == nil {
+		logger.Warnf("%q only applies to the plain line-by-line diff", "--histogram")
+	}
+	logger.Infof("edit distance %.2f (with changed pairs counted by how different they are), %d edit ops (lines on one side only), %d links", distance, alignment.EditOps(), len(alignment.Links))
+	if *detectBlockIndentPtr {
+		alignment = alignment.MatchBlockIndents(lines1, lines2)
+	}
+	if *ignoreBlankAtEofPtr {
+		alignment, lines1, lines2 = alignment.IgnoreTrailingBlankLines(lines1, lines2)
+	}
+
+	comparison.lines1, comparison.lines2 = lines1, lines2
+	comparison.distance, comparison.alignment = distance, alignment
+	return comparison, 0, nil
+}
+
+// ------------------------------------------- selectDisplayedChanges
+
+// The part of the alignment to show: with "--top", just the biggest changes,
+// and with "--only", just the changes in one direction.  Otherwise, all of it.
+func selectDisplayedChanges(alignment *diff.Alignment, source1, source2 *output.SourceLinesRec, htmlOptions output.HtmlOptions) (*diff.Alignment, output.HtmlOptions) {
+	if *topPtr > 0 {
+		alignment, htmlOptions = selectTopChanges(alignment, source1, source2, *topPtr, htmlOptions)
+	}
+	if htmlOptions.Focus != output.FocusNone {
+		alignment, htmlOptions = selectFocusedChanges(alignment, source1, source2, htmlOptions)
+	}
+	return alignment, htmlOptions
+}
+
+// ------------------------------------------- selectTopChanges
+
+// Cut the alignment down to the "n" most changed pairs of lines, with the
+// lines of context "--context-before" and "--context-after" give a hunk.  The pairs are ranked as they'll be shown, so the
+// alignment is realigned first.
+func selectTopChanges(alignment *diff.Alignment, source1, source2 *output.SourceLinesRec, n int, htmlOptions output.HtmlOptions) (*diff.Alignment, output.HtmlOptions) {
+	if *formatPtr == "color-words" {
+		logger.Warnf("%q doesn't apply to %q", "--top", "--format=color-words")
+	}
+	alignment, htmlOptions = realignForSelection(alignment, source1, source2, htmlOptions)
+	return alignment.TopChangesWithContext(source1.Compared(), source2.Compared(), n, htmlOptions.EffectiveHunkContext()), htmlOptions
+}
+
+// ------------------------------------------- selectFocusedChanges
+
+// Cut the alignment down to the lines the review is focused on, with the same
+// context as selectTopChanges.  The HTML also dims
+// everything else; the other formats just show the selection.
+func selectFocusedChanges(alignment *diff.Alignment, source1, source2 *output.SourceLinesRec, htmlOptions output.HtmlOptions) (*diff.Alignment, output.HtmlOptions) {
+	if *formatPtr == "color-words" {
+		logger.Warnf("%q doesn't apply to %q", "--only", "--format=color-words")
+	}
+	alignment, htmlOptions = realignForSelection(alignment, source1, source2, htmlOptions)
+	return alignment.OnlyChangesWithContext(htmlOptions.Focus.LinkType(), htmlOptions.EffectiveHunkContext()), htmlOptions
+}
+
+// ------------------------------------------- realignForSelection
+
+// Realign the alignment as it'll be shown, and pin the generators to the same
+// threshold, so that they don't choose another from the lines that are left
+// once part of the alignment has been selected.
+func realignForSelection(alignment *diff.Alignment, source1, source2 *output.SourceLinesRec, htmlOptions output.HtmlOptions) (*diff.Alignment, output.HtmlOptions) {
+	if htmlOptions.NoRealign {
+		return alignment, htmlOptions
 	}
+	var threshold float32 = diff.DEFAULT_REALIGN_THRESHOLD
+	if htmlOptions.AdaptiveRealign != nil {
+		threshold = htmlOptions.AdaptiveRealign.ChooseForWith(alignment, source1.Compared(), source2.Compared(), htmlOptions.CompareFunc())
+	}
+	htmlOptions.AdaptiveRealign = &diff.AdaptiveThreshold{Strict: threshold, Lax: threshold}
+	return alignment.RealignUsingThresholdWith(source1.Compared(), source2.Compared(), threshold, htmlOptions.CompareFunc()), htmlOptions
+}
+
+// ------------------------------------------- describeWholeFileReplacement
+
+// If the files are less similar than "threshold", describe them in a few
+// lines in place of the diff.  A file being created or emptied is a real
+// change, not a replacement, so both files have to have some lines.  The
+// similarity is that of the final alignment, after any passes which ignore
+// some of the changes, not of the edit distance the diff started from.
+func describeWholeFileReplacement(alignment *diff.Alignment, source1, source2 *output.SourceLinesRec, threshold float32) (string, bool) {
+	stats := output.ComputeChangeStats(alignment)
+	if stats.LeftLines == 0 || stats.RightLines == 0 {
+		return "", false
+	}
+	cost := alignment.Cost(source1.Compared(), source2.Compared())
+	similarity := diff.SequenceSimilarity(cost, stats.LeftLines, stats.RightLines)
+	if similarity >= threshold {
+		return "", false
+	}
+	lineCount := func (count int) string {
+		if count == 1 {
+			return "1 line"
+		}
+		return fmt.Sprintf("%d lines", count)
+	}
+	report := fmt.Sprintf("Files are entirely different (similarity %.0f%%)\n", similarity * 100)
+	report += fmt.Sprintf("  %s: %s\n", source1.FilePath, lineCount(stats.LeftLines))
+	report += fmt.Sprintf("  %s: %s\n", source2.FilePath, lineCount(stats.RightLines))
+	return report, true
+}
+
+// ------------------------------------------- makeReadOptions
+
+// Assemble the options for reading files from the command line flags.  The
+// files read with them share a line pool, with "--intern-lines".
+func makeReadOptions() diff.Options {
+	return diff.Options{
+		TabSize: *tabSizePtr,
+		StripAnsi: *stripAnsiPtr,
+		NormalizeTypography: *normalizeTypographyPtr,
+		MinHashLen: *minHashLenPtr,
+		MaxLineLength: *maxLineLengthPtr,
+		Similarity: *similarityPtr,
+		PreSplit: *preSplitPtr,
+		Sentences: *modePtr == "sentence",
+		LinePool: makeLinePool(),
+		CommentSyntax: makeCommentSyntax(),
+		StringLiterals: makeQuoteSyntax(),
+		TrailingToken: makeTrailingTokenRegexp(),
+		StripLinePrefix: *stripLinePrefixPtr,
+		StripLineSuffix: *stripLineSuffixPtr,
+	}
+}
+
+// ------------------------------------------- makeLinePool
+
+// With "--intern-lines", a pool for all the files to share; otherwise nil.
+func makeLinePool() *diff.LinePool {
+	if !*internLinesPtr {
+		return nil
+	}
+	return diff.NewLinePool()
+}
+
+// ------------------------------------------- checkSimilarityFlag
+
+// Exit with a usage error unless "--similarity" names a metric we have.
+func checkSimilarityFlag() {
+	if !diff.IsSimilarityMetric(*similarityPtr) {
+		fmt.Fprintf(stderr, "Unknown %q value %q; expected one of %s.\n", "--similarity", *similarityPtr, strings.Join(diff.SimilarityMetricNames(), ", "))
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+}
+
+// ------------------------------------------- makeTrailingTokenRegexp
+
+// With "--strip-trailing-token", the token pattern; otherwise nil.  A pattern
+// which doesn't compile is a usage error.
+func makeTrailingTokenRegexp() *regexp.Regexp {
+	if *stripTrailingTokenPtr == "" {
+		return nil
+	}
+	pattern, err := etc.CompileTrailingToken(*stripTrailingTokenPtr)
+	if err != nil {
+		fmt.Fprintf(stderr, "The %q pattern %q is not a valid regular expression; error = %v\n", "--strip-trailing-token", *stripTrailingTokenPtr, err)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+	return pattern
+}
+
+// ------------------------------------------- makeCommentSyntax
+
+// With "--ignore-comments", the comment syntax to strip; otherwise nil.  A
+// syntax we can't make sense of is a usage error.
+func makeCommentSyntax() *etc.CommentSyntax {
+	if !*ignoreCommentsPtr {
+		return nil
+	}
+	syntax, err := etc.ParseCommentSyntax(*commentSyntaxPtr)
+	if err != nil {
+		fmt.Fprintf(stderr, "Bad %q value %q; %v.\n", "--comment-syntax", *commentSyntaxPtr, err)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+	return syntax
+}
+
+// ------------------------------------------- makeQuoteSyntax
+
+// With "--ignore-string-literals", the quotes around the string literals to
+// ignore; otherwise nil.  Like makeCommentSyntax.
+func makeQuoteSyntax() *etc.QuoteSyntax {
+	if !*ignoreStringLiteralsPtr {
+		return nil
+	}
+	syntax, err := etc.ParseQuoteSyntax(*quoteSyntaxPtr)
+	if err != nil {
+		fmt.Fprintf(stderr, "Bad %q value %q; %v.\n", "--quote-syntax", *quoteSyntaxPtr, err)
+		fmt.Fprintln(stderr)
+		exitWithNotification(1)
+	}
+	return syntax
+}
+
+// ------------------------------------------- writeSummaryJson
+
+// Write the "--summary-json" file.
+func writeSummaryJson(path string, alignment *diff.Alignment, distance float32, source1, source2 *output.SourceLinesRec) {
+	summaryFile, err := os.Create(path)
+	if err == nil {
+		err = output.GenerateJsonSummary(summaryFile, alignment, distance, source1, source2)
+		if closeErr := summaryFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not write the summary to %q; error = %v\n", path, err)
+		exitWithNotification(4)
+	}
+}
 
-	output.GenerateHtmlDiffPage(outputFile, alignment, sourceLines1, sourceLines2)
+// ------------------------------------------- createOutputFile
 
-	// If we are doing "--open-with" then we need to invoke the open command on the temp file.
-	if *openWithPtr != "" {
-		err := executeCommand(*openWithPtr, outputFile.Name())
+// We output to the "--output" file if there is one, otherwise to stdout, or to
+// a temporary file when doing "--open-with".  Stdout is nil, since it isn't
+// ours to close.
+func createOutputFile() *os.File {
+	if *outputPtr != "" {
+		outputFile, err := os.Create(*outputPtr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, 
-						"Tried to execute the %q command %q, but got an error.\n", 
-						"--open-with", *openWithPtr)
-			fmt.Fprintf(os.Stderr, "The error was %v", err)
+			fmt.Fprintf(stderr, "Could not create %q; error = %v\n", *outputPtr, err)
 			exitWithNotification(4)
 		}
+		return outputFile
+	}
+	if *openWithPtr == "" {
+		return nil
+	}
+	outputFile, err := ioutil.TempFile("", "diffy")
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not open the temporary file; error = %v\n", err)
+		exitWithNotification(4)
+	}
+	return outputFile
+}
+
+// ------------------------------------------- teeOutput
+
+// With "--tee" and "--open-with", write to stdout as well as the temporary
+// file, so the output can be captured as well as viewed.
+func teeOutput(outputFile *os.File, stdout io.Writer) io.Writer {
+	if outputFile == nil {
+		return stdout
+	}
+	if !*teePtr || *openWithPtr == "" {
+		return outputFile
+	}
+	return io.MultiWriter(outputFile, stdout)
+}
+
+// ------------------------------------------- openOutputFile
+
+// If we are doing "--open-with" then we need to invoke the open command on the temp file.
+func openOutputFile(outputFile *os.File) {
+	if *openWithPtr == "" {
+		return
+	}
+	openOutputPath(outputFile.Name())
+}
+
+// ------------------------------------------- openOutputPath
+
+// Invoke the "--open-with" command, if any, on the file at "outputPath".
+func openOutputPath(outputPath string) {
+	if *openWithPtr == "" {
+		return
+	}
+	err := executeCommand(*openWithPtr, outputPath)
+	if err != nil {
+		message, exitCode := describeLaunchFailure(*openWithPtr, outputPath, err)
+		fmt.Fprint(stderr, message)
+		exitWithNotification(exitCode)
+	}
+}
+
+// ------------------------------------------- describeLaunchFailure
+
+// Explain why the "--open-with" command failed, and choose the exit code.  A
+// launcher which isn't on the PATH gets its own message and exit code (5),
+// since that's the usual mistake and the fix is obvious.  Either way the diff
+// has already been written, so tell the user where to find it.
+func describeLaunchFailure(cmdText, outputPath string, err error) (string, int) {
+	var message string
+	exitCode := 4
+	if errors.Is(err, exec.ErrNotFound) {
+		message = fmt.Sprintf("The %q command %q was not found; is it on your PATH?\n", "--open-with", cmdText)
+		exitCode = 5
+	} else {
+		message = fmt.Sprintf("Tried to execute the %q command %q, but got an error.\n", "--open-with", cmdText)
+		message += fmt.Sprintf("The error was %v\n", err)
 	}
+	message += fmt.Sprintf("The diff was saved to %q.\n", outputPath)
+	return message, exitCode
 }
 
 // ------------------------------------------- executeCommand
@@ -94,7 +1673,10 @@ func main() {
 func executeCommand(cmdText string, extraArgs ...string) error {
 
 	// Figure out the executable name and assemble the arguments.
-	cmdWords := etc.ParseWords(cmdText)
+	cmdWords, err := etc.ParseWordsStrict(cmdText, *maxQuoteDepthPtr)
+	if err != nil {
+		return err
+	}
 	cmdName := cmdWords[0]
 	cmdArgs := cmdWords[1:]
 	cmdArgs = append(cmdArgs, extraArgs...)
@@ -104,86 +1686,184 @@ func executeCommand(cmdText string, extraArgs ...string) error {
 	return command.Run();
 }
 
+// ------------------------------------------- isOutputFormat
+
+func isOutputFormat(format string) bool {
+	for _, outputFormat := range outputFormats {
+		if format == outputFormat {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------- statPath
+
+// Stat a path according to "--follow-symlinks": through any symbolic link
+// with "yes", or the link itself with "no".
+func statPath(path string) (os.FileInfo, error) {
+	if *followSymlinksPtr == "no" {
+		return os.Lstat(path)
+	}
+	return os.Stat(path)
+}
+
 // ------------------------------------------- checkThatPathExists
 
 func checkThatPathExists(path string) bool {
-	if _, err := os.Stat(path); err != nil {
-		fmt.Fprintf(os.Stderr, "The path %q does not exist.\n", path)
-		fmt.Fprintln(os.Stderr)
+	if _, err := statPath(path); err != nil {
+		fmt.Fprintf(stderr, "The path %q does not exist.\n", path)
+		fmt.Fprintln(stderr)
 		return false
 	}
 	return true
 }
 
+// ------------------------------------------- isADirectory
+
+func isADirectory(path string) bool {
+	fileInfo, err := statPath(path)
+	return err == nil && fileInfo.IsDir()
+}
+
 // ------------------------------------------- checkThatPathIsAFile
 
 func checkThatPathIsAFile(path string) bool {
-	fileInfo, err := os.Stat(path)
+	fileInfo, err := statPath(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Can't stat the path %q.\n", path)
-		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(stderr, "Can't stat the path %q.\n", path)
+		fmt.Fprintln(stderr)
+		return false
+	}
+	if fileInfo.Mode() & os.ModeSymlink != 0 {
+		fmt.Fprintf(stderr, "The path %q is a symbolic link, which %q doesn't follow.\n", path, "--follow-symlinks=no")
+		fmt.Fprintln(stderr)
 		return false
 	}
 	if fileInfo.IsDir() {
-		fmt.Fprintf(os.Stderr, "The path %q points to a directory, not a file.\n", path)
-		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(stderr, "The path %q points to a directory, not a file.\n", path)
+		fmt.Fprintln(stderr)
 		return false
 	}
 	return true
 }
 
+// ------------------------------------------- filesEqual
+
+// Whether two files are byte for byte the same, reading no more of them than
+// it takes to tell.
+func filesEqual(path1, path2 string) (bool, error) {
+	file1, err := os.Open(path1)
+	if err != nil {
+		return false, err
+	}
+	defer file1.Close()
+	file2, err := os.Open(path2)
+	if err != nil {
+		return false, err
+	}
+	defer file2.Close()
+	return diff.FilesEqual(file1, file2)
+}
+
 // ------------------------------------------- readFile
 
-func readFile(pathToFile string) (diff.ComparableLines, error) {
-	file, err := os.Open(pathToFile)
+// Read the lines of a file.  Besides the lines, report whether the file ends
+// with a newline.
+func readFile(pathToFile string, readOptions diff.Options) (diff.ComparableLines, bool, error) {
+	lines, finalNewline, _, err := readFileWithEncoding(pathToFile, readOptions)
+	return lines, finalNewline, err
+}
+
+// ------------------------------------------- readFileWithEncoding
+
+// Read the lines of a file, decoded as "--encoding" says, or as its byte
+// order mark says if it's UTF-16.  Besides the lines and whether the file
+// ends with a newline, describe its encoding, e.g. "utf-16le with BOM".
+func readFileWithEncoding(pathToFile string, readOptions diff.Options) (diff.ComparableLines, bool, string, error) {
+	content, err := ioutil.ReadFile(pathToFile)
 	if err != nil {
-		return nil, err
+		return nil, false, "", err
 	}
-	defer file.Close()
+	text, encoding, err := etc.DecodeText(content, *encodingPtr)
+	if err != nil {
+		return nil, false, encoding, err
+	}
+	lines, finalNewline, err := diff.ReadLines(strings.NewReader(text), readOptions)
+	return lines, finalNewline, encoding, err
+}
 
-	reader := bufio.NewReader(file)
+// ------------------------------------------- readAdaptedFile
 
-	var lines diff.ComparableLines
-	for {
-		strLine, err := reader.ReadString('\n')
-		if len(strLine) > 0 {
-			strLine = expandTabsAndStripLineEndings(strLine, 4)
-			lines = append(lines, diff.NewTextLine(strLine))
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
+// Read a file using an adapter.  Return the adapted sequence along with one
+// display line per item of the sequence.
+func readAdaptedFile(pathToFile string, adapterFn adapter.AdapterFunc) (diff.ComparableSequence, diff.ComparableLines, error) {
+	content, err := ioutil.ReadFile(pathToFile)
+	if err != nil {
+		return nil, nil, err
 	}
+	seq, renderer, err := adapterFn(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return seq, adapter.RenderLines(seq, renderer), nil
+}
+
+// ------------------------------------------- exitWithNotification
 
-	return lines, nil
+func exitWithNotification(exitCode int) {
+	fmt.Fprintf(stderr, "Exit %d.\n", exitCode)
+	exit(exitCode)
 }
 
-// ------------------------------------------- expandTabsAndStripLineEndings
+// ------------------------------------------- exit
 
-func expandTabsAndStripLineEndings(s string, tabSize int) string {
-	result := ""
-	for _, char := range s {
-		if char == '\t' {
-			spaceCount := tabSize - len(result) % tabSize
-			for i := 0; i < spaceCount; i++ {
-				result += " "
-			}
-		} else if char == '\n' || char == '\r' {
-			// do nothing
+// Stop running with "exitCode", which Run returns.  Deferred functions run
+// on the way out, just as they do when returning.
+type tExit struct {
+	exitCode int
+}
+
+func exit(exitCode int) {
+	panic(tExit{exitCode})
+}
+
+// ------------------------------------------- resetFlags
+
+// The flags defined above, as opposed to any another package adds, such as
+// the testing package's.
+var diffyFlags = map[string]bool{}
+
+func init() {
+	flag.VisitAll(func (f *flag.Flag) { diffyFlags[f.Name] = true })
+}
+
+// Put every flag back to its default, ready for parsing another command line.
+func resetFlags() {
+	flag.CommandLine.Init(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	flag.CommandLine.SetOutput(stderr)
+	flag.VisitAll(func (f *flag.Flag) {
+		if !diffyFlags[f.Name] {
+			return
+		}
+		if count, ok := f.Value.(*tCountFlag); ok {
+			*count = 0
 		} else {
-			result += string(char)
+			f.Value.Set(f.DefValue)
 		}
-	}
-	return result
+	})
 }
 
-// ------------------------------------------- exitWithNotification
+// ------------------------------------------- parseFlags
 
-func exitWithNotification(exitCode int) {
-	fmt.Fprintf(os.Stderr, "Exit %d.\n", exitCode)
-	os.Exit(exitCode)
+// Parse "args" as flags, exiting as the flag package would on an error: with
+// 0 for "-h", after the usage message, and 2 for anything else.
+func parseFlags(args []string) {
+	switch err := flag.CommandLine.Parse(args); {
+	case err == flag.ErrHelp:
+		exit(0)
+	case err != nil:
+		exit(2)
+	}
 }
 
@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+	"diffy/etc"
+	"diffy/output"
+)
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+// Create a temporary directory for a test and return its path along with a
+// function which removes it again.
+func makeTempDir(t *testing.T) (string, func ()) {
+	dir, err := ioutil.TempDir("", "diffy-test")
+	if err != nil {
+		t.Fatalf("could not create a temporary directory: %v", err)
+	}
+	return dir, func () { os.RemoveAll(dir) }
+}
+
+// Write "content" to the file "name" in "dir" and return the file's path.
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("could not write %q: %v", path, err)
+	}
+	return path
+}
+
+var defaultReadOptions = diff.Options{TabSize: 4}
+
+// -------------------------------------------
+// ------------------------------------------- TestReadFileFinalNewline
+// -------------------------------------------
+
+func TestReadFileFinalNewline(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	testCases := []struct {
+		content string
+		lineCount int
+		finalNewline bool
+	}{
+		{"", 0, true},
+		{"\n", 1, true},
+		{"one\ntwo\n", 2, true},
+		{"one\ntwo", 2, false},
+		{"one\r\ntwo\r\n", 2, true},
+	}
+
+	for index, testCase := range testCases {
+		path := writeTestFile(t, dir, "file.txt", testCase.content)
+		lines, finalNewline, err := readFile(path, defaultReadOptions)
+		if err != nil {
+			t.Fatalf("case %d: unexpected error %v", index, err)
+		}
+		if len(lines) != testCase.lineCount {
+			t.Errorf("case %d: got %d lines, expected %d", index, len(lines), testCase.lineCount)
+		}
+		if finalNewline != testCase.finalNewline {
+			t.Errorf("case %d: got final newline %v, expected %v", index, finalNewline, testCase.finalNewline)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestReadFileEncoding
+// -------------------------------------------
+
+func TestReadFileEncoding(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	// The same text in UTF-8, and in UTF-16LE with a byte order mark.
+	text := "naïve café\nsecond line\n"
+	var utf16 bytes.Buffer
+	utf16.WriteString("\xFF\xFE")
+	for _, char := range text {
+		utf16.WriteByte(byte(char))
+		utf16.WriteByte(byte(char >> 8))
+	}
+	utf8Path := writeTestFile(t, dir, "utf8.txt", text)
+	utf16Path := writeTestFile(t, dir, "utf16.txt", utf16.String())
+
+	lines1, finalNewline1, encoding1, err := readFileWithEncoding(utf8Path, defaultReadOptions)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	lines2, finalNewline2, encoding2, err := readFileWithEncoding(utf16Path, defaultReadOptions)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The encodings differ, and say so, but the content is identical.
+	if encoding1 != "utf-8" || encoding2 != "utf-16le with BOM" {
+		t.Errorf("Expected the encodings to be noted, got %q and %q", encoding1, encoding2)
+	}
+	if len(lines2) != 2 || lines2[0].Text != "naïve café" {
+		t.Fatalf("Expected the decoded text to be displayed, got %d lines", len(lines2))
+	}
+	_, alignment := diff.Diff_v2(lines1, lines2)
+	source1, source2 := output.NewSourceLinesRec(lines1, utf8Path), output.NewSourceLinesRec(lines2, utf16Path)
+	source1.FinalNewline, source2.FinalNewline = finalNewline1, finalNewline2
+	if output.HasDifferences(alignment, source1, source2) {
+		t.Errorf("Expected the decoded files to be identical")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestPerFileTabSize
+// -------------------------------------------
+
+func TestPerFileTabSize(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	tabbedPath := writeTestFile(t, dir, "tabbed.go", "func f() {\n\tif x {\n\t\treturn\n\t}\n}\n")
+	spacedPath := writeTestFile(t, dir, "spaced.go", "func f() {\n    if x {\n        return\n    }\n}\n")
+
+	linkTypesFor := func (leftTabSize int) []diff.LinkType {
+		left, _, err := readFile(tabbedPath, diff.Options{TabSize: leftTabSize})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		right, _, err := readFile(spacedPath, diff.Options{TabSize: 4})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		_, alignment := diff.Diff_v2(left, right)
+		var linkTypes []diff.LinkType
+		for _, link := range alignment.Links {
+			linkTypes = append(linkTypes, link.LinkType)
+		}
+		return linkTypes
+	}
+
+	// With a tab size of 4 on the tabbed side, every line matches.
+	for index, linkType := range linkTypesFor(4) {
+		if linkType != diff.Matching {
+			t.Errorf("line %d: expected Matching with a left tab size of 4, got %v", index, linkType)
+		}
+	}
+
+	// With 8, the indented lines don't.
+	matchingCount := 0
+	for _, linkType := range linkTypesFor(8) {
+		if linkType == diff.Matching {
+			matchingCount++
+		}
+	}
+	if matchingCount != 2 {
+		t.Errorf("expected only the 2 unindented lines to match with a left tab size of 8, got %d", matchingCount)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestLaunchFailure
+// -------------------------------------------
+
+func TestLaunchFailure(t *testing.T) {
+
+	// A launcher which isn't on the PATH.
+	err := executeCommand("diffy-no-such-launcher --new-window", "/tmp/diffy123")
+	if err == nil {
+		t.Fatalf("Expected an error for a missing command")
+	}
+	message, exitCode := describeLaunchFailure("diffy-no-such-launcher --new-window", "/tmp/diffy123", err)
+	if exitCode != 5 || !strings.Contains(message, "was not found") || !strings.Contains(message, "\"/tmp/diffy123\"") {
+		t.Errorf("Expected a not-found message with the saved path and exit code 5, got %d:\n%s", exitCode, message)
+	}
+
+	// A launcher which runs but fails.
+	err = executeCommand("sh -c 'exit 3'", "/tmp/diffy123")
+	if err == nil {
+		t.Fatalf("Expected an error for a failing command")
+	}
+	message, exitCode = describeLaunchFailure("sh -c 'exit 3'", "/tmp/diffy123", err)
+	if exitCode != 4 || strings.Contains(message, "was not found") || !strings.Contains(message, "exit status 3") || !strings.Contains(message, "\"/tmp/diffy123\"") {
+		t.Errorf("Expected a failure message with the saved path and exit code 4, got %d:\n%s", exitCode, message)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestFollowSymlinks
+// -------------------------------------------
+
+func TestFollowSymlinks(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	filePath := writeTestFile(t, dir, "file.txt", "content\n")
+	subdirPath := filepath.Join(dir, "subdir")
+	fileLinkPath := filepath.Join(dir, "file-link")
+	dirLinkPath := filepath.Join(dir, "dir-link")
+	danglingLinkPath := filepath.Join(dir, "dangling-link")
+	if err := os.Mkdir(subdirPath, 0755); err != nil {
+		t.Fatalf("could not create a directory: %v", err)
+	}
+	for link, target := range map[string]string{fileLinkPath: filePath, dirLinkPath: subdirPath, danglingLinkPath: filepath.Join(dir, "missing")} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("could not create a symbolic link: %v", err)
+		}
+	}
+
+	defer func (saved string) { *followSymlinksPtr = saved }(*followSymlinksPtr)
+
+	testCases := []struct {
+		policy, path string
+		exists, isAFile bool
+	}{
+		{"yes", filePath, true, true},
+		{"yes", fileLinkPath, true, true},
+		{"yes", dirLinkPath, true, false},
+		{"yes", danglingLinkPath, false, false},
+		{"no", filePath, true, true},
+		{"no", fileLinkPath, true, false},
+		{"no", dirLinkPath, true, false},
+		{"no", danglingLinkPath, true, false},
+	}
+
+	for _, testCase := range testCases {
+		*followSymlinksPtr = testCase.policy
+		if exists := checkThatPathExists(testCase.path); exists != testCase.exists {
+			t.Errorf("--follow-symlinks=%s %s: expected exists = %v", testCase.policy, filepath.Base(testCase.path), testCase.exists)
+		}
+		if isAFile := checkThatPathIsAFile(testCase.path); isAFile != testCase.isAFile {
+			t.Errorf("--follow-symlinks=%s %s: expected is a file = %v", testCase.policy, filepath.Base(testCase.path), testCase.isAFile)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestListTreeSymlinks
+// -------------------------------------------
+
+func TestListTreeSymlinks(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	// A file and a directory, links to each, and two links which loop.
+	writeTestFile(t, dir, "a.txt", "a\n")
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("could not create a directory: %v", err)
+	}
+	writeTestFile(t, dir, "sub/b.txt", "b\n")
+	for link, target := range map[string]string{"file-link": "a.txt", "dir-link": "sub", "loop": ".", "sub/up": ".."} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skipf("could not create a symbolic link: %v", err)
+		}
+	}
+
+	defer func (saved string, savedStderr io.Writer) { *followSymlinksPtr, stderr = saved, savedStderr }(*followSymlinksPtr, stderr)
+	testCases := []struct {
+		policy string
+		expected string
+		skipped []string		// reported on stderr
+	}{
+		{"yes", "[a.txt dir-link/b.txt file-link sub/b.txt]", []string{"loop\", which links back", "up\", which links back"}},
+		{"no", "[a.txt sub/b.txt]", []string{"dir-link\", which is a symbolic link", "file-link\", which is a symbolic link", "loop\", which is a symbolic link"}},
+	}
+	for _, testCase := range testCases {
+		*followSymlinksPtr = testCase.policy
+		var buffer bytes.Buffer
+		stderr = &buffer
+		paths, err := listTree(dir, &etc.IgnoreList{})
+		if err != nil || fmt.Sprint(paths) != testCase.expected {
+			t.Errorf("--follow-symlinks=%s: expected %s, got %v (error = %v)", testCase.policy, testCase.expected, paths, err)
+		}
+		for _, skipped := range testCase.skipped {
+			if !strings.Contains(buffer.String(), skipped) {
+				t.Errorf("--follow-symlinks=%s: expected %q to be reported, got:\n%s", testCase.policy, skipped, buffer.String())
+			}
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestWriteNormalizedFile
+// -------------------------------------------
+
+func TestWriteNormalizedFile(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	path := writeTestFile(t, dir, "input.txt", "\tx = 1  \r\nab\tc\n\x1b[31m“quoted”\x1b[0m\t")
+
+	testCases := []struct {
+		readOptions diff.Options
+		expected string
+	}{
+		{diff.Options{TabSize: 4}, "    x = 1  \nab  c\n\x1b[31m“quoted”\x1b[0m   "},
+		{diff.Options{TabSize: 8}, "        x = 1  \nab      c\n\x1b[31m“quoted”\x1b[0m       "},
+		{diff.Options{TabSize: 4, StripAnsi: true, NormalizeTypography: true}, "    x = 1  \nab  c\n\"quoted\"    "},
+	}
+
+	for _, testCase := range testCases {
+		var buffer bytes.Buffer
+		if err := writeNormalizedFile(&buffer, path, testCase.readOptions); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buffer.String() != testCase.expected {
+			t.Errorf("%+v: expected %q, got %q", testCase.readOptions, testCase.expected, buffer.String())
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestWholeFileReplacement
+// -------------------------------------------
+
+func TestWholeFileReplacement(t *testing.T) {
+
+	describe := func (text1, text2 string) (string, bool) {
+		lines1, _, _ := diff.ReadLines(strings.NewReader(text1), diff.Options{})
+		lines2, _, _ := diff.ReadLines(strings.NewReader(text2), diff.Options{})
+		_, alignment := diff.Diff_v2(lines1, lines2)
+		return describeWholeFileReplacement(alignment, output.NewSourceLinesRec(lines1, "old.txt"), output.NewSourceLinesRec(lines2, "new.txt"), 0.05)
+	}
+
+	// Two files with nothing in common get the short report.
+	report, replaced := describe("alpha beta gamma\ndelta epsilon\nzeta eta theta\n", "1234567890\n")
+	expected := "Files are entirely different (similarity 0%)\n  old.txt: 3 lines\n  new.txt: 1 line\n"
+	if !replaced || report != expected {
+		t.Errorf("Expected\n%s\ngot %v\n%s", expected, replaced, report)
+	}
+
+	// Similar files, and new or emptied files, still get the full diff.
+	for _, texts := range [][2]string{
+		{"one\ntwo\nthree\nfour\n", "one\ntwo\n3\nfour\n"},
+		{"", "1234567890\n"},
+		{"alpha beta gamma\n", ""},
+	} {
+		if _, replaced := describe(texts[0], texts[1]); replaced {
+			t.Errorf("%q vs %q: expected the full diff", texts[0], texts[1])
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestTee
+// -------------------------------------------
+
+func TestTee(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	// The fake launcher copies whatever it's asked to open.
+	openedPath := filepath.Join(dir, "opened.html")
+	defer func (savedOpenWith string, savedTee bool) { *openWithPtr, *teePtr = savedOpenWith, savedTee }(*openWithPtr, *teePtr)
+	*openWithPtr, *teePtr = "sh -c 'cat \"$0\" > " + openedPath + "'", true
+
+	outputFile := createOutputFile()
+	defer os.Remove(outputFile.Name())
+	var stdout bytes.Buffer
+	fmt.Fprint(teeOutput(outputFile, &stdout), "<html>diff</html>")
+	outputFile.Close()
+	openOutputFile(outputFile)
+
+	if stdout.String() != "<html>diff</html>" {
+		t.Errorf("Expected stdout to get the HTML too, got %q", stdout.String())
+	}
+	if opened, err := ioutil.ReadFile(openedPath); err != nil || string(opened) != "<html>diff</html>" {
+		t.Errorf("Expected the launcher to open the HTML, got %q (%v)", opened, err)
+	}
+
+	// Without "--tee", stdout gets nothing.
+	*teePtr = false
+	stdout.Reset()
+	outputFile = createOutputFile()
+	defer os.Remove(outputFile.Name())
+	fmt.Fprint(teeOutput(outputFile, &stdout), "<html>diff</html>")
+	outputFile.Close()
+	if stdout.Len() != 0 {
+		t.Errorf("Expected nothing on stdout without %q, got %q", "--tee", stdout.String())
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestServe
+// -------------------------------------------
+
+func TestServe(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatalf("could not create %q: %v", root, err)
+	}
+	root, _ = filepath.EvalSymlinks(root)
+	writeTestFile(t, root, "old.txt", "same\nold line\n")
+	writeTestFile(t, root, "new.txt", "same\nnew line\n")
+	writeTestFile(t, root, "padded.txt", "same\nold line\n\n\n")
+	secret := writeTestFile(t, dir, "secret.txt", "the secret\n")
+
+	server := httptest.NewServer(newDiffHandler(root, defaultReadOptions, makeCompareSettings(), output.HtmlOptions{Charset: output.CHARSET_UTF8}))
+	defer server.Close()
+	get := func (left, right string) (int, string) {
+		response, err := http.Get(server.URL + "/diff?left=" + url.QueryEscape(left) + "&right=" + url.QueryEscape(right))
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		return response.StatusCode, string(body)
+	}
+
+	// A valid pair gets the HTML diff.
+	status, body := get("old.txt", "new.txt")
+	if status != http.StatusOK || !strings.Contains(body, "<!DOCTYPE html>") || !strings.Contains(body, "same") {
+		t.Errorf("Expected the HTML diff, got %d:\n%s", status, body)
+	}
+	if strings.Contains(body, root) {
+		t.Errorf("Expected the root not to be given away")
+	}
+
+	// Nothing outside the root is served, however it's asked for.
+	if err := os.Symlink(secret, filepath.Join(root, "link.txt")); err != nil {
+		t.Fatalf("could not create a symbolic link: %v", err)
+	}
+	for _, path := range []string{"../secret.txt", "sub/../../secret.txt", secret, "link.txt"} {
+		if status, body := get("old.txt", path); status != http.StatusForbidden || strings.Contains(body, "the secret") {
+			t.Errorf("%q: expected %d, got %d:\n%s", path, http.StatusForbidden, status, body)
+		}
+	}
+
+	if status, _ := get("old.txt", "missing.txt"); status != http.StatusNotFound {
+		t.Errorf("Expected %d for a missing file, got %d", http.StatusNotFound, status)
+	}
+	if status, _ := get("old.txt", ""); status != http.StatusBadRequest {
+		t.Errorf("Expected %d for a missing parameter, got %d", http.StatusBadRequest, status)
+	}
+
+	// The files are compared as they would be on the command line.
+	defer func (saved bool) { *ignoreBlankAtEofPtr = saved }(*ignoreBlankAtEofPtr)
+	for _, ignoreBlankAtEof := range []bool{false, true} {
+		*ignoreBlankAtEofPtr = ignoreBlankAtEof
+		status, body := get("old.txt", "padded.txt")
+		if shown := strings.Contains(body, "id='R-3'"); status != http.StatusOK || shown == ignoreBlankAtEof {
+			t.Errorf("With %q %v, expected the trailing blank lines shown %v, got %d:\n%s", "--ignore-blank-at-eof", ignoreBlankAtEof, !ignoreBlankAtEof, status, body)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestRun
+// -------------------------------------------
+
+func TestRun(t *testing.T) {
+
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+	oldPath := writeTestFile(t, dir, "old.txt", "one\ntwo\nthree\n")
+	newPath := writeTestFile(t, dir, "new.txt", "one\n2\nthree\n")
+	quotedPath := writeTestFile(t, dir, "quoted.txt", "> one\n> two\n> three\n")
+	oldCsvPath := writeTestFile(t, dir, "old.csv", "id,name,note\n1,ab,x\n2,cd,y\n")
+	newCsvPath := writeTestFile(t, dir, "new.csv", "id,name,note\n1,ab,completely rewritten remark here\n2,cd,y\n")
+	islandPath := writeTestFile(t, dir, "island.txt", "1\ntwo\n3\n")
+	pluralPath := writeTestFile(t, dir, "plural.txt", "one\ntwos\nthree\n")
+	xPath := writeTestFile(t, dir, "x.txt", "x\ny\n")
+	x2Path := writeTestFile(t, dir, "x2.txt", "x\nz\n\n")
+	unterminatedPath := writeTestFile(t, dir, "unterminated.txt", "one\ntwo\nthree")
+	paddedPath := writeTestFile(t, dir, "padded.txt", "one\ntwo\nthree\n\n  \n")
+	missingPath := filepath.Join(dir, "missing.txt")
+	var trees []string
+	for _, tree := range [][]string{{"tree1", "one\ntwo\n", "a\n", "k\n"}, {"tree2", "one\n2\n", "b\n", "k2\n"}} {
+		root := filepath.Join(dir, tree[0])
+		if err := os.MkdirAll(filepath.Join(root, "build"), 0755); err != nil {
+			t.Fatalf("could not make %q: %v", root, err)
+		}
+		writeTestFile(t, root, ".diffyignore", "build/*\n!build/keep\n")
+		writeTestFile(t, root, "x", tree[1])
+		writeTestFile(t, root, "build/out", tree[2])
+		writeTestFile(t, root, "build/keep", tree[3])
+		writeTestFile(t, root, "same.csv", "a,\"b\nc\n")		// unparseable, so an error if it's diffed
+		trees = append(trees, root)
+	}
+	ignorePath := writeTestFile(t, dir, "extra.ignore", "x\n")
+	for _, name := range []string{"lower/x", "upper/X"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatalf("could not make the directory of %q: %v", name, err)
+		}
+		writeTestFile(t, dir, name, "one\n")
+	}
+	lowerPath, upperPath := filepath.Join(dir, "lower"), filepath.Join(dir, "upper")
+	for _, name := range []string{"gen1", "gen2"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatalf("could not make %q: %v", name, err)
+		}
+		writeTestFile(t, dir, name + "/g.go", "// Code generated by stringer; DO NOT EDIT.\n\n" + name + "\n")
+	}
+	gen1Path, gen2Path := filepath.Join(dir, "gen1"), filepath.Join(dir, "gen2")
+
+	testCases := []struct {
+		name string
+		args []string
+		exitCode int
+		stdout, stderr string		// expected somewhere in the output, if not empty
+	}{
+		{"no files", []string{}, 1, "", "Usage:"},
+		{"one file", []string{oldPath}, 1, "", "Usage:"},
+		{"three files", []string{oldPath, newPath, oldPath}, 1, "", "Usage:"},
+		{"unknown flag", []string{"--no-such-flag", oldPath, newPath}, 2, "", "no-such-flag"},
+		{"bad flag value", []string{"--format=nonsense", oldPath, newPath}, 1, "", "Unknown \"--format\" value \"nonsense\""},
+		{"missing left file", []string{missingPath, newPath}, 1, "", "missing.txt\" does not exist"},
+		{"missing right file", []string{oldPath, missingPath}, 1, "", "missing.txt\" does not exist"},
+		{"files differ", []string{"--format=unified", oldPath, newPath}, 1, "-two\n+2\n", ""},
+		{"files match", []string{"--format=unified", oldPath, oldPath}, 0, "", ""},
+		{"checked", []string{"--check", "--ignore-blank-at-eof", "--format=unified", oldPath, newPath}, 1, "-two\n+2\n", ""},
+		{"html by default", []string{oldPath, newPath}, 1, "<!DOCTYPE html>", ""},
+		{"stat", []string{"--stat", oldPath, newPath}, 1, "1 file changed, 1 insertion(+), 1 deletion(-)", ""},
+		{"stat of a final newline", []string{"--stat", oldPath, unterminatedPath}, 1, " 1 file changed, 1 insertion(+), 1 deletion(-)", ""},
+		{"additions in context", []string{"--view=additions-in-context", oldPath, newPath}, 1, "  one\n+ 2\n  three\n", ""},
+		{"debug heatmap", []string{"--debug-heatmap", oldPath, newPath}, 1, "title='left 2 vs right 2: ", ""},
+		{"csv field change", []string{"--format=json", oldCsvPath, newCsvPath}, 1, "\"type\": \"different\",\n      \"left\": 2,\n      \"right\": 2,\n      \"confidence\": 0.6666666", ""},
+		{"quote prefix", []string{"--strip-line-prefix=> ", "--format=unified", quotedPath, oldPath}, 0, "", ""},
+		{"context before and after", []string{"--context-before=0", "--context-after=1", "--format=unified", oldPath, newPath}, 1, "@@ -2,2 +2,2 @@\n-two\n+2\n three\n", ""},
+		{"min match run", []string{"--context-before=0", "--context-after=0", "--min-match-run=2", "--format=unified", oldPath, islandPath}, 1, "@@ -1,3 +1,3 @@\n-one\n+1\n two\n-three\n+3\n", ""},
+		{"only with context", []string{"--only=added", "--context-before=1", "--context-after=0", "--format=unified", oldPath, newPath}, 1, "@@ -2 +2 @@\n-two\n+2\n", ""},
+		{"similar lines paired", []string{"--format=json", oldPath, pluralPath}, 1, "\"type\": \"different\"", ""},
+		{"exact", []string{"--exact", "--format=json", oldPath, pluralPath}, 1, "\"type\": \"left-only\",\n      \"left\": 2\n", ""},
+		{"exact unified", []string{"--exact", "--format=unified", oldPath, pluralPath}, 1, " one\n-two\n+twos\n three\n", ""},
+		{"two kinds of anchor", []string{"--anchor=^func ", "--anchor-unique", oldPath, newPath}, 1, "", "The \"--anchor\" and \"--anchor-unique\" options can't be used together."},
+		{"negative context", []string{"--context-after=-1", oldPath, newPath}, 1, "", "can't be negative"},
+		{"blank at eof", []string{"--check", "--ignore-blank-at-eof", "--format=unified", oldPath, paddedPath}, 0, "", ""},
+		{"blank at eof after a change", []string{"--ignore-blank-at-eof", "--force-full", "--format=unified", xPath, x2Path}, 1, "@@ -1,2 +1,2 @@\n x\n-y\n+z\n", ""},
+		{"blank at eof reversed", []string{"--check", "--ignore-blank-at-eof", "--format=unified", paddedPath, newPath}, 1, "-two\n+2\n", ""},
+		{"strip affix unified", []string{"--strip-common-affix", "--format=unified", oldPath, quotedPath}, 1, "", "\"--strip-common-affix\" only applies to the HTML formats, so it can't be used with \"--format\" \"unified\"."},
+		{"strip affix set", []string{"--strip-common-affix", "--set", oldPath, quotedPath}, 1, "", "\"--strip-common-affix\" only applies to the HTML formats, so it can't be used with \"--set\"."},
+		{"directories", []string{"--stat", trees[0], trees[1]}, 1, " build/keep | 2 +-\n x          | 2 +-\n 2 files changed", ""},
+		{"directories unified", []string{"--format=unified", trees[0], trees[1]}, 1, "diff --git a/build/keep b/build/keep\n", ""},
+		{"directories ignore file", []string{"--stat", "--ignore-file=" + ignorePath, trees[0], trees[1]}, 1, " build/keep | 2 +-\n 1 file changed", ""},
+		{"directories by case", []string{"--stat", lowerPath, upperPath}, 1, " X | 1 +\n x | 1 -\n", ""},
+		{"directories ignoring case", []string{"--stat", "--path-normalize=case", lowerPath, upperPath}, 0, "", ""},
+		{"bad path normalization", []string{"--path-normalize=unicode", lowerPath, upperPath}, 1, "", "Unknown \"--path-normalize\" value \"unicode\""},
+		{"directories generated", []string{"--format=unified", gen1Path, gen2Path}, 1, "-gen1\n+gen2\n", ""},
+		{"directories skipping generated", []string{"--skip-generated", "--format=unified", gen1Path, gen2Path}, 0, "Generated files a/g.go and b/g.go differ\n", ""},
+		{"directories skipping generated stat", []string{"--skip-generated", "--stat", gen1Path, gen2Path}, 0, " g.go | generated\n", ""},
+		{"directories html", []string{trees[0], trees[1]}, 1, "", "Two directories can only be compared with"},
+		{"bad view", []string{"--view=nonsense", oldPath, newPath}, 1, "", "Unknown \"--view\" value \"nonsense\""},
+	}
+
+	for _, testCase := range testCases {
+		var stdout, stderr bytes.Buffer
+		exitCode := Run(testCase.args, &stdout, &stderr)
+		if exitCode != testCase.exitCode {
+			t.Errorf("%s: expected exit code %d, got %d; stderr:\n%s", testCase.name, testCase.exitCode, exitCode, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), testCase.stdout) {
+			t.Errorf("%s: expected %q on stdout, got:\n%s", testCase.name, testCase.stdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), testCase.stderr) {
+			t.Errorf("%s: expected %q on stderr, got:\n%s", testCase.name, testCase.stderr, stderr.String())
+		}
+	}
+
+	// Quietly, there's only the exit code, whether or not the files are byte for
+	// byte the same.  And as in git, there's no diffstat of identical files.
+	crlfPath := writeTestFile(t, dir, "crlf.txt", "one\r\ntwo\r\nthree\r\n")
+	emptyPath := writeTestFile(t, dir, "empty.txt", "")
+	for _, testCase := range []struct {
+		args []string
+		exitCode int
+	}{
+		{[]string{"--quiet", oldPath, oldPath}, 0},
+		{[]string{"-q", oldPath, newPath}, 1},
+		{[]string{"-q", "--stat", oldPath, newPath}, 1},
+		{[]string{"-q", oldPath, crlfPath}, 0},
+		{[]string{"-q", oldPath, emptyPath}, 1},
+		{[]string{"-q", "--skip-generated", "-v", oldPath, newPath}, 1},
+		{[]string{"--stat", oldPath, oldPath}, 0},
+		{[]string{"-q", trees[0], trees[0]}, 0},
+		{[]string{"-q", trees[0], trees[1]}, 1},
+		{[]string{"-q", "--format=unified", trees[0], trees[1]}, 1},
+	} {
+		var stdout, stderr bytes.Buffer
+		if exitCode := Run(testCase.args, &stdout, &stderr); exitCode != testCase.exitCode {
+			t.Errorf("%q: expected exit code %d, got %d; stderr:\n%s", testCase.args, testCase.exitCode, exitCode, stderr.String())
+		}
+		if stdout.Len() != 0 || stderr.Len() != 0 {
+			t.Errorf("%q: expected no output, got:\n%s%s", testCase.args, stdout.String(), stderr.String())
+		}
+	}
+
+	// The summary goes to its own file, whatever else is output.
+	summaryPath := filepath.Join(dir, "summary.json")
+	for _, testCase := range []struct {
+		args []string
+		exitCode int
+		stdout, summary string
+	}{
+		{[]string{"--format=unified", oldPath, newPath}, 1, "-two\n+2\n", `"changed":1,"added":0,"removed":0,"unchanged":2,`},
+		{[]string{"-q", oldPath, oldPath}, 0, "", `"changed":0,"added":0,"removed":0,"unchanged":3,"distance":0,"similarity":1,"identical":true}`},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"--summary-json", summaryPath}, testCase.args...)
+		if exitCode := Run(args, &stdout, &stderr); exitCode != testCase.exitCode {
+			t.Errorf("%q: expected exit code %d, got %d; stderr:\n%s", args, testCase.exitCode, exitCode, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), testCase.stdout) {
+			t.Errorf("%q: expected %q on stdout, got:\n%s", args, testCase.stdout, stdout.String())
+		}
+		if summary, err := ioutil.ReadFile(summaryPath); err != nil || !strings.Contains(string(summary), testCase.summary) {
+			t.Errorf("%q: expected %q in the summary, got %q (error = %v)", args, testCase.summary, summary, err)
+		}
+	}
+
+	// With --skip-generated, a pair with a generated file isn't diffed, and doesn't count as a difference.
+	generatedPath := writeTestFile(t, dir, "old_string.go", "// Code generated by stringer; DO NOT EDIT.\n\none\n")
+	pagePath := filepath.Join(dir, "matrix.html")
+	for _, testCase := range []struct {
+		args []string
+		exitCode int
+		diffed, skipped []string		// the pairs with a diff, and without
+	}{
+		{[]string{"--matrix", "--skip-generated", oldPath, generatedPath}, 0, nil, []string{"pair-0-1"}},
+		{[]string{"--matrix", "--skip-generated", oldPath, newPath, generatedPath}, 1, []string{"pair-0-1"}, []string{"pair-0-2", "pair-1-2"}},
+		{[]string{"--matrix", oldPath, generatedPath}, 1, []string{"pair-0-1"}, nil},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"--output", pagePath}, testCase.args...)
+		if exitCode := Run(args, &stdout, &stderr); exitCode != testCase.exitCode {
+			t.Errorf("%q: expected exit code %d, got %d; stderr:\n%s", testCase.args, testCase.exitCode, exitCode, stderr.String())
+		}
+		content, err := ioutil.ReadFile(pagePath)
+		if err != nil {
+			t.Fatalf("%q: could not read the page: %v", testCase.args, err)
+		}
+		page := string(content)
+		for _, id := range testCase.diffed {
+			if !strings.Contains(page, "id=\"" + id + "\"") {
+				t.Errorf("%q: expected a diff for %s", testCase.args, id)
+			}
+		}
+		for _, id := range testCase.skipped {
+			if strings.Contains(page, "id=\"" + id + "\"") {
+				t.Errorf("%q: expected no diff for %s", testCase.args, id)
+			}
+		}
+		if strings.Contains(page, "old_string.go (generated)") != (len(testCase.skipped) > 0) {
+			t.Errorf("%q: expected the generated file marked only when skipped", testCase.args)
+		}
+	}
+
+	// Like a single diff, the matrix page goes to stdout without "--output", and
+	// with "--tee", to stdout as well as the file "--open-with" opens.
+	openedPath := filepath.Join(dir, "opened.html")
+	for _, args := range [][]string{
+		{"--matrix", oldPath, newPath},
+		{"--matrix", "--tee", "--open-with=sh -c 'cat \"$0\" > " + openedPath + "'", oldPath, newPath},
+	} {
+		var stdout, stderr bytes.Buffer
+		if exitCode := Run(args, &stdout, &stderr); exitCode != 1 {
+			t.Errorf("%q: expected exit code 1, got %d; stderr:\n%s", args, exitCode, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "id=\"pair-0-1\"") {
+			t.Errorf("%q: expected the page on stdout, got:\n%s", args, stdout.String())
+		}
+	}
+	if opened, err := ioutil.ReadFile(openedPath); err != nil || !strings.Contains(string(opened), "id=\"pair-0-1\"") {
+		t.Errorf("Expected the launcher to open the page too, got %q (%v)", opened, err)
+	}
+
+	// Comparing directories on several goroutines doesn't change the output.
+	for _, format := range []string{"--format=unified", "--stat"} {
+		var outputs []string
+		for _, jobs := range []string{"--jobs=1", "--jobs=4"} {
+			var stdout, stderr bytes.Buffer
+			if exitCode := Run([]string{jobs, format, trees[0], trees[1]}, &stdout, &stderr); exitCode != 1 {
+				t.Errorf("%s %s: expected exit code 1, got %d; stderr:\n%s", jobs, format, exitCode, stderr.String())
+			}
+			outputs = append(outputs, stdout.String())
+		}
+		if outputs[0] != outputs[1] {
+			t.Errorf("%s: expected the same output with 1 and 4 jobs, got:\n%s\nand:\n%s", format, outputs[0], outputs[1])
+		}
+	}
+
+	// Each run starts from the default flags, not the last run's.
+	var stdout, stderr bytes.Buffer
+	Run([]string{"--format=unified", "-v", oldPath, newPath}, &stdout, &stderr)
+	stdout.Reset()
+	stderr.Reset()
+	Run([]string{oldPath, newPath}, &stdout, &stderr)
+	if !strings.HasPrefix(stdout.String(), "<!DOCTYPE html>") || stderr.Len() != 0 {
+		t.Errorf("Expected the default HTML and no log, got:\n%s\nand:\n%s", stdout.String(), stderr.String())
+	}
+}
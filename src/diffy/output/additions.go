@@ -0,0 +1,77 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"diffy/diff"
+)
+
+// "additions.go" - The first file as it reads with the second file's
+// additions slotted in, and its own deletions left out: a preview of what's
+// new, in the place it's new.
+
+// -------------------------------------------
+// ------------------------------------------- type View
+// -------------------------------------------
+
+// A View is a way of looking at the diff other than the output format's own.
+
+type View int
+
+const (
+	ViewNone View = iota		// the output format's usual view
+	ViewAdditionsInContext		// the first file with the second's additions interleaved
+)
+
+var viewNames = map[string]View{
+	"": ViewNone,
+	"additions-in-context": ViewAdditionsInContext,
+}
+
+// Look up a View by its "--view" name.
+func ParseView(name string) (View, bool) {
+	view, found := viewNames[name]
+	return view, found
+}
+
+// The prefix of each line of the additions in context.
+const (
+	ADDITIONS_CONTEXT_PREFIX = "  "
+	ADDITIONS_ADDED_PREFIX = "+ "
+	ADDITIONS_CHANGED_PREFIX = "~ "		// the second file's version of a changed line
+)
+
+// ------------------------------------------- GenerateAdditionsInContext
+//
+// Write the lines of the left file which are still in the right one, with
+// the right file's added lines interleaved where they go, each line with a
+// prefix saying which it is.  A line which was changed is shown as it is in
+// the right file, with its own prefix, and lines which were only deleted
+// aren't shown at all.  The lines are written as they were read, tabs and
+// all.  Only the realign options of "opts" apply.
+//
+func GenerateAdditionsInContext(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) {
+	rawText := func (line *diff.TextLine) string {
+		if line.RawText != "" {
+			return line.RawText
+		}
+		return line.Text
+	}
+
+	alignment = realignForDisplay(alignment, leftSource, rightSource, opts)
+	for _, link := range alignment.Links {
+		switch link.LinkType {
+		case diff.Matching:
+			fmt.Fprintln(outputFile, ADDITIONS_CONTEXT_PREFIX + rawText(leftSource.Lines[link.LeftIndex]))
+		case diff.Different:
+			fmt.Fprintln(outputFile, ADDITIONS_CHANGED_PREFIX + rawText(rightSource.Lines[link.RightIndex]))
+		case diff.LeftOnly:
+			// deletions aren't shown
+		case diff.RightOnly:
+			fmt.Fprintln(outputFile, ADDITIONS_ADDED_PREFIX + rawText(rightSource.Lines[link.RightIndex]))
+		default:
+			panic("not reached")
+		}
+	}
+}
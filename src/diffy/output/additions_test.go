@@ -0,0 +1,51 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestAdditionsInContext
+
+func TestAdditionsInContext(t *testing.T) {
+
+	left := makeLines("# Setup", "Install the tools.", "Clone the repository.", "Build it.", "Run the tests.")
+	right := makeLines("# Setup", "Install Go 1.21 or later.", "Install the tools.", "Clone the repository.", "Configure the proxy.", "Build it.", "Run the tests.", "Report any failures.")
+	_, alignment := diff.Diff_v2(left, right)
+
+	var buffer bytes.Buffer
+	GenerateAdditionsInContext(&buffer, alignment, NewSourceLinesRec(left, "a.md"), NewSourceLinesRec(right, "b.md"), HtmlOptions{})
+	expected := "" +
+		"  # Setup\n" +
+		"+ Install Go 1.21 or later.\n" +
+		"  Install the tools.\n" +
+		"  Clone the repository.\n" +
+		"+ Configure the proxy.\n" +
+		"  Build it.\n" +
+		"  Run the tests.\n" +
+		"+ Report any failures.\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+
+	// Deleted lines are left out, and changed ones are shown as they are now.
+	left = makeLines("one", "three hundred", "two", "four")
+	right = makeLines("one", "three hundreds", "four")
+	_, alignment = diff.Diff_v2(left, right)
+	buffer.Reset()
+	GenerateAdditionsInContext(&buffer, alignment, NewSourceLinesRec(left, "a.txt"), NewSourceLinesRec(right, "b.txt"), HtmlOptions{})
+	expected = "  one\n~ three hundreds\n  four\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+
+	// Only the names it has are views.
+	if view, ok := ParseView("additions-in-context"); !ok || view != ViewAdditionsInContext {
+		t.Errorf("Expected the additions-in-context view")
+	}
+	if _, ok := ParseView("deletions-in-context"); ok {
+		t.Errorf("Expected no deletions-in-context view")
+	}
+}
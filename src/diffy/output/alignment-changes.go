@@ -0,0 +1,36 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"diffy/diff"
+)
+
+// "alignment-changes.go" - A report of how the alignment of the same two files
+// changed between two runs, e.g. with different normalization options.
+
+// ------------------------------------------- GenerateAlignmentChanges
+//
+// Write one line per change, with the link before and the link after, e.g.
+//
+//     different (3, 3) -> left-only (3, -)
+//
+// The line numbers are the left and right lines, starting from 1, with "-"
+// for a side the link doesn't have.  No changes write nothing.
+//
+func GenerateAlignmentChanges(outputFile io.Writer, changes []diff.LinkChange) {
+	for _, change := range changes {
+		fmt.Fprintf(outputFile, "%s -> %s\n", describeLink(change.Before), describeLink(change.After))
+	}
+}
+
+func describeLink(link diff.Link) string {
+	lineNumber := func (index int) string {
+		if index < 0 {
+			return "-"
+		}
+		return fmt.Sprint(index + 1)
+	}
+	return fmt.Sprintf("%s (%s, %s)", jsonLinkTypeNames[link.LinkType], lineNumber(link.LeftIndex), lineNumber(link.RightIndex))
+}
@@ -0,0 +1,54 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestAlignmentChangesFromJson
+
+func TestAlignmentChangesFromJson(t *testing.T) {
+
+	left := makeLines("unchanged", "the quick brown fox jumps over the lazy dog", "tail")
+	right := makeLines("unchanged", "the quick brown fox jumps over the lazy hog", "tail")
+	leftSource, rightSource := NewSourceLinesRec(left, "old.txt"), NewSourceLinesRec(right, "new.txt")
+
+	// Two runs which differ in how they classify the middle line.
+	runs := []*diff.Alignment{
+		{Links: []diff.Link{{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0}, {LinkType: diff.Different, LeftIndex: 1, RightIndex: 1}, {LinkType: diff.Matching, LeftIndex: 2, RightIndex: 2}}},
+		{Links: []diff.Link{{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0}, {LinkType: diff.Matching, LeftIndex: 1, RightIndex: 1}, {LinkType: diff.Matching, LeftIndex: 2, RightIndex: 2}}},
+	}
+
+	// Round trip both through the JSON output.
+	var readBack []*diff.Alignment
+	for _, run := range runs {
+		var buffer bytes.Buffer
+		if err := GenerateJsonDiff(&buffer, run, leftSource, rightSource, HtmlOptions{}); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		alignment, err := ReadJsonAlignment(&buffer)
+		if err != nil {
+			t.Fatalf("Could not read the JSON back: %v", err)
+		}
+		readBack = append(readBack, alignment)
+	}
+	if len(readBack[0].Links) != 3 || readBack[0].Links[1] != runs[0].Links[1] {
+		t.Errorf("Expected the links to survive the round trip, got %v", readBack[0].Links)
+	}
+
+	changes, err := diff.CompareAlignments(readBack[0], readBack[1])
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	var buffer bytes.Buffer
+	GenerateAlignmentChanges(&buffer, changes)
+	if got, expected := buffer.String(), "different (2, 2) -> matching (2, 2)\n"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	if _, err := ReadJsonAlignment(bytes.NewBufferString(`{"links": [{"type": "sideways"}]}`)); err == nil {
+		t.Errorf("Expected an unknown link type to be an error")
+	}
+}
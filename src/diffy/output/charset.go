@@ -0,0 +1,105 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// "charset.go" - Writing HTML pages in character sets other than UTF-8, and
+// with a byte order mark, for tools which insist on them.
+
+// ------------------------------------------- constants
+
+const CHARSET_UTF8 = "utf-8"
+const CHARSET_LATIN1 = "iso-8859-1"
+
+const UTF8_BOM = "\uFEFF"
+
+// ------------------------------------------- ParseCharset
+//
+// The canonical name of a character set we can write, e.g. "iso-8859-1" for
+// "Latin-1", and whether we can write it at all.
+//
+func ParseCharset(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8":
+		return CHARSET_UTF8, true
+	case "iso-8859-1", "latin-1", "latin1":
+		return CHARSET_LATIN1, true
+	}
+	return "", false
+}
+
+// ------------------------------------------- newCharsetWriter
+//
+// Wrap "outputFile" so the UTF-8 written to it comes out in "charset", which
+// is CHARSET_UTF8 or CHARSET_LATIN1.  Characters Latin-1 doesn't have are
+// written as HTML character references, so this is for HTML only.
+//
+func newCharsetWriter(outputFile io.Writer, charset string) io.Writer {
+	switch charset {
+	case "", CHARSET_UTF8:
+		return outputFile
+	case CHARSET_LATIN1:
+		return &tLatin1Writer{outputFile: outputFile}
+	default:
+		panic("not reached")
+	}
+}
+
+// ------------------------------------------- flushCharsetWriter
+//
+// Write out whatever a writer from newCharsetWriter is still holding back,
+// once the page is finished.
+//
+func flushCharsetWriter(writer io.Writer) error {
+	if latin1Writer, ok := writer.(*tLatin1Writer); ok {
+		return latin1Writer.Flush()
+	}
+	return nil
+}
+
+// ------------------------------------------- type tLatin1Writer
+
+// Transcodes UTF-8 to Latin-1.  A character split between two writes is held
+// back until the rest of it arrives, or until Flush, which gives up on it.
+type tLatin1Writer struct {
+	outputFile io.Writer
+	pending []byte
+}
+
+func (writer *tLatin1Writer) Write(p []byte) (int, error) {
+	text := append(writer.pending, p...)
+	writer.pending = nil
+	transcoded := make([]byte, 0, len(text))
+	for len(text) > 0 {
+		if !utf8.FullRune(text) {
+			writer.pending = append([]byte(nil), text...)
+			break
+		}
+		char, size := utf8.DecodeRune(text)
+		if char < 0x100 && char != utf8.RuneError {
+			transcoded = append(transcoded, byte(char))
+		} else {
+			transcoded = append(transcoded, fmt.Sprintf("&#%d;", char)...)
+		}
+		text = text[size:]
+	}
+	if _, err := writer.outputFile.Write(transcoded); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write the bytes of an unfinished character, if any, as replacement
+// characters, as if the text had ended with invalid UTF-8.
+func (writer *tLatin1Writer) Flush() error {
+	replacement := strings.Repeat(fmt.Sprintf("&#%d;", utf8.RuneError), len(writer.pending))
+	writer.pending = nil
+	if _, err := io.WriteString(writer.outputFile, replacement); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+// ------------------------------------------- TestCharset
+
+func TestCharset(t *testing.T) {
+
+	left, right := makeLines("café", "same"), makeLines("café ✓", "same")
+	generatePage := func (opts HtmlOptions) []byte {
+		return []byte(generateTestPage(left, right, opts))
+	}
+
+	// By default, UTF-8 without a BOM.
+	page := generatePage(HtmlOptions{})
+	if bytes.HasPrefix(page, []byte(UTF8_BOM)) {
+		t.Errorf("Expected no BOM by default")
+	}
+	if !bytes.Contains(page, []byte(`<meta charset="utf-8"/>`)) || !bytes.Contains(page, []byte("café ✓")) {
+		t.Errorf("Expected a UTF-8 page")
+	}
+
+	// With a BOM, which comes before anything else.
+	page = generatePage(HtmlOptions{Bom: true})
+	if !bytes.HasPrefix(page, []byte(UTF8_BOM + "<!DOCTYPE html>")) {
+		t.Errorf("Expected the page to start with a BOM, got %q", page[:20])
+	}
+	if bytes.Count(page, []byte(UTF8_BOM)) != 1 {
+		t.Errorf("Expected exactly one BOM")
+	}
+
+	// Latin-1 declares itself, and has character references for what it lacks.
+	page = generatePage(HtmlOptions{Charset: CHARSET_LATIN1, Bom: true})
+	if bytes.HasPrefix(page, []byte(UTF8_BOM)) {
+		t.Errorf("Expected no BOM on a Latin-1 page")
+	}
+	if !bytes.Contains(page, []byte(`<meta charset="iso-8859-1"/>`)) {
+		t.Errorf("Expected a Latin-1 charset declaration")
+	}
+	if !bytes.Contains(page, []byte("caf\xe9")) || bytes.Contains(page, []byte("café")) {
+		t.Errorf("Expected \"é\" as a single Latin-1 byte")
+	}
+	if !bytes.Contains(page, []byte("&#10003;")) || bytes.Contains(page, []byte("✓")) {
+		t.Errorf("Expected \"✓\" as a character reference")
+	}
+}
+
+// ------------------------------------------- TestLatin1WriterSplitCharacters
+
+func TestLatin1WriterSplitCharacters(t *testing.T) {
+
+	// A character split across writes comes out whole.
+	var buffer bytes.Buffer
+	writer := newCharsetWriter(&buffer, CHARSET_LATIN1)
+	text := []byte("é→x")
+	for index := range text {
+		if n, err := writer.Write(text[index:index + 1]); n != 1 || err != nil {
+			t.Fatalf("Unexpected write result %d, %v", n, err)
+		}
+	}
+	if got := buffer.String(); got != "\xe9&#8594;x" {
+		t.Errorf("Expected %q, got %q", "\xe9&#8594;x", got)
+	}
+
+	// An unfinished character is only written once flushed, a replacement
+	// character per byte.
+	buffer.Reset()
+	if _, err := writer.Write([]byte("x\xe2\x86")); err != nil {
+		t.Fatalf("Unexpected write error %v", err)
+	}
+	if got := buffer.String(); got != "x" {
+		t.Errorf("Expected %q before flushing, got %q", "x", got)
+	}
+	if err := flushCharsetWriter(writer); err != nil {
+		t.Fatalf("Unexpected flush error %v", err)
+	}
+	if got := buffer.String(); got != "x&#65533;&#65533;" {
+		t.Errorf("Expected %q after flushing, got %q", "x&#65533;&#65533;", got)
+	}
+
+	for _, name := range []string{"UTF8", "latin-1", "ISO-8859-1"} {
+		if _, ok := ParseCharset(name); !ok {
+			t.Errorf("Expected %q to be a known charset", name)
+		}
+	}
+	if _, ok := ParseCharset("ebcdic"); ok {
+		t.Errorf("Expected EBCDIC to be unknown")
+	}
+}
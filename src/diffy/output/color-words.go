@@ -0,0 +1,155 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "color-words.go" - A word diff rendered as a single stream of text, in the
+// style of git's "--color-words" option.  Deleted words are shown in red and
+// inserted words in green, inline, rather than line-by-line.
+
+// ------------------------------------------- type WordDiffMarkers
+//
+// WordDiffMarkers records hold the strings used to mark the start and end of
+// deleted and inserted runs of words.
+
+type WordDiffMarkers struct {
+	DeletedStart, DeletedEnd string
+	InsertedStart, InsertedEnd string
+}
+
+// Red for deleted words, green for inserted ones.
+var AnsiWordDiffMarkers = WordDiffMarkers{
+	DeletedStart: "\x1b[31m",
+	DeletedEnd: "\x1b[m",
+	InsertedStart: "\x1b[32m",
+	InsertedEnd: "\x1b[m",
+}
+
+// The same markers as git's "--word-diff=plain".
+var PlainWordDiffMarkers = WordDiffMarkers{
+	DeletedStart: "[-",
+	DeletedEnd: "-]",
+	InsertedStart: "{+",
+	InsertedEnd: "+}",
+}
+
+// ------------------------------------------- GenerateColorWords
+//
+// Each file is split into paragraphs at blank lines, and the paragraphs are
+// aligned first.  Aligned paragraphs are then diffed word by word, so a change
+// in one paragraph can't ripple into its neighbors.  Paragraphs which only
+// exist on one side are marked as deleted or inserted in their entirety.
+// "wordChars" says what a word is made of; nil means diff.CodeWordChars.
+//
+func GenerateColorWords(outputFile io.Writer, leftSource, rightSource *SourceLinesRec, markers WordDiffMarkers, wordChars diff.WordChars) {
+
+	leftParagraphs := splitParagraphs(leftSource.Lines)
+	rightParagraphs := splitParagraphs(rightSource.Lines)
+	_, alignment := diff.Diff_v2(leftParagraphs, rightParagraphs)
+
+	var paragraphTexts []string
+	for _, link := range alignment.Links {
+		switch link.LinkType {
+		case diff.Matching:
+			paragraphTexts = append(paragraphTexts, rightParagraphs[link.RightIndex].Text)
+		case diff.Different:
+			paragraphTexts = append(paragraphTexts,
+				generateWordDiff(leftParagraphs[link.LeftIndex].Text, rightParagraphs[link.RightIndex].Text, markers, wordChars))
+		case diff.LeftOnly:
+			paragraphTexts = append(paragraphTexts,
+				markRun(leftParagraphs[link.LeftIndex].Text, markers.DeletedStart, markers.DeletedEnd))
+		case diff.RightOnly:
+			paragraphTexts = append(paragraphTexts,
+				markRun(rightParagraphs[link.RightIndex].Text, markers.InsertedStart, markers.InsertedEnd))
+		default:
+			panic("not reached")
+		}
+	}
+
+	if len(paragraphTexts) > 0 {
+		fmt.Fprintln(outputFile, strings.Join(paragraphTexts, "\n\n"))
+	}
+}
+
+// ------------------------------------------- splitParagraphs
+//
+// Group lines into paragraphs separated by one or more blank lines.  Each
+// paragraph becomes a single TextLine with its lines joined by newlines.
+func splitParagraphs(lines diff.ComparableLines) diff.ComparableLines {
+	var paragraphs diff.ComparableLines
+	var currentLines []string
+	flush := func () {
+		if len(currentLines) > 0 {
+			paragraphs = append(paragraphs, diff.NewTextLine(strings.Join(currentLines, "\n")))
+			currentLines = nil
+		}
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line.Text) == "" {
+			flush()
+		} else {
+			currentLines = append(currentLines, line.Text)
+		}
+	}
+	flush()
+	return paragraphs
+}
+
+// ------------------------------------------- generateWordDiff
+//
+// Diff two paragraphs word by word and return a single text in which deleted
+// and inserted runs of words are marked.  Where words were replaced, the
+// deleted run comes first, followed by the inserted run.
+func generateWordDiff(leftText, rightText string, markers WordDiffMarkers, wordChars diff.WordChars) string {
+
+	leftTokens, rightTokens := diff.ComparableTokens(diff.TokenizeWith(leftText, wordChars)), diff.ComparableTokens(diff.TokenizeWith(rightText, wordChars))
+	_, alignment := diff.Diff_v2(leftTokens, rightTokens)
+
+	var result, deleted, inserted strings.Builder
+	flush := func () {
+		result.WriteString(markRun(deleted.String(), markers.DeletedStart, markers.DeletedEnd))
+		result.WriteString(markRun(inserted.String(), markers.InsertedStart, markers.InsertedEnd))
+		deleted.Reset()
+		inserted.Reset()
+	}
+
+	for _, link := range alignment.Links {
+		switch link.LinkType {
+		case diff.Matching:
+			flush()
+			result.WriteString(leftTokens[link.LeftIndex])
+		case diff.Different:
+			deleted.WriteString(leftTokens[link.LeftIndex])
+			inserted.WriteString(rightTokens[link.RightIndex])
+		case diff.LeftOnly:
+			deleted.WriteString(leftTokens[link.LeftIndex])
+		case diff.RightOnly:
+			inserted.WriteString(rightTokens[link.RightIndex])
+		default:
+			panic("not reached")
+		}
+	}
+	flush()
+
+	return result.String()
+}
+
+// ------------------------------------------- markRun
+//
+// Wrap "text" in the start and end markers.  Markers never span a newline,
+// so each line of a multi-line run is wrapped separately.  Empty text, and
+// empty lines, are left unmarked.
+func markRun(text string, startMarker, endMarker string) string {
+	pieces := strings.Split(text, "\n")
+	for i, piece := range pieces {
+		if piece != "" {
+			pieces[i] = startMarker + piece + endMarker
+		}
+	}
+	return strings.Join(pieces, "\n")
+}
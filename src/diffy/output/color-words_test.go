@@ -0,0 +1,81 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"diffy/etc"
+)
+
+// ------------------------------------------- helper functions
+
+func generateTestColorWords(leftLines, rightLines []string, markers WordDiffMarkers) string {
+	leftSource := NewSourceLinesRec(makeLines(leftLines...), "left.txt")
+	rightSource := NewSourceLinesRec(makeLines(rightLines...), "right.txt")
+
+	var buffer bytes.Buffer
+	GenerateColorWords(&buffer, leftSource, rightSource, markers, nil)
+	return buffer.String()
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestColorWords
+// -------------------------------------------
+
+func TestColorWords(t *testing.T) {
+
+	leftLines := []string{
+		"The quick brown fox",
+		"jumps over the dog.",
+		"",
+		"A second paragraph.",
+	}
+	rightLines := []string{
+		"The quick red fox",
+		"jumps over the lazy dog.",
+		"",
+		"A second paragraph.",
+	}
+
+	// Only the changed words are marked, and the untouched paragraph is left alone.
+	expected := "The quick [-brown-]{+red+} fox\njumps over the{+ lazy+} dog.\n\nA second paragraph.\n"
+	if result := generateTestColorWords(leftLines, rightLines, PlainWordDiffMarkers); result != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", result, expected)
+	}
+
+	// With the color stripped, what's left is the deleted words followed by the inserted ones.
+	colored := generateTestColorWords(leftLines, rightLines, AnsiWordDiffMarkers)
+	expected = "The quick brownred fox\njumps over the lazy dog.\n\nA second paragraph.\n"
+	if stripped := etc.StripAnsiEscapes(colored); stripped != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", stripped, expected)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestColorWordsParagraphs
+// -------------------------------------------
+
+func TestColorWordsParagraphs(t *testing.T) {
+
+	// A paragraph inserted in the middle must not disturb its neighbors.
+	leftLines := []string{
+		"First paragraph stays the same.",
+		"",
+		"Last paragraph stays the same.",
+	}
+	rightLines := []string{
+		"First paragraph stays the same.",
+		"",
+		"A brand new paragraph",
+		"over two lines.",
+		"",
+		"Last paragraph stays the same.",
+	}
+
+	expected := "First paragraph stays the same.\n\n" +
+				"{+A brand new paragraph+}\n{+over two lines.+}\n\n" +
+				"Last paragraph stays the same.\n"
+	if result := generateTestColorWords(leftLines, rightLines, PlainWordDiffMarkers); result != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", result, expected)
+	}
+}
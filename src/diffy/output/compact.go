@@ -0,0 +1,112 @@
+package output
+
+import (
+	"html"
+)
+
+// "compact.go" - A narrower side-by-side diff, where a changed pair of lines
+// with a long stretch in common at the start or the end shows that stretch
+// just once, in a row of its own below the pair, and only the differing
+// middle on each side.
+
+// ------------------------------------------- CSS style definitions
+
+var compactSharedEndsStyle CssStyle = MakeCssStyle("compact-shared-ends",
+	"color: #808080",
+	"text-align: center",
+)
+
+var compactElisionStyle CssStyle = MakeCssStyle("compact-elision",
+	"color: #808080",
+)
+
+// The shortest stretch in common, in characters, which is worth eliding.
+const COMPACT_MIN_SHARED = 8
+
+// What an elided stretch is shown as.
+const COMPACT_ELISION = "…"
+
+// ------------------------------------------- elideSharedEnds
+//
+// Cut the first run of both lines, if it's the same on both sides and at
+// least COMPACT_MIN_SHARED characters long, and likewise the last run.  The
+// runs alternate between unchanged and changed, starting with unchanged, so a
+// first or last run which matches on both sides is the shared start or end.
+// Return the runes and run positions left, along with the shared start and
+// end, which are empty if they weren't cut.
+//
+func elideSharedEnds(leftRunes []rune, leftPositions []int, rightRunes []rune, rightPositions []int) ([]rune, []int, []rune, []int, string, string) {
+
+	run := func (runes []rune, positions []int, index int) string {
+		return string(runes[positions[index]:positions[index + 1]])
+	}
+	lastEvenRun := func (positions []int) int {
+		last := len(positions) - 2
+		if last % 2 == 1 {
+			return -1
+		}
+		return last
+	}
+	if len(leftPositions) < 2 || len(rightPositions) < 2 {
+		return leftRunes, leftPositions, rightRunes, rightPositions, "", ""
+	}
+
+	prefix := run(leftRunes, leftPositions, 0)
+	if prefix != run(rightRunes, rightPositions, 0) || len([]rune(prefix)) < COMPACT_MIN_SHARED {
+		prefix = ""
+	}
+	suffix := ""
+	leftLast, rightLast := lastEvenRun(leftPositions), lastEvenRun(rightPositions)
+	if leftLast > 0 && rightLast > 0 {
+		suffix = run(leftRunes, leftPositions, leftLast)
+		if suffix != run(rightRunes, rightPositions, rightLast) || len([]rune(suffix)) < COMPACT_MIN_SHARED {
+			suffix = ""
+		}
+	}
+
+	cut := func (runes []rune, positions []int) ([]rune, []int) {
+		start, end := len([]rune(prefix)), len(runes) - len([]rune(suffix))
+		cutPositions := make([]int, len(positions))
+		for index, position := range positions {
+			if position < start {
+				position = start
+			} else if position > end {
+				position = end
+			}
+			cutPositions[index] = position - start
+		}
+		return runes[start:end], cutPositions
+	}
+	leftRunes, leftPositions = cut(leftRunes, leftPositions)
+	rightRunes, rightPositions = cut(rightRunes, rightPositions)
+	return leftRunes, leftPositions, rightRunes, rightPositions, prefix, suffix
+}
+
+// ------------------------------------------- elideSpans
+//
+// elideSpans("<span>x</span>", "a", "") => "<span>…</span><span>x</span>"
+// Mark where a shared start or end was cut from a line's spans.
+//
+func elideSpans(spansHtml string, prefix, suffix string, opts HtmlOptions) string {
+	elision := generateElement(opts, "span", COMPACT_ELISION, compactElisionStyle)
+	if prefix != "" {
+		spansHtml = elision + spansHtml
+	}
+	if suffix != "" {
+		spansHtml += elision
+	}
+	return spansHtml
+}
+
+// ------------------------------------------- generateSharedEndsHtml
+//
+// generateSharedEndsHtml("The quick ", " dog") => "The quick <span>…</span> dog"
+// The shared start and end of a pair of lines, once, with the elision between
+// them standing for both sides' middles.  Nothing shared gives "".
+//
+func generateSharedEndsHtml(prefix, suffix string, opts HtmlOptions) string {
+	if prefix == "" && suffix == "" {
+		return ""
+	}
+	return html.EscapeString(prefix) + generateElement(opts, "span", COMPACT_ELISION, compactElisionStyle) + html.EscapeString(suffix)
+}
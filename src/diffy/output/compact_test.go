@@ -0,0 +1,60 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestCompactDiff
+
+func TestCompactDiff(t *testing.T) {
+
+	prefix, suffix := "	logger.Infof(\"reading the configuration from ", " and the environment\")"
+	left := makeLines("start", prefix + "files" + suffix, "end")
+	right := makeLines("start", prefix + "network" + suffix, "end")
+	_, alignment := diff.Diff_v2(left, right)
+	generatePage := func (opts HtmlOptions) string {
+		var buffer bytes.Buffer
+		GenerateHtmlDiffPage(&buffer, alignment, NewSourceLinesRec(left, "left.go"), NewSourceLinesRec(right, "right.go"), opts)
+		return buffer.String()
+	}
+
+	// The shared start and end are shown once, not once per side.
+	escapedPrefix, escapedSuffix := strings.Replace(prefix, "\"", "&#34;", -1), strings.Replace(suffix, "\"", "&#34;", -1)
+	page := generatePage(HtmlOptions{CompactDiff: true})
+	if count := strings.Count(page, escapedPrefix); count != 1 {
+		t.Errorf("Expected the shared start once, got %d times:\n%s", count, page)
+	}
+	if count := strings.Count(page, escapedSuffix); count != 1 {
+		t.Errorf("Expected the shared end once, got %d times:\n%s", count, page)
+	}
+	if !strings.Contains(page, "colspan='5'") || !strings.Contains(page, "files") || !strings.Contains(page, "network") {
+		t.Errorf("Expected a shared row, and the middles on either side, got:\n%s", page)
+	}
+
+	// The pair comes first, since the first row of a fixed layout table sets
+	// the widths of its columns.
+	if pairIndex, sharedIndex := strings.Index(page, "network"), strings.Index(page, "colspan='5'"); pairIndex < 0 || sharedIndex < pairIndex {
+		t.Errorf("Expected the shared row below the pair, got:\n%s", page)
+	}
+
+	// The shared row stretches across a reflowed row too.
+	if page := generatePage(HtmlOptions{CompactDiff: true, Breakpoint: 600}); !strings.Contains(page, "class='diffy-shared'") {
+		t.Errorf("Expected the shared row to have a responsive class, got:\n%s", page)
+	}
+
+	// Without the option, each side has it all.
+	page = generatePage(HtmlOptions{})
+	if count := strings.Count(page, escapedPrefix); count != 2 {
+		t.Errorf("Expected the shared start on both sides, got %d times", count)
+	}
+
+	// A short stretch in common isn't worth eliding.
+	sharedHtml, leftHtml, _ := generateCompactableLineHtml(diff.NewTextLine("x = 1"), diff.NewTextLine("x = 2"), HtmlOptions{CompactDiff: true})
+	if sharedHtml != "" || strings.Contains(leftHtml, COMPACT_ELISION) {
+		t.Errorf("Expected nothing elided, got %q and %q", sharedHtml, leftHtml)
+	}
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"diffy/diff"
+)
+
+// "diffstat.go" - An overview of a diff in the style of "git diff --stat": one
+// line per file with its number of changed lines and a bar of "+" and "-",
+// followed by the totals.
+
+// The widest bar, in characters.  Bigger changes are scaled down to fit.
+const DEFAULT_DIFFSTAT_BAR_WIDTH = 50
+
+// ------------------------------------------- type DiffStatEntry
+//
+// DiffStatEntry records hold the counts for one file.  A changed line counts
+// as a deletion and an insertion, just as in git.  A Generated file has no
+// counts, since it wasn't diffed.
+type DiffStatEntry struct {
+	Path string
+	Insertions, Deletions int
+	Generated bool
+}
+
+// ------------------------------------------- NewDiffStatEntry
+
+// The counts for a file.  A last line which only gains or loses its newline
+// is a deletion and an insertion, as in git, since it's a change in a patch.
+func NewDiffStatEntry(alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec) DiffStatEntry {
+	alignment = diff.MarkFinalNewlineChange(alignment, leftSource.FinalNewline, rightSource.FinalNewline)
+	stats := ComputeChangeStats(alignment)
+	path := leftSource.FilePath
+	if rightSource.FilePath != path {
+		path += " => " + rightSource.FilePath
+	}
+	return DiffStatEntry{Path: path, Insertions: stats.RightChanged, Deletions: stats.LeftChanged}
+}
+
+// ------------------------------------------- GenerateDiffStat
+//
+// Write one line per entry, and a line of totals, e.g.
+//
+//     main.go | 12 +++++-------
+//     1 file changed, 5 insertions(+), 7 deletions(-)
+//
+// The bars are scaled so the biggest one is at most "barWidth" characters.
+// Entries with no insertions or deletions are left out, and with none left,
+// nothing is written at all, as when git has no changes to report.  Generated
+// entries are marked "generated" in place of their counts, and aren't in the
+// totals.
+func GenerateDiffStat(outputFile io.Writer, entries []DiffStatEntry, barWidth int) {
+
+	var changedEntries []DiffStatEntry
+	for _, entry := range entries {
+		if entry.Insertions + entry.Deletions > 0 || entry.Generated {
+			changedEntries = append(changedEntries, entry)
+		}
+	}
+	if len(changedEntries) == 0 {
+		return
+	}
+	entries = changedEntries
+
+	pathWidth, countWidth, maxChanges := 0, 0, 0
+	fileCount, totalInsertions, totalDeletions := 0, 0, 0
+	for _, entry := range entries {
+		changes := entry.Insertions + entry.Deletions
+		if width := utf8.RuneCountInString(entry.Path); width > pathWidth {
+			pathWidth = width
+		}
+		if entry.Generated {
+			continue
+		}
+		fileCount++
+		if width := len(fmt.Sprint(changes)); width > countWidth {
+			countWidth = width
+		}
+		if changes > maxChanges {
+			maxChanges = changes
+		}
+		totalInsertions += entry.Insertions
+		totalDeletions += entry.Deletions
+	}
+
+	for _, entry := range entries {
+		padding := strings.Repeat(" ", pathWidth - utf8.RuneCountInString(entry.Path))
+		if entry.Generated {
+			fmt.Fprintf(outputFile, " %s%s | generated\n", entry.Path, padding)
+			continue
+		}
+		insertionBar, deletionBar := scaleDiffStatBars(entry.Insertions, entry.Deletions, maxChanges, barWidth)
+		fmt.Fprintf(outputFile, " %s%s | %*d %s%s\n", entry.Path, padding, countWidth, entry.Insertions + entry.Deletions,
+			strings.Repeat("+", insertionBar), strings.Repeat("-", deletionBar))
+	}
+
+	if fileCount > 0 {
+		fmt.Fprintf(outputFile, " %s\n", formatDiffStatTotals(fileCount, totalInsertions, totalDeletions))
+	}
+}
+
+// ------------------------------------------- scaleDiffStatBars
+//
+// The lengths of the "+" and "-" bars.  When the biggest entry fits, the bars
+// are the actual counts.  Otherwise they're scaled linearly, except that a
+// non-zero count always gets at least one character.
+func scaleDiffStatBars(insertions, deletions, maxChanges, barWidth int) (int, int) {
+	if maxChanges <= barWidth {
+		return insertions, deletions
+	}
+	scale := func (count int) int {
+		if count == 0 {
+			return 0
+		}
+		scaled := (count * barWidth * 2 + maxChanges) / (maxChanges * 2)	// rounded to the nearest
+		if scaled < 1 {
+			scaled = 1
+		}
+		return scaled
+	}
+	insertionBar, deletionBar := scale(insertions), scale(deletions)
+	if insertionBar + deletionBar > barWidth {
+		if insertionBar > deletionBar {
+			insertionBar--
+		} else {
+			deletionBar--
+		}
+	}
+	return insertionBar, deletionBar
+}
+
+// ------------------------------------------- formatDiffStatTotals
+//
+// formatDiffStatTotals(1, 5, 7) => "1 file changed, 5 insertions(+), 7 deletions(-)"
+// Zero counts are left out, as in git.
+func formatDiffStatTotals(fileCount, insertions, deletions int) string {
+	plural := func (count int, singular, plural string) string {
+		if count == 1 {
+			return fmt.Sprintf("%d %s", count, singular)
+		}
+		return fmt.Sprintf("%d %s", count, plural)
+	}
+	totals := plural(fileCount, "file changed", "files changed")
+	if insertions > 0 {
+		totals += ", " + plural(insertions, "insertion(+)", "insertions(+)")
+	}
+	if deletions > 0 {
+		totals += ", " + plural(deletions, "deletion(-)", "deletions(-)")
+	}
+	return totals
+}
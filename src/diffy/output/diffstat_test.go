@@ -0,0 +1,113 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestDiffStatCounts
+
+func TestDiffStatCounts(t *testing.T) {
+
+	// One line changed, one deleted, two inserted.
+	left, right := makeLines("a", "b", "c", "d"), makeLines("a", "B", "d", "e", "f")
+	alignment := &diff.Alignment{Links: []diff.Link{
+		{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0},
+		{LinkType: diff.Different, LeftIndex: 1, RightIndex: 1},
+		{LinkType: diff.LeftOnly, LeftIndex: 2, RightIndex: -1},
+		{LinkType: diff.Matching, LeftIndex: 3, RightIndex: 2},
+		{LinkType: diff.RightOnly, LeftIndex: -1, RightIndex: 3},
+		{LinkType: diff.RightOnly, LeftIndex: -1, RightIndex: 4},
+	}}
+	entry := NewDiffStatEntry(alignment, NewSourceLinesRec(left, "old.txt"), NewSourceLinesRec(right, "new.txt"))
+
+	if entry != (DiffStatEntry{Path: "old.txt => new.txt", Insertions: 3, Deletions: 2}) {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	var buffer bytes.Buffer
+	GenerateDiffStat(&buffer, []DiffStatEntry{entry}, DEFAULT_DIFFSTAT_BAR_WIDTH)
+	expected := " old.txt => new.txt | 5 +++--\n 1 file changed, 3 insertions(+), 2 deletions(-)\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+
+	// An unchanged file is left out, and with nothing changed there's nothing to write.
+	same := NewDiffStatEntry(&diff.Alignment{Links: alignment.Links[:1]}, NewSourceLinesRec(left[:1], "same.txt"), NewSourceLinesRec(right[:1], "same.txt"))
+	buffer.Reset()
+	GenerateDiffStat(&buffer, []DiffStatEntry{same, entry}, DEFAULT_DIFFSTAT_BAR_WIDTH)
+	if buffer.String() != expected {
+		t.Errorf("Expected the unchanged file to be left out, got\n%s", buffer.String())
+	}
+	buffer.Reset()
+	GenerateDiffStat(&buffer, []DiffStatEntry{same}, DEFAULT_DIFFSTAT_BAR_WIDTH)
+	if buffer.Len() != 0 {
+		t.Errorf("Expected nothing for an unchanged file, got\n%s", buffer.String())
+	}
+
+	// Only adding the final newline is a change.
+	noNewline := NewSourceLinesRec(left[:1], "same.txt")
+	noNewline.FinalNewline = false
+	if entry := NewDiffStatEntry(&diff.Alignment{Links: alignment.Links[:1]}, noNewline, NewSourceLinesRec(right[:1], "same.txt")); entry.Insertions != 1 || entry.Deletions != 1 {
+		t.Errorf("Expected a missing final newline to count as a change, got %+v", entry)
+	}
+
+	// A generated file is marked, but isn't in the totals.
+	buffer.Reset()
+	GenerateDiffStat(&buffer, []DiffStatEntry{entry, {Path: "gen.go", Generated: true}}, DEFAULT_DIFFSTAT_BAR_WIDTH)
+	expected = " old.txt => new.txt | 5 +++--\n gen.go             | generated\n 1 file changed, 3 insertions(+), 2 deletions(-)\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+}
+
+// ------------------------------------------- TestDiffStatScaling
+
+func TestDiffStatScaling(t *testing.T) {
+
+	entries := []DiffStatEntry{
+		{Path: "big.go", Insertions: 300, Deletions: 100},
+		{Path: "small.go", Insertions: 1, Deletions: 0},
+		{Path: "medium.go", Insertions: 20, Deletions: 20},
+	}
+
+	var buffer bytes.Buffer
+	GenerateDiffStat(&buffer, entries, 40)
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got\n%s", buffer.String())
+	}
+
+	barOf := func (line string) string {
+		fields := strings.Fields(line)
+		return fields[len(fields) - 1]
+	}
+
+	// The biggest entry fills the width, in proportion.
+	if bar := barOf(lines[0]); bar != strings.Repeat("+", 30) + strings.Repeat("-", 10) {
+		t.Errorf("Expected the biggest bar to be scaled to the width, got %q", bar)
+	}
+	// A small change still gets a mark.
+	if bar := barOf(lines[1]); bar != "+" {
+		t.Errorf("Expected a single mark for a tiny change, got %q", bar)
+	}
+	if bar := barOf(lines[2]); bar != "++--" {
+		t.Errorf("Expected the medium bar to be scaled down, got %q", bar)
+	}
+
+	// The columns line up.
+	if !strings.HasPrefix(lines[1], " small.go  |   1 ") || !strings.HasPrefix(lines[0], " big.go    | 400 ") {
+		t.Errorf("Expected aligned columns, got\n%s", buffer.String())
+	}
+	if lines[3] != " 3 files changed, 321 insertions(+), 120 deletions(-)" {
+		t.Errorf("Unexpected totals %q", lines[3])
+	}
+
+	// Small enough to fit, the bars are the counts.
+	if insertionBar, deletionBar := scaleDiffStatBars(3, 4, 7, 40); insertionBar != 3 || deletionBar != 4 {
+		t.Errorf("Expected unscaled bars, got %d and %d", insertionBar, deletionBar)
+	}
+}
@@ -0,0 +1,121 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"io"
+
+	"diffy/diff"
+)
+
+// "heatmap.go" - A debugging page showing how similar every line of one file
+// is to every line of the other as a heatmap, with the alignment outlined on
+// it, so it's plain to see why the lines were paired the way they were.
+
+// ------------------------------------------- CSS style definitions
+
+var heatmapTableStyle CssStyle = MakeCssStyle("heatmap-table",
+	"margin: 10px",
+	"border-collapse: collapse",
+	"font-family: monospace",
+	"font-size: 8pt",
+)
+
+var heatmapHeadingStyle CssStyle = MakeCssStyle("heatmap-heading",
+	"padding: 2px 4px",
+	"border: solid #696969 1px",
+	"background-color: #4682B4",
+	"color: white",
+	"white-space: pre",
+	"text-align: left",
+)
+
+var heatmapCellStyle CssStyle = MakeCssStyle("heatmap-cell",
+	"padding: 2px 4px",
+	"border: solid #D3D3D3 1px",
+	"text-align: right",
+)
+
+// The cells are shaded by similarity, from nothing in common to identical.
+var heatmapShadeStyles = []CssStyle{
+	MakeCssStyle("heatmap-shade-0", "background-color: #FFFFFF"),
+	MakeCssStyle("heatmap-shade-1", "background-color: #FFF3C4"),
+	MakeCssStyle("heatmap-shade-2", "background-color: #FFD98A"),
+	MakeCssStyle("heatmap-shade-3", "background-color: #FFB060"),
+	MakeCssStyle("heatmap-shade-4", "background-color: #F07830"),
+}
+
+// The pairs of lines the alignment chose.
+var heatmapPathStyle CssStyle = MakeCssStyle("heatmap-path",
+	"outline: solid black 2px",
+	"font-weight: bold",
+)
+
+// The widest a line's text gets in a row heading, in characters.
+const HEATMAP_LABEL_WIDTH = 30
+
+// ------------------------------------------- GenerateSimilarityHeatmap
+//
+// Generate a page with a table of "grid", the result of diff.SimilarityGrid on
+// the lines of "leftSource" and "rightSource": a row per left line, a column
+// per right line, and a cell per pair, shaded by how similar the lines are.
+// The cells of the pairs in "alignment" are outlined.  Each cell's title says
+// which lines it compares.
+//
+func GenerateSimilarityHeatmap(outputFile io.Writer, grid [][]float32, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) {
+
+	outputFile = generatePagePrologue(outputFile, opts)
+
+	type tPair struct {
+		leftIndex, rightIndex int
+	}
+	onPath := map[tPair]bool{}
+	for _, link := range alignment.Links {
+		if link.LeftIndex >= 0 && link.RightIndex >= 0 {
+			onPath[tPair{link.LeftIndex, link.RightIndex}] = true
+		}
+	}
+
+	title := fmt.Sprintf("How similar each line of %s (rows) is to each line of %s (columns); the pairs the alignment chose are outlined",
+		leftSource.GetFileName(), rightSource.GetFileName())
+	fmt.Fprintf(outputFile, "		%s\n", generateElement(opts, "div", html.EscapeString(title), matrixPairHeadingStyle))
+	fmt.Fprintf(outputFile, "		%s\n", generateStartTag(opts, "table", heatmapTableStyle))
+
+	// A column per right line, labeled with its line number, and its text as the title.
+	fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "th", "", heatmapHeadingStyle))
+	for j, line := range rightSource.Lines {
+		heading := generateElement(opts, "th", fmt.Sprint(j + 1), heatmapHeadingStyle)
+		fmt.Fprintf(outputFile, "				%s\n", setElementTitle(heading, "th", line.Text))
+	}
+	fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
+
+	// A row per left line, labeled with its line number and (the start of) its text.
+	for i, row := range grid {
+		fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+		label := fmt.Sprintf("%d %s", i + 1, leftSource.Lines[i].Stringify(HEATMAP_LABEL_WIDTH))
+		heading := generateElement(opts, "th", html.EscapeString(label), heatmapHeadingStyle)
+		fmt.Fprintf(outputFile, "				%s\n", setElementTitle(heading, "th", leftSource.Lines[i].Text))
+		for j, similarity := range row {
+			cell := generateElement(opts, "td", fmt.Sprintf("%.2f", similarity), heatmapCellStyle, heatmapShadeStyle(similarity), heatmapPathStyle.when(onPath[tPair{i, j}]))
+			fmt.Fprintf(outputFile, "				%s\n", setElementTitle(cell, "td", fmt.Sprintf("left %d vs right %d: %.2f similar", i + 1, j + 1, similarity)))
+		}
+		fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
+	}
+	fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
+
+	generatePageEpilogue(outputFile, opts)
+}
+
+// ------------------------------------------- heatmapShadeStyle
+
+// heatmapShadeStyle(0.0) => heatmapShadeStyles[0], heatmapShadeStyle(1.0) => the last
+func heatmapShadeStyle(similarity float32) CssStyle {
+	shade := int(similarity * float32(len(heatmapShadeStyles)))
+	if shade < 0 {
+		shade = 0
+	} else if shade >= len(heatmapShadeStyles) {
+		shade = len(heatmapShadeStyles) - 1
+	}
+	return heatmapShadeStyles[shade]
+}
@@ -0,0 +1,39 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestSimilarityHeatmap
+
+func TestSimilarityHeatmap(t *testing.T) {
+
+	left := makeLines("one", "two <2>", "three")
+	right := makeLines("one", "three")
+	_, alignment := diff.Diff_v2(left, right)
+	grid := diff.SimilarityGrid(left, right)
+
+	var buffer bytes.Buffer
+	GenerateSimilarityHeatmap(&buffer, grid, alignment, NewSourceLinesRec(left, "left.txt"), NewSourceLinesRec(right, "right.txt"), HtmlOptions{})
+	page := buffer.String()
+
+	// A cell per pair of lines, m * n of them.
+	if count := strings.Count(page, "<td "); count != len(left) * len(right) {
+		t.Errorf("Expected %d cells, got %d", len(left) * len(right), count)
+	}
+	if !strings.Contains(page, "title='left 3 vs right 2: 1.00 similar'") {
+		t.Errorf("Expected a titled cell for the third and second lines, got:\n%s", page)
+	}
+
+	// The pairs the alignment chose are outlined, and the line text is escaped.
+	if count := strings.Count(page, heatmapPathStyle.properties[0]); count != 2 {
+		t.Errorf("Expected 2 outlined cells, got %d", count)
+	}
+	if !strings.Contains(page, "two &lt;2&gt;") {
+		t.Errorf("Expected the left line's text to be escaped, got:\n%s", page)
+	}
+}
@@ -0,0 +1,65 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "histogram.go" - The similarities of the changed pairs of lines as a text
+// histogram, for choosing a realign threshold to suit a kind of file.
+
+// The widest bar, in characters.  Bigger buckets are scaled down to fit.
+const DEFAULT_HISTOGRAM_BAR_WIDTH = 50
+
+// ------------------------------------------- GenerateSimilarityHistogram
+//
+// Write one line per bucket, least similar first, and a line saying where
+// "threshold" splits pairs for display, e.g.
+//
+//     0.00-0.10 |   2 ##
+//     ...
+//     0.90-1.00 |  14 ##############
+//     16 changed pairs; pairs less similar than 0.60 are split for display
+//
+// The bucket the threshold falls in is marked with a "<".
+func GenerateSimilarityHistogram(outputFile io.Writer, histogram *diff.SimilarityHistogram, threshold float32, barWidth int) {
+
+	maxCount, countWidth := 0, 1
+	for _, count := range histogram.Counts {
+		if count > maxCount {
+			maxCount = count
+		}
+		if width := len(fmt.Sprint(count)); width > countWidth {
+			countWidth = width
+		}
+	}
+
+	// A pair is split when its cost is more than the threshold.
+	splitBelow := 1.0 - threshold
+	bucketCount := len(histogram.Counts)
+	for bucket, count := range histogram.Counts {
+		bar := count
+		if maxCount > barWidth {
+			bar = (count * barWidth * 2 + maxCount) / (maxCount * 2)	// rounded to the nearest
+			if bar < 1 && count > 0 {
+				bar = 1
+			}
+		}
+		line := fmt.Sprintf("%.2f-%.2f | %*d %s", float32(bucket) / float32(bucketCount), float32(bucket + 1) / float32(bucketCount),
+			countWidth, count, strings.Repeat("#", bar))
+		if bucket == histogram.Bucket(splitBelow) {
+			line += " <"
+		}
+		fmt.Fprintln(outputFile, strings.TrimRight(line, " "))
+	}
+
+	total := histogram.Total()
+	pairs := "changed pairs"
+	if total == 1 {
+		pairs = "changed pair"
+	}
+	fmt.Fprintf(outputFile, "%d %s; pairs less similar than %.2f are split for display\n", total, pairs, splitBelow)
+}
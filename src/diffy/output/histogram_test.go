@@ -0,0 +1,27 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestSimilarityHistogram
+
+func TestSimilarityHistogram(t *testing.T) {
+
+	histogram := &diff.SimilarityHistogram{Counts: []int{1, 0, 12, 100}}
+
+	var buffer bytes.Buffer
+	GenerateSimilarityHistogram(&buffer, histogram, 0.4, 10)
+	expected := "" +
+		"0.00-0.25 |   1 #\n" +
+		"0.25-0.50 |   0\n" +
+		"0.50-0.75 |  12 # <\n" +
+		"0.75-1.00 | 100 ##########\n" +
+		"113 changed pairs; pairs less similar than 0.60 are split for display\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+}
@@ -0,0 +1,46 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"io"
+
+	"diffy/diff"
+)
+
+// "html-fragment.go" - The side-by-side diff as an HTML fragment, for pasting
+// into a GitHub or GitLab comment, where it shows up collapsed.
+
+// ------------------------------------------- GenerateHtmlFragment
+//
+// Generate the same rows as GenerateHtmlDiffPage, wrapped in a "<details>"
+// element whose summary is the file name, with no document structure around
+// it.  Markdown sanitizers strip style sheets, head content, and the like, so
+// the fragment relies on inline styles only: the "HeadExtra" and "Breakpoint"
+// options are ignored.  "BodyPrefix" and "BodySuffix" go just inside the
+// "<details>" element.  With "ShowStats", the summary also gives the
+// percentage of all the lines which changed.
+//
+func GenerateHtmlFragment(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) {
+
+	opts.HeadExtra, opts.Breakpoint = "", 0
+
+	summary := rightSource.GetFileName()
+	if leftSource.GetFileName() != summary {
+		summary = leftSource.GetFileName() + " → " + summary
+	}
+	if opts.ShowStats {
+		summary += fmt.Sprintf(" · %.1f%% changed", ComputeChangeStats(alignment).TotalPercent())
+	}
+
+	fmt.Fprintln(outputFile, "<details>")
+	fmt.Fprintf(outputFile, "<summary>%s</summary>\n", html.EscapeString(summary))
+	if opts.BodyPrefix != "" {
+		fmt.Fprintln(outputFile, opts.BodyPrefix)
+	}
+	generateHtmlDiffTables(outputFile, alignment, leftSource, rightSource, opts)
+	if opts.BodySuffix != "" {
+		fmt.Fprintln(outputFile, opts.BodySuffix)
+	}
+	fmt.Fprintln(outputFile, "</details>")
+}
@@ -0,0 +1,34 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestHtmlFragment
+
+func TestHtmlFragment(t *testing.T) {
+
+	leftLines, rightLines := makeLines("a", "b"), makeLines("a", "c")
+	_, alignment := diff.Diff_v2(leftLines, rightLines)
+
+	var buffer bytes.Buffer
+	opts := HtmlOptions{HeadExtra: "<link rel='stylesheet' href='x.css'>", Breakpoint: 800}
+	GenerateHtmlFragment(&buffer, alignment, NewSourceLinesRec(leftLines, "a/main.go"), NewSourceLinesRec(rightLines, "b/main.go"), opts)
+	fragment := buffer.String()
+
+	if !strings.HasPrefix(fragment, "<details>\n<summary>main.go</summary>\n") || !strings.HasSuffix(fragment, "</details>\n") {
+		t.Errorf("Expected the fragment to be a details element summarized by the file name, got:\n%s", fragment)
+	}
+	for _, unwanted := range []string{"<!DOCTYPE", "<html", "<head", "<body", "<script", "<style", "<link", "class="} {
+		if strings.Contains(fragment, unwanted) {
+			t.Errorf("Expected no %q in the fragment", unwanted)
+		}
+	}
+	if !strings.Contains(fragment, "style='") {
+		t.Errorf("Expected inline styles in the fragment")
+	}
+}
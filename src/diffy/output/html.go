@@ -3,23 +3,37 @@ package output
 import (
 	"fmt"
 	"html"
-	"os"
+	"io"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"diffy/diff"
+	"diffy/etc"
 )
 
 // ------------------------------------------- type SourceLinesRec
 
 type SourceLinesRec struct {
 	Lines diff.ComparableLines
+	Items diff.ComparableSequence	// what an adapter compared, which the lines were rendered from; nil if the lines were compared
 	FilePath string
+	FinalNewline bool		// does the file end with a newline?  (empty files count as yes)
+	Generated bool			// was the file generated, and so skipped?  (see diff.IsGenerated)
 }
 
 func NewSourceLinesRec(lines diff.ComparableLines, filePath string) *SourceLinesRec {
-	return &SourceLinesRec{Lines: lines, FilePath: filePath}
+	return &SourceLinesRec{Lines: lines, FilePath: filePath, FinalNewline: true}
+}
+
+// What the lines were compared as, which is what realigning and confidences
+// have to go by: the adapted items, if any, otherwise the lines themselves.
+func (source *SourceLinesRec) Compared() diff.ComparableSequence {
+	if source.Items != nil {
+		return source.Items
+	}
+	return source.Lines
 }
 
 func (source *SourceLinesRec) GetFileName() string {
@@ -34,13 +48,246 @@ func (source *SourceLinesRec) GetAbsoluteFilePath() string {
 	return absolutePath
 }
 
+// ------------------------------------------- type PathDisplay
+//
+// What the HTML headings show under each file name.  Absolute paths give
+// away user names and directory layouts, which isn't always wanted in a
+// report that's going to be shared.
+
+type PathDisplay int
+
+const (
+	PathAbsolute PathDisplay = iota	// the absolute path
+	PathRelative					// the path relative to the base directory
+	PathNameOnly					// nothing beyond the file name
+)
+
+var pathDisplayNames = map[string]PathDisplay{
+	"absolute": PathAbsolute,
+	"relative": PathRelative,
+	"name-only": PathNameOnly,
+}
+
+// Look up a PathDisplay by its "--path-display" name.
+func ParsePathDisplay(name string) (PathDisplay, bool) {
+	display, found := pathDisplayNames[name]
+	return display, found
+}
+
+// ------------------------------------------- type Focus
+//
+// Which one direction of change, if any, a review pass is focused on.  The
+// focused lines stand out and every other line is dimmed.
+
+type Focus int
+
+const (
+	FocusNone Focus = iota	// no focus; all changes look alike
+	FocusAdded				// lines only in the right file
+	FocusRemoved			// lines only in the left file
+)
+
+var focusNames = map[string]Focus{
+	"": FocusNone,
+	"added": FocusAdded,
+	"removed": FocusRemoved,
+}
+
+// Look up a Focus by its "--only" name.
+func ParseFocus(name string) (Focus, bool) {
+	focus, found := focusNames[name]
+	return focus, found
+}
+
+// The type of the links a Focus picks out.
+func (focus Focus) LinkType() diff.LinkType {
+	switch focus {
+	case FocusAdded:
+		return diff.RightOnly
+	case FocusRemoved:
+		return diff.LeftOnly
+	default:
+		panic("not reached")
+	}
+}
+
+// ------------------------------------------- type GroupBy
+//
+// How the changed lines are organized: in file order, or in sections by the
+// type of change, for a survey of what a change added, removed or modified.
+
+type GroupBy int
+
+const (
+	GroupByNone GroupBy = iota	// in file order, with the matching lines
+	GroupByType					// "Added", "Removed", and "Modified" sections of changed lines only
+)
+
+var groupByNames = map[string]GroupBy{
+	"": GroupByNone,
+	"type": GroupByType,
+}
+
+// Look up a GroupBy by its "--group-by" name.
+func ParseGroupBy(name string) (GroupBy, bool) {
+	groupBy, found := groupByNames[name]
+	return groupBy, found
+}
+
+// The sections for GroupByType, in order, each holding the links of one type.
+var changeTypeSections = []diff.LinkType{diff.RightOnly, diff.LeftOnly, diff.Different}
+
+// formatSectionTitle(diff.RightOnly, 3) => "Added (3 lines)"
+func formatSectionTitle(linkType diff.LinkType, lineCount int) string {
+	var title string
+	switch linkType {
+	case diff.RightOnly:
+		title = "Added"
+	case diff.LeftOnly:
+		title = "Removed"
+	case diff.Different:
+		title = "Modified"
+	default:
+		panic("not reached")
+	}
+	if lineCount == 1 {
+		return title + " (1 line)"
+	}
+	return fmt.Sprintf("%s (%d lines)", title, lineCount)
+}
+
+// The id of a section's title, e.g. "section-added".
+func sectionId(linkType diff.LinkType) string {
+	return "section-" + strings.ToLower(strings.Fields(formatSectionTitle(linkType, 0))[0])
+}
+
+// ------------------------------------------- SourceLinesRec GetDisplayPath method
+//
+// The path to show under the file name, or "" for none.  Relative paths are
+// relative to "baseDir", or the current directory if it's empty.  A file
+// outside the base directory just shows its name, rather than a path full of
+// ".." which gives away as much as the absolute path would.
+func (source *SourceLinesRec) GetDisplayPath(display PathDisplay, baseDir string) string {
+	switch display {
+	case PathAbsolute:
+		return source.GetAbsoluteFilePath()
+	case PathRelative:
+		if baseDir == "" {
+			baseDir = "."
+		}
+		absoluteBaseDir, err := filepath.Abs(baseDir)
+		if err != nil {
+			return source.GetFileName()
+		}
+		relativePath, err := filepath.Rel(absoluteBaseDir, source.GetAbsoluteFilePath())
+		if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".." + string(filepath.Separator)) {
+			return source.GetFileName()
+		}
+		return relativePath
+	case PathNameOnly:
+		return ""
+	}
+	panic("not reached")
+}
+
+// ------------------------------------------- HasDifferences
+//
+// Report whether two sources differ, either in their lines or in whether they
+// end with a newline.  This is what determines the exit code.
+func HasDifferences(alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec) bool {
+	for _, link := range alignment.Links {
+		if link.LinkType != diff.Matching {
+			return true
+		}
+	}
+	return leftSource.FinalNewline != rightSource.FinalNewline
+}
+
+// ------------------------------------------- type HtmlOptions
+//
+// HtmlOptions records control the optional parts of the generated HTML page.
+// The zero value gives you the default page.
+//
+// The "HeadExtra", "BodyPrefix", and "BodySuffix" strings are emitted verbatim,
+// without any escaping, so it is up to the caller to make sure they're safe.
+// They make it possible to inject custom CSS, a site header, or a wrapper
+// element without templating the whole page.
+
+type HtmlOptions struct {
+	HeadExtra string		// emitted just before "</head>"
+	Charset string			// the page's character set, CHARSET_UTF8 (the default if empty) or CHARSET_LATIN1
+	Bom bool				// start a UTF-8 page with a byte order mark
+	BodyPrefix string		// emitted just after "<body>"
+	BodySuffix string		// emitted just before "</body>"
+	DetectIndentChange bool	// badge lines whose only change is tabs-vs-spaces indentation
+	DetectBlockIndent bool	// badge matching lines whose indentation was shifted along with their block
+	PreserveTabs bool		// show lines with their original tabs, rather than expanded
+	TabGuides bool			// with PreserveTabs, draw each tab as a faint indentation guide
+	TabSize int				// the CSS "tab-size" to use when preserving tabs
+	RightTabSize int		// if positive, the right side's tab size, when it differs from the left's
+	Breakpoint int			// if positive, switch to an inline view on viewports narrower than this, in pixels
+	AdaptiveRealign *diff.AdaptiveThreshold	// if set, scale the realign threshold with the files' similarity
+	Compare diff.CompareFunc	// compares pairs of lines when realigning, e.g. a SimilarityCache's Compare; nil compares them directly
+	HunkContext *diff.HunkContext	// the unchanged lines kept around each change in hunks; DEFAULT_CONTEXT either side if nil
+	WholeWordHighlight bool	// widen intra-line highlights to cover whole words
+	WordChars diff.WordChars	// what whole words are made of; nil for diff.CodeWordChars
+	GradedRuns bool			// shade each changed run within a line by its size
+	RunTooltips bool		// give each changed run within a line a tooltip with its size and the lines' similarity
+	ShowStats bool			// show each file's line count and percentage of lines changed in the heading
+	PathDisplay PathDisplay	// which path to show under each file name in the heading
+	BaseDir string			// what PathRelative paths are relative to; the current directory if empty
+	LineIdPrefix string		// prepended to the line ids, to keep them unique when there are several diffs on a page
+	Focus Focus				// emphasize only the added or only the removed lines, and dim the rest
+	NoRealign bool			// show the alignment exactly as given, without splitting dissimilar pairs
+	GroupChanges bool		// draw each run of changed lines as one bordered block, rather than row by row
+	CssFile string			// if set, link to this style sheet and use class names in place of the default inline styles
+	StrippedPrefix string	// shown once under the heading, having been stripped from every line of both files
+	StrippedSuffix string	// likewise
+	GroupBy GroupBy			// show the changed lines in file order, or in sections by the type of change
+	StableLineIds bool		// give each line a "data-line-id" based on its content, which survives lines added above it
+	CompactDiff bool		// show a long start or end shared by a changed pair of lines once, below the pair, rather than on both sides
+}
+
+func (opts HtmlOptions) charset() string {
+	if opts.Charset == "" {
+		return CHARSET_UTF8
+	}
+	return opts.Charset
+}
+
+// The context of a hunk, which is DEFAULT_CONTEXT lines either side unless
+// "HunkContext" says otherwise.
+func (opts HtmlOptions) EffectiveHunkContext() diff.HunkContext {
+	if opts.HunkContext == nil {
+		return diff.DefaultHunkContext
+	}
+	return *opts.HunkContext
+}
+
+// How to compare pairs of lines, which is directly unless "Compare" says otherwise.
+func (opts HtmlOptions) CompareFunc() diff.CompareFunc {
+	if opts.Compare == nil {
+		return diff.DirectCompare
+	}
+	return opts.Compare
+}
+
+func (opts HtmlOptions) rightTabSize() int {
+	if opts.RightTabSize > 0 {
+		return opts.RightTabSize
+	}
+	return opts.TabSize
+}
+
 // ------------------------------------------- type CssStyle
 //
 // CssStyle records represent a CSS "style", which for our purposes is just
 // a list of CSS properties and their values, with each property/value pair
 // represented as a single string.  Multiple CssStyle records can be 
-// combined into a single inline HTML "style" attribute.  In the future
-// there may be support for generating a proper CSS style sheet.
+// combined into a single inline HTML "style" attribute, or written out as
+// a style sheet with a rule per class name (see "stylesheet.go").  A style
+// without a class name, such as one computed for the page, is only ever
+// used inline.
 
 type CssStyle struct{
 	className string
@@ -48,10 +295,12 @@ type CssStyle struct{
 }
 
 func MakeCssStyle(className string, properties ...string) CssStyle {
-	return CssStyle{
+	style := CssStyle{
 		className:className,
 		properties:properties,
 	}
+	registerCssStyle(style)
+	return style
 }
 
 func ConcatCssStyles(styles ...CssStyle) string {
@@ -70,6 +319,14 @@ func (style CssStyle) when(cond bool) CssStyle {
 	}
 }
 
+// ------------------------------------------- constants
+
+const NO_NEWLINE_NOTE = "\\ No newline at end of file"
+
+const LEFT_EMPTY_NOTE = "Left file is empty"
+const RIGHT_EMPTY_NOTE = "Right file is empty"
+const BOTH_EMPTY_NOTE = "Both files are empty, identical"
+
 // ------------------------------------------- CSS style definitions
 
 // ........................................... null style
@@ -148,6 +405,11 @@ var codeLineNoneStyle CssStyle = MakeCssStyle("code-line-none",
 	"background-color: #F0F0F0",
 )
 
+// With "GroupChanges", a whole run of changed lines is one table with one border.
+var changeBlockStyle CssStyle = MakeCssStyle("change-block",
+	"border: solid #DAA520 1px",
+)
+
 var twoLineDiffGutterStyle CssStyle = MakeCssStyle("two-line-diff-gutter",
 	"height: 3px",
 	"width: 1px",
@@ -159,56 +421,307 @@ var codeRunDifferentStyle CssStyle = MakeCssStyle("code-run-different",
 	"background-color: lightgreen",
 )
 
+// With "GradedRuns", changed runs are shaded by size: one character, two or
+// three, four to seven, and eight or more.  The middle shade is the usual
+// "codeRunDifferentStyle" color.
+var codeRunGradedStyles = []CssStyle{
+	MakeCssStyle("code-run-different-1", "background-color: #D8F8D8"),
+	MakeCssStyle("code-run-different-2", "background-color: #B4F0B4"),
+	MakeCssStyle("code-run-different-3", "background-color: lightgreen"),
+	MakeCssStyle("code-run-different-4", "background-color: #5CD65C"),
+}
+
+// With "TabGuides", each preserved tab is drawn with a faint line at its left
+// edge, so indentation levels line up visibly.  An inset shadow doesn't take
+// up any room, so the tab stops stay where "tab-size" puts them.
+var tabGuideStyle CssStyle = MakeCssStyle("tab-guide",
+	"box-shadow: inset 1px 0 0 rgba(0, 0, 0, 0.2)",
+)
+
+// With a "Focus", the lines focused on are emphasized and all the others,
+// including the other changes, fade into the background.
+var codeLineFocusStyle CssStyle = MakeCssStyle("code-line-focus",
+	"font-weight: bold",
+	"box-shadow: inset 3px 0 0 #FF8C00",
+)
+
+var codeLineDimmedStyle CssStyle = MakeCssStyle("code-line-dimmed",
+	"opacity: 0.4",
+)
+
+var noNewlineNoteStyle CssStyle = MakeCssStyle("no-newline-note",
+	"color: #696969",
+	"font-style: italic",
+)
+
+var emptyFileNoteStyle CssStyle = MakeCssStyle("empty-file-note",
+	"color: #696969",
+	"font-style: italic",
+	"font-weight: bold",
+)
+
+var sectionTitleStyle CssStyle = MakeCssStyle("section-title",
+	"margin-top: 10px",
+	"padding: 3px",
+	"font-family: monospace",
+	"font-weight: bold",
+	"border-bottom: solid #696969 1px",
+)
+
+var strippedAffixStyle CssStyle = MakeCssStyle("stripped-affix",
+	"padding: 3px",
+	"color: #696969",
+	"font-family: monospace",
+	"white-space: pre",
+)
+
+var indentChangeBadgeStyle CssStyle = MakeCssStyle("indent-change-badge",
+	"float: right",
+	"padding-left: 3px",
+	"padding-right: 3px",
+	"border-radius: 3px",
+	"background-color: #B0C4DE",
+	"color: black",
+	"font-family: sans-serif",
+	"font-size: 7pt",
+)
+
 // ------------------------------------------- GenerateHtmlDiffPage
 //
-func GenerateHtmlDiffPage(outputFile *os.File, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec) {
-
-	// Re-jigger the alignment to make it more suitable for display.
-	alignment = alignment.RealignUsingThreshold(leftSource.Lines, rightSource.Lines, 0.4)
+func GenerateHtmlDiffPage(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) {
+	outputFile = generatePagePrologue(outputFile, opts)
+	generateHtmlDiffTables(outputFile, alignment, leftSource, rightSource, opts)
+	generatePageEpilogue(outputFile, opts)
+}
 
-	// Print the page prologue.
+// ------------------------------------------- generatePagePrologue
+//
+// Everything up to and including the "<body>" tag.  The rest of the page is
+// written to the writer returned, which takes care of the character set.
+func generatePagePrologue(outputFile io.Writer, opts HtmlOptions) io.Writer {
+	if opts.Bom && opts.charset() == CHARSET_UTF8 {
+		fmt.Fprint(outputFile, UTF8_BOM)
+	}
+	outputFile = newCharsetWriter(outputFile, opts.charset())
 	fmt.Fprintln(outputFile, "<!DOCTYPE html>")
 	fmt.Fprintln(outputFile, "<html>")
 	fmt.Fprintln(outputFile, "	<head>")
 	fmt.Fprintln(outputFile, "		<title>Diff</title>")
 	fmt.Fprintln(outputFile, "")
-	fmt.Fprintln(outputFile, "		<meta charset=\"utf-8\"/>")
+	fmt.Fprintf(outputFile, "		<meta charset=\"%s\"/>\n", opts.charset())
+	if opts.Breakpoint > 0 {
+		fmt.Fprintln(outputFile, "		<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\"/>")
+		if opts.CssFile == "" {
+			fmt.Fprint(outputFile, generateResponsiveStyleSheet(opts.Breakpoint))
+		}
+	}
+	if opts.CssFile != "" {
+		fmt.Fprintf(outputFile, "		<link rel=\"stylesheet\" href=\"%s\"/>\n", html.EscapeString(opts.CssFile))
+	}
+	if opts.HeadExtra != "" {
+		fmt.Fprintln(outputFile, opts.HeadExtra)
+	}
 	fmt.Fprintln(outputFile, "	</head>")
 	fmt.Fprintln(outputFile, "	<body>")
+	if opts.BodyPrefix != "" {
+		fmt.Fprintln(outputFile, opts.BodyPrefix)
+	}
+	return outputFile
+}
+
+// ------------------------------------------- generatePageEpilogue
+//
+// Everything from the "</body>" tag on.
+func generatePageEpilogue(outputFile io.Writer, opts HtmlOptions) {
+	if opts.BodySuffix != "" {
+		fmt.Fprintln(outputFile, opts.BodySuffix)
+	}
+	fmt.Fprintln(outputFile, "	</body>")
+	fmt.Fprintln(outputFile, "</html>")
+	flushCharsetWriter(outputFile)
+}
+
+// ------------------------------------------- EmptyInputsNote
+
+// Explain a diff with an empty file on one side or both, or return "" if
+// neither file is empty.  Either way, the diff itself is trivial.
+func EmptyInputsNote(leftLineCount, rightLineCount int) string {
+	switch {
+	case leftLineCount == 0 && rightLineCount == 0:
+		return BOTH_EMPTY_NOTE
+	case leftLineCount == 0:
+		return LEFT_EMPTY_NOTE
+	case rightLineCount == 0:
+		return RIGHT_EMPTY_NOTE
+	}
+	return ""
+}
+
+// ------------------------------------------- DescribeStrippedAffix
+
+// DescribeStrippedAffix("[INFO] ", "") => "Stripped from every line: prefix \"[INFO] \""
+// Nothing stripped is "".
+func DescribeStrippedAffix(prefix, suffix string) string {
+	var parts []string
+	if prefix != "" {
+		parts = append(parts, fmt.Sprintf("prefix %q", prefix))
+	}
+	if suffix != "" {
+		parts = append(parts, fmt.Sprintf("suffix %q", suffix))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Stripped from every line: " + strings.Join(parts, ", ")
+}
+
+// ------------------------------------------- generateNoteRow
+
+// A row with no line numbers, just a note under one side's code or both.
+func generateNoteRow(outputFile io.Writer, leftNoteHtml, rightNoteHtml string, opts HtmlOptions) {
+	fmt.Fprintf(outputFile, "		%s\n", generateStartTag(opts, "table", twoLineDiffStyle))
+	fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", lineNumStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", leftNoteHtml, codeLineStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", twoLineDiffGutterStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", rightNoteHtml, codeLineStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", lineNumStyle))
+	fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
+	fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
+	fmt.Fprintln(outputFile, "")
+}
+
+// ------------------------------------------- generateHtmlDiffTables
+//
+// The side-by-side diff itself: the file name headings followed by one table
+// per pair of lines.  This is the body of a diff page, and can be repeated to
+// put several diffs on one page.
+func generateHtmlDiffTables(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) {
+
+	// Re-jigger the alignment to make it more suitable for display.
+	alignment = realignForDisplay(alignment, leftSource, rightSource, opts)
 
 	// Print the heading.
+	stats := ComputeChangeStats(alignment)
 	fmt.Fprintln(outputFile, "")
 
-	fmt.Fprintf(outputFile, "		%s\n", generateStartTag("table", titleHeadingsTableStyle))
-	fmt.Fprintf(outputFile, "			%s\n", generateStartTag("tr"))
-	fmt.Fprintf(outputFile, "				%s\n", generateStartTag("td", titleHeadingBoxStyle))
-	fmt.Fprintf(outputFile, "					%s\n", generateElement("div", leftSource.GetFileName(), headingTitleStyle))
-	fmt.Fprintf(outputFile, "					%s\n", generateElement("div", leftSource.GetAbsoluteFilePath(), headingSubtitleStyle))
+	fmt.Fprintf(outputFile, "		%s\n", generateStartTag(opts, "table", titleHeadingsTableStyle))
+	fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+	fmt.Fprintf(outputFile, "				%s\n", generateStartTag(opts, "td", titleHeadingBoxStyle))
+	fmt.Fprintf(outputFile, "					%s\n", generateElement(opts, "div", leftSource.GetFileName(), headingTitleStyle))
+	if path := leftSource.GetDisplayPath(opts.PathDisplay, opts.BaseDir); path != "" {
+		fmt.Fprintf(outputFile, "					%s\n", generateElement(opts, "div", path, headingSubtitleStyle))
+	}
+	if opts.ShowStats {
+		fmt.Fprintf(outputFile, "					%s\n", generateElement(opts, "div", formatLineStats(stats.LeftLines, stats.LeftChanged), headingSubtitleStyle))
+	}
 	fmt.Fprintf(outputFile, "				%s\n", generateEndTag("td"))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", twoLineDiffGutterStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateStartTag("td", titleHeadingBoxStyle))
-	fmt.Fprintf(outputFile, "					%s\n", generateElement("div", rightSource.GetFileName(), headingTitleStyle))
-	fmt.Fprintf(outputFile, "					%s\n", generateElement("div", rightSource.GetAbsoluteFilePath(), headingSubtitleStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", twoLineDiffGutterStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateStartTag(opts, "td", titleHeadingBoxStyle))
+	fmt.Fprintf(outputFile, "					%s\n", generateElement(opts, "div", rightSource.GetFileName(), headingTitleStyle))
+	if path := rightSource.GetDisplayPath(opts.PathDisplay, opts.BaseDir); path != "" {
+		fmt.Fprintf(outputFile, "					%s\n", generateElement(opts, "div", path, headingSubtitleStyle))
+	}
+	if opts.ShowStats {
+		fmt.Fprintf(outputFile, "					%s\n", generateElement(opts, "div", formatLineStats(stats.RightLines, stats.RightChanged), headingSubtitleStyle))
+	}
 	fmt.Fprintf(outputFile, "				%s\n", generateEndTag("td"))
 	fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
 	fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
 	fmt.Fprintln(outputFile, "")
 
+	// Text stripped from every line is shown just the once.
+	if description := DescribeStrippedAffix(opts.StrippedPrefix, opts.StrippedSuffix); description != "" {
+		fmt.Fprintf(outputFile, "		%s\n", generateElement(opts, "div", html.EscapeString(description), strippedAffixStyle))
+		fmt.Fprintln(outputFile, "")
+	}
+
 	// Generate an empty initial "code-line" table to provide some extra spacing.
-	fmt.Fprintf(outputFile, "		%s\n", generateStartTag("table", twoLineDiffStyle))
-	fmt.Fprintf(outputFile, "			%s\n", generateStartTag("tr"))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", lineNumStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", codeLineStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", twoLineDiffGutterStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", codeLineStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", lineNumStyle))
+	fmt.Fprintf(outputFile, "		%s\n", generateStartTag(opts, "table", twoLineDiffStyle))
+	fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", lineNumStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", codeLineStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", twoLineDiffGutterStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", codeLineStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", lineNumStyle))
+	fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
+	fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
+	fmt.Fprintln(outputFile, "")
+
+	// An empty file gets an explicit note in place of its (missing) lines.
+	if note := EmptyInputsNote(len(leftSource.Lines), len(rightSource.Lines)); note != "" {
+		leftNoteHtml, rightNoteHtml := "", ""
+		if len(leftSource.Lines) == 0 {
+			leftNoteHtml = generateElement(opts, "span", html.EscapeString(note), emptyFileNoteStyle)
+		}
+		if len(rightSource.Lines) == 0 {
+			rightNoteHtml = generateElement(opts, "span", html.EscapeString(note), emptyFileNoteStyle)
+		}
+		generateNoteRow(outputFile, leftNoteHtml, rightNoteHtml, opts)
+	}
+
+	// The lines themselves, in order or in sections by the type of change.
+	if opts.GroupBy == GroupByType {
+		sections := diff.GroupByLinkType(alignment, changeTypeSections...)
+		for index, section := range sections {
+			title := formatSectionTitle(changeTypeSections[index], len(section.Links))
+			fmt.Fprintf(outputFile, "		%s\n", setElementId(generateElement(opts, "div", title, sectionTitleStyle), "div", opts.LineIdPrefix + sectionId(changeTypeSections[index])))
+			generateHtmlDiffRows(outputFile, section, leftSource, rightSource, opts)
+			fmt.Fprintln(outputFile, "")
+		}
+	} else {
+		generateHtmlDiffRows(outputFile, alignment, leftSource, rightSource, opts)
+	}
+	fmt.Fprintln(outputFile, "")
+
+	// Like GNU diff, point out a file which is missing its final newline when the other file isn't.
+	if leftSource.FinalNewline != rightSource.FinalNewline {
+		leftNoteHtml, rightNoteHtml := "", ""
+		if !leftSource.FinalNewline {
+			leftNoteHtml = generateElement(opts, "span", html.EscapeString(NO_NEWLINE_NOTE), noNewlineNoteStyle)
+		}
+		if !rightSource.FinalNewline {
+			rightNoteHtml = generateElement(opts, "span", html.EscapeString(NO_NEWLINE_NOTE), noNewlineNoteStyle)
+		}
+		generateNoteRow(outputFile, leftNoteHtml, rightNoteHtml, opts)
+	}
+
+	// Generate an empty final "code-line" table to provide some extra spacing.
+	fmt.Fprintf(outputFile, "		%s\n", generateStartTag(opts, "table", twoLineDiffStyle))
+	fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", lineNumStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", codeLineStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", twoLineDiffGutterStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", codeLineStyle))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", "", lineNumStyle))
 	fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
 	fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
 	fmt.Fprintln(outputFile, "")
+}
+
+// ------------------------------------------- generateHtmlDiffRows
+//
+// One table per pair of lines in "alignment", which has already been realigned.
+func generateHtmlDiffRows(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) {
+
+	// Preserved tabs are sized by the browser.
+	makeTabSizeStyle := func (tabSize int) CssStyle {
+		if !opts.PreserveTabs || tabSize <= 0 {
+			return nullStyle
+		}
+		return MakeCssStyle("", fmt.Sprintf("tab-size: %d", tabSize), fmt.Sprintf("-moz-tab-size: %d", tabSize))
+	}
+	leftTabSizeStyle, rightTabSizeStyle := makeTabSizeStyle(opts.TabSize), makeTabSizeStyle(opts.rightTabSize())
+	leftLineIds, rightLineIds := stableLineIds(leftSource, opts), stableLineIds(rightSource, opts)
+
+	// With "GroupChanges", a run of changed lines shares one table, so it reads as one block.
+	isChange := func (index int) bool {
+		return opts.GroupChanges && index >= 0 && index < len(alignment.Links) && alignment.Links[index].LinkType != diff.Matching
+	}
 
 	// For each link in the alignment generate a side-by-side diff of the corresponding
 	// pair of lines.  We will just use blank lines when one line is missing.
-	for _, link := range alignment.Links {
+	for linkIndex, link := range alignment.Links {
 
 		// Figure out what type of link we've got.
 		var leftItem, rightItem diff.Comparable = nil, nil
@@ -224,30 +737,59 @@ func GenerateHtmlDiffPage(outputFile *os.File, alignment *diff.Alignment, leftSo
 		}
 
 		// Generate the HTML for the left and right lines.
-		leftHtml, rightHtml := "", ""
+		sharedHtml, leftHtml, rightHtml := "", "", ""
 		if link.LinkType == diff.Different {
-			leftHtml, rightHtml = generateLineHtml(leftItem.(*diff.TextLine).Text, rightItem.(*diff.TextLine).Text)
+			sharedHtml, leftHtml, rightHtml = generateCompactableLineHtml(leftItem.(*diff.TextLine), rightItem.(*diff.TextLine), opts)
 		} else {
 			if leftItem != nil {
-				leftHtml = html.EscapeString(leftItem.(*diff.TextLine).Text)
+				leftHtml = html.EscapeString(displayText(leftItem.(*diff.TextLine), opts))
 			}
 			if rightItem != nil {
-				rightHtml = html.EscapeString(rightItem.(*diff.TextLine).Text)
+				rightHtml = html.EscapeString(displayText(rightItem.(*diff.TextLine), opts))
+			}
+		}
+
+		if opts.TabGuides {
+			leftHtml, rightHtml = generateTabGuides(leftHtml, opts), generateTabGuides(rightHtml, opts)
+		}
+
+		// With DetectBlockIndent, matching lines can still differ in indentation, when a whole
+		// block has been shifted.
+		if opts.DetectBlockIndent && link.LinkType == diff.Matching {
+			leftLine, rightLine := leftItem.(*diff.TextLine), rightItem.(*diff.TextLine)
+			if shift := diff.IndentShift(leftLine.Text, rightLine.Text); shift != "" {
+				rightHtml = generateIndentShiftBadge(shift, opts) + rightHtml
+			}
+		}
+
+		// Flag lines whose only change is switching between tabs and spaces for indentation.
+		if opts.DetectIndentChange && leftItem != nil && rightItem != nil {
+			leftLine, rightLine := leftItem.(*diff.TextLine), rightItem.(*diff.TextLine)
+			if diff.IndentStyleChanged(leftLine, rightLine) {
+				rightHtml = generateIndentChangeBadge(leftLine, rightLine, opts) + rightHtml
 			}
 		}
 
 		// Figure out the appropriate styles for the left and right lines.
+		focused := opts.Focus != FocusNone && link.LinkType == opts.Focus.LinkType()
+		dimmed := opts.Focus != FocusNone && !focused
 		leftLineStyle := []CssStyle{
 			codeLineStyle,
+			leftTabSizeStyle,
 			codeLineLinesDifferStyle.when(link.LinkType == diff.Different),
 			codeLineOnlyOneStyle.when(link.LinkType == diff.LeftOnly),
 			codeLineNoneStyle.when(leftItem == nil),
+			codeLineFocusStyle.when(focused && leftItem != nil),
+			codeLineDimmedStyle.when(dimmed),
 		}
 		rightLineStyle := []CssStyle{
 			codeLineStyle,
+			rightTabSizeStyle,
 			codeLineLinesDifferStyle.when(link.LinkType == diff.Different),
 			codeLineOnlyOneStyle.when(link.LinkType == diff.RightOnly),
 			codeLineNoneStyle.when(rightItem == nil),
+			codeLineFocusStyle.when(focused && rightItem != nil),
+			codeLineDimmedStyle.when(dimmed),
 		}
 
 		// Line numbers.  Remember that slice indexes start from zero, but line numbers start from 1!
@@ -259,51 +801,260 @@ func GenerateHtmlDiffPage(outputFile *os.File, alignment *diff.Alignment, leftSo
 			rightLineNumHtml = strconv.FormatInt(int64(link.RightIndex + 1), 10)
 		}
 
+		// Each line gets ids based on its line number, so "#L-42" links to the 42nd
+		// line on the left, and keeps doing so as long as the line doesn't move.
+		leftNumId, leftCodeId, rightNumId, rightCodeId := "", "", "", ""
+		if link.LeftIndex >= 0 {
+			leftNumId = fmt.Sprintf("%sL-%d", opts.LineIdPrefix, link.LeftIndex + 1)
+			leftCodeId = leftNumId + "-code"
+		}
+		if link.RightIndex >= 0 {
+			rightNumId = fmt.Sprintf("%sR-%d", opts.LineIdPrefix, link.RightIndex + 1)
+			rightCodeId = rightNumId + "-code"
+		}
+
+		// The class names are only needed by the responsive style sheet.
+		rowClass, leftClass, rightClass, gutterClass := "", "", "", ""
+		if opts.Breakpoint > 0 {
+			rowClass, gutterClass = "diffy-row", "diffy-gutter"
+			if link.LinkType == diff.Matching {
+				rowClass += " diffy-matching"
+			}
+			leftClass, rightClass = "diffy-left", "diffy-right"
+			if leftItem == nil {
+				leftClass += " diffy-empty"
+			}
+			if rightItem == nil {
+				rightClass += " diffy-empty"
+			}
+		}
+		withClass := func (className, suffix string) string {
+			if className == "" {
+				return ""
+			}
+			return className + " " + suffix
+		}
+
 		// Output the HTML for these two lines.
-		fmt.Fprintf(outputFile, "		%s\n", generateStartTag("table", twoLineDiffStyle))
-		fmt.Fprintf(outputFile, "			%s\n", generateStartTag("tr"))
-		fmt.Fprintf(outputFile, "				%s\n", generateElement("td", leftLineNumHtml, lineNumStyle))
-		fmt.Fprintf(outputFile, "				%s\n", generateElement("td", leftHtml, leftLineStyle...))
-		fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", twoLineDiffGutterStyle))
-		fmt.Fprintf(outputFile, "				%s\n", generateElement("td", rightHtml, rightLineStyle...))
-		fmt.Fprintf(outputFile, "				%s\n", generateElement("td", rightLineNumHtml, lineNumStyle))
+		if !isChange(linkIndex) || !isChange(linkIndex - 1) {
+			fmt.Fprintf(outputFile, "		%s\n", generateClassedStartTag(opts, "table", rowClass, twoLineDiffStyle, changeBlockStyle.when(isChange(linkIndex))))
+		}
+		fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+		fmt.Fprintf(outputFile, "				%s\n", setElementId(generateClassedElement(opts, "td", leftLineNumHtml, withClass(leftClass, "diffy-num"), lineNumStyle), "td", leftNumId))
+		fmt.Fprintf(outputFile, "				%s\n", setElementLineId(setElementId(generateClassedElement(opts, "td", leftHtml, withClass(leftClass, "diffy-code"), leftLineStyle...), "td", leftCodeId), "td", leftLineIds, link.LeftIndex))
+		fmt.Fprintf(outputFile, "				%s\n", generateClassedElement(opts, "td", "", gutterClass, twoLineDiffGutterStyle))
+		fmt.Fprintf(outputFile, "				%s\n", setElementLineId(setElementId(generateClassedElement(opts, "td", rightHtml, withClass(rightClass, "diffy-code"), rightLineStyle...), "td", rightCodeId), "td", rightLineIds, link.RightIndex))
+		fmt.Fprintf(outputFile, "				%s\n", setElementId(generateClassedElement(opts, "td", rightLineNumHtml, withClass(rightClass, "diffy-num"), lineNumStyle), "td", rightNumId))
 		fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
-		fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
+
+		// The shared start or end goes below the pair: the table's layout is
+		// fixed, so its first row sets the widths of the columns, which the
+		// one cell spanning them all would leave even.
+		if sharedHtml != "" {
+			sharedClass := ""
+			if opts.Breakpoint > 0 {
+				sharedClass = "diffy-shared"
+			}
+			fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+			fmt.Fprintf(outputFile, "				%s\n", setElementColspan(generateClassedElement(opts, "td", sharedHtml, sharedClass, codeLineStyle, compactSharedEndsStyle), "td", 5))
+			fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
+		}
+		if !isChange(linkIndex) || !isChange(linkIndex + 1) {
+			fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
+		}
 	}
-	fmt.Fprintln(outputFile, "")
+}
 
-	// Generate an empty final "code-line" table to provide some extra spacing.
-	fmt.Fprintf(outputFile, "		%s\n", generateStartTag("table", twoLineDiffStyle))
-	fmt.Fprintf(outputFile, "			%s\n", generateStartTag("tr"))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", lineNumStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", codeLineStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", twoLineDiffGutterStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", codeLineStyle))
-	fmt.Fprintf(outputFile, "				%s\n", generateElement("td", "", lineNumStyle))
-	fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
-	fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
-	fmt.Fprintln(outputFile, "")
+// ------------------------------------------- realignForDisplay
+//
+// The alignment as it's shown: with pairs of lines too dissimilar to show
+// side by side split apart, unless "NoRealign" asks for the raw alignment.
+func realignForDisplay(alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) *diff.Alignment {
+	if opts.NoRealign {
+		return alignment
+	}
+	return alignment.RealignUsingThresholdWith(leftSource.Compared(), rightSource.Compared(), chooseRealignThreshold(alignment, leftSource, rightSource, opts), opts.CompareFunc())
+}
 
-	// Print the page epilogue.
-	fmt.Fprintln(outputFile, "	</body>")
-	fmt.Fprintln(outputFile, "</html>")
+// ------------------------------------------- chooseRealignThreshold
+//
+// The threshold for splitting dissimilar pairs of lines for display.
+func chooseRealignThreshold(alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) float32 {
+	if opts.AdaptiveRealign == nil {
+		return diff.DEFAULT_REALIGN_THRESHOLD
+	}
+	return opts.AdaptiveRealign.ChooseForWith(alignment, leftSource.Compared(), rightSource.Compared(), opts.CompareFunc())
+}
+
+// ------------------------------------------- responsiveStyleRules
+//
+// The rules of the responsive style sheet, which a linked "CssFile" takes
+// the place of.  "GenerateStyleSheet" writes them out for it.
+var responsiveStyleRules = []string{
+	".diffy-row, .diffy-row tbody { display: block !important; }",
+	".diffy-row tr { display: flex !important; flex-wrap: wrap; }",
+	".diffy-row td { display: block !important; box-sizing: border-box; }",
+	".diffy-row .diffy-num { flex: 0 0 6ex; }",
+	".diffy-row .diffy-code { flex: 1 0 calc(100% - 6ex); }",
+	".diffy-row .diffy-shared { flex: 1 0 100%; }",
+	".diffy-left.diffy-num { order: 1; }",
+	".diffy-left.diffy-code { order: 2; }",
+	".diffy-right.diffy-num { order: 3; }",
+	".diffy-right.diffy-code { order: 4; }",
+	".diffy-gutter, .diffy-empty, .diffy-matching .diffy-right { display: none !important; }",
+}
+
+// ------------------------------------------- generateResponsiveStyleSheet
+//
+// The page is laid out side by side, with inline styles.  On a viewport
+// narrower than "breakpoint" pixels, this style sheet reflows each row into
+// an inline view instead: the left line above the right line, each with its
+// line number first.  Matching rows only show the left line, and a missing
+// line takes up no space at all.  The rules have to be "!important" to win
+// out over the inline styles.
+func generateResponsiveStyleSheet(breakpoint int) string {
+	var sheet strings.Builder
+	sheet.WriteString("		<style>\n")
+	fmt.Fprintf(&sheet, "			@media (max-width: %dpx) {\n", breakpoint - 1)
+	for _, rule := range responsiveStyleRules {
+		fmt.Fprintf(&sheet, "				%s\n", rule)
+	}
+	sheet.WriteString("			}\n")
+	sheet.WriteString("		</style>\n")
+	return sheet.String()
+}
+
+// ------------------------------------------- generateIndentChangeBadge
+//
+// Generate a small badge describing an indentation style change, e.g. "tabs → spaces".
+func generateIndentChangeBadge(leftLine, rightLine *diff.TextLine, opts HtmlOptions) string {
+	badgeText := diff.IndentStyle(leftLine.RawIndent) + " &rarr; " + diff.IndentStyle(rightLine.RawIndent)
+	return generateElement(opts, "span", badgeText, indentChangeBadgeStyle)
+}
+
+// ------------------------------------------- displayText
+//
+// The text to show for a line: the raw text when we're preserving tabs and
+// have it, and otherwise the expanded text that was compared.
+func displayText(line *diff.TextLine, opts HtmlOptions) string {
+	if opts.PreserveTabs && line.RawText != "" {
+		return line.RawText
+	}
+	return line.Text
+}
+
+// ------------------------------------------- generateTabGuides
+//
+// Wrap each tab in a line's HTML in a guide span.  Neither the escaped text
+// nor the tags around it contain any other tabs, so this can be done after
+// the line has been highlighted.
+func generateTabGuides(lineHtml string, opts HtmlOptions) string {
+	return strings.Replace(lineHtml, "\t", generateElement(opts, "span", "\t", tabGuideStyle), -1)
+}
+
+// ------------------------------------------- generateIndentShiftBadge
+//
+// Generate a small badge describing a block indentation shift, e.g. "indent +4".
+func generateIndentShiftBadge(shift string, opts HtmlOptions) string {
+	badgeText := fmt.Sprintf("indent %s%d", shift[:1], len(shift) - 1)
+	return generateElement(opts, "span", badgeText, indentChangeBadgeStyle)
 }
 
 // ------------------------------------------- generateLineHtml
 //
 // Generate HTML which highlights the differences between two different but similar lines.
-func generateLineHtml(leftLine, rightLine string) (string, string) {
+func generateLineHtml(leftLine, rightLine *diff.TextLine, opts HtmlOptions) (string, string) {
+	_, leftHtml, rightHtml := generateCompactableLineHtml(leftLine, rightLine, opts)
+	return leftHtml, rightHtml
+}
+
+// ------------------------------------------- generateCompactableLineHtml
+//
+// Like generateLineHtml, and with "CompactDiff", also the HTML for the start
+// and end the lines share, which is cut from both lines; see elideSharedEnds.
+// Without "CompactDiff", or when there's nothing worth cutting, the shared
+// HTML is "".
+func generateCompactableLineHtml(leftLine, rightLine *diff.TextLine, opts HtmlOptions) (string, string, string) {
 
 	// Generate a diff for the two lines.
-	leftLineRunes, rightLineRunes := diff.MakeComparableString(leftLine), diff.MakeComparableString(rightLine)
+	leftLineRunes, rightLineRunes := diff.MakeComparableString(leftLine.Text), diff.MakeComparableString(rightLine.Text)
 	_, alignment := diff.Diff_v2(leftLineRunes, rightLineRunes)
 
 	// Use the "alignment" generated above to generate HTML which highlights the differences.
 	leftRunPositions, rightRunPositions := findAlternatingRunPositions(alignment, diff.Matching)
-	leftSpansHtml := constructEvenOddSpans(leftLineRunes, leftRunPositions, nullStyle, codeRunDifferentStyle)
-	rightSpansHtml := constructEvenOddSpans(rightLineRunes, rightRunPositions, nullStyle, codeRunDifferentStyle)
+	if opts.WholeWordHighlight {
+		leftRunPositions = snapRunPositionsToWords(leftLineRunes, leftRunPositions, opts.WordChars)
+		rightRunPositions = snapRunPositionsToWords(rightLineRunes, rightRunPositions, opts.WordChars)
+	}
 
-	return leftSpansHtml, rightSpansHtml
+	// The diff was done on the expanded text.  To show the raw text instead, the run
+	// positions have to be mapped back onto it.  A tab is highlighted if any of the
+	// spaces it expanded into are.
+	if displayText(leftLine, opts) != leftLine.Text {
+		leftLineRunes, leftRunPositions = mapRunPositionsToRawText(leftLine, leftRunPositions, opts.TabSize)
+	}
+	if displayText(rightLine, opts) != rightLine.Text {
+		rightLineRunes, rightRunPositions = mapRunPositionsToRawText(rightLine, rightRunPositions, opts.rightTabSize())
+	}
+
+	// A long start or end the lines share can be shown just once.
+	var sharedPrefix, sharedSuffix string
+	if opts.CompactDiff {
+		leftLineRunes, leftRunPositions, rightLineRunes, rightRunPositions, sharedPrefix, sharedSuffix = elideSharedEnds(leftLineRunes, leftRunPositions, rightLineRunes, rightRunPositions)
+	}
+	sharedHtml := generateSharedEndsHtml(sharedPrefix, sharedSuffix, opts)
+
+	// Hovering over a changed run tells how big a change it is.
+	var oddTitle func (run []rune) string
+	if opts.RunTooltips {
+		similarity := leftLine.Similarity(rightLine)
+		oddTitle = func (run []rune) string { return runTooltip(run, similarity) }
+	}
+
+	var leftSpansHtml, rightSpansHtml string
+	if opts.GradedRuns {
+		leftSpansHtml, rightSpansHtml = constructGradedSpans(leftLineRunes, leftRunPositions, oddTitle, opts), constructGradedSpans(rightLineRunes, rightRunPositions, oddTitle, opts)
+	} else {
+		leftSpansHtml = constructEvenOddSpans(leftLineRunes, leftRunPositions, nullStyle, codeRunDifferentStyle, oddTitle, opts)
+		rightSpansHtml = constructEvenOddSpans(rightLineRunes, rightRunPositions, nullStyle, codeRunDifferentStyle, oddTitle, opts)
+	}
+
+	return sharedHtml, elideSpans(leftSpansHtml, sharedPrefix, sharedSuffix, opts), elideSpans(rightSpansHtml, sharedPrefix, sharedSuffix, opts)
+}
+
+// ------------------------------------------- mapRunPositionsToRawText
+//
+// Map run positions in the expanded text of a line onto its raw text.  Even
+// runs are unhighlighted and odd runs highlighted, so a run start which lands
+// in the middle of a tab is rounded down for odd runs and up for even runs.
+// That way the tab ends up highlighted when any part of it is.
+//
+// If the raw text doesn't expand to the same length as the expanded text, e.g.
+// because it was expanded with a different tab size, the mapping can't be
+// trusted and the expanded text and positions are returned unchanged.
+func mapRunPositionsToRawText(line *diff.TextLine, runPositions []int, tabSize int) ([]rune, []int) {
+	rawIndexes := etc.ExpandedRuneIndexes(line.RawText, tabSize)
+	if len(rawIndexes) != len([]rune(line.Text)) + 1 {
+		return []rune(line.Text), runPositions
+	}
+	rawRunes := []rune(line.RawText)
+	rawPositions := make([]int, len(runPositions))
+	for i, position := range runPositions {
+		rawPosition := rawIndexes[position]
+		startsMidTab := position > 0 && rawIndexes[position - 1] == rawPosition
+		if startsMidTab && i % 2 == 0 {
+			rawPosition++
+		}
+		rawPositions[i] = rawPosition
+	}
+
+	// Rounding can make a run overtake the next one; keep them ascending.
+	for i := 1; i < len(rawPositions); i++ {
+		if rawPositions[i] < rawPositions[i - 1] {
+			rawPositions[i] = rawPositions[i - 1]
+		}
+	}
+	return rawRunes, rawPositions
 }
 
 // ------------------------------------------- findAlternatingRunPositions
@@ -343,6 +1094,49 @@ func findAlternatingRunPositions(alignment *diff.Alignment, linkType diff.LinkTy
 	return findRunPositions(leftLinks), findRunPositions(rightLinks)
 }
 
+// ------------------------------------------- snapRunPositionsToWords
+//
+// Widen the odd (highlighted) runs so that they start and end on word boundaries,
+// so that "colour" vs "color" highlights the whole word rather than just the "u".
+// Runs which grow into each other are merged.  The result follows the same rules
+// as the output of findAlternatingRunPositions.  "wordChars" says what a word is
+// made of; nil means diff.CodeWordChars.
+func snapRunPositionsToWords(runes []rune, runPositions []int, wordChars diff.WordChars) []int {
+
+	if wordChars == nil {
+		wordChars = diff.CodeWordChars
+	}
+	isWordRune := func (index int) bool {
+		return index >= 0 && index < len(runes) && !unicode.IsSpace(runes[index]) && wordChars(runes[index])
+	}
+
+	snappedPositions := []int{0}
+	for i := 1; i < len(runPositions) - 1; i += 2 {
+		start, end := runPositions[i], runPositions[i + 1]
+		for isWordRune(start - 1) && isWordRune(start) {
+			start--
+		}
+		for isWordRune(end - 1) && isWordRune(end) {
+			end++
+		}
+
+		// Merge with the previous highlighted run if they now touch or overlap.
+		last := len(snappedPositions) - 1
+		if last > 0 && start <= snappedPositions[last] {
+			if end > snappedPositions[last] {
+				snappedPositions[last] = end
+			}
+			continue
+		}
+		snappedPositions = append(snappedPositions, start, end)
+	}
+
+	if snappedPositions[len(snappedPositions) - 1] != len(runes) || len(snappedPositions) == 1 {
+		snappedPositions = append(snappedPositions, len(runes))
+	}
+	return snappedPositions
+}
+
 // ------------------------------------------- getLeftlinks
 
 func getLeftLinks(alignment *diff.Alignment) []diff.Link {
@@ -371,7 +1165,8 @@ func getRightLinks(alignment *diff.Alignment) []diff.Link {
 //
 // Convert the literal text (or a subset thereof) in "runes" into HTML, where each "run" is
 // represented as a single SPAN element, and where even spans are styled with "evenStyle" and
-// odd runs are styled with "oddStyle".
+// odd runs are styled with "oddStyle".  If "oddTitle" isn't nil, each odd run gets a "title"
+// attribute, i.e. a tooltip, with whatever it returns for the run.
 //
 // Notes:
 // - each run position denotes the *beginning* of a run
@@ -380,39 +1175,128 @@ func getRightLinks(alignment *diff.Alignment) []diff.Link {
 // - when the runs cover the whole rune slice, the first run position will be 0
 // - when the runs cover the whole rune slice, the last run position will be len(runes)
 //
-func constructEvenOddSpans(runes []rune, runPositions []int, evenStyle, oddStyle CssStyle) string {
+func constructEvenOddSpans(runes []rune, runPositions []int, evenStyle, oddStyle CssStyle, oddTitle func (run []rune) string, opts HtmlOptions) string {
+	return constructStyledSpans(runes, runPositions, func (runIndex, runLength int) CssStyle {
+		if runIndex % 2 == 0 {
+			return evenStyle
+		}
+		return oddStyle
+	}, oddTitle, opts)
+}
+
+// ------------------------------------------- constructGradedSpans
+//
+// Like constructEvenOddSpans, with the odd (changed) runs shaded by their
+// length, so a rewritten word stands out more than a changed comma.
+//
+func constructGradedSpans(runes []rune, runPositions []int, oddTitle func (run []rune) string, opts HtmlOptions) string {
+	return constructStyledSpans(runes, runPositions, func (runIndex, runLength int) CssStyle {
+		if runIndex % 2 == 0 {
+			return nullStyle
+		}
+		return gradedRunStyle(runLength)
+	}, oddTitle, opts)
+}
+
+// ------------------------------------------- gradedRunStyle
+
+func gradedRunStyle(runLength int) CssStyle {
+	switch {
+	case runLength <= 1:
+		return codeRunGradedStyles[0]
+	case runLength <= 3:
+		return codeRunGradedStyles[1]
+	case runLength <= 7:
+		return codeRunGradedStyles[2]
+	}
+	return codeRunGradedStyles[3]
+}
+
+// ------------------------------------------- constructStyledSpans
+//
+// The general case of constructEvenOddSpans: each run is styled with whatever
+// "styleFor" returns for its index and its length in runes, and the odd runs
+// get a title from "oddTitle", if it isn't nil.
+//
+func constructStyledSpans(runes []rune, runPositions []int, styleFor func (runIndex, runLength int) CssStyle, oddTitle func (run []rune) string, opts HtmlOptions) string {
 	var spansHtml []string
 	for i := 0; i < len(runPositions) - 1; i++ {	// note: last iteration is i = len(runPositions) - 2
-		runIsEven := i % 2 == 0
-		runIsOdd := !runIsEven
 		runStartIndex := runPositions[i + 0]
 		runEndIndex := runPositions[i + 1]
 		spanText := runes[runStartIndex:runEndIndex]
 		spanTextEscaped := html.EscapeString(string(spanText))
-		span := generateElement("span", spanTextEscaped, evenStyle.when(runIsEven), oddStyle.when(runIsOdd))
+		span := generateElement(opts, "span", spanTextEscaped, styleFor(i, runEndIndex - runStartIndex))
+		if i % 2 == 1 && oddTitle != nil {
+			span = setElementTitle(span, "span", oddTitle(spanText))
+		}
 		spansHtml = append(spansHtml, span)
 	}
 	return strings.Join(spansHtml, "")
 }
 
+// ------------------------------------------- runTooltip
+//
+// runTooltip("abc", 0.82) => "3 characters changed; the lines are 82% similar"
+func runTooltip(run []rune, lineSimilarity float32) string {
+	characters := "characters"
+	if len(run) == 1 {
+		characters = "character"
+	}
+	return fmt.Sprintf("%d %s changed; the lines are %.0f%% similar", len(run), characters, lineSimilarity * 100)
+}
+
 // ------------------------------------------- generateElement
 //
-// generateElement("div" ...) => "<div>...</div>" or "<div style='...'>...</div>"
+// generateElement(opts, "div" ...) => "<div>...</div>" or "<div style='...'>...</div>"
 // This function will generate no additional newlines, although the body may
 // contain newlines which will be retained.
-func generateElement(tagName string, body string, styles ...CssStyle) string {
-	return generateStartTag(tagName, styles...) + body + generateEndTag(tagName)
+func generateElement(opts HtmlOptions, tagName string, body string, styles ...CssStyle) string {
+	return generateStartTag(opts, tagName, styles...) + body + generateEndTag(tagName)
 }
 
 // ------------------------------------------- generateStartTag
 //
-// generateStartTag("div" ...) => "<div>" or "<div style='...'>" as appropriate,
-// depending on whether any styles are generated or not.
-func generateStartTag(tagName string, styles ...CssStyle) string {
+// generateStartTag(opts, "div" ...) => "<div>" or "<div style='...'>" as
+// appropriate, depending on whether any styles are generated or not.  With a
+// "CssFile", the styles with a class name come out as the class instead, e.g.
+// "<div class='...'>", and only the rest are inline.
+func generateStartTag(opts HtmlOptions, tagName string, styles ...CssStyle) string {
+	return generateClassedStartTag(opts, tagName, "", styles...)
+}
 
-	startTagText := "<" + tagName
+// ------------------------------------------- generateClassedElement
+//
+// Like generateElement, but with a "class" attribute, unless "className" is empty.
+func generateClassedElement(opts HtmlOptions, tagName string, body string, className string, styles ...CssStyle) string {
+	return generateClassedStartTag(opts, tagName, className, styles...) + body + generateEndTag(tagName)
+}
 
-	stylePropertyText := ConcatCssStyles(styles...)
+// ------------------------------------------- generateClassedStartTag
+//
+// generateClassedStartTag(opts, "div", "foo" ...) => "<div class='foo'>" or "<div class='foo' style='...'>"
+func generateClassedStartTag(opts HtmlOptions, tagName string, className string, styles ...CssStyle) string {
+
+	var classNames []string
+	if className != "" {
+		classNames = append(classNames, className)
+	}
+	inlineStyles := styles
+	if opts.CssFile != "" {
+		inlineStyles = nil
+		for _, style := range styles {
+			if knownCssClassNames[style.className] {
+				classNames = append(classNames, style.className)
+			} else {
+				inlineStyles = append(inlineStyles, style)
+			}
+		}
+	}
+
+	startTagText := "<" + tagName
+	if len(classNames) > 0 {
+		startTagText += " class='" + strings.Join(classNames, " ") + "'"
+	}
+	stylePropertyText := ConcatCssStyles(inlineStyles...)
 	if stylePropertyText != "" {
 		startTagText += " style='" + stylePropertyText + "'"
 	}
@@ -420,6 +1304,32 @@ func generateStartTag(tagName string, styles ...CssStyle) string {
 	return startTagText + ">"
 }
 
+// ------------------------------------------- setElementId
+//
+// setElementId("<td>...</td>", "td", "L-1") => "<td id='L-1'>...</td>"
+// An empty id leaves the element alone.
+func setElementId(elementHtml string, tagName string, id string) string {
+	if id == "" {
+		return elementHtml
+	}
+	return "<" + tagName + " id='" + id + "'" + elementHtml[len(tagName) + 1:]
+}
+
+// ------------------------------------------- setElementTitle
+//
+// setElementTitle("<span>...</span>", "span", "a tip") => "<span title='a tip'>...</span>"
+// Like setElementId, but the title is escaped.
+func setElementTitle(elementHtml string, tagName string, title string) string {
+	return "<" + tagName + " title='" + html.EscapeString(title) + "'" + elementHtml[len(tagName) + 1:]
+}
+
+// ------------------------------------------- setElementColspan
+//
+// setElementColspan("<td>...</td>", "td", 5) => "<td colspan='5'>...</td>"
+func setElementColspan(elementHtml string, tagName string, colspan int) string {
+	return "<" + tagName + " colspan='" + strconv.Itoa(colspan) + "'" + elementHtml[len(tagName) + 1:]
+}
+
 // ------------------------------------------- generateEndTag
 //
 // generateEndTag("div") => "</div>"
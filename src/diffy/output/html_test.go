@@ -0,0 +1,825 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+	"diffy/etc"
+)
+
+// -------------------------------------------
+// ------------------------------------------- helper functions
+// -------------------------------------------
+
+// Build a ComparableLines slice from plain strings.
+func makeLines(texts ...string) diff.ComparableLines {
+	var lines diff.ComparableLines
+	for _, text := range texts {
+		lines = append(lines, diff.NewTextLine(text))
+	}
+	return lines
+}
+
+// Diff two slices of lines and render them as an HTML page using the
+// specified options.  The generated page is returned as a string.
+func generateTestPage(leftLines, rightLines diff.ComparableLines, opts HtmlOptions) string {
+	_, alignment := diff.Diff_v2(leftLines, rightLines)
+	leftSource := NewSourceLinesRec(leftLines, "left.txt")
+	rightSource := NewSourceLinesRec(rightLines, "right.txt")
+
+	var buffer bytes.Buffer
+	GenerateHtmlDiffPage(&buffer, alignment, leftSource, rightSource, opts)
+	return buffer.String()
+}
+
+// The links a page shows, one per row, as "left/right" line numbers, e.g.
+// "3/-" for a line only in the left file.
+func renderedLinks(page string) []string {
+	var links []string
+	for _, row := range strings.Split(page, "<table")[1:] {
+		if !strings.Contains(row, "-code'") {
+			continue
+		}
+		link := ""
+		for _, prefix := range []string{"L", "R"} {
+			number := "-"
+			if start := strings.Index(row, "id='" + prefix + "-"); start >= 0 {
+				number = strings.TrimSuffix(strings.SplitN(row[start + len("id='" + prefix + "-"):], "'", 2)[0], "-code")
+			}
+			link += "/" + number
+		}
+		links = append(links, link[1:])
+	}
+	return links
+}
+
+// The links of an alignment, as renderedLinks shows them.
+func linkNumbers(alignment *diff.Alignment) []string {
+	var links []string
+	for _, link := range alignment.Links {
+		number := func (index int) string {
+			if index < 0 {
+				return "-"
+			}
+			return fmt.Sprint(index + 1)
+		}
+		links = append(links, number(link.LeftIndex) + "/" + number(link.RightIndex))
+	}
+	return links
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestHtmlOptionsInjection
+// -------------------------------------------
+
+func TestHtmlOptionsInjection(t *testing.T) {
+
+	opts := HtmlOptions{
+		HeadExtra: "<!-- head-extra-marker -->",
+		BodyPrefix: "<!-- body-prefix-marker -->",
+		BodySuffix: "<!-- body-suffix-marker -->",
+	}
+	page := generateTestPage(makeLines("a", "b"), makeLines("a", "c"), opts)
+
+	// Each marker must appear exactly once.
+	for _, marker := range []string{opts.HeadExtra, opts.BodyPrefix, opts.BodySuffix} {
+		if count := strings.Count(page, marker); count != 1 {
+			t.Errorf("expected %q to appear exactly once, but it appeared %d times", marker, count)
+		}
+	}
+
+	// The markers must land in the right places relative to the surrounding tags.
+	headExtraPos := strings.Index(page, opts.HeadExtra)
+	bodyPrefixPos := strings.Index(page, opts.BodyPrefix)
+	bodySuffixPos := strings.Index(page, opts.BodySuffix)
+	headEndPos := strings.Index(page, "</head>")
+	bodyStartPos := strings.Index(page, "<body>")
+	bodyEndPos := strings.Index(page, "</body>")
+	firstTablePos := strings.Index(page, "<table")
+	lastTablePos := strings.LastIndex(page, "</table>")
+
+	if !(headExtraPos < headEndPos) {
+		t.Errorf("HeadExtra should appear before </head>")
+	}
+	if !(bodyStartPos < bodyPrefixPos && bodyPrefixPos < firstTablePos) {
+		t.Errorf("BodyPrefix should appear after <body> and before the diff")
+	}
+	if !(lastTablePos < bodySuffixPos && bodySuffixPos < bodyEndPos) {
+		t.Errorf("BodySuffix should appear after the diff and before </body>")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestHtmlOptionsDefault
+// -------------------------------------------
+
+func TestHtmlOptionsDefault(t *testing.T) {
+
+	// The zero value options should leave the page structure untouched.
+	page := generateTestPage(makeLines("a"), makeLines("a"), HtmlOptions{})
+	if !strings.Contains(page, "\t</head>\n\t<body>\n") {
+		t.Errorf("expected </head> to be followed directly by <body> when no options are set")
+	}
+	if !strings.Contains(page, "\t</body>\n</html>\n") {
+		t.Errorf("expected </body> to be followed directly by </html> when no options are set")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestIndentChangeBadge
+// -------------------------------------------
+
+func TestIndentChangeBadge(t *testing.T) {
+
+	makeIndentedLine := func (rawIndent, expandedIndent, content string) *diff.TextLine {
+		line := diff.NewTextLine(expandedIndent + content)
+		line.RawIndent = rawIndent
+		return line
+	}
+
+	leftLines := diff.ComparableLines{
+		makeIndentedLine("\t", "    ", "indentation changed"),
+		makeIndentedLine("\t", "    ", "content changed"),
+	}
+	rightLines := diff.ComparableLines{
+		makeIndentedLine("    ", "    ", "indentation changed"),
+		makeIndentedLine("\t", "    ", "content has changed"),
+	}
+
+	// With the option enabled, only the indentation change gets a badge.
+	page := generateTestPage(leftLines, rightLines, HtmlOptions{DetectIndentChange: true})
+	if count := strings.Count(page, "tabs &rarr; spaces"); count != 1 {
+		t.Errorf("expected exactly one indentation change badge, found %d", count)
+	}
+
+	// With the option disabled there are no badges at all.
+	page = generateTestPage(leftLines, rightLines, HtmlOptions{})
+	if strings.Contains(page, "&rarr;") {
+		t.Errorf("expected no indentation change badges when the option is disabled")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestBlockIndentBadge
+// -------------------------------------------
+
+func TestBlockIndentBadge(t *testing.T) {
+	leftLines := diff.ComparableLines{diff.NewTextLine("if x {"), diff.NewTextLine("y()"), diff.NewTextLine("}")}
+	rightLines := diff.ComparableLines{diff.NewTextLine("if x {"), diff.NewTextLine("    y()"), diff.NewTextLine("}")}
+	_, alignment := diff.Diff_v2(leftLines, rightLines)
+	alignment = alignment.MatchBlockIndents(leftLines, rightLines)
+	leftSource := NewSourceLinesRec(leftLines, "left.txt")
+	rightSource := NewSourceLinesRec(rightLines, "right.txt")
+
+	generatePage := func(opts HtmlOptions) string {
+		var buffer bytes.Buffer
+		GenerateHtmlDiffPage(&buffer, alignment, leftSource, rightSource, opts)
+		return buffer.String()
+	}
+
+	// With the option enabled, the shifted line gets a badge.
+	if page := generatePage(HtmlOptions{DetectBlockIndent: true}); !strings.Contains(page, "indent +4") {
+		t.Errorf("expected an indent shift badge in:\n%s", page)
+	}
+
+	// Without it, matching lines are never badged.
+	if page := generatePage(HtmlOptions{}); strings.Contains(page, "indent +") {
+		t.Errorf("expected no indent shift badge in:\n%s", page)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestFinalNewline
+// -------------------------------------------
+
+func TestFinalNewline(t *testing.T) {
+
+	testCases := []struct {
+		leftFinalNewline, rightFinalNewline bool
+		differ bool
+		noteCount int
+	}{
+		{true, true, false, 0},		// both have
+		{false, false, false, 0},	// neither has
+		{true, false, true, 1},		// one has
+		{false, true, true, 1},		// the other has
+	}
+
+	for _, testCase := range testCases {
+		lines := makeLines("same", "lines")
+		_, alignment := diff.Diff_v2(lines, lines)
+		leftSource := NewSourceLinesRec(lines, "left.txt")
+		rightSource := NewSourceLinesRec(lines, "right.txt")
+		leftSource.FinalNewline = testCase.leftFinalNewline
+		rightSource.FinalNewline = testCase.rightFinalNewline
+
+		if differ := HasDifferences(alignment, leftSource, rightSource); differ != testCase.differ {
+			t.Errorf("%v/%v: HasDifferences got %v, expected %v",
+						testCase.leftFinalNewline, testCase.rightFinalNewline, differ, testCase.differ)
+		}
+
+		var buffer bytes.Buffer
+		GenerateHtmlDiffPage(&buffer, alignment, leftSource, rightSource, HtmlOptions{})
+		if count := strings.Count(buffer.String(), "No newline at end of file"); count != testCase.noteCount {
+			t.Errorf("%v/%v: got %d notes, expected %d",
+						testCase.leftFinalNewline, testCase.rightFinalNewline, count, testCase.noteCount)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestEmptyInputs
+// -------------------------------------------
+
+func TestEmptyInputs(t *testing.T) {
+
+	testCases := []struct {
+		name string
+		leftLines, rightLines diff.ComparableLines
+		note string
+		noteCount int
+	}{
+		{"empty left", nil, makeLines("a", "b"), LEFT_EMPTY_NOTE, 1},
+		{"empty right", makeLines("a", "b"), nil, RIGHT_EMPTY_NOTE, 1},
+		{"both empty", nil, nil, BOTH_EMPTY_NOTE, 2},
+		{"neither empty", makeLines("a"), makeLines("b"), "", 0},
+	}
+
+	for _, testCase := range testCases {
+		if note := EmptyInputsNote(len(testCase.leftLines), len(testCase.rightLines)); note != testCase.note {
+			t.Errorf("%s: expected the note %q, got %q", testCase.name, testCase.note, note)
+		}
+		page := generateTestPage(testCase.leftLines, testCase.rightLines, HtmlOptions{})
+		for _, note := range []string{LEFT_EMPTY_NOTE, RIGHT_EMPTY_NOTE, BOTH_EMPTY_NOTE} {
+			expectedCount := 0
+			if note == testCase.note {
+				expectedCount = testCase.noteCount
+			}
+			if count := strings.Count(page, note); count != expectedCount {
+				t.Errorf("%s: expected %d of %q in the page, got %d", testCase.name, expectedCount, note, count)
+			}
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestStrippedAffix
+// -------------------------------------------
+
+func TestStrippedAffix(t *testing.T) {
+
+	leftLines, rightLines := makeLines("[INFO] starting", "[INFO] ready"), makeLines("[INFO] starting", "[INFO] stopped")
+	prefix, suffix := diff.CommonAffix(leftLines, rightLines)
+	leftLines, rightLines = diff.StripAffix(leftLines, prefix, suffix), diff.StripAffix(rightLines, prefix, suffix)
+	page := generateTestPage(leftLines, rightLines, HtmlOptions{StrippedPrefix: prefix, StrippedSuffix: suffix})
+
+	// The prefix is shown just the once, and not on the lines.
+	if count := strings.Count(page, "[INFO]"); count != 1 {
+		t.Errorf("Expected the prefix once, got %d times", count)
+	}
+	if !strings.Contains(page, html.EscapeString("Stripped from every line: prefix \"[INFO] \"")) {
+		t.Errorf("Expected the stripped prefix in the heading")
+	}
+
+	// Nothing stripped, nothing shown.
+	if description := DescribeStrippedAffix("", ""); description != "" {
+		t.Errorf("Expected no description, got %q", description)
+	}
+	if description := DescribeStrippedAffix("", " (pid 7)"); description != "Stripped from every line: suffix \" (pid 7)\"" {
+		t.Errorf("Unexpected description %q", description)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestPreserveTabs
+// -------------------------------------------
+
+func TestPreserveTabs(t *testing.T) {
+
+	makeTabbedLine := func (rawText string) *diff.TextLine {
+		line := diff.NewTextLine(etc.ExpandTabs(rawText, 4))
+		line.RawText = rawText
+		return line
+	}
+
+	left := diff.ComparableLines{makeTabbedLine("\tkeep()"), makeTabbedLine("\tx\t= 1")}
+	right := diff.ComparableLines{makeTabbedLine("\tkeep()"), makeTabbedLine("\ty\t= 1")}
+
+	// By default the expanded text is shown.
+	page := generateTestPage(left, right, HtmlOptions{TabSize: 4})
+	if strings.Contains(page, "\t" + "keep()") || strings.Contains(page, "tab-size") {
+		t.Errorf("Expected no tabs without PreserveTabs")
+	}
+
+	page = generateTestPage(left, right, HtmlOptions{PreserveTabs: true, TabSize: 4})
+	if count := strings.Count(page, ">\tkeep()<"); count != 2 {
+		t.Errorf("Expected the matching line to keep its tab on both sides, got %d", count)
+	}
+	if !strings.Contains(page, "tab-size: 4") {
+		t.Errorf("Expected a tab-size style")
+	}
+
+	// The highlighted run of the changed line maps back onto the raw text.
+	if !strings.Contains(page, ">\t</span>") || !strings.Contains(page, ">x</span>") || !strings.Contains(page, ">\t= 1</span>") {
+		t.Errorf("Expected the changed line's spans to cover the raw text, got:\n%s", page)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestTabGuides
+// -------------------------------------------
+
+func TestTabGuides(t *testing.T) {
+
+	makeTabbedLine := func (rawText string) *diff.TextLine {
+		line := diff.NewTextLine(etc.ExpandTabs(rawText, 4))
+		line.RawText = rawText
+		return line
+	}
+
+	left := diff.ComparableLines{makeTabbedLine("\t\tkeep()"), makeTabbedLine("\tx = 1")}
+	right := diff.ComparableLines{makeTabbedLine("\t\tkeep()"), makeTabbedLine("\ty = 1")}
+	guide := generateElement(HtmlOptions{}, "span", "\t", tabGuideStyle)
+
+	page := generateTestPage(left, right, HtmlOptions{PreserveTabs: true, TabSize: 4})
+	if strings.Contains(page, "box-shadow") {
+		t.Errorf("Expected no tab guides without TabGuides")
+	}
+
+	// Every literal tab gets a guide, on matching and changed lines alike.
+	page = generateTestPage(left, right, HtmlOptions{PreserveTabs: true, TabGuides: true, TabSize: 4})
+	if count := strings.Count(page, guide + guide + "keep()"); count != 2 {
+		t.Errorf("Expected both tabs of the matching line to be guides on both sides, got %d", count)
+	}
+	if count := strings.Count(page, guide); count != 6 {
+		t.Errorf("Expected 6 tab guides, got %d:\n%s", count, page)
+	}
+	if strings.Contains(strings.Replace(page, guide, "", -1), "\t" + "keep()") {
+		t.Errorf("Expected no bare tabs left in the code lines")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestResponsiveLayout
+// -------------------------------------------
+
+func TestResponsiveLayout(t *testing.T) {
+
+	left, right := makeLines("same", "old line", "gone"), makeLines("same", "old line!")
+
+	// Without a breakpoint the page is plain side by side.
+	page := generateTestPage(left, right, HtmlOptions{})
+	if strings.Contains(page, "@media") || strings.Contains(page, "class=") {
+		t.Errorf("Expected no responsive markup without a breakpoint")
+	}
+
+	page = generateTestPage(left, right, HtmlOptions{Breakpoint: 640})
+	if !strings.Contains(page, "@media (max-width: 639px)") {
+		t.Errorf("Expected a media query for the breakpoint")
+	}
+
+	// The side-by-side layout is the default, and the inline layout is in the media query.
+	mediaPos := strings.Index(page, "@media")
+	for _, rule := range []string{".diffy-row tr { display: flex", ".diffy-left.diffy-num { order: 1; }", ".diffy-matching .diffy-right"} {
+		if pos := strings.Index(page, rule); pos < mediaPos {
+			t.Errorf("Expected the inline rule %q inside the media query", rule)
+		}
+	}
+	if !strings.Contains(page, "<table class='diffy-row'") || !strings.Contains(page, "<table class='diffy-row diffy-matching'") {
+		t.Errorf("Expected the rows to be classed for the style sheet")
+	}
+	if !strings.Contains(page, "class='diffy-right diffy-empty diffy-code'") {
+		t.Errorf("Expected the missing right line to be classed as empty")
+	}
+	if strings.Count(page, "<td class='diffy-gutter'") != 3 {
+		t.Errorf("Expected each row's gutter to be classed")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestWholeWordHighlight
+// -------------------------------------------
+
+func TestWholeWordHighlight(t *testing.T) {
+
+	left, right := diff.NewTextLine("the colour red"), diff.NewTextLine("the color red")
+	highlighted := func (text string) string {
+		return generateElement(HtmlOptions{}, "span", text, codeRunDifferentStyle)
+	}
+
+	// By default just the changed letter is highlighted.
+	leftHtml, _ := generateLineHtml(left, right, HtmlOptions{})
+	if !strings.Contains(leftHtml, highlighted("u")) {
+		t.Errorf("Expected just the \"u\" to be highlighted, got %s", leftHtml)
+	}
+
+	leftHtml, rightHtml := generateLineHtml(left, right, HtmlOptions{WholeWordHighlight: true})
+	if !strings.Contains(leftHtml, highlighted("colour")) || strings.Contains(leftHtml, highlighted("u")) {
+		t.Errorf("Expected the whole word to be highlighted, got %s", leftHtml)
+	}
+	if strings.Contains(rightHtml, "code-run-different") {
+		t.Errorf("Expected nothing highlighted on the right, got %s", rightHtml)
+	}
+
+	// What makes a word depends on the profile.
+	left, right = diff.NewTextLine("max_count = 1"), diff.NewTextLine("max_total = 1")
+	leftHtml, _ = generateLineHtml(left, right, HtmlOptions{WholeWordHighlight: true})
+	if !strings.Contains(leftHtml, highlighted("max_count")) {
+		t.Errorf("Expected the whole identifier to be highlighted, got %s", leftHtml)
+	}
+	leftHtml, _ = generateLineHtml(left, right, HtmlOptions{WholeWordHighlight: true, WordChars: diff.ProseWordChars})
+	if !strings.Contains(leftHtml, highlighted("count")) || strings.Contains(leftHtml, highlighted("max_count")) {
+		t.Errorf("Expected just the changed word of the identifier to be highlighted, got %s", leftHtml)
+	}
+}
+
+// ------------------------------------------- TestSnapRunPositionsToWords
+
+func TestSnapRunPositionsToWords(t *testing.T) {
+	testCases := []struct {
+		text string
+		runPositions, expected []int
+	}{
+		{"abc def", []int{0, 7}, []int{0, 7}},
+		{"abc def", []int{0, 1, 2, 7}, []int{0, 0, 3, 7}},
+		{"abc def", []int{0, 2, 5, 7}, []int{0, 0, 7}},
+		{"abc def", []int{0, 3, 4, 7}, []int{0, 3, 4, 7}},
+		{"abc, def", []int{0, 1, 2, 6, 7, 8}, []int{0, 0, 3, 5, 8}},
+		{"", []int{0, 0}, []int{0, 0}},
+	}
+	for _, testCase := range testCases {
+		result := snapRunPositionsToWords([]rune(testCase.text), testCase.runPositions, nil)
+		if fmt.Sprint(result) != fmt.Sprint(testCase.expected) {
+			t.Errorf("%q %v: expected %v, got %v", testCase.text, testCase.runPositions, testCase.expected, result)
+		}
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestLineIds
+// -------------------------------------------
+
+func TestLineIds(t *testing.T) {
+
+	var leftTexts, rightTexts []string
+	for i := 1; i <= 50; i++ {
+		leftTexts = append(leftTexts, fmt.Sprintf("line %d", i))
+		if i != 20 {
+			rightTexts = append(rightTexts, fmt.Sprintf("line %d", i))
+		}
+	}
+
+	page := generateTestPage(makeLines(leftTexts...), makeLines(rightTexts...), HtmlOptions{})
+	if count := strings.Count(page, "id='L-42'"); count != 1 {
+		t.Errorf("Expected the id for left line 42 exactly once, got %d", count)
+	}
+	if !strings.Contains(page, "<td id='L-42'") || !strings.Contains(page, "<td id='L-42-code'") {
+		t.Errorf("Expected both the line number and the code cells to carry ids")
+	}
+
+	// The deleted line has no right line number, and the right side is one behind after it.
+	if strings.Contains(page, "id='R-50'") || !strings.Contains(page, "id='R-49'") || !strings.Contains(page, "id='L-20'") {
+		t.Errorf("Expected ids based on each side's own line numbers")
+	}
+
+	page = generateTestPage(makeLines("a"), makeLines("b"), HtmlOptions{LineIdPrefix: "pair-0-1-"})
+	if !strings.Contains(page, "id='pair-0-1-L-1'") || strings.Contains(page, "id='L-1'") {
+		t.Errorf("Expected the ids to be prefixed")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestPathDisplay
+// -------------------------------------------
+
+func TestPathDisplay(t *testing.T) {
+
+	base := filepath.Join(string(filepath.Separator), "home", "someone", "project")
+	inside := NewSourceLinesRec(nil, filepath.Join(base, "src", "main.go"))
+	outside := NewSourceLinesRec(nil, filepath.Join(string(filepath.Separator), "etc", "passwd"))
+
+	testCases := []struct {
+		source *SourceLinesRec
+		display PathDisplay
+		expected string
+	}{
+		{inside, PathAbsolute, filepath.Join(base, "src", "main.go")},
+		{inside, PathRelative, filepath.Join("src", "main.go")},
+		{inside, PathNameOnly, ""},
+		{outside, PathRelative, "passwd"},		// no "../../../etc/passwd"
+	}
+	for _, testCase := range testCases {
+		if path := testCase.source.GetDisplayPath(testCase.display, base); path != testCase.expected {
+			t.Errorf("%q with display %d: expected %q, got %q", testCase.source.FilePath, testCase.display, testCase.expected, path)
+		}
+	}
+
+	// Relative to the current directory by default.
+	if path := NewSourceLinesRec(nil, filepath.Join("a", "b.txt")).GetDisplayPath(PathRelative, ""); path != filepath.Join("a", "b.txt") {
+		t.Errorf("Expected a path relative to the current directory, got %q", path)
+	}
+
+	// The page only shows what it's asked to.
+	lines := makeLines("same")
+	absolutePath := NewSourceLinesRec(nil, "left.txt").GetAbsoluteFilePath()
+	if page := generateTestPage(lines, lines, HtmlOptions{}); !strings.Contains(page, absolutePath) {
+		t.Errorf("Expected the absolute path by default")
+	}
+	if page := generateTestPage(lines, lines, HtmlOptions{PathDisplay: PathRelative}); strings.Contains(page, absolutePath) || strings.Count(page, ">left.txt<") != 2 {
+		t.Errorf("Expected just the relative path under the file name")
+	}
+	if page := generateTestPage(lines, lines, HtmlOptions{PathDisplay: PathNameOnly}); strings.Contains(page, absolutePath) || strings.Count(page, ">left.txt<") != 1 {
+		t.Errorf("Expected just the file name")
+	}
+
+	if _, ok := ParsePathDisplay("relative"); !ok {
+		t.Errorf("Expected \"relative\" to be a path display")
+	}
+	if _, ok := ParsePathDisplay("full"); ok {
+		t.Errorf("Expected \"full\" not to be a path display")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestGradedRuns
+// -------------------------------------------
+
+func TestGradedRuns(t *testing.T) {
+
+	// A one character change, and an eight character one.
+	spansHtml := constructGradedSpans([]rune("x.yyyyyyyy"), []int{0, 1, 2, 2, 10}, nil, HtmlOptions{})
+	expected := "<span>x</span><span style='background-color: #D8F8D8'>.</span><span></span><span style='background-color: #5CD65C'>yyyyyyyy</span>"
+	if spansHtml != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, spansHtml)
+	}
+	if gradedRunStyle(1).className == gradedRunStyle(8).className {
+		t.Errorf("Expected runs of different sizes to get different styles")
+	}
+
+	// Only with the option.
+	left, right := makeLines("total = count + 1;"), makeLines("total = count + offset;")
+	hasGradedColors := func (page string) bool {
+		for _, style := range []CssStyle{gradedRunStyle(1), gradedRunStyle(2), gradedRunStyle(8)} {
+			if strings.Contains(page, ConcatCssStyles(style)) {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasGradedColors(generateTestPage(left, right, HtmlOptions{GradedRuns: true})) {
+		t.Errorf("Expected graded colors with GradedRuns")
+	}
+	if hasGradedColors(generateTestPage(left, right, HtmlOptions{})) {
+		t.Errorf("Expected the flat color without GradedRuns")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestRunTooltips
+// -------------------------------------------
+
+func TestRunTooltips(t *testing.T) {
+
+	// Only the changed runs get a tooltip, with their own size.
+	spansHtml := constructEvenOddSpans([]rune("x.yyyy"), []int{0, 1, 2, 2, 6}, nullStyle, codeRunDifferentStyle, func (run []rune) string { return runTooltip(run, 0.5) }, HtmlOptions{})
+	expected := "<span>x</span>" +
+		"<span title='1 character changed; the lines are 50% similar' style='" + ConcatCssStyles(codeRunDifferentStyle) + "'>.</span>" +
+		"<span></span>" +
+		"<span title='4 characters changed; the lines are 50% similar' style='" + ConcatCssStyles(codeRunDifferentStyle) + "'>yyyy</span>"
+	if spansHtml != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, spansHtml)
+	}
+
+	// The similarity is the lines', as the diff measured it.
+	left, right := makeLines("total = count + 1;"), makeLines("total = count + offset;")
+	leftHtml, rightHtml := generateLineHtml(left[0], right[0], HtmlOptions{RunTooltips: true})
+	similarity := fmt.Sprintf("the lines are %.0f%% similar", left[0].Similarity(right[0]) * 100)
+	for _, spansHtml := range []string{leftHtml, rightHtml} {
+		if strings.Count(spansHtml, "<span title='") != strings.Count(spansHtml, ConcatCssStyles(codeRunDifferentStyle)) || !strings.Contains(spansHtml, similarity) {
+			t.Errorf("Expected a tooltip with %q on every changed run, got %s", similarity, spansHtml)
+		}
+	}
+	if !strings.Contains(rightHtml, "<span title='6 characters changed;") {
+		t.Errorf("Expected a tooltip for \"offset\", got %s", rightHtml)
+	}
+
+	// Graded runs get them too, and neither gets them without the option.
+	if leftHtml, _ := generateLineHtml(left[0], right[0], HtmlOptions{RunTooltips: true, GradedRuns: true}); !strings.Contains(leftHtml, similarity) {
+		t.Errorf("Expected tooltips on graded runs, got %s", leftHtml)
+	}
+	if strings.Contains(generateTestPage(left, right, HtmlOptions{}), "title=") {
+		t.Errorf("Expected no tooltips without RunTooltips")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestFocus
+// -------------------------------------------
+
+func TestFocus(t *testing.T) {
+
+	left, right := makeLines("same", "removed line", "also same"), makeLines("same", "also same", "added line")
+
+	// The line of the page with the element with the given id.
+	lineWithId := func (page, id string) string {
+		for _, line := range strings.Split(page, "\n") {
+			if strings.Contains(line, "id='" + id + "'") {
+				return line
+			}
+		}
+		t.Fatalf("No element with id %q in\n%s", id, page)
+		return ""
+	}
+	focusProperties, dimmedProperties := ConcatCssStyles(codeLineFocusStyle), ConcatCssStyles(codeLineDimmedStyle)
+
+	// Without a focus, nothing is emphasized or dimmed.
+	page := generateTestPage(left, right, HtmlOptions{})
+	if strings.Contains(page, focusProperties) || strings.Contains(page, dimmedProperties) {
+		t.Errorf("Expected no focus without a Focus")
+	}
+
+	// Focused on the additions, the added line stands out and the removed one is dimmed.
+	page = generateTestPage(left, right, HtmlOptions{Focus: FocusAdded})
+	if line := lineWithId(page, "R-3-code"); !strings.Contains(line, focusProperties) || strings.Contains(line, dimmedProperties) {
+		t.Errorf("Expected the added line to be emphasized, got %s", line)
+	}
+	if line := lineWithId(page, "L-2-code"); !strings.Contains(line, dimmedProperties) || strings.Contains(line, focusProperties) {
+		t.Errorf("Expected the removed line to be dimmed, got %s", line)
+	}
+	if line := lineWithId(page, "L-1-code"); !strings.Contains(line, dimmedProperties) {
+		t.Errorf("Expected the matching line to be dimmed, got %s", line)
+	}
+
+	// And the other way around.
+	page = generateTestPage(left, right, HtmlOptions{Focus: FocusRemoved})
+	if line := lineWithId(page, "L-2-code"); !strings.Contains(line, focusProperties) {
+		t.Errorf("Expected the removed line to be emphasized, got %s", line)
+	}
+	if line := lineWithId(page, "R-3-code"); !strings.Contains(line, dimmedProperties) {
+		t.Errorf("Expected the added line to be dimmed, got %s", line)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestNoRealign
+// -------------------------------------------
+
+func TestNoRealign(t *testing.T) {
+
+	left, right := makeLines("same", "the old line", "gone", "end"), makeLines("same", "zzz 123 +++", "end")
+	_, alignment := diff.Diff_v2(left, right)
+
+	expected := linkNumbers(alignment)
+
+	// Raw, the dissimilar pair is shown side by side, just as Diff_v2 paired it.
+	page := generateTestPage(left, right, HtmlOptions{NoRealign: true})
+	if rendered := renderedLinks(page); fmt.Sprint(rendered) != fmt.Sprint(expected) {
+		t.Errorf("Expected the rows %v, got %v", expected, rendered)
+	}
+
+	// Realigned, it isn't.
+	page = generateTestPage(left, right, HtmlOptions{})
+	if rendered := renderedLinks(page); fmt.Sprint(rendered) == fmt.Sprint(expected) {
+		t.Errorf("Expected realigning to change the rows %v", expected)
+	}
+}
+
+// ------------------------------------------- TestSimilarityCacheOption
+
+func TestSimilarityCacheOption(t *testing.T) {
+
+	left := makeLines("same", "the old line", "a changed line", "gone", "end")
+	right := makeLines("same", "zzz 123 +++", "a changed lint", "end")
+
+	// Choosing the adaptive threshold and realigning compare the same pairs,
+	// so the second pass is all hits, and the page is the same either way.
+	cache := diff.NewSimilarityCache()
+	cached := generateTestPage(left, right, HtmlOptions{AdaptiveRealign: &diff.DefaultAdaptiveThreshold, Compare: cache.Compare})
+	if page := generateTestPage(left, right, HtmlOptions{AdaptiveRealign: &diff.DefaultAdaptiveThreshold}); cached != page {
+		t.Errorf("Expected the cache not to change the page")
+	}
+	if cache.Misses == 0 || cache.Hits != cache.Misses {
+		t.Errorf("Expected every pair compared once and then found in the cache, got %d misses and %d hits", cache.Misses, cache.Hits)
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestGroupChanges
+// -------------------------------------------
+
+func TestGroupChanges(t *testing.T) {
+
+	// Three changed lines in a row, then a deleted and an inserted one.
+	left := makeLines("same", "alpha one", "beta two", "gamma three", "middle", "the old text", "end")
+	right := makeLines("same", "alpha one!", "beta two!", "gamma three!", "middle", "zzz 123 +++", "end")
+	blockProperties := ConcatCssStyles(changeBlockStyle)
+
+	// Row by row, every line gets its own table.
+	page := generateTestPage(left, right, HtmlOptions{})
+	if strings.Contains(page, blockProperties) {
+		t.Errorf("Expected no change blocks without GroupChanges")
+	}
+	ungroupedTables := strings.Count(page, "<table")
+
+	// Grouped, each run of changes is one table with several rows.
+	page = generateTestPage(left, right, HtmlOptions{GroupChanges: true})
+	if count := strings.Count(page, blockProperties); count != 2 {
+		t.Errorf("Expected 2 change blocks, got %d", count)
+	}
+	if count := strings.Count(page, "<table"); count != ungroupedTables - 3 {
+		t.Errorf("Expected the 3 Different rows and the 2 deleted and inserted rows to share 2 tables, got %d tables rather than %d", count, ungroupedTables)
+	}
+	for _, table := range strings.Split(page, "<table")[1:] {
+		if strings.Contains(table, "L-2-code") && (strings.Count(table, "<tr") != 3 || !strings.Contains(table, "L-4-code")) {
+			t.Errorf("Expected the run of three Different lines to be one table of three rows, got\n%s", table)
+		}
+	}
+	if strings.Count(page, "<table") != strings.Count(page, "</table>") {
+		t.Errorf("Expected the tables to be balanced")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestGroupByType
+// -------------------------------------------
+
+func TestGroupByType(t *testing.T) {
+
+	left := makeLines("same", "the changed line", "removed one", "same again", "another changed line", "removed two", "end")
+	right := makeLines("same", "the changed lime", "added one", "same again", "another changed lime", "added two", "added three", "end")
+	leftSource, rightSource := NewSourceLinesRec(left, "left.txt"), NewSourceLinesRec(right, "right.txt")
+	_, alignment := diff.Diff_v2(left, right)
+	opts := HtmlOptions{GroupBy: GroupByType}
+
+	var buffer bytes.Buffer
+	GenerateHtmlDiffPage(&buffer, alignment, leftSource, rightSource, opts)
+	page := buffer.String()
+
+	// Each section only has rows of its own type.
+	sections := map[string][]string{}
+	for _, section := range strings.Split(page, "id='section-")[1:] {
+		name := strings.SplitN(section, "'", 2)[0]
+		sections[name] = renderedLinks(section)
+	}
+	isOfType := map[string]func (link string) bool{
+		"added": func (link string) bool { return strings.HasPrefix(link, "-/") },
+		"removed": func (link string) bool { return strings.HasSuffix(link, "/-") },
+		"modified": func (link string) bool { return !strings.Contains(link, "-") },
+	}
+	if len(sections) != len(isOfType) {
+		t.Fatalf("Expected %d sections, got %v", len(isOfType), sections)
+	}
+	for name, links := range sections {
+		if len(links) == 0 {
+			t.Errorf("Expected some %s lines", name)
+		}
+		for _, link := range links {
+			if !isOfType[name](link) {
+				t.Errorf("Unexpected row %s in the %s section", link, name)
+			}
+		}
+	}
+
+	// Every changed link is in exactly one section, and nothing else is.
+	shownCounts := map[string]int{}
+	for _, links := range sections {
+		for _, link := range links {
+			shownCounts[link]++
+		}
+	}
+	displayAlignment := realignForDisplay(alignment, leftSource, rightSource, opts)
+	changedCount := 0
+	for index, link := range displayAlignment.Links {
+		number := linkNumbers(displayAlignment)[index]
+		if link.LinkType == diff.Matching {
+			if shownCounts[number] != 0 {
+				t.Errorf("Expected the matching row %s not to be shown", number)
+			}
+			continue
+		}
+		changedCount++
+		if shownCounts[number] != 1 {
+			t.Errorf("Expected the row %s once, got %d times", number, shownCounts[number])
+		}
+	}
+	if len(shownCounts) != changedCount {
+		t.Errorf("Expected %d rows, got %v", changedCount, shownCounts)
+	}
+
+	// The section titles count their lines.
+	if !strings.Contains(page, "Added (3 lines)") || !strings.Contains(page, "Removed (2 lines)") || !strings.Contains(page, "Modified (2 lines)") {
+		t.Errorf("Unexpected section titles in:\n%s", page)
+	}
+
+	// "--group-by" takes just the one name, besides the default.
+	for name, expected := range map[string]bool{"": true, "type": true, "file": false} {
+		if _, ok := ParseGroupBy(name); ok != expected {
+			t.Errorf("ParseGroupBy(%q): expected %v, got %v", name, expected, ok)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"diffy/diff"
+)
+
+// "json.go" - The alignment as JSON, for tools rather than people.
+
+// ------------------------------------------- JSON document types
+
+type tJsonDiff struct {
+	Left tJsonFile			`json:"left"`
+	Right tJsonFile			`json:"right"`
+	EditOps int				`json:"editOps"`
+	Links []tJsonLink		`json:"links"`
+}
+
+type tJsonFile struct {
+	Path string				`json:"path"`
+	Lines int				`json:"lines"`
+	FinalNewline bool		`json:"finalNewline"`
+}
+
+// Line numbers start from 1, and are left out for a side the link doesn't
+// have.  So is the confidence, for links with only one side, and so are the
+// stable line ids, unless they're asked for.
+type tJsonLink struct {
+	Type string				`json:"type"`
+	Left int				`json:"left,omitempty"`
+	Right int				`json:"right,omitempty"`
+	LeftId string			`json:"leftId,omitempty"`
+	RightId string			`json:"rightId,omitempty"`
+	Confidence *float32		`json:"confidence,omitempty"`
+}
+
+var jsonLinkTypeNames = map[diff.LinkType]string{
+	diff.Matching: "matching",
+	diff.Different: "different",
+	diff.LeftOnly: "left-only",
+	diff.RightOnly: "right-only",
+}
+
+// ------------------------------------------- GenerateJsonDiff
+//
+// Write the alignment as a JSON document, realigned just as it would be for
+// the HTML, so the links are the rows of the HTML page.  Only the realign
+// options and "StableLineIds" of "opts" apply.  Each link has a "confidence":
+// see diff.LinkConfidence.  The "editOps" are the "left-only" and "right-only"
+// links: see diff.Alignment.EditOps.
+//
+func GenerateJsonDiff(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) error {
+
+	alignment = realignForDisplay(alignment, leftSource, rightSource, opts)
+
+	document := tJsonDiff{
+		Left: tJsonFile{leftSource.FilePath, len(leftSource.Lines), leftSource.FinalNewline},
+		Right: tJsonFile{rightSource.FilePath, len(rightSource.Lines), rightSource.FinalNewline},
+		EditOps: alignment.EditOps(),
+		Links: make([]tJsonLink, len(alignment.Links)),
+	}
+	leftLineIds, rightLineIds := stableLineIds(leftSource, opts), stableLineIds(rightSource, opts)
+	for index, link := range alignment.Links {
+		jsonLink := tJsonLink{Type: jsonLinkTypeNames[link.LinkType], Left: link.LeftIndex + 1, Right: link.RightIndex + 1}
+		if leftLineIds != nil && link.LeftIndex >= 0 {
+			jsonLink.LeftId = leftLineIds[link.LeftIndex]
+		}
+		if rightLineIds != nil && link.RightIndex >= 0 {
+			jsonLink.RightId = rightLineIds[link.RightIndex]
+		}
+		if confidence := diff.LinkConfidence(link, leftSource.Compared(), rightSource.Compared()); confidence != diff.NO_CONFIDENCE {
+			jsonLink.Confidence = &confidence
+		}
+		document.Links[index] = jsonLink
+	}
+
+	encoder := json.NewEncoder(outputFile)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+// ------------------------------------------- ReadJsonAlignment
+//
+// Read back the alignment from a document written by GenerateJsonDiff, e.g.
+// to compare the alignments of two runs with CompareAlignments.  Since the
+// document was realigned, so is the alignment.
+//
+func ReadJsonAlignment(inputFile io.Reader) (*diff.Alignment, error) {
+	var document tJsonDiff
+	if err := json.NewDecoder(inputFile).Decode(&document); err != nil {
+		return nil, err
+	}
+	alignment := &diff.Alignment{Links: make([]diff.Link, len(document.Links))}
+	for index, jsonLink := range document.Links {
+		linkType, found := parseJsonLinkType(jsonLink.Type)
+		if !found {
+			return nil, fmt.Errorf("link %d has an unknown type %q", index + 1, jsonLink.Type)
+		}
+		alignment.Links[index] = diff.Link{LinkType: linkType, LeftIndex: jsonLink.Left - 1, RightIndex: jsonLink.Right - 1}
+	}
+	return alignment, nil
+}
+
+func parseJsonLinkType(name string) (diff.LinkType, bool) {
+	for linkType, linkTypeName := range jsonLinkTypeNames {
+		if name == linkTypeName {
+			return linkType, true
+		}
+	}
+	return 0, false
+}
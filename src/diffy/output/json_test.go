@@ -0,0 +1,79 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestJsonDiff
+
+func TestJsonDiff(t *testing.T) {
+
+	left := makeLines("unchanged", "the quick brown fox jumps over the lazy dog", "gone")
+	right := makeLines("unchanged", "the quick brown fox jumps over the lazy hog", "1234567890")
+	alignment := &diff.Alignment{Links: []diff.Link{
+		{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0},
+		{LinkType: diff.Different, LeftIndex: 1, RightIndex: 1},
+		{LinkType: diff.Different, LeftIndex: 2, RightIndex: 2},
+	}}
+	leftSource, rightSource := NewSourceLinesRec(left, "old.txt"), NewSourceLinesRec(right, "new.txt")
+	rightSource.FinalNewline = false
+
+	var buffer bytes.Buffer
+	if err := GenerateJsonDiff(&buffer, alignment, leftSource, rightSource, HtmlOptions{}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	var document struct {
+		Left, Right struct {
+			Path string
+			Lines int
+			FinalNewline bool
+		}
+		EditOps int
+		Links []struct {
+			Type string
+			Left, Right int
+			Confidence *float32
+		}
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("Could not parse the JSON: %v\n%s", err, buffer.String())
+	}
+
+	if document.Left.Path != "old.txt" || document.Left.Lines != 3 || !document.Left.FinalNewline {
+		t.Errorf("Unexpected left file %+v", document.Left)
+	}
+	if document.Right.Path != "new.txt" || document.Right.FinalNewline {
+		t.Errorf("Unexpected right file %+v", document.Right)
+	}
+
+	// The dissimilar pair is split by the realignment, into two edit ops.
+	if document.EditOps != 2 {
+		t.Errorf("Expected 2 edit ops, got %d", document.EditOps)
+	}
+	expected := []struct {
+		linkType string
+		left, right int
+		hasConfidence bool
+	}{
+		{"matching", 1, 1, true},
+		{"different", 2, 2, true},
+		{"left-only", 3, 0, false},
+		{"right-only", 0, 3, false},
+	}
+	if len(document.Links) != len(expected) {
+		t.Fatalf("Expected %d links, got\n%s", len(expected), buffer.String())
+	}
+	for index, link := range document.Links {
+		if link.Type != expected[index].linkType || link.Left != expected[index].left || link.Right != expected[index].right || (link.Confidence != nil) != expected[index].hasConfidence {
+			t.Errorf("Link %d: unexpected %+v", index, link)
+		}
+	}
+	if *document.Links[0].Confidence != 1.0 || *document.Links[1].Confidence >= 1.0 || *document.Links[1].Confidence < 0.9 {
+		t.Errorf("Unexpected confidences %v and %v", *document.Links[0].Confidence, *document.Links[1].Confidence)
+	}
+}
@@ -0,0 +1,56 @@
+package output
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// "line-ids.go" - Ids for lines based on their content rather than their
+// position, so a review comment anchored to a line stays with it when the
+// diff is run again after lines are added or removed above it.
+
+// ------------------------------------------- SourceLinesRec StableLineIds method
+//
+// An id for each line: a short hash of its text, e.g. "3f9a2c1d-1", with a
+// counter after it telling lines which hash the same apart, so the second
+// blank line is "...-2" and so on.  An id only changes when the line's own
+// text does, or when a line which hashes the same is added or removed above
+// it.
+//
+func (source *SourceLinesRec) StableLineIds() []string {
+	ids := make([]string, len(source.Lines))
+	counts := map[uint32]int{}
+	for index, line := range source.Lines {
+		text := line.RawText
+		if text == "" {
+			text = line.Text
+		}
+		hasher := fnv.New32a()
+		hasher.Write([]byte(text))
+		hash := hasher.Sum32()
+		counts[hash]++
+		ids[index] = fmt.Sprintf("%08x-%d", hash, counts[hash])
+	}
+	return ids
+}
+
+// ------------------------------------------- stableLineIds
+
+// The stable ids of the lines of "source" if "opts" asks for them, or nil.
+func stableLineIds(source *SourceLinesRec, opts HtmlOptions) []string {
+	if !opts.StableLineIds {
+		return nil
+	}
+	return source.StableLineIds()
+}
+
+// ------------------------------------------- setElementLineId
+//
+// setElementLineId("<td>...</td>", "td", ids, 4) => "<td data-line-id='3f9a2c1d-1'>...</td>"
+// Like setElementId, for the id of line "index", if there are ids and a line.
+func setElementLineId(elementHtml string, tagName string, ids []string, index int) string {
+	if ids == nil || index < 0 {
+		return elementHtml
+	}
+	return "<" + tagName + " data-line-id='" + ids[index] + "'" + elementHtml[len(tagName) + 1:]
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestStableLineIds
+
+func TestStableLineIds(t *testing.T) {
+
+	ids := NewSourceLinesRec(makeLines("func main() {", "", "	run()", "", "}"), "main.go").StableLineIds()
+
+	// Lines added above a line leave its id alone.
+	inserted := NewSourceLinesRec(makeLines("// A comment.", "import \"os\"", "func main() {", "", "	run()", "", "}"), "main.go").StableLineIds()
+	if inserted[2] != ids[0] || inserted[4] != ids[2] || inserted[6] != ids[4] {
+		t.Errorf("Expected the ids to survive the insertion, got %v and %v", ids, inserted)
+	}
+
+	// A changed line gets a new id, and its neighbors don't.
+	changed := NewSourceLinesRec(makeLines("func main() {", "", "	run(os.Args)", "", "}"), "main.go").StableLineIds()
+	if changed[2] == ids[2] || changed[0] != ids[0] || changed[4] != ids[4] {
+		t.Errorf("Expected just the changed line's id to change, got %v and %v", ids, changed)
+	}
+
+	// Duplicates are told apart.
+	if ids[1] == ids[3] || !strings.HasSuffix(ids[1], "-1") || !strings.HasSuffix(ids[3], "-2") {
+		t.Errorf("Expected the blank lines to be numbered, got %q and %q", ids[1], ids[3])
+	}
+}
+
+// ------------------------------------------- TestStableLineIdsOutput
+
+func TestStableLineIdsOutput(t *testing.T) {
+
+	left, right := makeLines("one", "two", "three"), makeLines("one", "2", "three")
+	_, alignment := diff.Diff_v2(left, right)
+	leftSource, rightSource := NewSourceLinesRec(left, "left.txt"), NewSourceLinesRec(right, "right.txt")
+	leftIds, rightIds := leftSource.StableLineIds(), rightSource.StableLineIds()
+
+	var page bytes.Buffer
+	GenerateHtmlDiffPage(&page, alignment, leftSource, rightSource, HtmlOptions{StableLineIds: true})
+	for _, id := range append(leftIds, rightIds...) {
+		if !strings.Contains(page.String(), "data-line-id='" + id + "'") {
+			t.Errorf("Expected the HTML to have the line id %q", id)
+		}
+	}
+
+	var document bytes.Buffer
+	if err := GenerateJsonDiff(&document, alignment, leftSource, rightSource, HtmlOptions{StableLineIds: true}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !strings.Contains(document.String(), "\"leftId\": \"" + leftIds[1] + "\"") || !strings.Contains(document.String(), "\"rightId\": \"" + rightIds[1] + "\"") {
+		t.Errorf("Expected the JSON to have the ids of the changed lines, got:\n%s", document.String())
+	}
+
+	// Without the option, there are none.
+	page.Reset()
+	GenerateHtmlDiffPage(&page, alignment, leftSource, rightSource, HtmlOptions{})
+	document.Reset()
+	GenerateJsonDiff(&document, alignment, leftSource, rightSource, HtmlOptions{})
+	if strings.Contains(page.String(), "data-line-id") || strings.Contains(document.String(), "Id\"") {
+		t.Errorf("Expected no line ids by default")
+	}
+}
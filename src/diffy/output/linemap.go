@@ -0,0 +1,58 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"diffy/diff"
+)
+
+// "linemap.go" - A table mapping each line of either file to its counterpart
+// in the other, for tools which carry positions forward from one version of
+// a file to the next, e.g. review comments.
+
+// ------------------------------------------- JSON document types
+
+// Line numbers start from 1.  A null entry is a line with no counterpart:
+// a deleted line in "leftToRight", or an inserted one in "rightToLeft".
+type tJsonLineMap struct {
+	Left tJsonFile			`json:"left"`
+	Right tJsonFile			`json:"right"`
+	LeftToRight []*int		`json:"leftToRight"`
+	RightToLeft []*int		`json:"rightToLeft"`
+}
+
+// ------------------------------------------- GenerateLineMap
+//
+// Write the alignment as a JSON line map, realigned just as it would be for
+// the HTML, so a line maps to the line it's shown beside.  Changed lines map
+// to each other just as matching lines do.  Only the realign options of
+// "opts" apply.
+//
+func GenerateLineMap(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) error {
+
+	alignment = realignForDisplay(alignment, leftSource, rightSource, opts)
+
+	// Map the lines of one side through the links covering them.
+	mapLines := func (lineCount int, lookup func (int) (diff.Link, bool), otherIndex func (diff.Link) int) []*int {
+		lineMap := make([]*int, lineCount)
+		for index := range lineMap {
+			if link, ok := lookup(index); ok && otherIndex(link) >= 0 {
+				lineNumber := otherIndex(link) + 1
+				lineMap[index] = &lineNumber
+			}
+		}
+		return lineMap
+	}
+
+	lookup := diff.NewLinkLookup(alignment)
+	document := tJsonLineMap{
+		Left: tJsonFile{leftSource.FilePath, len(leftSource.Lines), leftSource.FinalNewline},
+		Right: tJsonFile{rightSource.FilePath, len(rightSource.Lines), rightSource.FinalNewline},
+		LeftToRight: mapLines(len(leftSource.Lines), lookup.Left, func (link diff.Link) int { return link.RightIndex }),
+		RightToLeft: mapLines(len(rightSource.Lines), lookup.Right, func (link diff.Link) int { return link.LeftIndex }),
+	}
+
+	encoder := json.NewEncoder(outputFile)
+	return encoder.Encode(document)
+}
@@ -0,0 +1,78 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestLineMap
+
+func TestLineMap(t *testing.T) {
+
+	left := makeLines("unchanged", "deleted", "the quick brown fox jumps over the lazy dog", "tail")
+	right := makeLines("unchanged", "the quick brown fox jumps over the lazy hog", "inserted", "tail")
+	alignment := &diff.Alignment{Links: []diff.Link{
+		{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0},
+		{LinkType: diff.LeftOnly, LeftIndex: 1, RightIndex: -1},
+		{LinkType: diff.Different, LeftIndex: 2, RightIndex: 1},
+		{LinkType: diff.RightOnly, LeftIndex: -1, RightIndex: 2},
+		{LinkType: diff.Matching, LeftIndex: 3, RightIndex: 3},
+	}}
+	leftSource, rightSource := NewSourceLinesRec(left, "old.txt"), NewSourceLinesRec(right, "new.txt")
+
+	var buffer bytes.Buffer
+	if err := GenerateLineMap(&buffer, alignment, leftSource, rightSource, HtmlOptions{}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	var document struct {
+		Left, Right struct {
+			Path string
+			Lines int
+		}
+		LeftToRight, RightToLeft []*int
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("Could not parse the JSON: %v\n%s", err, buffer.String())
+	}
+	if document.Left.Path != "old.txt" || document.Right.Lines != 4 {
+		t.Errorf("Unexpected files %+v, %+v", document.Left, document.Right)
+	}
+
+	// Zero stands for null, i.e. no counterpart.
+	flatten := func (lineMap []*int) []int {
+		flat := make([]int, len(lineMap))
+		for index, lineNumber := range lineMap {
+			if lineNumber != nil {
+				flat[index] = *lineNumber
+			}
+		}
+		return flat
+	}
+	leftToRight, rightToLeft := flatten(document.LeftToRight), flatten(document.RightToLeft)
+	expectedLeftToRight, expectedRightToLeft := []int{1, 0, 2, 4}, []int{1, 3, 0, 4}
+	if len(leftToRight) != len(expectedLeftToRight) || len(rightToLeft) != len(expectedRightToLeft) {
+		t.Fatalf("Expected a map entry for every line, got\n%s", buffer.String())
+	}
+	for index := range expectedLeftToRight {
+		if leftToRight[index] != expectedLeftToRight[index] || rightToLeft[index] != expectedRightToLeft[index] {
+			t.Errorf("Expected %v and %v, got %v and %v", expectedLeftToRight, expectedRightToLeft, leftToRight, rightToLeft)
+			break
+		}
+	}
+
+	// The two directions agree: a line maps back to itself.
+	for leftIndex, rightLine := range leftToRight {
+		if rightLine != 0 && rightToLeft[rightLine - 1] != leftIndex + 1 {
+			t.Errorf("Left line %d maps to right line %d, which maps to %d", leftIndex + 1, rightLine, rightToLeft[rightLine - 1])
+		}
+	}
+	for rightIndex, leftLine := range rightToLeft {
+		if leftLine != 0 && leftToRight[leftLine - 1] != rightIndex + 1 {
+			t.Errorf("Right line %d maps to left line %d, which maps to %d", rightIndex + 1, leftLine, leftToRight[leftLine - 1])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "markdown.go" - The changes as a fenced "diff" code block, for pasting into
+// issue trackers and wikis which render Markdown and highlight diffs.
+
+// ------------------------------------------- GenerateMarkdownDiff
+//
+// Write the hunks, with the "HunkContext" of "opts", as a fenced code
+// block tagged "diff", so "+" and "-" lines get the renderer's highlighting.
+// With "hunkHeaders", each hunk starts with its "@@ -l,n +r,m @@" line, just
+// as in a unified diff.  Identical files write nothing.
+//
+// Nothing inside a fenced block can be escaped, so instead the fence is made
+// longer than the longest run of backticks in the lines, which is what
+// CommonMark requires to close the block.  Otherwise only the realign options
+// of "opts" apply.
+//
+func GenerateMarkdownDiff(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, hunkHeaders bool, opts HtmlOptions) {
+
+	var body strings.Builder
+	for _, hunkText := range formatUnifiedHunks(alignment, leftSource, rightSource, opts) {
+		if !hunkHeaders {
+			hunkText = hunkText[strings.Index(hunkText, "\n") + 1:]
+		}
+		body.WriteString(hunkText)
+	}
+	if body.Len() == 0 {
+		return
+	}
+
+	fence := strings.Repeat("`", longestBacktickRun(body.String()) + 1)
+	if len(fence) < 3 {
+		fence = "```"
+	}
+	fmt.Fprintf(outputFile, "%sdiff\n", fence)
+	fmt.Fprint(outputFile, body.String())
+	fmt.Fprintln(outputFile, fence)
+}
+
+// ------------------------------------------- longestBacktickRun
+
+func longestBacktickRun(s string) int {
+	longest, run := 0, 0
+	for _, char := range s {
+		if char == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
@@ -0,0 +1,66 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- helper functions
+
+func generateTestMarkdown(leftLines, rightLines []string, hunkHeaders bool) string {
+	left, right := makeLines(leftLines...), makeLines(rightLines...)
+	_, alignment := diff.Diff_v2(left, right)
+
+	var buffer bytes.Buffer
+	GenerateMarkdownDiff(&buffer, alignment, NewSourceLinesRec(left, "left.md"), NewSourceLinesRec(right, "right.md"), hunkHeaders, HtmlOptions{})
+	return buffer.String()
+}
+
+// ------------------------------------------- TestMarkdownDiff
+
+func TestMarkdownDiff(t *testing.T) {
+
+	left := []string{"one", "two", "three", "four", "five"}
+	right := []string{"one", "two", "three", "4", "five"}
+
+	expected := "```diff\n@@ -1,5 +1,5 @@\n one\n two\n three\n-four\n+4\n five\n```\n"
+	if markdown := generateTestMarkdown(left, right, true); markdown != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, markdown)
+	}
+
+	expected = "```diff\n one\n two\n three\n-four\n+4\n five\n```\n"
+	if markdown := generateTestMarkdown(left, right, false); markdown != expected {
+		t.Errorf("Expected without headers\n%s\ngot\n%s", expected, markdown)
+	}
+
+	if markdown := generateTestMarkdown(left, left, true); markdown != "" {
+		t.Errorf("Expected nothing for identical files, got\n%s", markdown)
+	}
+}
+
+// ------------------------------------------- TestMarkdownDiffBackticks
+
+func TestMarkdownDiffBackticks(t *testing.T) {
+
+	left := []string{"Some docs:", "```go", "x := 1", "```"}
+	right := []string{"Some docs:", "```go", "x := 2", "```"}
+	markdown := generateTestMarkdown(left, right, true)
+
+	// The fence is longer than any run of backticks inside it, so the lines
+	// with "```" can't close it early.
+	lines := strings.Split(strings.TrimSuffix(markdown, "\n"), "\n")
+	if lines[0] != "````diff" || lines[len(lines) - 1] != "````" {
+		t.Errorf("Expected a four backtick fence, got\n%s", markdown)
+	}
+	for _, line := range lines[1:len(lines) - 1] {
+		if strings.HasPrefix(line, "````") {
+			t.Errorf("Line %q would close the fence", line)
+		}
+	}
+	if !strings.Contains(markdown, "\n ```go\n-x := 1\n+x := 2\n ```\n") {
+		t.Errorf("Expected the code block lines to be kept as they are, got\n%s", markdown)
+	}
+}
@@ -0,0 +1,140 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"io"
+
+	"diffy/diff"
+)
+
+// "matrix.go" - An HTML report for several files at once: a table of the
+// pairwise similarities, followed by the side-by-side diff of every pair which
+// isn't identical.  Each cell of the table links to the diff for its pair.
+
+// ------------------------------------------- CSS style definitions
+
+var matrixTableStyle CssStyle = MakeCssStyle("matrix-table",
+	"margin: 10px",
+	"border-collapse: collapse",
+	"font-family: monospace",
+	"font-size: 10pt",
+)
+
+var matrixHeadingStyle CssStyle = MakeCssStyle("matrix-heading",
+	"padding: 5px",
+	"border: solid #696969 1px",
+	"background-color: #4682B4",
+	"color: white",
+)
+
+var matrixCellStyle CssStyle = MakeCssStyle("matrix-cell",
+	"padding: 5px",
+	"border: solid #696969 1px",
+	"text-align: right",
+)
+
+var matrixCellIdenticalStyle CssStyle = MakeCssStyle("matrix-cell-identical",
+	"background-color: #F0F0F0",
+	"color: #696969",
+)
+
+var matrixCellDifferentStyle CssStyle = MakeCssStyle("matrix-cell-different",
+	"background-color: #FFFFE0",
+)
+
+var matrixCellGeneratedStyle CssStyle = MakeCssStyle("matrix-cell-generated",
+	"background-color: #F0F0F0",
+	"color: #696969",
+	"font-style: italic",
+)
+
+var matrixPairHeadingStyle CssStyle = MakeCssStyle("matrix-pair-heading",
+	"margin-top: 20px",
+	"font-family: monospace",
+	"font-size: 12pt",
+	"font-weight: bold",
+)
+
+// ------------------------------------------- GenerateHtmlMatrixPage
+//
+// Generate the matrix report for "sources", where "matrix" is the result of
+// diff.DiffMatrix on the sources' lines.  Cell [i][j] compares the file in row
+// i with the file in column j.
+//
+func GenerateHtmlMatrixPage(outputFile io.Writer, sources []*SourceLinesRec, matrix [][]diff.MatrixCell, opts HtmlOptions) {
+
+	outputFile = generatePagePrologue(outputFile, opts)
+
+	// The table of pairwise similarities.
+	fmt.Fprintf(outputFile, "		%s\n", generateStartTag(opts, "table", matrixTableStyle))
+	fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+	fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "th", "", matrixHeadingStyle))
+	for _, source := range sources {
+		fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "th", matrixFileNameHtml(source), matrixHeadingStyle))
+	}
+	fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
+	for i, source := range sources {
+		fmt.Fprintf(outputFile, "			%s\n", generateStartTag(opts, "tr"))
+		fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "th", matrixFileNameHtml(source), matrixHeadingStyle))
+		for j := range sources {
+			cell := matrix[i][j]
+			cellHtml := "identical"
+			if cell.Err != nil {
+				cellHtml = "<span title=\"" + html.EscapeString(cell.Err.Error()) + "\">error</span>"
+			} else if cell.Generated {
+				cellHtml = "generated"
+			} else if !cell.Identical {
+				cellHtml = fmt.Sprintf("<a href=\"#%s\">%.0f%% (%.2f)</a>", matrixPairId(i, j), cell.Similarity * 100, cell.Distance)
+			}
+			cellStyles := []CssStyle{
+				matrixCellStyle,
+				matrixCellIdenticalStyle.when(cell.Identical),
+				matrixCellDifferentStyle.when(!cell.Identical && !cell.Generated),
+				matrixCellGeneratedStyle.when(cell.Generated),
+			}
+			fmt.Fprintf(outputFile, "				%s\n", generateElement(opts, "td", cellHtml, cellStyles...))
+		}
+		fmt.Fprintf(outputFile, "			%s\n", generateEndTag("tr"))
+	}
+	fmt.Fprintf(outputFile, "		%s\n", generateEndTag("table"))
+	fmt.Fprintln(outputFile, "")
+
+	// The diff for each pair of files which aren't identical.
+	for i := range sources {
+		for j := i + 1; j < len(sources); j++ {
+			if matrix[i][j].Identical || matrix[i][j].Err != nil || matrix[i][j].Generated {
+				continue
+			}
+			pairTitle := html.EscapeString(sources[i].GetFileName() + " vs " + sources[j].GetFileName())
+			fmt.Fprintf(outputFile, "		<div id=\"%s\">\n", matrixPairId(i, j))
+			fmt.Fprintf(outputFile, "		%s\n", generateElement(opts, "div", pairTitle, matrixPairHeadingStyle))
+			pairOpts := opts
+			pairOpts.LineIdPrefix = opts.LineIdPrefix + matrixPairId(i, j) + "-"
+			generateHtmlDiffTables(outputFile, matrix[i][j].Alignment, sources[i], sources[j], pairOpts)
+			fmt.Fprintln(outputFile, "		</div>")
+		}
+	}
+
+	generatePageEpilogue(outputFile, opts)
+}
+
+// ------------------------------------------- matrixFileNameHtml
+
+func matrixFileNameHtml(source *SourceLinesRec) string {
+	if source.Generated {
+		return html.EscapeString(source.GetFileName()) + " (generated)"
+	}
+	return html.EscapeString(source.GetFileName())
+}
+
+// ------------------------------------------- matrixPairId
+//
+// The HTML id of the diff for files "i" and "j".  There's only one diff per
+// unordered pair, so [i][j] and [j][i] share an id.
+func matrixPairId(i, j int) string {
+	if j < i {
+		i, j = j, i
+	}
+	return fmt.Sprintf("pair-%d-%d", i, j)
+}
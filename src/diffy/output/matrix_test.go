@@ -0,0 +1,122 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestMatrixPage
+
+func TestMatrixPage(t *testing.T) {
+
+	files := []diff.ComparableLines{
+		makeLines("a", "b", "c"),
+		makeLines("a", "b", "c"),
+		makeLines("a", "x", "c"),
+	}
+	var sources []*SourceLinesRec
+	for i, lines := range files {
+		sources = append(sources, NewSourceLinesRec(lines, []string{"one.conf", "two.conf", "three.conf"}[i]))
+	}
+	matrix := diff.DiffMatrix(files, diff.Diff_v2)
+
+	var buffer bytes.Buffer
+	GenerateHtmlMatrixPage(&buffer, sources, matrix, HtmlOptions{})
+	page := buffer.String()
+
+	// Only the pairs which differ get a diff, and both of their cells link to it.
+	for _, id := range []string{"pair-0-2", "pair-1-2"} {
+		if count := strings.Count(page, "id=\"" + id + "\""); count != 1 {
+			t.Errorf("Expected one diff with id %q, got %d", id, count)
+		}
+		if count := strings.Count(page, "href=\"#" + id + "\""); count != 2 {
+			t.Errorf("Expected two links to %q, got %d", id, count)
+		}
+	}
+	if strings.Contains(page, "pair-0-1") {
+		t.Errorf("Expected no diff for the identical pair")
+	}
+	if count := strings.Count(page, "<html>"); count != 1 {
+		t.Errorf("Expected a single page, got %d <html> tags", count)
+	}
+}
+
+// ------------------------------------------- TestMatrixPageGenerated
+
+func TestMatrixPageGenerated(t *testing.T) {
+
+	files := []diff.ComparableLines{
+		makeLines("// Code generated by stringer; DO NOT EDIT.", "const a = 1"),
+		makeLines("// Code generated by stringer; DO NOT EDIT.", "const a = 2"),
+		makeLines("const a = 3"),
+	}
+	var sources []*SourceLinesRec
+	for i, lines := range files {
+		sources = append(sources, NewSourceLinesRec(lines, []string{"a_string.go", "b_string.go", "c.go"}[i]))
+	}
+	sources[0].Generated, sources[1].Generated = true, true
+	matrix := diff.DiffMatrixSkippingGenerated(files, diff.Diff_v2, 1, []bool{true, true, false})
+
+	var buffer bytes.Buffer
+	GenerateHtmlMatrixPage(&buffer, sources, matrix, HtmlOptions{})
+	page := buffer.String()
+
+	// The generated files are named as such, and none of their pairs has a diff.
+	if count := strings.Count(page, "a_string.go (generated)"); count != 2 {
+		t.Errorf("Expected the generated file marked in both headings, got %d", count)
+	}
+	if strings.Contains(page, "c.go (generated)") {
+		t.Errorf("Expected the ungenerated file unmarked")
+	}
+	if count := strings.Count(page, ">generated</td>"); count != 6 {
+		t.Errorf("Expected six generated cells, got %d", count)
+	}
+	if strings.Contains(page, "id=\"pair-") {
+		t.Errorf("Expected no diffs")
+	}
+}
+
+// ------------------------------------------- TestMatrixPageJobs
+
+func TestMatrixPageJobs(t *testing.T) {
+
+	var files []diff.ComparableLines
+	var sources []*SourceLinesRec
+	for _, name := range []string{"a.conf", "b.conf", "c.conf", "d.conf", "e.conf"} {
+		lines := makeLines("shared", "name = " + name, "shared too")
+		files = append(files, lines)
+		sources = append(sources, NewSourceLinesRec(lines, name))
+	}
+
+	generatePage := func (matrix [][]diff.MatrixCell) string {
+		var buffer bytes.Buffer
+		GenerateHtmlMatrixPage(&buffer, sources, matrix, HtmlOptions{})
+		return buffer.String()
+	}
+
+	// The page is the same however many pairs are diffed at once.
+	expected := generatePage(diff.DiffMatrixWithJobs(files, diff.Diff_v2, 1))
+	for _, jobs := range []int{2, 3, 8} {
+		if page := generatePage(diff.DiffMatrixWithJobs(files, diff.Diff_v2, jobs)); page != expected {
+			t.Errorf("Expected the same page with %d jobs", jobs)
+		}
+	}
+	if strings.Index(expected, "id=\"pair-0-1\"") > strings.Index(expected, "id=\"pair-3-4\"") {
+		t.Errorf("Expected the pairs in input order")
+	}
+
+	// A failed pair is reported in its cells, and has no diff.
+	matrix := diff.DiffMatrixWithJobs(files, diff.Diff_v2, 4)
+	matrix[0][1] = diff.MatrixCell{Err: errors.New("out of <memory>")}
+	page := generatePage(matrix)
+	if !strings.Contains(page, "title=\"out of &lt;memory&gt;\">error</span>") {
+		t.Errorf("Expected the failed pair's cell to show the error")
+	}
+	if strings.Contains(page, "id=\"pair-0-1\"") {
+		t.Errorf("Expected no diff for the failed pair")
+	}
+}
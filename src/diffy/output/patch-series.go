@@ -0,0 +1,91 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "patch-series.go" - The unified diffs of several files as one patch, in
+// the style of "git diff", which "git apply" or "patch -p1" can apply in one
+// go to turn one tree into another.
+
+// -------------------------------------------
+// ------------------------------------------- type PatchFile
+// -------------------------------------------
+
+// A PatchFile is one file of a patch series: its path relative to the roots
+// of the two trees, and its lines on either side.  A file which is only in the
+// left tree has no Right, and a file which is only in the right tree has no
+// Left.  If the Alignment is nil, the lines are diffed with diff.Diff_v2.  A
+// Generated file isn't diffed at all; the patch just says that it differs,
+// in a line which "git apply" skips.
+
+type PatchFile struct {
+	Path string
+	Left, Right *SourceLinesRec
+	Alignment *diff.Alignment
+	Generated bool
+}
+
+// The mode "git apply" gives the files a patch series creates.
+const PATCH_FILE_MODE = "100644"
+
+// ------------------------------------------- GeneratePatchSeries
+//
+// Write the changes to "files" as a single patch, with a "diff --git" header
+// for each file which changed.  The paths are prefixed with "a/" and "b/", so
+// the patch applies with "-p1".  A file on one side only is patched in or out
+// whole, against "/dev/null".  Identical files write nothing.  Only the
+// realign options and the "HunkContext" of "opts" apply.
+//
+func GeneratePatchSeries(outputFile io.Writer, files []PatchFile, opts HtmlOptions) {
+	for _, file := range files {
+		path := filepath.ToSlash(file.Path)
+		if file.Generated {
+			fmt.Fprintf(outputFile, "Generated files a/%s and b/%s differ\n", path, path)
+			continue
+		}
+		leftSource, rightSource := file.Left, file.Right
+		if leftSource == nil {
+			leftSource = NewSourceLinesRec(nil, "/dev/null")
+		}
+		if rightSource == nil {
+			rightSource = NewSourceLinesRec(nil, "/dev/null")
+		}
+		alignment := file.Alignment
+		if alignment == nil {
+			_, alignment = diff.Diff_v2(leftSource.Lines, rightSource.Lines)
+		}
+		hunks := formatUnifiedHunks(alignment, leftSource, rightSource, opts)
+		if len(hunks) == 0 && file.Left != nil && file.Right != nil {
+			continue
+		}
+
+		fmt.Fprintf(outputFile, "diff --git a/%s b/%s\n", path, path)
+		switch {
+		case file.Left == nil:
+			fmt.Fprintf(outputFile, "new file mode %s\n", PATCH_FILE_MODE)
+		case file.Right == nil:
+			fmt.Fprintf(outputFile, "deleted file mode %s\n", PATCH_FILE_MODE)
+		}
+
+		// An empty file is created or deleted by its header alone.
+		if len(hunks) == 0 {
+			continue
+		}
+		leftPath, rightPath := "a/" + path, "b/" + path
+		if file.Left == nil {
+			leftPath = "/dev/null"
+		}
+		if file.Right == nil {
+			rightPath = "/dev/null"
+		}
+		fmt.Fprintf(outputFile, "--- %s\n", leftPath)
+		fmt.Fprintf(outputFile, "+++ %s\n", rightPath)
+		fmt.Fprint(outputFile, strings.Join(hunks, ""))
+	}
+}
@@ -0,0 +1,134 @@
+package output
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+	"diffy/etc"
+)
+
+// ------------------------------------------- TestPatchSeriesGitApply
+
+func TestPatchSeriesGitApply(t *testing.T) {
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	dir, err := ioutil.TempDir("", "diffy-series")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	before := map[string]string{
+		"README.md": "# Tool\n\nDoes things.\n",
+		"src/main.go": "package main\n\nfunc main() {\n\trun()\n}\n",
+		"src/old.go": "package main\n\n// Going away.\n",
+		"src/same.go": "package main\n",
+		"src/empty.txt": "",
+		"notes.txt": "no newline at the end",
+	}
+	after := map[string]string{
+		"README.md": "# Tool\n\nDoes things, quickly.\n",
+		"src/main.go": "package main\n\nfunc main() {\n\tsetup()\n\trun()\n}\n",
+		"src/new.go": "package main\n\nfunc setup() {}\n",
+		"src/same.go": "package main\n",
+		"docs/empty.txt": "",
+		"notes.txt": "no newline at the end\nnow there is\n",
+	}
+
+	// Write a tree, and read it back in.
+	writeTree := func (root string, files map[string]string) {
+		for path, text := range files {
+			filePath := filepath.Join(root, filepath.FromSlash(path))
+			if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filePath, []byte(text), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	readSources := func (files map[string]string) ([]string, map[string]*SourceLinesRec) {
+		var paths []string
+		sources := map[string]*SourceLinesRec{}
+		for path, text := range files {
+			lines, finalNewline, err := diff.ReadLines(strings.NewReader(text), diff.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sources[path] = NewSourceLinesRec(lines, path)
+			sources[path].FinalNewline = finalNewline
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		return paths, sources
+	}
+
+	leftPaths, leftSources := readSources(before)
+	rightPaths, rightSources := readSources(after)
+	pairs, leftOnly, rightOnly := etc.PairPaths(leftPaths, rightPaths, etc.NormalizeNothing)
+	var files []PatchFile
+	for _, pair := range pairs {
+		files = append(files, PatchFile{Path: pair.Left, Left: leftSources[pair.Left], Right: rightSources[pair.Right]})
+	}
+	for _, path := range leftOnly {
+		files = append(files, PatchFile{Path: path, Left: leftSources[path]})
+	}
+	for _, path := range rightOnly {
+		files = append(files, PatchFile{Path: path, Right: rightSources[path]})
+	}
+
+	var buffer bytes.Buffer
+	GeneratePatchSeries(&buffer, files, HtmlOptions{})
+	patch := buffer.String()
+
+	// One header per changed file, and none for the file which didn't change.
+	if count := strings.Count(patch, "diff --git "); count != 7 {
+		t.Errorf("Expected seven files in the patch, got %d:\n%s", count, patch)
+	}
+	if strings.Contains(patch, "same.go") {
+		t.Errorf("Expected nothing for the unchanged file")
+	}
+	for _, expected := range []string{
+		"diff --git a/src/new.go b/src/new.go\nnew file mode 100644\n--- /dev/null\n+++ b/src/new.go\n@@ -0,0 +1,3 @@\n",
+		"diff --git a/src/old.go b/src/old.go\ndeleted file mode 100644\n--- a/src/old.go\n+++ /dev/null\n@@ -1,3 +0,0 @@\n",
+		"diff --git a/docs/empty.txt b/docs/empty.txt\nnew file mode 100644\ndiff --git ",
+	} {
+		if !strings.Contains(patch, expected) {
+			t.Errorf("Expected %q in the patch:\n%s", expected, patch)
+		}
+	}
+
+	// Applied to the first tree, the patch gives the second.
+	root := filepath.Join(dir, "tree")
+	writeTree(root, before)
+	if err := ioutil.WriteFile(filepath.Join(dir, "series.patch"), []byte(patch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	command := exec.Command("git", "apply", "-p1", filepath.Join("..", "series.patch"))
+	command.Dir = root
+	if output, err := command.CombinedOutput(); err != nil {
+		t.Fatalf("git apply failed: %v\n%s\npatch:\n%s", err, output, patch)
+	}
+	for path, text := range after {
+		if applied, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(path))); err != nil || string(applied) != text {
+			t.Errorf("%s: expected %q, got %q (%v)", path, text, applied, err)
+		}
+	}
+	for path := range before {
+		if _, inAfter := after[path]; !inAfter {
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(path))); !os.IsNotExist(err) {
+				t.Errorf("%s: expected the file to be deleted", path)
+			}
+		}
+	}
+}
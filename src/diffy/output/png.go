@@ -0,0 +1,166 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+
+	"diffy/diff"
+)
+
+// "png.go" - The side by side diff drawn as a PNG image, for changelogs and
+// dashboards which can show an image but not HTML.
+
+// ------------------------------------------- layout
+
+// Everything is measured in character cells of the 5x7 font, drawn at
+// PNG_SCALE pixels per font pixel.  Code lines are cut off after
+// PNG_CODE_COLUMNS characters, and the image after PNG_MAX_LINES rows.
+
+const (
+	PNG_SCALE = 2
+	PNG_CODE_COLUMNS = 80
+	PNG_NUMBER_COLUMNS = 5
+	PNG_MAX_LINES = 2000
+)
+
+const (
+	pngCellWidth = (FONT_GLYPH_WIDTH + 1) * PNG_SCALE
+	pngRowHeight = (FONT_GLYPH_HEIGHT + 4) * PNG_SCALE
+	pngPadding = pngCellWidth / 2
+	pngGutterWidth = 3 * PNG_SCALE
+	pngNumberWidth = PNG_NUMBER_COLUMNS * pngCellWidth + 2 * pngPadding
+	pngCodeWidth = PNG_CODE_COLUMNS * pngCellWidth + 2 * pngPadding
+	pngSideWidth = pngNumberWidth + pngCodeWidth
+)
+
+// ------------------------------------------- the palette
+
+// The same colors as the HTML page's styles.
+var (
+	pngHeadingColor = color.RGBA{0x46, 0x82, 0xB4, 0xFF}		// titleHeadingBoxStyle
+	pngLineNumColor = color.RGBA{0xEE, 0xEE, 0xEE, 0xFF}		// lineNumStyle
+	pngLinesDifferColor = color.RGBA{0xFF, 0xFF, 0xE0, 0xFF}	// codeLineLinesDifferStyle
+	pngOnlyOneColor = color.RGBA{0xFF, 0xEC, 0x8B, 0xFF}		// codeLineOnlyOneStyle
+	pngNoneColor = color.RGBA{0xF0, 0xF0, 0xF0, 0xFF}			// codeLineNoneStyle
+	pngNoteColor = color.RGBA{0x69, 0x69, 0x69, 0xFF}			// noNewlineNoteStyle
+	pngGutterColor = color.Black
+	pngTextColor = color.Black
+	pngBackgroundColor = color.White
+)
+
+// ------------------------------------------- GeneratePngDiff
+//
+// Draw the side by side diff as a PNG image, with the file names across the
+// top and each pair of lines colored the way the HTML page colors them.
+// Only the realign options of "opts" apply.  Long lines are cut off, and a
+// diff of more than PNG_MAX_LINES rows is cut short with a note saying how
+// many were left out.
+//
+func GeneratePngDiff(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) error {
+
+	alignment = realignForDisplay(alignment, leftSource, rightSource, opts)
+
+	links, hiddenCount := alignment.Links, 0
+	if len(links) > PNG_MAX_LINES {
+		links, hiddenCount = links[:PNG_MAX_LINES], len(links) - PNG_MAX_LINES
+	}
+	rowCount := 1 + len(links)
+	if hiddenCount > 0 {
+		rowCount++
+	}
+
+	canvas := &tPngCanvas{image.NewRGBA(image.Rect(0, 0, 2 * pngSideWidth + pngGutterWidth, rowCount * pngRowHeight))}
+	canvas.fill(canvas.image.Bounds(), pngBackgroundColor)
+	rightX := pngSideWidth + pngGutterWidth
+
+	// The headings.
+	canvas.fill(image.Rect(0, 0, pngSideWidth, pngRowHeight), pngHeadingColor)
+	canvas.fill(image.Rect(rightX, 0, rightX + pngSideWidth, pngRowHeight), pngHeadingColor)
+	canvas.drawText(pngPadding, 0, leftSource.GetFileName(), pngSideWidth / pngCellWidth - 1, color.White)
+	canvas.drawText(rightX + pngPadding, 0, rightSource.GetFileName(), pngSideWidth / pngCellWidth - 1, color.White)
+
+	// One row per pair of lines.
+	drawSide := func (x, y, index int, lines diff.ComparableLines, lineColor color.Color) {
+		canvas.fill(image.Rect(x, y, x + pngNumberWidth, y + pngRowHeight), pngLineNumColor)
+		canvas.fill(image.Rect(x + pngNumberWidth, y, x + pngSideWidth, y + pngRowHeight), lineColor)
+		if index < 0 {
+			return
+		}
+		lineNum := strconv.Itoa(index + 1)
+		canvas.drawText(x + pngNumberWidth - pngPadding - len(lineNum) * pngCellWidth, y, lineNum, PNG_NUMBER_COLUMNS, pngTextColor)
+		canvas.drawText(x + pngNumberWidth + pngPadding, y, lines[index].Text, PNG_CODE_COLUMNS, pngTextColor)
+	}
+	for row, link := range links {
+		var leftColor, rightColor color.Color = pngBackgroundColor, pngBackgroundColor
+		switch link.LinkType {
+		case diff.Matching:
+		case diff.Different:
+			leftColor, rightColor = pngLinesDifferColor, pngLinesDifferColor
+		case diff.LeftOnly:
+			leftColor, rightColor = pngOnlyOneColor, pngNoneColor
+		case diff.RightOnly:
+			leftColor, rightColor = pngNoneColor, pngOnlyOneColor
+		default:
+			panic("not reached")
+		}
+		y := (row + 1) * pngRowHeight
+		drawSide(0, y, link.LeftIndex, leftSource.Lines, leftColor)
+		drawSide(rightX, y, link.RightIndex, rightSource.Lines, rightColor)
+	}
+
+	// The note about the rows left out, if any.
+	if hiddenCount > 0 {
+		note := fmt.Sprintf("... %d more lines not shown", hiddenCount)
+		canvas.drawText(pngPadding, (rowCount - 1) * pngRowHeight, note, len(note), pngNoteColor)
+	}
+
+	// The gutter runs the whole height of the image.
+	canvas.fill(image.Rect(pngSideWidth, 0, rightX, rowCount * pngRowHeight), pngGutterColor)
+
+	return png.Encode(outputFile, canvas.image)
+}
+
+// -------------------------------------------
+// ------------------------------------------- type tPngCanvas
+// -------------------------------------------
+
+type tPngCanvas struct {
+	image *image.RGBA
+}
+
+// ------------------------------------------- tPngCanvas fill method
+
+func (canvas *tPngCanvas) fill(rect image.Rectangle, fillColor color.Color) {
+	draw.Draw(canvas.image, rect, image.NewUniform(fillColor), image.Point{}, draw.Src)
+}
+
+// ------------------------------------------- tPngCanvas drawText method
+
+// Draw up to "maxColumns" characters of "text" in the row whose top is at
+// "y", starting at "x".  Only the set pixels of each glyph are drawn, so the
+// background shows through.
+func (canvas *tPngCanvas) drawText(x, y int, text string, maxColumns int, textColor color.Color) {
+	column := 0
+	for _, char := range text {
+		if column >= maxColumns {
+			break
+		}
+		glyph := fontGlyph(char)
+		for glyphX, bits := range glyph {
+			for glyphY := 0; glyphY < FONT_GLYPH_HEIGHT; glyphY++ {
+				if bits & (1 << uint(glyphY)) == 0 {
+					continue
+				}
+				pixelX := x + column * pngCellWidth + glyphX * PNG_SCALE
+				pixelY := y + 2 * PNG_SCALE + glyphY * PNG_SCALE
+				canvas.fill(image.Rect(pixelX, pixelY, pixelX + PNG_SCALE, pixelY + PNG_SCALE), textColor)
+			}
+		}
+		column++
+	}
+}
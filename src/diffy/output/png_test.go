@@ -0,0 +1,68 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- helper functions
+
+// Diff the lines and draw them, returning the decoded image.
+func generateTestPng(t *testing.T, leftLines, rightLines diff.ComparableLines) image.Image {
+	_, alignment := diff.Diff_v2(leftLines, rightLines)
+	var buffer bytes.Buffer
+	if err := GeneratePngDiff(&buffer, alignment, NewSourceLinesRec(leftLines, "left.txt"), NewSourceLinesRec(rightLines, "right.txt"), HtmlOptions{}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if buffer.Len() == 0 {
+		t.Fatalf("Expected a non-empty PNG")
+	}
+	decoded, err := png.Decode(&buffer)
+	if err != nil {
+		t.Fatalf("Expected a valid PNG, got %v", err)
+	}
+	return decoded
+}
+
+// ------------------------------------------- TestPngDiff
+
+func TestPngDiff(t *testing.T) {
+
+	if len(fontGlyphs) != FONT_LAST_CHAR - FONT_FIRST_CHAR + 1 {
+		t.Fatalf("Expected a glyph for every printable ASCII character, got %d", len(fontGlyphs))
+	}
+
+	small := generateTestPng(t, makeLines("same", "old line", "gone"), makeLines("same", "old line!"))
+	bounds := small.Bounds()
+	if bounds.Dx() != 2 * pngSideWidth + pngGutterWidth || bounds.Dy() != 4 * pngRowHeight {
+		t.Errorf("Expected a heading and three rows, got %v", bounds)
+	}
+
+	// The rows are colored like the HTML: the deleted line is highlighted on
+	// the left, with nothing on the right.
+	pixel := func (img image.Image, x, y int) string {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return fmt.Sprintf("#%02X%02X%02X", r >> 8, g >> 8, b >> 8)
+	}
+	codeX, rowY := pngNumberWidth + 1, 3 * pngRowHeight + 1
+	if left, right := pixel(small, codeX, rowY), pixel(small, pngSideWidth + pngGutterWidth + codeX, rowY); left != "#FFEC8B" || right != "#F0F0F0" {
+		t.Errorf("Expected the deleted line's row to be #FFEC8B beside #F0F0F0, got %s beside %s", left, right)
+	}
+
+	// The height grows by a row per line.
+	var left, right diff.ComparableLines
+	for i := 0; i < 20; i++ {
+		left, right = append(left, diff.NewTextLine(fmt.Sprintf("line %d", i))), append(right, diff.NewTextLine(fmt.Sprintf("line %d", i)))
+	}
+	if height := generateTestPng(t, left[:10], right[:10]).Bounds().Dy(); height != 11 * pngRowHeight {
+		t.Errorf("Expected 10 lines to be 11 rows high, got %d pixels", height)
+	}
+	if height := generateTestPng(t, left, right).Bounds().Dy(); height != 21 * pngRowHeight {
+		t.Errorf("Expected 20 lines to be 21 rows high, got %d pixels", height)
+	}
+}
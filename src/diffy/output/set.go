@@ -0,0 +1,30 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"diffy/diff"
+)
+
+// "set.go" - The lines two files don't have in common, ignoring their order.
+
+// ------------------------------------------- GenerateSetDiff
+//
+// Write each line only in the left file with a "-" and each line only in the
+// right file with a "+", once per extra copy, with the left file's lines
+// first.  Files with the same lines, in whatever order, write nothing.
+//
+func GenerateSetDiff(outputFile io.Writer, setDiff *diff.SetDiff, leftSource, rightSource *SourceLinesRec) {
+	for _, link := range setDiff.Links {
+		switch link.LinkType {
+		case diff.Matching:
+		case diff.LeftOnly:
+			fmt.Fprintf(outputFile, "-%s\n", leftSource.Lines[link.LeftIndex].Text)
+		case diff.RightOnly:
+			fmt.Fprintf(outputFile, "+%s\n", rightSource.Lines[link.RightIndex].Text)
+		default:
+			panic("not reached")
+		}
+	}
+}
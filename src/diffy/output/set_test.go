@@ -0,0 +1,29 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestGenerateSetDiff
+
+func TestGenerateSetDiff(t *testing.T) {
+
+	left := diff.ComparableLines{diff.NewTextLine("b"), diff.NewTextLine("a"), diff.NewTextLine("a")}
+	right := diff.ComparableLines{diff.NewTextLine("c"), diff.NewTextLine("a"), diff.NewTextLine("b")}
+	leftSource, rightSource := NewSourceLinesRec(left, "left.txt"), NewSourceLinesRec(right, "right.txt")
+
+	var buffer bytes.Buffer
+	GenerateSetDiff(&buffer, diff.DiffSets(left, right), leftSource, rightSource)
+	if got, expected := buffer.String(), "-a\n+c\n"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	buffer.Reset()
+	GenerateSetDiff(&buffer, diff.DiffSets(left, left[1:]), leftSource, leftSource)
+	if got, expected := buffer.String(), "-b\n"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,169 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "split.go" - The side-by-side diff split up into one HTML page per hunk (or
+// per few hunks), plus an index page linking to them all, so a big review can
+// be shared out among several reviewers.
+
+// ------------------------------------------- CSS style definitions
+
+var splitIndexListStyle CssStyle = MakeCssStyle("split-index-list",
+	"margin: 10px",
+	"font-family: monospace",
+	"font-size: 10pt",
+	"line-height: 1.6",
+)
+
+// ------------------------------------------- GenerateSplitHtml
+//
+// Write the diff into "dir" (which is created if need be) as one page per
+// "hunksPerFile" hunks, with the "HunkContext" of "opts" around each change,
+// and an "index.html" page listing the pages and the lines each one covers.
+// Files which are already in the directory are never overwritten: a
+// name which is taken gets a numeric suffix instead.  Identical files give an
+// index which just says so.  Return the path of the index page.
+//
+func GenerateSplitHtml(dir string, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, hunksPerFile int, opts HtmlOptions) (string, error) {
+
+	if hunksPerFile < 1 {
+		hunksPerFile = 1
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Realign the whole diff once, and make each page stick to the same
+	// threshold; an adaptive threshold would otherwise be chosen afresh from
+	// each page's own few lines.
+	if !opts.NoRealign {
+		threshold := chooseRealignThreshold(alignment, leftSource, rightSource, opts)
+		alignment = alignment.RealignUsingThresholdWith(leftSource.Compared(), rightSource.Compared(), threshold, opts.CompareFunc())
+		opts.AdaptiveRealign = &diff.AdaptiveThreshold{Strict: threshold, Lax: threshold}
+	}
+
+	hunks := diff.GroupHunksWithContext(alignment, opts.EffectiveHunkContext())
+
+	// Write the pages.
+	var index strings.Builder
+	for first := 0; first < len(hunks); first += hunksPerFile {
+		last := first + hunksPerFile
+		if last > len(hunks) {
+			last = len(hunks)
+		}
+		pageHunks := hunks[first:last]
+
+		var links []diff.Link
+		for _, hunk := range pageHunks {
+			links = append(links, hunk.Links...)
+		}
+
+		pageFile, err := createUniqueFile(dir, splitPageName(first, last, len(hunks)), ".html")
+		if err != nil {
+			return "", err
+		}
+		GenerateHtmlDiffPage(pageFile, &diff.Alignment{Links: links}, leftSource, rightSource, opts)
+		if err := pageFile.Close(); err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&index, "			<li><a href=\"%s\">%s</a>: %s</li>\n",
+			html.EscapeString(filepath.Base(pageFile.Name())), html.EscapeString(describeSplitPage(first, last)), html.EscapeString(describeHunkLines(pageHunks)))
+	}
+
+	// Write the index.
+	indexFile, err := createUniqueFile(dir, "index", ".html")
+	if err != nil {
+		return "", err
+	}
+	generateSplitIndex(indexFile, leftSource, rightSource, len(hunks), index.String(), opts)
+	if err := indexFile.Close(); err != nil {
+		return "", err
+	}
+	return indexFile.Name(), nil
+}
+
+// ------------------------------------------- generateSplitIndex
+
+func generateSplitIndex(outputFile io.Writer, leftSource, rightSource *SourceLinesRec, hunkCount int, items string, opts HtmlOptions) {
+	title := leftSource.GetFileName() + " → " + rightSource.GetFileName()
+	outputFile = generatePagePrologue(outputFile, opts)
+	fmt.Fprintf(outputFile, "		%s\n", generateElement(opts, "div", html.EscapeString(title), matrixPairHeadingStyle))
+	if hunkCount == 0 {
+		fmt.Fprintf(outputFile, "		%s\n", generateElement(opts, "p", "No differences.", splitIndexListStyle))
+	} else {
+		fmt.Fprintf(outputFile, "		%s\n", generateStartTag(opts, "ul", splitIndexListStyle))
+		fmt.Fprint(outputFile, items)
+		fmt.Fprintf(outputFile, "		%s\n", generateEndTag("ul"))
+	}
+	generatePageEpilogue(outputFile, opts)
+}
+
+// ------------------------------------------- splitPageName
+//
+// The page for hunks [first, last), numbered from one and zero padded so the
+// pages sort in order, e.g. "hunk-007" or "hunks-007-009".
+func splitPageName(first, last, hunkCount int) string {
+	width := len(fmt.Sprint(hunkCount))
+	if width < 3 {
+		width = 3
+	}
+	if last - first == 1 {
+		return fmt.Sprintf("hunk-%0*d", width, first + 1)
+	}
+	return fmt.Sprintf("hunks-%0*d-%0*d", width, first + 1, width, last)
+}
+
+// ------------------------------------------- describeSplitPage
+
+func describeSplitPage(first, last int) string {
+	if last - first == 1 {
+		return fmt.Sprintf("Hunk %d", first + 1)
+	}
+	return fmt.Sprintf("Hunks %d–%d", first + 1, last)
+}
+
+// ------------------------------------------- describeHunkLines
+//
+// The lines a run of hunks covers on each side, e.g. "left 10–25, right
+// 10–27".  Line numbers are one-based.
+func describeHunkLines(hunks []diff.Hunk) string {
+	describe := func (side string, start, end int) string {
+		switch {
+		case end <= start:
+			return side + " none"
+		case end - start == 1:
+			return fmt.Sprintf("%s %d", side, start + 1)
+		}
+		return fmt.Sprintf("%s %d–%d", side, start + 1, end)
+	}
+	first, last := hunks[0], hunks[len(hunks) - 1]
+	return describe("left", first.LeftStart, last.LeftStart + last.LeftCount) + ", " +
+		describe("right", first.RightStart, last.RightStart + last.RightCount)
+}
+
+// ------------------------------------------- createUniqueFile
+//
+// Create "name" + "ext" in "dir", or if that's taken, "name-2" + "ext", and so
+// on.  Existing files are never truncated.
+func createUniqueFile(dir, name, ext string) (*os.File, error) {
+	for suffix := 1; ; suffix++ {
+		fileName := name + ext
+		if suffix > 1 {
+			fileName = fmt.Sprintf("%s-%d%s", name, suffix, ext)
+		}
+		file, err := os.OpenFile(filepath.Join(dir, fileName), os.O_WRONLY | os.O_CREATE | os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+}
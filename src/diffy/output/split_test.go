@@ -0,0 +1,123 @@
+package output
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- helper functions
+
+// Thirty lines with a change at lines 3, 15, and 27, each far enough from the
+// others to be a hunk of its own.
+func makeSplitTestSources() (*diff.Alignment, *SourceLinesRec, *SourceLinesRec) {
+	var leftTexts, rightTexts []string
+	alignment := &diff.Alignment{}
+	for i := 0; i < 30; i++ {
+		text := fmt.Sprintf("line number %02d of the file", i + 1)
+		leftTexts = append(leftTexts, text)
+		linkType := diff.Matching
+		if i == 2 || i == 14 || i == 26 {
+			text += " was changed"
+			linkType = diff.Different
+		}
+		rightTexts = append(rightTexts, text)
+		alignment.Links = append(alignment.Links, diff.Link{LinkType: linkType, LeftIndex: i, RightIndex: i})
+	}
+	return alignment, NewSourceLinesRec(makeLines(leftTexts...), "old.txt"), NewSourceLinesRec(makeLines(rightTexts...), "new.txt")
+}
+
+func readTestFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read %q: %v", path, err)
+	}
+	return string(content)
+}
+
+// ------------------------------------------- TestSplitHtml
+
+func TestSplitHtml(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "diffy-split")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	alignment, leftSource, rightSource := makeSplitTestSources()
+	indexPath, err := GenerateSplitHtml(dir, alignment, leftSource, rightSource, 1, HtmlOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if indexPath != filepath.Join(dir, "index.html") {
+		t.Errorf("Unexpected index path %q", indexPath)
+	}
+
+	// One page per hunk, each with only its own changed line, and each linked from the index.
+	index := readTestFile(t, indexPath)
+	for i, line := range []int{3, 15, 27} {
+		pageName := fmt.Sprintf("hunk-%03d.html", i + 1)
+		expectedItem := fmt.Sprintf("<a href=\"%s\">Hunk %d</a>: left %d–%d, right %d–%d", pageName, i + 1, line - 3, line + 3, line - 3, line + 3)
+		if line == 3 {
+			expectedItem = fmt.Sprintf("<a href=\"%s\">Hunk 1</a>: left 1–6, right 1–6", pageName)
+		}
+		if !strings.Contains(index, expectedItem) {
+			t.Errorf("Expected the index to contain %q, got\n%s", expectedItem, index)
+		}
+		page := readTestFile(t, filepath.Join(dir, pageName))
+		if !strings.Contains(page, fmt.Sprintf("id='R-%d'", line)) || strings.Contains(page, fmt.Sprintf("id='R-%d'", line + 4)) {
+			t.Errorf("Expected %s to show line %d and its context only", pageName, line)
+		}
+	}
+
+	// Doing it again doesn't overwrite anything, and several hunks can share a page.
+	indexPath, err = GenerateSplitHtml(dir, alignment, leftSource, rightSource, 2, HtmlOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if indexPath != filepath.Join(dir, "index-2.html") {
+		t.Errorf("Expected the second index to get a new name, got %q", indexPath)
+	}
+	index = readTestFile(t, indexPath)
+	for _, expected := range []string{"<a href=\"hunks-001-002.html\">Hunks 1–2</a>", "<a href=\"hunk-003-2.html\">Hunk 3</a>"} {
+		if !strings.Contains(index, expected) {
+			t.Errorf("Expected the index to contain %q, got\n%s", expected, index)
+		}
+	}
+	if page := readTestFile(t, filepath.Join(dir, "hunks-001-002.html")); !strings.Contains(page, "id='R-3'") || !strings.Contains(page, "id='R-15'") {
+		t.Errorf("Expected the first page to show the first two changes")
+	}
+}
+
+// ------------------------------------------- TestSplitHtmlNoDifferences
+
+func TestSplitHtmlNoDifferences(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "diffy-split")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lines := makeLines("one", "two")
+	alignment := &diff.Alignment{Links: []diff.Link{
+		{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0},
+		{LinkType: diff.Matching, LeftIndex: 1, RightIndex: 1},
+	}}
+	indexPath, err := GenerateSplitHtml(filepath.Join(dir, "new-dir"), alignment, NewSourceLinesRec(lines, "a.txt"), NewSourceLinesRec(lines, "b.txt"), 1, HtmlOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !strings.Contains(readTestFile(t, indexPath), "No differences.") {
+		t.Errorf("Expected the index to say there are no differences")
+	}
+	if files, _ := ioutil.ReadDir(filepath.Join(dir, "new-dir")); len(files) != 1 {
+		t.Errorf("Expected only the index, got %d files", len(files))
+	}
+}
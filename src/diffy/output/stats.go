@@ -0,0 +1,91 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+
+	"diffy/diff"
+)
+
+// "stats.go" - Line counts and the percentage of lines changed, for a quick
+// sense of the scale of a diff.
+
+// ------------------------------------------- type ChangeStats
+//
+// ChangeStats records how many lines each side has, and how many of them
+// changed, i.e. aren't linked to a matching line on the other side.
+type ChangeStats struct {
+	LeftLines, LeftChanged int
+	RightLines, RightChanged int
+}
+
+// ------------------------------------------- ComputeChangeStats
+
+func ComputeChangeStats(alignment *diff.Alignment) ChangeStats {
+	var stats ChangeStats
+	for _, link := range alignment.Links {
+		changed := link.LinkType != diff.Matching
+		if link.LeftIndex >= 0 {
+			stats.LeftLines++
+			if changed {
+				stats.LeftChanged++
+			}
+		}
+		if link.RightIndex >= 0 {
+			stats.RightLines++
+			if changed {
+				stats.RightChanged++
+			}
+		}
+	}
+	return stats
+}
+
+// ------------------------------------------- ChangeStats percentage methods
+
+func (stats ChangeStats) LeftPercent() float64 {
+	return changedPercent(stats.LeftChanged, stats.LeftLines)
+}
+
+func (stats ChangeStats) RightPercent() float64 {
+	return changedPercent(stats.RightChanged, stats.RightLines)
+}
+
+// The percentage of all the lines, on either side, which changed.
+func (stats ChangeStats) TotalPercent() float64 {
+	return changedPercent(stats.LeftChanged + stats.RightChanged, stats.LeftLines + stats.RightLines)
+}
+
+func changedPercent(changed, lines int) float64 {
+	if lines == 0 {
+		return 0.0
+	}
+	return float64(changed) * 100.0 / float64(lines)
+}
+
+// ------------------------------------------- formatLineStats
+//
+// formatLineStats(1204, 39) => "1,204 lines · 3.2% changed"
+// The format is the same whatever the locale.
+func formatLineStats(lines, changed int) string {
+	noun := "lines"
+	if lines == 1 {
+		noun = "line"
+	}
+	return fmt.Sprintf("%s %s · %.1f%% changed", formatThousands(lines), noun, changedPercent(changed, lines))
+}
+
+// ------------------------------------------- formatThousands
+//
+// formatThousands(1234567) => "1,234,567"
+func formatThousands(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestComputeChangeStats
+
+func TestComputeChangeStats(t *testing.T) {
+
+	// Left: 5 lines, 2 changed.  Right: 4 lines, 1 changed.
+	alignment := &diff.Alignment{Links: []diff.Link{
+		{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0},
+		{LinkType: diff.Different, LeftIndex: 1, RightIndex: 1},
+		{LinkType: diff.Matching, LeftIndex: 2, RightIndex: 2},
+		{LinkType: diff.LeftOnly, LeftIndex: 3, RightIndex: -1},
+		{LinkType: diff.Matching, LeftIndex: 4, RightIndex: 3},
+	}}
+
+	stats := ComputeChangeStats(alignment)
+	if stats != (ChangeStats{LeftLines: 5, LeftChanged: 2, RightLines: 4, RightChanged: 1}) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats.LeftPercent() != 40.0 || stats.RightPercent() != 25.0 || stats.TotalPercent() != 100.0 / 3.0 {
+		t.Errorf("Unexpected percentages %v, %v, %v", stats.LeftPercent(), stats.RightPercent(), stats.TotalPercent())
+	}
+	if percent := ComputeChangeStats(&diff.Alignment{}).TotalPercent(); percent != 0.0 {
+		t.Errorf("Expected no change in empty files, got %v", percent)
+	}
+}
+
+// ------------------------------------------- TestFormatLineStats
+
+func TestFormatLineStats(t *testing.T) {
+	testCases := []struct {
+		lines, changed int
+		expected string
+	}{
+		{1204, 39, "1,204 lines · 3.2% changed"},
+		{1, 1, "1 line · 100.0% changed"},
+		{0, 0, "0 lines · 0.0% changed"},
+		{999, 0, "999 lines · 0.0% changed"},
+		{1234567, 1, "1,234,567 lines · 0.0% changed"},
+	}
+	for _, testCase := range testCases {
+		if result := formatLineStats(testCase.lines, testCase.changed); result != testCase.expected {
+			t.Errorf("formatLineStats(%d, %d): expected %q, got %q", testCase.lines, testCase.changed, testCase.expected, result)
+		}
+	}
+}
+
+// ------------------------------------------- TestShowStats
+
+func TestShowStats(t *testing.T) {
+	left, right := makeLines("a", "b", "c", "d"), makeLines("a", "b", "c")
+
+	if page := generateTestPage(left, right, HtmlOptions{}); strings.Contains(page, "changed") {
+		t.Errorf("Expected no stats by default")
+	}
+	page := generateTestPage(left, right, HtmlOptions{ShowStats: true})
+	for _, expected := range []string{"4 lines · 25.0% changed", "3 lines · 0.0% changed"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected the heading to contain %q", expected)
+		}
+	}
+}
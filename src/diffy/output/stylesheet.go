@@ -0,0 +1,47 @@
+package output
+
+import (
+	"fmt"
+	"io"
+)
+
+// "stylesheet.go" - The CssStyle values as a standalone style sheet, for
+// pages which link to a style sheet of their own rather than use inline styles.
+
+// ------------------------------------------- known styles
+
+// Every CssStyle made with a class name, in the order they were made, which
+// is the order their rules go in the style sheet.  Later rules win, just as
+// later styles do when they're concatenated inline.
+var knownCssStyles []CssStyle
+var knownCssClassNames = map[string]bool{}
+
+func registerCssStyle(style CssStyle) {
+	if style.className == "" || len(style.properties) == 0 || knownCssClassNames[style.className] {
+		return
+	}
+	knownCssStyles = append(knownCssStyles, style)
+	knownCssClassNames[style.className] = true
+}
+
+// ------------------------------------------- GenerateStyleSheet
+//
+// The default styles as a style sheet, with a rule per class, followed by the
+// responsive rules if "opts" has a breakpoint.  This is where to start from
+// when writing a style sheet for "CssFile".
+func GenerateStyleSheet(outputFile io.Writer, opts HtmlOptions) {
+	for _, style := range knownCssStyles {
+		fmt.Fprintf(outputFile, ".%s {\n", style.className)
+		for _, property := range style.properties {
+			fmt.Fprintf(outputFile, "	%s;\n", property)
+		}
+		fmt.Fprintln(outputFile, "}")
+	}
+	if opts.Breakpoint > 0 {
+		fmt.Fprintf(outputFile, "@media (max-width: %dpx) {\n", opts.Breakpoint - 1)
+		for _, rule := range responsiveStyleRules {
+			fmt.Fprintf(outputFile, "	%s\n", rule)
+		}
+		fmt.Fprintln(outputFile, "}")
+	}
+}
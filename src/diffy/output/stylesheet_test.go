@@ -0,0 +1,111 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// -------------------------------------------
+// ------------------------------------------- TestGenerateStyleSheet
+// -------------------------------------------
+
+func TestGenerateStyleSheet(t *testing.T) {
+
+	var buffer bytes.Buffer
+	GenerateStyleSheet(&buffer, HtmlOptions{})
+	sheet := buffer.String()
+
+	// A rule per known class, with each of its properties.
+	for _, style := range []CssStyle{codeLineStyle, lineNumStyle, codeRunDifferentStyle, gradedRunStyle(3), matrixCellStyle, splitIndexListStyle} {
+		rule := "." + style.className + " {\n"
+		for _, property := range style.properties {
+			rule += "	" + property + ";\n"
+		}
+		rule += "}\n"
+		if count := strings.Count(sheet, rule); count != 1 {
+			t.Errorf("Expected one rule for %q, got %d", style.className, count)
+		}
+	}
+	if count := strings.Count(sheet, " {\n"); count != len(knownCssStyles) {
+		t.Errorf("Expected %d rules, one per known class, got %d", len(knownCssStyles), count)
+	}
+
+	// The null style has no properties, so it has no rule.
+	if strings.Contains(sheet, ".null ") {
+		t.Errorf("Expected no rule for the null style")
+	}
+
+	// The responsive rules come along with a breakpoint.
+	if strings.Contains(sheet, "@media") {
+		t.Errorf("Expected no responsive rules without a breakpoint")
+	}
+	buffer.Reset()
+	GenerateStyleSheet(&buffer, HtmlOptions{Breakpoint: 800})
+	if !strings.Contains(buffer.String(), "@media (max-width: 799px) {\n	" + responsiveStyleRules[0]) {
+		t.Errorf("Expected the responsive rules with a breakpoint")
+	}
+}
+
+// -------------------------------------------
+// ------------------------------------------- TestCssFile
+// -------------------------------------------
+
+func TestCssFile(t *testing.T) {
+
+	leftLines, rightLines := makeLines("same", "a changed line", "\tindented"), makeLines("same", "a changed lint", "\tindented")
+	opts := HtmlOptions{Breakpoint: 800, PreserveTabs: true, TabSize: 4, CssFile: "my \"styles\".css"}
+	page := generateTestPage(leftLines, rightLines, opts)
+
+	// The user's style sheet takes the place of the default styles.
+	if !strings.Contains(page, "<link rel=\"stylesheet\" href=\"my &#34;styles&#34;.css\"/>") {
+		t.Errorf("Expected a link to the style sheet, got:\n%s", page)
+	}
+	if strings.Contains(page, "<style>") {
+		t.Errorf("Expected no style block")
+	}
+	for _, style := range knownCssStyles {
+		if strings.Contains(page, strings.Join(style.properties, ";")) {
+			t.Errorf("Expected the %q style as a class, not inline", style.className)
+		}
+	}
+
+	// Each element has its class names in place of its styles, after any it already had.
+	for _, expected := range []string{
+		"<table class='title-headings-table'>",
+		"class='diffy-left diffy-num line-num'",
+		"class='code-run-different'",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected %q in the page", expected)
+		}
+	}
+
+	// The tab size is computed for the page, so it stays inline.
+	if !strings.Contains(page, "style='tab-size: 4;-moz-tab-size: 4'") {
+		t.Errorf("Expected the tab size inline")
+	}
+
+	// Styles with the same properties as another still get their own class.
+	gradedOpts := opts
+	gradedOpts.GradedRuns = true
+	if page := generateTestPage(makeLines("fmt.Println(total, count, done)"), makeLines("fmt.Println(total, count, extra, done)"), gradedOpts); !strings.Contains(page, "class='" + gradedRunStyle(6).className + "'") || strings.Contains(page, "class='" + codeRunDifferentStyle.className + "'") {
+		t.Errorf("Expected the graded run's own class, got:\n%s", page)
+	}
+	files := []diff.ComparableLines{makeLines("a", "b"), makeLines("a", "x")}
+	sources := []*SourceLinesRec{NewSourceLinesRec(files[0], "one.conf"), NewSourceLinesRec(files[1], "two.conf")}
+	var buffer bytes.Buffer
+	GenerateHtmlMatrixPage(&buffer, sources, diff.DiffMatrix(files, diff.Diff_v2), opts)
+	if page := buffer.String(); !strings.Contains(page, "class='matrix-cell matrix-cell-different'") {
+		t.Errorf("Expected the matrix cells' own classes, got:\n%s", page)
+	}
+
+	// Without a style sheet of its own, the page has inline styles and no classes for them.
+	opts.CssFile = ""
+	page = generateTestPage(leftLines, rightLines, opts)
+	if strings.Contains(page, "rel=\"stylesheet\"") || strings.Contains(page, "class='title-headings-table'") || !strings.Contains(page, "<style>") {
+		t.Errorf("Expected the default styles without %q", "CssFile")
+	}
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"diffy/diff"
+)
+
+// "summary.go" - A few numbers about a diff as JSON, for a pipeline to gate
+// on, whatever form the diff itself is shown in.
+
+// ------------------------------------------- type tJsonSummary
+
+// "changed" counts the pairs of different lines, "added" and "removed" the
+// lines on one side only, and "unchanged" the matching pairs.
+type tJsonSummary struct {
+	Changed int				`json:"changed"`
+	Added int				`json:"added"`
+	Removed int				`json:"removed"`
+	Unchanged int			`json:"unchanged"`
+	Distance float32		`json:"distance"`
+	Similarity float32		`json:"similarity"`
+	Identical bool			`json:"identical"`
+}
+
+// ------------------------------------------- GenerateJsonSummary
+//
+// Write a one-line summary of the alignment as a JSON object, e.g.
+//
+//	{"changed":12,"added":3,"removed":5,"unchanged":402,"distance":20,"similarity":0.93,"identical":false}
+//
+// Unlike GenerateJsonDiff, the alignment isn't realigned for display, so the
+// counts are of the links the exit code goes by.  The "distance" is the edit
+// distance the alignment was found with, and the "similarity" is computed from
+// it: see diff.SequenceSimilarity.  The files are "identical" when they have
+// no differences: see HasDifferences.
+//
+func GenerateJsonSummary(outputFile io.Writer, alignment *diff.Alignment, distance float32, leftSource, rightSource *SourceLinesRec) error {
+	summary := tJsonSummary{
+		Distance: distance,
+		Similarity: diff.SequenceSimilarity(distance, len(leftSource.Lines), len(rightSource.Lines)),
+		Identical: !HasDifferences(alignment, leftSource, rightSource),
+	}
+	for _, link := range alignment.Links {
+		switch link.LinkType {
+		case diff.Matching:
+			summary.Unchanged++
+		case diff.Different:
+			summary.Changed++
+		case diff.LeftOnly:
+			summary.Removed++
+		case diff.RightOnly:
+			summary.Added++
+		default:
+			panic("not reached")
+		}
+	}
+	return json.NewEncoder(outputFile).Encode(summary)
+}
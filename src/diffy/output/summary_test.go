@@ -0,0 +1,61 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- TestJsonSummary
+
+func TestJsonSummary(t *testing.T) {
+
+	left := makeLines("a", "b", "c", "d", "e")
+	right := makeLines("a", "B", "c", "e", "f", "g")
+	alignment := &diff.Alignment{Links: []diff.Link{
+		{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0},
+		{LinkType: diff.Different, LeftIndex: 1, RightIndex: 1},
+		{LinkType: diff.Matching, LeftIndex: 2, RightIndex: 2},
+		{LinkType: diff.LeftOnly, LeftIndex: 3, RightIndex: -1},
+		{LinkType: diff.Matching, LeftIndex: 4, RightIndex: 3},
+		{LinkType: diff.RightOnly, LeftIndex: -1, RightIndex: 4},
+		{LinkType: diff.RightOnly, LeftIndex: -1, RightIndex: 5},
+	}}
+	leftSource, rightSource := NewSourceLinesRec(left, "old.txt"), NewSourceLinesRec(right, "new.txt")
+
+	var buffer bytes.Buffer
+	if err := GenerateJsonSummary(&buffer, alignment, 3.0, leftSource, rightSource); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if output := buffer.String(); strings.Count(output, "\n") != 1 || !strings.HasSuffix(output, "}\n") {
+		t.Errorf("Expected one line, got %q", output)
+	}
+
+	var summary tJsonSummary
+	if err := json.Unmarshal(buffer.Bytes(), &summary); err != nil {
+		t.Fatalf("Could not parse the JSON: %v\n%s", err, buffer.String())
+	}
+	expected := tJsonSummary{Changed: 1, Added: 2, Removed: 1, Unchanged: 3, Distance: 3.0, Similarity: 0.5, Identical: false}
+	if summary != expected {
+		t.Errorf("Expected %+v, got %+v", expected, summary)
+	}
+
+	// Only a missing final newline still isn't identical.
+	same := &diff.Alignment{Links: []diff.Link{{LinkType: diff.Matching, LeftIndex: 0, RightIndex: 0}}}
+	leftSource, rightSource = NewSourceLinesRec(makeLines("a"), "old.txt"), NewSourceLinesRec(makeLines("a"), "new.txt")
+	for _, finalNewline := range []bool{true, false} {
+		rightSource.FinalNewline = finalNewline
+		buffer.Reset()
+		if err := GenerateJsonSummary(&buffer, same, 0.0, leftSource, rightSource); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		summary = tJsonSummary{}
+		json.Unmarshal(buffer.Bytes(), &summary)
+		if summary.Identical != finalNewline || summary.Unchanged != 1 || summary.Similarity != 1.0 {
+			t.Errorf("With a final newline %v, unexpected %+v", finalNewline, summary)
+		}
+	}
+}
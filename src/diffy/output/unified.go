@@ -0,0 +1,46 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"diffy/diff"
+)
+
+// "unified.go" - The changes as a unified diff, in the style of "diff -u", for
+// "patch" and "git apply".
+
+// ------------------------------------------- GenerateUnifiedDiff
+//
+// Write a unified diff with the "HunkContext" of "opts", DEFAULT_CONTEXT lines
+// either side by default, headed by the two file paths.  Identical files write
+// nothing.  A file which doesn't end with a newline gets a "\ No newline at end
+// of file" marker after its last line, so the patch applies exactly.
+// Otherwise only the realign options of "opts" apply.
+//
+func GenerateUnifiedDiff(outputFile io.Writer, alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) {
+	hunks := formatUnifiedHunks(alignment, leftSource, rightSource, opts)
+	if len(hunks) == 0 {
+		return
+	}
+	fmt.Fprintf(outputFile, "--- %s\n", leftSource.FilePath)
+	fmt.Fprintf(outputFile, "+++ %s\n", rightSource.FilePath)
+	fmt.Fprint(outputFile, strings.Join(hunks, ""))
+}
+
+// ------------------------------------------- formatUnifiedHunks
+//
+// Each hunk in the style of "diff -u", realigned just as it would be for the
+// HTML, and with the final newlines accounted for.
+//
+func formatUnifiedHunks(alignment *diff.Alignment, leftSource, rightSource *SourceLinesRec, opts HtmlOptions) []string {
+	alignment = realignForDisplay(alignment, leftSource, rightSource, opts)
+	alignment = diff.MarkFinalNewlineChange(alignment, leftSource.FinalNewline, rightSource.FinalNewline)
+
+	var hunks []string
+	for _, hunk := range diff.GroupHunksWithContext(alignment, opts.EffectiveHunkContext()) {
+		hunks = append(hunks, diff.FormatUnifiedHunkWithNewlines(hunk, leftSource.Lines, rightSource.Lines, leftSource.FinalNewline, rightSource.FinalNewline))
+	}
+	return hunks
+}
@@ -0,0 +1,171 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diffy/diff"
+)
+
+// ------------------------------------------- helper functions
+
+// Read "text" as a file would be read, and diff it against "otherText" as a
+// unified diff of "a/file.txt" and "b/file.txt".
+func generateTestUnifiedDiff(t *testing.T, text, otherText string) string {
+	return generateTestUnifiedDiffReversible(t, text, otherText, false)
+}
+
+// Like generateTestUnifiedDiff, but optionally with the diff reversed the way
+// "--reverse" does it, so that the patch turns "otherText" back into "text".
+func generateTestUnifiedDiffReversible(t *testing.T, text, otherText string, reverse bool) string {
+	read := func (text, path string) *SourceLinesRec {
+		lines, finalNewline, err := diff.ReadLines(strings.NewReader(text), diff.Options{})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		source := NewSourceLinesRec(lines, path)
+		source.FinalNewline = finalNewline
+		return source
+	}
+	leftSource, rightSource := read(text, "a/file.txt"), read(otherText, "b/file.txt")
+	_, alignment := diff.Diff_v2(leftSource.Lines, rightSource.Lines)
+	if reverse {
+		alignment = alignment.Swap()
+		leftSource, rightSource = rightSource, leftSource
+	}
+
+	var buffer bytes.Buffer
+	GenerateUnifiedDiff(&buffer, alignment, leftSource, rightSource, HtmlOptions{})
+	return buffer.String()
+}
+
+// ------------------------------------------- TestUnifiedDiffNoNewline
+
+func TestUnifiedDiffNoNewline(t *testing.T) {
+
+	testCases := []struct {
+		left, right, expected string
+	}{
+		// The newline is added.
+		{"one\ntwo", "one\ntwo\n", "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+two\n"},
+		// The newline is removed along with a change.
+		{"one\ntwo\n", "one\n2", "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n\\ No newline at end of file\n"},
+		// Neither file has one, so the last line is shared context.
+		{"one\ntwo\nthree", "one\n2\nthree", "--- a/file.txt\n+++ b/file.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n\\ No newline at end of file\n"},
+		// Identical files, newlines and all.
+		{"one\ntwo", "one\ntwo", ""},
+		// Each line keeps its CRLF.
+		{"one\r\ntwo\r\n", "one\r\n2\r\n", "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n one\r\n-two\r\n+2\r\n"},
+	}
+	for _, testCase := range testCases {
+		if patch := generateTestUnifiedDiff(t, testCase.left, testCase.right); patch != testCase.expected {
+			t.Errorf("%q vs %q: expected\n%s\ngot\n%s", testCase.left, testCase.right, testCase.expected, patch)
+		}
+	}
+}
+
+// ------------------------------------------- TestUnifiedDiffGitApply
+
+func TestUnifiedDiffGitApply(t *testing.T) {
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	dir, err := ioutil.TempDir("", "diffy-unified")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := [][2]string{
+		{"one\ntwo\nthree", "one\ntwo\nthree\n"},
+		{"one\ntwo\nthree\n", "one\ntwo\nthree"},
+		{"one\ntwo\n", "one\nTWO"},
+		{"one\ntwo\nthree", "one\nTWO\nthree"},
+		{"one\ntwo", "one\nthree"},
+		{"one", "one\ntwo\n"},
+		{"one\ntwo\n", "one"},
+		{"a\nb\nc\nd\ne\nf\ng\nh\ni\nj", "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\n"},
+		{"one\r\ntwo\r\nthree\r\n", "one\r\nTWO\r\nthree\r\n"},
+		{"one\r\ntwo\r\n", "one\r\ntwo\r\nthree"},
+	}
+	for _, testCase := range testCases {
+		patch := generateTestUnifiedDiff(t, testCase[0], testCase[1])
+		filePath, patchPath := filepath.Join(dir, "file.txt"), filepath.Join(dir, "change.patch")
+		if err := ioutil.WriteFile(filePath, []byte(testCase[0]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(patchPath, []byte(patch), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		command := exec.Command("git", "apply", "change.patch")
+		command.Dir = dir
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Errorf("%q vs %q: git apply failed: %v\n%s\npatch:\n%s", testCase[0], testCase[1], err, output, patch)
+			continue
+		}
+		if applied, _ := ioutil.ReadFile(filePath); string(applied) != testCase[1] {
+			t.Errorf("%q vs %q: the patch gave %q\npatch:\n%s", testCase[0], testCase[1], applied, patch)
+		}
+	}
+}
+
+// ------------------------------------------- TestUnifiedDiffReverseGitApply
+
+func TestUnifiedDiffReverseGitApply(t *testing.T) {
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	dir, err := ioutil.TempDir("", "diffy-reverse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	apply := func (patch string) error {
+		if err := ioutil.WriteFile(filepath.Join(dir, "change.patch"), []byte(patch), 0644); err != nil {
+			t.Fatal(err)
+		}
+		command := exec.Command("git", "apply", "change.patch")
+		command.Dir = dir
+		if output, err := command.CombinedOutput(); err != nil {
+			return fmt.Errorf("%v\n%s\npatch:\n%s", err, output, patch)
+		}
+		return nil
+	}
+
+	// Applying a diff and then its reverse gets back to the original.
+	testCases := [][2]string{
+		{"one\ntwo\nthree\n", "one\nTWO\nthree\n"},
+		{"one\ntwo\nthree", "one\ntwo\nthree\n"},
+		{"one\n", "one\ntwo\nthree\n"},
+		{"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n", "a\nB\nc\nd\ne\nf\ng\ni\nj\nk\n"},
+	}
+	for _, testCase := range testCases {
+		filePath := filepath.Join(dir, "file.txt")
+		if err := ioutil.WriteFile(filePath, []byte(testCase[0]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := apply(generateTestUnifiedDiff(t, testCase[0], testCase[1])); err != nil {
+			t.Errorf("%q vs %q: git apply failed: %v", testCase[0], testCase[1], err)
+			continue
+		}
+		if err := apply(generateTestUnifiedDiffReversible(t, testCase[0], testCase[1], true)); err != nil {
+			t.Errorf("%q vs %q: git apply of the reverse failed: %v", testCase[0], testCase[1], err)
+			continue
+		}
+		if restored, _ := ioutil.ReadFile(filePath); string(restored) != testCase[0] {
+			t.Errorf("%q vs %q: the round trip gave %q", testCase[0], testCase[1], restored)
+		}
+	}
+}